@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -19,6 +21,9 @@ import (
 	"github.com/username/event-ticketing-system/internal/service"
 	"github.com/username/event-ticketing-system/internal/worker"
 	"github.com/username/event-ticketing-system/pkg/email"
+	"github.com/username/event-ticketing-system/pkg/oidc"
+	"github.com/username/event-ticketing-system/pkg/payment"
+	"github.com/username/event-ticketing-system/pkg/signing"
 	"github.com/username/event-ticketing-system/pkg/utils"
 	"golang.org/x/time/rate"
 )
@@ -39,19 +44,55 @@ func main() {
 	workerPool.Start(context.Background())
 	defer workerPool.Shutdown()
 
-	ticketService := service.NewTicketService(db)
-	registrationService := service.NewRegistrationService(db, ticketService, emailService, workerPool)
+	ticketSigner := newTicketSigner(cfg)
+	ticketService := service.NewTicketService(db, ticketSigner)
+	inventory := service.NewInventory(cfg.InventoryStrategy, cfg.InventoryShards)
+	waitlistService := service.NewWaitlistService(db, ticketService, emailService, workerPool, inventory, time.Duration(cfg.WaitlistOfferMinutes)*time.Minute)
+	paymentService := service.NewPaymentService(db, newPaymentProvider(cfg), ticketService, emailService, workerPool, waitlistService, cfg.PaymentCurrency, time.Duration(cfg.PaymentTimeoutMinutes)*time.Minute)
+	waitlistService.Payments = paymentService
+	registrationService := service.NewRegistrationService(db, ticketService, emailService, workerPool, inventory, waitlistService, paymentService)
+	orderService := service.NewOrderService(db, ticketService, emailService, workerPool, inventory, paymentService)
+	holdService := service.NewHoldService(db, ticketService, emailService, workerPool, inventory, waitlistService, paymentService, time.Duration(cfg.HoldTTLMinutes)*time.Minute)
+	promoService := service.NewPromoService(db)
+	questionService := service.NewQuestionService(db)
+	seatingService := service.NewSeatingService(db)
+	waitingRoomService := service.NewWaitingRoomService(db, cfg.QueueAdmitPerMinute, time.Duration(cfg.QueueAdmissionMinutes)*time.Minute)
 	analyticsService := service.NewAnalyticsService(db)
 	auditService := service.NewAuditService(db)
+	checkInService := service.NewCheckInService(db, ticketSigner)
+	verificationService := service.NewVerificationService(db, emailService, workerPool, cfg.JWTSecret, cfg.AppURL, time.Duration(cfg.EmailVerificationHours)*time.Hour, time.Duration(cfg.VerificationResendSeconds)*time.Second)
+	mfaService := service.NewMFAService(db, cfg.JWTSecret, cfg.MFAIssuer, time.Duration(cfg.MFAChallengeMinutes)*time.Minute, cfg.MFARequiredForAdmins)
+	oidcService := service.NewOIDCService(db, newOIDCProviders(cfg), time.Duration(cfg.OIDCStateMinutes)*time.Minute)
+	eventAccessService := service.NewEventAccessService(db)
+	sessionService := service.NewSessionService(db, cfg.JWTSecret, time.Duration(cfg.AccessTokenMinutes)*time.Minute, time.Duration(cfg.RefreshTokenDays)*24*time.Hour)
+	idempotencyService := service.NewIdempotencyService(db, time.Duration(cfg.IdempotencyKeyTTLHours)*time.Hour, time.Duration(cfg.IdempotencyKeyLeaseSeconds)*time.Second)
+	resaleService := service.NewResaleService(db, paymentService)
+	exportService := service.NewExportService(db, cfg.ExportBatchSize)
+	importService := service.NewImportService(db, ticketService, emailService, workerPool, inventory, cfg.ImportBatchSize)
+	transferService := service.NewTransferService(db, ticketService, emailService, workerPool, time.Duration(cfg.TransferOfferHours)*time.Hour, cfg.AppURL)
 
 	auditHandler := handler.NewAuditHandler(auditService)
 	userHandler := handler.NewUserHandler(db)
 	loadTestHandler := handler.NewLoadTestHandler(registrationService)
 	feedbackHandler := handler.NewFeedbackHandler(db)
 
-	authHandler := handler.NewAuthHandler(db, cfg, emailService)
-	eventHandler := handler.NewEventHandler(db, workerPool, emailService, eventCache)
+	authHandler := handler.NewAuthHandler(db, cfg, emailService, sessionService, verificationService, mfaService, oidcService)
+	mfaHandler := handler.NewMFAHandler(mfaService)
+	eventHandler := handler.NewEventHandler(db, workerPool, emailService, eventCache, inventory)
 	registrationHandler := handler.NewRegistrationHandler(registrationService)
+	checkInHandler := handler.NewCheckInHandler(checkInService)
+	waitlistHandler := handler.NewWaitlistHandler(waitlistService)
+	holdHandler := handler.NewHoldHandler(holdService)
+	orderHandler := handler.NewOrderHandler(orderService)
+	paymentHandler := handler.NewPaymentHandler(paymentService)
+	promoHandler := handler.NewPromoHandler(promoService)
+	questionHandler := handler.NewQuestionHandler(questionService)
+	seatingHandler := handler.NewSeatingHandler(seatingService)
+	waitingRoomHandler := handler.NewWaitingRoomHandler(waitingRoomService)
+	transferHandler := handler.NewTransferHandler(transferService)
+	resaleHandler := handler.NewResaleHandler(resaleService)
+	exportHandler := handler.NewExportHandler(exportService)
+	importHandler := handler.NewImportHandler(importService)
 
 	r := gin.New()
 	r.Use(middleware.Logger(), gin.Recovery(), middleware.RateLimitMiddleware(rate.Limit(5), 10))
@@ -59,7 +100,7 @@ func main() {
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Queue-Token, Idempotency-Key")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -67,12 +108,21 @@ func main() {
 		c.Next()
 	})
 
-	setupRoutes(r, authHandler, eventHandler, registrationHandler, auditHandler, userHandler, loadTestHandler, feedbackHandler, analyticsService, cfg)
+	setupRoutes(r, authHandler, eventHandler, registrationHandler, auditHandler, userHandler, loadTestHandler, feedbackHandler, checkInHandler, waitlistHandler, holdHandler, orderHandler, paymentHandler, promoHandler, questionHandler, seatingHandler, waitingRoomHandler, transferHandler, resaleHandler, exportHandler, importHandler, mfaHandler, analyticsService, idempotencyService, sessionService, mfaService, eventAccessService, cfg)
 
 	// Background worker
 	workerCtx, cancelWorker := context.WithCancel(context.Background())
 	notificationWorker := worker.NewNotificationWorker(db, emailService, 30*time.Minute, workerPool)
 	go notificationWorker.Start(workerCtx)
+	go worker.NewPeriodicJob("waitlist-offer-expiry", time.Minute, waitlistService.ExpireOffers).Start(workerCtx)
+	go worker.NewPeriodicJob("seat-hold-expiry", 30*time.Second, holdService.ExpireHolds).Start(workerCtx)
+	go worker.NewPeriodicJob("payment-expiry", time.Minute, paymentService.ExpirePayments).Start(workerCtx)
+	go worker.NewPeriodicJob("refund-retry", 5*time.Minute, paymentService.ProcessRefunds).Start(workerCtx)
+	go worker.NewPeriodicJob("waiting-room-admission", 5*time.Second, waitingRoomService.AdvanceQueues).Start(workerCtx)
+	go worker.NewPeriodicJob("idempotency-key-purge", time.Hour, idempotencyService.PurgeExpired).Start(workerCtx)
+	go worker.NewPeriodicJob("session-purge", time.Hour, sessionService.PurgeExpired).Start(workerCtx)
+	go worker.NewPeriodicJob("oidc-state-purge", time.Hour, oidcService.PurgeExpired).Start(workerCtx)
+	go worker.NewPeriodicJob("transfer-offer-expiry", time.Minute, transferService.ExpireOffers).Start(workerCtx)
 
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -112,7 +162,53 @@ func main() {
 	log.Println("Server exiting")
 }
 
-func setupRoutes(r *gin.Engine, ah *handler.AuthHandler, eh *handler.EventHandler, rh *handler.RegistrationHandler, adh *handler.AuditHandler, uh *handler.UserHandler, lth *handler.LoadTestHandler, fbh *handler.FeedbackHandler, as *service.AnalyticsService, cfg *config.Config) {
+func newTicketSigner(cfg *config.Config) *signing.Signer {
+	keys, err := signing.ParseKeys(cfg.TicketSigningKeys)
+	if err != nil {
+		log.Fatalf("Invalid TICKET_SIGNING_KEYS: %v", err)
+	}
+
+	keyID := cfg.TicketSigningKeyID
+	if len(keys) == 0 {
+		log.Println("Warning: TICKET_SIGNING_KEYS not set, deriving ticket signing key from JWT_SECRET")
+		keyID = "dev"
+		keys[keyID] = signing.DeriveKey(cfg.JWTSecret)
+	}
+
+	signer, err := signing.NewSigner(keys, keyID)
+	if err != nil {
+		log.Fatalf("Invalid TICKET_SIGNING_KEY_ID: %v", err)
+	}
+	return signer
+}
+
+// newOIDCProviders sets up the configured sign-in providers. Each one
+// redirects back to {APP_URL}/api/v1/auth/oidc/<name>/callback.
+func newOIDCProviders(cfg *config.Config) map[string]*oidc.Provider {
+	providers := make(map[string]*oidc.Provider)
+	for _, p := range cfg.OIDCProviders {
+		providers[p.Name] = oidc.NewProvider(oidc.Config{
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  fmt.Sprintf("%s/api/v1/auth/oidc/%s/callback", strings.TrimRight(cfg.AppURL, "/"), p.Name),
+			Scopes:       p.Scopes,
+		}, nil)
+	}
+	return providers
+}
+
+func newPaymentProvider(cfg *config.Config) payment.PaymentProvider {
+	switch cfg.PaymentProvider {
+	case "fake":
+		return payment.NewFakeProvider(cfg.PaymentWebhookSecret)
+	default:
+		log.Fatalf("Unsupported PAYMENT_PROVIDER %q", cfg.PaymentProvider)
+		return nil
+	}
+}
+
+func setupRoutes(r *gin.Engine, ah *handler.AuthHandler, eh *handler.EventHandler, rh *handler.RegistrationHandler, adh *handler.AuditHandler, uh *handler.UserHandler, lth *handler.LoadTestHandler, fbh *handler.FeedbackHandler, ch *handler.CheckInHandler, wh *handler.WaitlistHandler, hh *handler.HoldHandler, oh *handler.OrderHandler, ph *handler.PaymentHandler, prh *handler.PromoHandler, qh *handler.QuestionHandler, sh *handler.SeatingHandler, wrh *handler.WaitingRoomHandler, th *handler.TransferHandler, rsh *handler.ResaleHandler, exh *handler.ExportHandler, imh *handler.ImportHandler, mh *handler.MFAHandler, as *service.AnalyticsService, is *service.IdempotencyService, ss *service.SessionService, ms *service.MFAService, eas *service.EventAccessService, cfg *config.Config) {
 	api := r.Group("/api")
 	{
 		v1 := api.Group("/v1")
@@ -121,8 +217,25 @@ func setupRoutes(r *gin.Engine, ah *handler.AuthHandler, eh *handler.EventHandle
 			{
 				auth.POST("/register", ah.Register)
 				auth.POST("/login", ah.Login)
+				auth.POST("/mfa/verify", ah.VerifyMFA)
+				auth.GET("/oidc", ah.ListOIDCProviders)
+				auth.GET("/oidc/:provider", ah.BeginOIDC)
+				auth.GET("/oidc/:provider/callback", ah.OIDCCallback)
+				auth.POST("/refresh", ah.Refresh)
+				auth.POST("/logout", middleware.AuthMiddleware(cfg, ss), ah.Logout)
+				auth.GET("/verify-email", ah.VerifyEmail)
+				auth.POST("/resend-verification", middleware.AuthMiddleware(cfg, ss), ah.ResendVerification)
 				auth.POST("/forgot-password", ah.ForgotPassword)
 				auth.POST("/reset-password", ah.ResetPassword)
+
+				mfa := auth.Group("/mfa")
+				mfa.Use(middleware.AuthMiddleware(cfg, ss))
+				{
+					mfa.POST("/enroll", mh.BeginEnrollment)
+					mfa.POST("/enroll/confirm", mh.ConfirmEnrollment)
+					mfa.POST("/recovery-codes", mh.RegenerateRecoveryCodes)
+					mfa.DELETE("/", mh.Disable)
+				}
 			}
 
 			events := v1.Group("/events")
@@ -130,37 +243,119 @@ func setupRoutes(r *gin.Engine, ah *handler.AuthHandler, eh *handler.EventHandle
 				events.GET("/", eh.ListEvents)
 				events.GET("/:id", eh.GetEvent)
 				events.GET("/:id/seats", eh.GetRemainingSeats)
+				events.GET("/:id/seat-map", sh.GetEventSeating)
+				events.GET("/:id/resale", rsh.GetEventListings)
+				events.GET("/:id/questions", qh.ListQuestions)
 			}
 
+			// Authenticated by the provider's signature rather than a user token
+			v1.POST("/webhooks/payments", ph.HandleWebhook)
+
+			// Transfer and claim tokens are the recipient's credential, so
+			// recipients without an account can answer them
+			v1.GET("/transfers/:token", th.GetTransfer)
+			v1.POST("/transfers/:token/accept", th.AcceptTransfer)
+			v1.POST("/transfers/:token/decline", th.DeclineTransfer)
+			v1.GET("/claims/:token", th.GetTicketClaim)
+
 			user := v1.Group("/")
-			user.Use(middleware.AuthMiddleware(cfg))
+			user.Use(middleware.AuthMiddleware(cfg, ss))
 			{
+				// Retry-safe with an Idempotency-Key header
+				idempotent := middleware.IdempotencyMiddleware(is)
+
 				registrations := user.Group("/registrations")
 				{
 					registrations.GET("/", rh.GetMyRegistrations)
-					registrations.POST("/", rh.RegisterForEvent)
-					registrations.DELETE("/:id", rh.CancelRegistration)
-					registrations.POST("/:id/transfer", rh.TransferTicket)
+					registrations.POST("/", idempotent, rh.RegisterForEvent)
+					registrations.DELETE("/:id", idempotent, rh.CancelRegistration)
+					registrations.POST("/:id/transfer", idempotent, th.CreateTransfer)
+					registrations.POST("/:id/resale", rsh.CreateListing)
 					registrations.PUT("/:id/rsvp", rh.UpdateRSVP)
 					registrations.GET("/:id/qr", rh.GetQR)
 					registrations.POST("/feedback", fbh.SubmitFeedback)
 				}
+
+				waitlist := user.Group("/waitlist")
+				{
+					waitlist.GET("/", wh.GetMyWaitlist)
+					waitlist.POST("/", wh.JoinWaitlist)
+					waitlist.DELETE("/:id", wh.LeaveWaitlist)
+					waitlist.POST("/:id/claim", wh.ClaimOffer)
+				}
+
+				transfers := user.Group("/transfers")
+				{
+					transfers.GET("/", th.ListTransfers)
+					transfers.DELETE("/:id", th.RevokeTransfer)
+				}
+
+				user.POST("/claims/:token", th.ClaimTicket)
+
+				resale := user.Group("/resale")
+				{
+					resale.GET("/", rsh.GetMyListings)
+					resale.DELETE("/:id", rsh.WithdrawListing)
+					resale.POST("/:id/buy", idempotent, rsh.BuyListing)
+				}
+
+				user.POST("/events/:id/queue", wrh.JoinQueue)
+				user.GET("/queue/:token", wrh.GetQueuePosition)
+
+				holds := user.Group("/holds")
+				{
+					holds.POST("/", hh.CreateHold)
+					holds.POST("/:id/confirm", hh.ConfirmHold)
+					holds.DELETE("/:id", hh.ReleaseHold)
+				}
+
+				orders := user.Group("/orders")
+				{
+					orders.GET("/", oh.GetMyOrders)
+					orders.POST("/", oh.CreateOrder)
+					orders.GET("/:id", oh.GetOrder)
+				}
+
+				payments := user.Group("/payments")
+				{
+					payments.GET("/:id", ph.GetPayment)
+					payments.POST("/:id/capture", ph.CapturePayment)
+				}
 			}
 
+			staff := v1.Group("/")
+			staff.Use(middleware.AuthMiddleware(cfg, ss), middleware.RequirePermission(domain.PermTicketsCheckIn), middleware.MFAEnrollmentMiddleware(ms))
+			{
+				staff.POST("/checkin", ch.CheckIn)
+				staff.GET("/verify/:token", ch.VerifyTicket)
+				staff.GET("/checkin/keys", ch.GetSigningKeys)
+				staff.GET("/checkin/events/:id/manifest", ch.GetManifest)
+				staff.POST("/checkin/events/:id/sync", ch.SyncOfflineScans)
+			}
+
+			// Each route needs a permission of the caller's role; routes on one
+			// event also need the caller to organize it, unless they are an admin
 			admin := v1.Group("/admin")
-			admin.Use(middleware.AuthMiddleware(cfg), middleware.RBACMiddleware(domain.RoleAdmin))
+			admin.Use(middleware.AuthMiddleware(cfg, ss), middleware.MFAEnrollmentMiddleware(ms))
 			{
+				can := middleware.RequirePermission
+				owned := middleware.RequireEventOwnership(eas)
+
 				eventAdmin := admin.Group("/events")
 				{
-					eventAdmin.POST("/", eh.CreateEvent)
-					eventAdmin.PUT("/:id", eh.UpdateEvent)
-					eventAdmin.DELETE("/:id", eh.DeleteEvent)
-					eventAdmin.GET("/:id/attendees", rh.GetAttendees)
+					eventAdmin.GET("/", can(domain.PermEventUpdate), eh.ListManagedEvents)
+					eventAdmin.POST("/", can(domain.PermEventCreate), eh.CreateEvent)
+					eventAdmin.PUT("/:id", can(domain.PermEventUpdate), owned, eh.UpdateEvent)
+					eventAdmin.DELETE("/:id", can(domain.PermEventDelete), owned, eh.DeleteEvent)
+					eventAdmin.GET("/:id/attendees", can(domain.PermAttendeesRead), owned, rh.GetAttendees)
+					eventAdmin.GET("/:id/attendees/export", can(domain.PermAttendeesRead), owned, exh.ExportAttendees)
+					eventAdmin.POST("/:id/attendees/import", can(domain.PermAttendeesImport), owned, imh.ImportAttendees)
+					eventAdmin.GET("/:id/feedback", can(domain.PermFeedbackRead), owned, fbh.GetEventFeedback)
 				}
 
-				admin.POST("/upload", eh.UploadImage)
+				admin.POST("/upload", can(domain.PermUploadsCreate), eh.UploadImage)
 
-				admin.GET("/analytics", func(c *gin.Context) {
+				admin.GET("/analytics", can(domain.PermAnalyticsRead), func(c *gin.Context) {
 					stats, err := as.GetEventStats()
 					if err != nil {
 						utils.ErrorResponse(c, 500, "Failed to fetch event stats")
@@ -169,7 +364,7 @@ func setupRoutes(r *gin.Engine, ah *handler.AuthHandler, eh *handler.EventHandle
 					utils.SuccessResponse(c, 200, "Event stats fetched successfully", stats)
 				})
 
-				admin.GET("/analytics/summary", func(c *gin.Context) {
+				admin.GET("/analytics/summary", can(domain.PermAnalyticsRead), func(c *gin.Context) {
 					stats, err := as.GetSystemStats()
 					if err != nil {
 						utils.ErrorResponse(c, 500, "Failed to fetch system stats")
@@ -178,11 +373,11 @@ func setupRoutes(r *gin.Engine, ah *handler.AuthHandler, eh *handler.EventHandle
 					utils.SuccessResponse(c, 200, "System stats fetched successfully", stats)
 				})
 
-				admin.GET("/audit-logs", adh.GetLogs)
-				admin.POST("/load-test", lth.LoadTest)
-				admin.GET("/events/:id/feedback", fbh.GetEventFeedback)
+				admin.GET("/audit-logs", can(domain.PermAuditLogsRead), adh.GetLogs)
+				admin.POST("/load-test", can(domain.PermLoadTestRun), lth.LoadTest)
 
 				userAdmin := admin.Group("/users")
+				userAdmin.Use(can(domain.PermUsersManage))
 				{
 					userAdmin.GET("/", uh.ListUsers)
 					userAdmin.PUT("/:id/role", uh.UpdateUserRole)
@@ -190,11 +385,35 @@ func setupRoutes(r *gin.Engine, ah *handler.AuthHandler, eh *handler.EventHandle
 				}
 
 				eventAdminTiers := admin.Group("/events/:id/ticket-types")
+				eventAdminTiers.Use(can(domain.PermTicketTypesManage), owned)
 				{
 					eventAdminTiers.POST("/", eh.CreateTicketType)
 					eventAdminTiers.PUT("/:tt_id", eh.UpdateTicketType)
 					eventAdminTiers.DELETE("/:tt_id", eh.DeleteTicketType)
 				}
+
+				eventAdminPromos := admin.Group("/events/:id/promo-codes")
+				eventAdminPromos.Use(can(domain.PermPromoCodesManage), owned)
+				{
+					eventAdminPromos.GET("/", prh.ListPromoCodes)
+					eventAdminPromos.POST("/", prh.CreatePromoCode)
+					eventAdminPromos.DELETE("/:promo_id", prh.DeactivatePromoCode)
+				}
+
+				eventAdminQuestions := admin.Group("/events/:id/questions")
+				eventAdminQuestions.Use(can(domain.PermQuestionsManage), owned)
+				{
+					eventAdminQuestions.POST("/", qh.CreateQuestion)
+					eventAdminQuestions.PUT("/:question_id", qh.UpdateQuestion)
+					eventAdminQuestions.DELETE("/:question_id", qh.DeleteQuestion)
+				}
+
+				seatMapAdmin := admin.Group("/seat-maps")
+				{
+					seatMapAdmin.GET("/", can(domain.PermSeatMapsRead), sh.ListSeatMaps)
+					seatMapAdmin.POST("/", can(domain.PermSeatMapsManage), sh.CreateSeatMap)
+					seatMapAdmin.GET("/:id", can(domain.PermSeatMapsRead), sh.GetSeatMap)
+				}
 			}
 		}
 	}
@@ -9,6 +9,7 @@ import (
 	"github.com/username/event-ticketing-system/internal/config"
 	"github.com/username/event-ticketing-system/internal/domain"
 	"github.com/username/event-ticketing-system/internal/repository"
+	"github.com/username/event-ticketing-system/internal/service"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -24,11 +25,12 @@ func main() {
 	log.Printf("Seeded %d users\n", len(users))
 
 	// 2. Seed Events and Ticket Types
-	events := seedEvents(db)
+	inventory := service.NewInventory(cfg.InventoryStrategy, cfg.InventoryShards)
+	events := seedEvents(db, inventory)
 	log.Printf("Seeded %d events\n", len(events))
 
 	// 3. Seed Registrations and Tickets
-	seedRegistrations(db, users, events)
+	seedRegistrations(db, inventory, users, events)
 	log.Println("Seeded registrations and tickets")
 
 	log.Println("Database seeding completed successfully!")
@@ -46,6 +48,13 @@ func seedUsers(db *gorm.DB) []domain.User {
 			PasswordHash: string(hashedPassword),
 			Role:         domain.RoleAdmin,
 		},
+		{
+			ID:           uuid.New(),
+			Email:        "organizer@event-system.com",
+			Name:         "Olivia Organizer",
+			PasswordHash: string(hashedPassword),
+			Role:         domain.RoleOrganizer,
+		},
 		{
 			ID:           uuid.New(),
 			Email:        "sarah.doe@example.com",
@@ -76,7 +85,10 @@ func seedUsers(db *gorm.DB) []domain.User {
 		},
 	}
 
+	// Demo accounts skip email verification
+	verifiedAt := time.Now()
 	for _, u := range users {
+		u.EmailVerifiedAt = &verifiedAt
 		var existing domain.User
 		if err := db.Where("email = ?", u.Email).First(&existing).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
@@ -90,7 +102,7 @@ func seedUsers(db *gorm.DB) []domain.User {
 	return allUsers
 }
 
-func seedEvents(db *gorm.DB) []domain.Event {
+func seedEvents(db *gorm.DB, inventory *service.Inventory) []domain.Event {
 	eventsData := []struct {
 		Title       string
 		Description string
@@ -129,38 +141,40 @@ func seedEvents(db *gorm.DB) []domain.Event {
 			if err == gorm.ErrRecordNotFound {
 				// Create new event
 				e := domain.Event{
-					ID:               uuid.New(),
-					Title:            ed.Title,
-					Description:      ed.Description,
-					Category:         ed.Category,
-					Status:           domain.StatusPublished,
-					StartTime:        time.Now().AddDate(0, 0, (i+1)*10), // Future dates
-					TotalTickets:     ed.Tickets,
-					RemainingTickets: ed.Tickets,
-					Price:            ed.Price,
-					ImageURL:         ed.ImageURL,
+					ID:           uuid.New(),
+					Title:        ed.Title,
+					Description:  ed.Description,
+					Category:     ed.Category,
+					Status:       domain.StatusPublished,
+					StartTime:    time.Now().AddDate(0, 0, (i+1)*10), // Future dates
+					TotalTickets: ed.Tickets,
+					Price:        ed.Price,
+					ImageURL:     ed.ImageURL,
 				}
 				db.Create(&e)
 
 				// Create Ticket Types
 				ttVIP := domain.TicketType{
-					ID:               uuid.New(),
-					EventID:          e.ID,
-					Name:             "VIP",
-					Price:            e.Price * 2.5,
-					Capacity:         int(float64(ed.Tickets) * 0.1),
-					RemainingTickets: int(float64(ed.Tickets) * 0.1),
+					ID:       uuid.New(),
+					EventID:  e.ID,
+					Name:     "VIP",
+					Price:    e.Price * 2.5,
+					Capacity: int(float64(ed.Tickets) * 0.1),
 				}
 				ttRegular := domain.TicketType{
-					ID:               uuid.New(),
-					EventID:          e.ID,
-					Name:             "Regular",
-					Price:            e.Price,
-					Capacity:         int(float64(ed.Tickets) * 0.9),
-					RemainingTickets: int(float64(ed.Tickets) * 0.9),
+					ID:       uuid.New(),
+					EventID:  e.ID,
+					Name:     "Regular",
+					Price:    e.Price,
+					Capacity: int(float64(ed.Tickets) * 0.9),
 				}
 				db.Create(&ttVIP)
 				db.Create(&ttRegular)
+				for _, tt := range []domain.TicketType{ttVIP, ttRegular} {
+					if err := inventory.Provision(db, tt.ID, tt.Capacity); err != nil {
+						log.Fatalf("Failed to provision stock for %s %s: %v", e.Title, tt.Name, err)
+					}
+				}
 
 				e.TicketTypes = []domain.TicketType{ttVIP, ttRegular}
 				seededEvents = append(seededEvents, e)
@@ -175,7 +189,7 @@ func seedEvents(db *gorm.DB) []domain.Event {
 	return seededEvents
 }
 
-func seedRegistrations(db *gorm.DB, users []domain.User, events []domain.Event) {
+func seedRegistrations(db *gorm.DB, inventory *service.Inventory, users []domain.User, events []domain.Event) {
 	for i, u := range users {
 		if u.Role == domain.RoleAdmin {
 			continue
@@ -185,21 +199,27 @@ func seedRegistrations(db *gorm.DB, users []domain.User, events []domain.Event)
 		event1 := events[i%len(events)]
 		event2 := events[(i+1)%len(events)]
 
-		registerUserForEvent(db, u, event1)
-		registerUserForEvent(db, u, event2)
+		registerUserForEvent(db, inventory, u, event1)
+		registerUserForEvent(db, inventory, u, event2)
 	}
 }
 
-func registerUserForEvent(db *gorm.DB, user domain.User, event domain.Event) {
+func registerUserForEvent(db *gorm.DB, inventory *service.Inventory, user domain.User, event domain.Event) {
 	var tt domain.TicketType
 	db.Where("event_id = ? AND name = ?", event.ID, "Regular").First(&tt)
 
 	var existing domain.Registration
 	if err := db.Where("user_id = ? AND event_id = ?", user.ID, event.ID).First(&existing).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			// Take the seat from stock first; a sold out event gets no registration
+			if err := inventory.Take(db, event.ID, tt.ID, 1); err != nil {
+				log.Printf("Skipping registration of %s for %s: %v\n", user.Email, event.Title, err)
+				return
+			}
+
 			reg := domain.Registration{
 				ID:           uuid.New(),
-				UserID:       user.ID,
+				UserID:       &user.ID,
 				EventID:      event.ID,
 				TicketTypeID: tt.ID,
 				Status:       domain.RegistrationConfirmed,
@@ -216,10 +236,6 @@ func registerUserForEvent(db *gorm.DB, user domain.User, event domain.Event) {
 			}
 			db.Create(&ticket)
 
-			// Update remaining tickets (simplified)
-			db.Model(&event).Update("remaining_tickets", event.RemainingTickets-1)
-			db.Model(&tt).Update("remaining_tickets", tt.RemainingTickets-1)
-
 			// Seed Audit Log
 			audit := domain.AuditLog{
 				ID:         uuid.New(),
@@ -2,8 +2,11 @@ package handler
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,6 +14,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/username/event-ticketing-system/internal/config"
 	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/service"
 	"github.com/username/event-ticketing-system/pkg/email"
 	"github.com/username/event-ticketing-system/pkg/utils"
 	"go.uber.org/zap"
@@ -22,19 +26,24 @@ type AuthHandler struct {
 	DB           *gorm.DB
 	Config       *config.Config
 	EmailService email.EmailService
+	Sessions     *service.SessionService
+	Verification *service.VerificationService
+	MFA          *service.MFAService
+	OIDC         *service.OIDCService
 }
 
-func NewAuthHandler(db *gorm.DB, cfg *config.Config, es email.EmailService) *AuthHandler {
-	return &AuthHandler{DB: db, Config: cfg, EmailService: es}
+func NewAuthHandler(db *gorm.DB, cfg *config.Config, es email.EmailService, sessions *service.SessionService, verification *service.VerificationService, mfa *service.MFAService, oidc *service.OIDCService) *AuthHandler {
+	return &AuthHandler{DB: db, Config: cfg, EmailService: es, Sessions: sessions, Verification: verification, MFA: mfa, OIDC: oidc}
 }
 
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
 	Name     string `json:"name"`
-	Role     string `json:"role"`
 }
 
+// Register signs up a new account. Every account starts as a plain user;
+// other roles are only granted by an admin.
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -48,21 +57,12 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	role := domain.RoleUser
-	if req.Role != "" {
-		if req.Role != string(domain.RoleAdmin) && req.Role != string(domain.RoleUser) {
-			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid role")
-			return
-		}
-		role = domain.UserRole(req.Role)
-	}
-
 	user := domain.User{
 		ID:           uuid.New(),
 		Email:        req.Email,
 		Name:         req.Name,
 		PasswordHash: string(hashedPassword),
-		Role:         role,
+		Role:         domain.RoleUser,
 	}
 
 	if err := h.DB.Create(&user).Error; err != nil {
@@ -85,7 +85,50 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 	h.DB.Create(&audit)
 
-	utils.SuccessResponse(c, http.StatusCreated, "User registered successfully", nil)
+	if err := h.Verification.SendVerification(user.ID); err != nil {
+		utils.Logger.Error("Failed to send verification email", zap.Error(err))
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "User registered successfully; check your email to verify your address", nil)
+}
+
+// VerifyEmail is the target of the link in the verification email.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Verification token is required")
+		return
+	}
+
+	if err := h.Verification.VerifyEmail(token); err != nil {
+		if errors.Is(err, service.ErrInvalidVerificationLink) {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Email verified successfully", nil)
+}
+
+// ResendVerification emails the signed-in user a new verification link.
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	err := h.Verification.SendVerification(userID)
+	switch {
+	case err == nil:
+		utils.SuccessResponse(c, http.StatusOK, "Verification email sent", nil)
+	case errors.Is(err, service.ErrVerificationTooSoon):
+		c.Header("Retry-After", strconv.Itoa(int(h.Verification.ResendInterval.Seconds())))
+		utils.ErrorResponse(c, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, service.ErrEmailAlreadyVerified):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to send verification email")
+	}
 }
 
 type LoginRequest struct {
@@ -111,11 +154,132 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := utils.GenerateJWT(user.ID, user.Role, h.Config.JWTSecret, h.Config.JWTExpiryHours)
+	h.completeFirstFactor(c, &user)
+}
+
+// completeFirstFactor signs in a user who proved who they are with a password
+// or a provider. With two-factor authentication on, that only earns a
+// challenge for VerifyMFA.
+func (h *AuthHandler) completeFirstFactor(c *gin.Context, user *domain.User) {
+	if user.MFAEnabledAt != nil {
+		challenge, err := h.MFA.Challenge(user)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+			return
+		}
+		utils.SuccessResponse(c, http.StatusOK, "Enter the code from your authenticator app", challenge)
+		return
+	}
+
+	h.startSession(c, user)
+}
+
+// oidcStateCookie ties a provider sign-in to the browser that started it, so
+// nobody can get a victim to finish a sign-in into the attacker's account.
+const oidcStateCookie = "oidc_state"
+
+// ListOIDCProviders lists the providers users can sign in with.
+func (h *AuthHandler) ListOIDCProviders(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Sign-in providers fetched successfully", h.OIDC.ProviderNames())
+}
+
+// BeginOIDC sends the browser to the provider to sign in.
+func (h *AuthHandler) BeginOIDC(c *gin.Context) {
+	authURL, state, err := h.OIDC.Begin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnknownOIDCProvider):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrOIDCLoginFailed):
+			utils.Logger.Error("Failed to start OIDC sign-in", zap.String("provider", c.Param("provider")), zap.Error(err))
+			utils.ErrorResponse(c, http.StatusBadGateway, service.ErrOIDCLoginFailed.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to start sign-in")
+		}
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(h.OIDC.StateTTL.Seconds()), "/api/v1/auth/oidc", "", strings.HasPrefix(h.Config.AppURL, "https://"), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback is where the provider sends the browser back. It answers like
+// Login: a token pair, or a challenge when two-factor authentication is on.
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	if providerErr := c.Query("error"); providerErr != "" {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Sign-in was cancelled or refused by the provider: "+providerErr)
+		return
+	}
+
+	state := c.Query("state")
+	code := c.Query("code")
+	cookie, _ := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, "/api/v1/auth/oidc", "", strings.HasPrefix(h.Config.AppURL, "https://"), true)
+	if state == "" || code == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+		utils.ErrorResponse(c, http.StatusBadRequest, service.ErrInvalidOIDCState.Error())
+		return
+	}
+
+	user, err := h.OIDC.Complete(c.Request.Context(), c.Param("provider"), state, code)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnknownOIDCProvider):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrInvalidOIDCState):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrOIDCEmailNotVerified):
+			utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+		case errors.Is(err, service.ErrOIDCLoginFailed):
+			utils.Logger.Warn("OIDC sign-in failed", zap.String("provider", c.Param("provider")), zap.Error(err))
+			utils.ErrorResponse(c, http.StatusUnauthorized, service.ErrOIDCLoginFailed.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to sign in")
+		}
+		return
+	}
+
+	h.completeFirstFactor(c, user)
+}
+
+type VerifyMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// VerifyMFA is the second sign-in step. It takes the challenge token from
+// Login and a code from the authenticator app or a recovery code.
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req VerifyMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.MFA.CompleteChallenge(req.MFAToken, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidMFAChallenge), errors.Is(err, service.ErrInvalidMFACode):
+			utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, service.ErrMFALocked):
+			utils.ErrorResponse(c, http.StatusTooManyRequests, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to verify code")
+		}
+		return
+	}
+
+	h.startSession(c, user)
+}
+
+// startSession signs in a user who has passed every sign-in step.
+func (h *AuthHandler) startSession(c *gin.Context, user *domain.User) {
+	tokens, err := h.Sessions.Start(user, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
+	tokens.MFAEnrollmentRequired = h.MFA.EnrollmentRequired(user)
 
 	// Audit Log
 	audit := domain.AuditLog{
@@ -128,7 +292,55 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 	h.DB.Create(&audit)
 
-	utils.SuccessResponse(c, http.StatusOK, "Login successful", gin.H{"token": token})
+	utils.SuccessResponse(c, http.StatusOK, "Login successful", tokens)
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token pair.
+// Each refresh token works once.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tokens, err := h.Sessions.Refresh(req.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Token refreshed", tokens)
+}
+
+// Logout revokes the session of the calling access token, or with ?all=true
+// every session of the user.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+	sessionIDStr, _ := c.Get("session_id")
+	sessionID, _ := uuid.Parse(sessionIDStr.(string))
+
+	var err error
+	if c.Query("all") == "true" {
+		err = service.RevokeUserSessions(h.DB, userID, userID)
+	} else {
+		err = h.Sessions.Revoke(userID, sessionID)
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to sign out")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Signed out successfully", nil)
 }
 
 type ForgotPasswordRequest struct {
@@ -204,7 +416,14 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 	user.PasswordResetToken = ""
 	user.PasswordResetExpires = time.Time{} // Clear expiry
 
-	if err := h.DB.Save(&user).Error; err != nil {
+	// Whoever knew the old password is signed out with it
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return service.RevokeUserSessions(tx, user.ID, user.ID)
+	})
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update password")
 		return
 	}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/service"
+	"github.com/username/event-ticketing-system/pkg/signing"
+	"github.com/username/event-ticketing-system/pkg/utils"
+)
+
+type CheckInHandler struct {
+	Service *service.CheckInService
+}
+
+func NewCheckInHandler(s *service.CheckInService) *CheckInHandler {
+	return &CheckInHandler{Service: s}
+}
+
+func (h *CheckInHandler) CheckIn(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	staffID, _ := uuid.Parse(userIDStr.(string))
+
+	var req struct {
+		TicketCode string `json:"ticket_code" binding:"required"`
+		Gate       string `json:"gate"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	checkIn, err := h.Service.CheckIn(staffID, req.TicketCode, req.Gate)
+	if err != nil {
+		var dup *service.AlreadyCheckedInError
+		switch {
+		case errors.As(err, &dup):
+			// Return the original scan so staff can see when and where it happened
+			c.JSON(http.StatusConflict, utils.APIResponse{Success: false, Error: err.Error(), Data: dup.CheckIn})
+		case errors.Is(err, service.ErrTicketNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrTicketNotActive), errors.Is(err, service.ErrEventNotToday),
+			errors.Is(err, signing.ErrInvalidToken), errors.Is(err, signing.ErrUnknownKey):
+			utils.ErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check in ticket")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Ticket checked in successfully", checkIn)
+}
+
+func (h *CheckInHandler) VerifyTicket(c *gin.Context) {
+	claims, err := h.Service.VerifyTicket(c.Param("token"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Ticket signature is valid", claims)
+}
+
+func (h *CheckInHandler) GetManifest(c *gin.Context) {
+	eventID, _ := uuid.Parse(c.Param("id"))
+
+	manifest, err := h.Service.ExportManifest(eventID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Scanner manifest generated successfully", manifest)
+}
+
+func (h *CheckInHandler) GetSigningKeys(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Signing keys fetched successfully", gin.H{
+		"active_key_id": h.Service.Signer.KeyID(),
+		"keys":          h.Service.Signer.PublicKeys(),
+	})
+}
+
+func (h *CheckInHandler) SyncOfflineScans(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	staffID, _ := uuid.Parse(userIDStr.(string))
+	eventID, _ := uuid.Parse(c.Param("id"))
+
+	var req struct {
+		DeviceID string                `json:"device_id" binding:"required"`
+		Scans    []service.OfflineScan `json:"scans" binding:"required,min=1,max=5000,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results, err := h.Service.SyncOfflineScans(staffID, eventID, req.DeviceID, req.Scans)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to sync offline scans")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Offline scans synced successfully", results)
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"math"
 	"net/http"
 	"strconv"
@@ -13,6 +15,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/patrickmn/go-cache"
 	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/service"
 	"github.com/username/event-ticketing-system/internal/worker"
 	"github.com/username/event-ticketing-system/pkg/email"
 	"github.com/username/event-ticketing-system/pkg/utils"
@@ -25,10 +28,11 @@ type EventHandler struct {
 	Pool         *worker.WorkerPool
 	EmailService email.EmailService
 	Cache        *cache.Cache
+	Inventory    *service.Inventory
 }
 
-func NewEventHandler(db *gorm.DB, pool *worker.WorkerPool, es email.EmailService, c *cache.Cache) *EventHandler {
-	return &EventHandler{DB: db, Pool: pool, EmailService: es, Cache: c}
+func NewEventHandler(db *gorm.DB, pool *worker.WorkerPool, es email.EmailService, c *cache.Cache, inv *service.Inventory) *EventHandler {
+	return &EventHandler{DB: db, Pool: pool, EmailService: es, Cache: c, Inventory: inv}
 }
 
 func (h *EventHandler) CreateEvent(c *gin.Context) {
@@ -41,6 +45,18 @@ func (h *EventHandler) CreateEvent(c *gin.Context) {
 		Price        float64 `json:"price" binding:"min=0"`
 		ImageURL     string  `json:"image_url"`
 		Status       string  `json:"status"`
+
+		RefundFullHoursBefore *int `json:"refund_full_hours_before" binding:"omitempty,min=0"`
+		RefundPartialPercent  *int `json:"refund_partial_percent" binding:"omitempty,min=0,max=100"`
+
+		SeatMapID *uuid.UUID `json:"seat_map_id"`
+
+		WaitingRoom         bool `json:"waiting_room"`
+		QueueAdmitPerMinute int  `json:"queue_admit_per_minute" binding:"omitempty,min=0"`
+
+		ResaleEnabled          bool `json:"resale_enabled"`
+		ResaleMaxMarkupPercent int  `json:"resale_max_markup_percent" binding:"omitempty,min=0"`
+		ResaleFeePercent       int  `json:"resale_fee_percent" binding:"omitempty,min=0,max=100"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
@@ -53,32 +69,58 @@ func (h *EventHandler) CreateEvent(c *gin.Context) {
 		return
 	}
 
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
 	status := domain.StatusDraft
 	if req.Status == string(domain.StatusPublished) {
 		status = domain.StatusPublished
 	}
 
 	event := domain.Event{
-		ID:               uuid.New(),
-		Title:            req.Title,
-		Description:      req.Description,
-		Category:         req.Category,
-		StartTime:        startTime,
-		TotalTickets:     req.TotalTickets,
-		RemainingTickets: req.TotalTickets,
-		Price:            req.Price,
-		ImageURL:         req.ImageURL,
-		Status:           status,
-	}
-
-	if err := h.DB.Create(&event).Error; err != nil {
+		ID:           uuid.New(),
+		Title:        req.Title,
+		Description:  req.Description,
+		Category:     req.Category,
+		StartTime:    startTime,
+		TotalTickets: req.TotalTickets,
+		Price:        req.Price,
+		ImageURL:     req.ImageURL,
+		Status:       status,
+
+		RefundFullHoursBefore: domain.DefaultRefundFullHoursBefore,
+		RefundPartialPercent:  domain.DefaultRefundPartialPercent,
+
+		WaitingRoom:         req.WaitingRoom,
+		QueueAdmitPerMinute: req.QueueAdmitPerMinute,
+
+		ResaleEnabled:          req.ResaleEnabled,
+		ResaleMaxMarkupPercent: req.ResaleMaxMarkupPercent,
+		ResaleFeePercent:       req.ResaleFeePercent,
+
+		OrganizerID: &userID,
+	}
+	if req.RefundFullHoursBefore != nil {
+		event.RefundFullHoursBefore = *req.RefundFullHoursBefore
+	}
+	if req.RefundPartialPercent != nil {
+		event.RefundPartialPercent = *req.RefundPartialPercent
+	}
+	if req.SeatMapID != nil {
+		if err := h.DB.First(&domain.SeatMap{}, "id = ?", *req.SeatMapID).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Seat map not found")
+			return
+		}
+		event.SeatMapID = req.SeatMapID
+	}
+
+	// Select every column so an explicit zero refund policy is not replaced by the column default
+	if err := h.DB.Select("*").Create(&event).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create event")
 		return
 	}
 
 	// Audit Log
-	userIDStr, _ := c.Get("user_id")
-	userID, _ := uuid.Parse(userIDStr.(string))
 	audit := domain.AuditLog{
 		ID:         uuid.New(),
 		UserID:     userID,
@@ -164,7 +206,9 @@ func (h *EventHandler) ListEvents(c *gin.Context) {
 
 	// availability filter
 	if available := c.Query("available"); available == "true" {
-		query = query.Where("remaining_tickets > 0")
+		query = query.Where(`EXISTS (SELECT 1 FROM ticket_type_shards s
+			JOIN ticket_types tt ON tt.id = s.ticket_type_id
+			WHERE tt.event_id = events.id AND tt.deleted_at IS NULL AND s.remaining > 0)`)
 	}
 
 	// date range filter
@@ -187,7 +231,7 @@ func (h *EventHandler) ListEvents(c *gin.Context) {
 	offset := (page - 1) * limit
 
 	var events []domain.Event
-	if err := query.Preload("TicketTypes").Limit(limit).Offset(offset).Find(&events).Error; err != nil {
+	if err := query.Scopes(service.WithEventRemaining).Preload("TicketTypes", service.WithTicketTypeRemaining).Limit(limit).Offset(offset).Find(&events).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch events")
 		return
 	}
@@ -205,10 +249,26 @@ func (h *EventHandler) ListEvents(c *gin.Context) {
 	})
 }
 
+// ListManagedEvents lists every event the caller may manage, drafts and
+// cancelled ones included: their own, or all of them for admins.
+func (h *EventHandler) ListManagedEvents(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+	role := domain.UserRole(c.GetString("role"))
+
+	var events []domain.Event
+	if err := h.DB.Scopes(service.ManagedEvents(userID, role), service.WithEventRemaining).Order("start_time desc").Find(&events).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch events")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Events fetched successfully", events)
+}
+
 func (h *EventHandler) GetEvent(c *gin.Context) {
 	id := c.Param("id")
 	var event domain.Event
-	if err := h.DB.Preload("TicketTypes").First(&event, "id = ?", id).Error; err != nil {
+	if err := h.DB.Scopes(service.WithEventRemaining).Preload("TicketTypes", service.WithTicketTypeRemaining).First(&event, "id = ?", id).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "Event not found")
 		return
 	}
@@ -225,15 +285,26 @@ func (h *EventHandler) UpdateEvent(c *gin.Context) {
 	}
 
 	var updateData struct {
-		Title            string             `json:"title"`
-		Description      string             `json:"description"`
-		Category         string             `json:"category"`
-		Status           domain.EventStatus `json:"status"`
-		StartTime        time.Time          `json:"start_time"`
-		TotalTickets     int                `json:"total_tickets"`
-		RemainingTickets int                `json:"remaining_tickets"`
-		Price            float64            `json:"price"`
-		ImageURL         string             `json:"image_url"`
+		Title        string             `json:"title"`
+		Description  string             `json:"description"`
+		Category     string             `json:"category"`
+		Status       domain.EventStatus `json:"status"`
+		StartTime    time.Time          `json:"start_time"`
+		TotalTickets int                `json:"total_tickets"`
+		Price        float64            `json:"price"`
+		ImageURL     string             `json:"image_url"`
+
+		RefundFullHoursBefore *int `json:"refund_full_hours_before" binding:"omitempty,min=0"`
+		RefundPartialPercent  *int `json:"refund_partial_percent" binding:"omitempty,min=0,max=100"`
+
+		SeatMapID *uuid.UUID `json:"seat_map_id"`
+
+		WaitingRoom         *bool `json:"waiting_room"`
+		QueueAdmitPerMinute *int  `json:"queue_admit_per_minute" binding:"omitempty,min=0"`
+
+		ResaleEnabled          *bool `json:"resale_enabled"`
+		ResaleMaxMarkupPercent *int  `json:"resale_max_markup_percent" binding:"omitempty,min=0"`
+		ResaleFeePercent       *int  `json:"resale_fee_percent" binding:"omitempty,min=0,max=100"`
 	}
 
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -275,25 +346,68 @@ func (h *EventHandler) UpdateEvent(c *gin.Context) {
 	if updateData.TotalTickets != 0 {
 		event.TotalTickets = updateData.TotalTickets
 	}
-	if updateData.RemainingTickets != 0 {
-		event.RemainingTickets = updateData.RemainingTickets
-	}
 	if updateData.Price != 0 {
 		event.Price = updateData.Price
 	}
 	if updateData.ImageURL != "" {
 		event.ImageURL = updateData.ImageURL
 	}
+	if updateData.RefundFullHoursBefore != nil {
+		event.RefundFullHoursBefore = *updateData.RefundFullHoursBefore
+	}
+	if updateData.RefundPartialPercent != nil {
+		event.RefundPartialPercent = *updateData.RefundPartialPercent
+	}
+	if updateData.WaitingRoom != nil {
+		event.WaitingRoom = *updateData.WaitingRoom
+	}
+	if updateData.QueueAdmitPerMinute != nil {
+		event.QueueAdmitPerMinute = *updateData.QueueAdmitPerMinute
+	}
+	if updateData.ResaleEnabled != nil {
+		event.ResaleEnabled = *updateData.ResaleEnabled
+	}
+	if updateData.ResaleMaxMarkupPercent != nil {
+		event.ResaleMaxMarkupPercent = *updateData.ResaleMaxMarkupPercent
+	}
+	if updateData.ResaleFeePercent != nil {
+		event.ResaleFeePercent = *updateData.ResaleFeePercent
+	}
+	if updateData.SeatMapID != nil && (event.SeatMapID == nil || *event.SeatMapID != *updateData.SeatMapID) {
+		if err := h.DB.First(&domain.SeatMap{}, "id = ?", *updateData.SeatMapID).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Seat map not found")
+			return
+		}
+		// Seats already sold would point at the old layout
+		var seated int64
+		h.DB.Model(&domain.Registration{}).Where("event_id = ? AND seat_id IS NOT NULL", event.ID).Count(&seated)
+		if seated > 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Cannot change the seat map of an event with seated registrations")
+			return
+		}
+		event.SeatMapID = updateData.SeatMapID
+	}
+
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
 
 	oldEvent := event // shallow copy for record
-	if err := h.DB.Save(&event).Error; err != nil {
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&event).Error; err != nil {
+			return err
+		}
+		// Tickets to a cancelled event can no longer be resold
+		if event.Status == domain.StatusCancelled {
+			return service.WithdrawEventListings(tx, userID, event.ID)
+		}
+		return nil
+	})
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update event")
 		return
 	}
 
 	// Audit Log
-	userIDStr, _ := c.Get("user_id")
-	userID, _ := uuid.Parse(userIDStr.(string))
 	audit := domain.AuditLog{
 		ID:         uuid.New(),
 		UserID:     userID,
@@ -319,7 +433,7 @@ func (h *EventHandler) UpdateEvent(c *gin.Context) {
 
 func (h *EventHandler) notifyAttendees(event domain.Event, subject string) {
 	var registrations []domain.Registration
-	if err := h.DB.Preload("User").Where("event_id = ? AND status = ?", event.ID, domain.RegistrationConfirmed).Find(&registrations).Error; err != nil {
+	if err := h.DB.Preload("User").Where("event_id = ? AND status IN ?", event.ID, service.AttendingStatuses).Find(&registrations).Error; err != nil {
 		utils.Logger.Error("Failed to fetch attendees for event notification", zap.Error(err))
 		return
 	}
@@ -328,7 +442,7 @@ func (h *EventHandler) notifyAttendees(event domain.Event, subject string) {
 		h.Pool.Submit(worker.Task{
 			Type: worker.TaskUpdate,
 			Payload: map[string]interface{}{
-				"email":       reg.User.Email,
+				"email":       reg.ContactEmail(),
 				"event_title": event.Title,
 				"subject":     subject,
 			},
@@ -352,14 +466,21 @@ func (h *EventHandler) DeleteEvent(c *gin.Context) {
 	// Notify before deletion
 	h.notifyAttendees(event, "Event Cancelled: "+event.Title)
 
-	if err := h.DB.Delete(&domain.Event{}, "id = ?", id).Error; err != nil {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.WithdrawEventListings(tx, userID, event.ID); err != nil {
+			return err
+		}
+		return tx.Delete(&domain.Event{}, "id = ?", id).Error
+	})
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete event")
 		return
 	}
 
 	// Audit Log
-	userIDStr, _ := c.Get("user_id")
-	userID, _ := uuid.Parse(userIDStr.(string))
 	eventID, _ := uuid.Parse(id)
 	audit := domain.AuditLog{
 		ID:         uuid.New(),
@@ -380,7 +501,7 @@ func (h *EventHandler) DeleteEvent(c *gin.Context) {
 func (h *EventHandler) GetRemainingSeats(c *gin.Context) {
 	id := c.Param("id")
 	var event domain.Event
-	if err := h.DB.Select("remaining_tickets").First(&event, "id = ?", id).Error; err != nil {
+	if err := h.DB.Scopes(service.WithEventRemaining).First(&event, "id = ?", id).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "Event not found")
 		return
 	}
@@ -396,14 +517,29 @@ func (h *EventHandler) CreateTicketType(c *gin.Context) {
 		return
 	}
 
-	tt.ID = uuid.New()
 	tt.EventID = eventID
-	tt.RemainingTickets = tt.Capacity
+	if err := validateTicketTypeRules(&tt); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.validateSeatZone(&tt); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	if err := h.DB.Create(&tt).Error; err != nil {
+	tt.ID = uuid.New()
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&tt).Error; err != nil {
+			return err
+		}
+		return h.Inventory.Provision(tx, tt.ID, tt.Capacity)
+	})
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create ticket type")
 		return
 	}
+	tt.RemainingTickets = tt.Capacity
 
 	// Audit Log
 	userIDStr, _ := c.Get("user_id")
@@ -425,7 +561,7 @@ func (h *EventHandler) CreateTicketType(c *gin.Context) {
 func (h *EventHandler) UpdateTicketType(c *gin.Context) {
 	id := c.Param("tt_id")
 	var tt domain.TicketType
-	if err := h.DB.First(&tt, "id = ?", id).Error; err != nil {
+	if err := h.DB.First(&tt, "id = ? AND event_id = ?", id, c.Param("id")).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "Ticket type not found")
 		return
 	}
@@ -435,11 +571,32 @@ func (h *EventHandler) UpdateTicketType(c *gin.Context) {
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	// The ticket type stays on its event whatever the body says
+	tt.ID = oldTT.ID
+	tt.EventID = oldTT.EventID
 
-	if err := h.DB.Save(&tt).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update ticket type")
+	if err := validateTicketTypeRules(&tt); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	if tt.SeatZone != oldTT.SeatZone || tt.Capacity != oldTT.Capacity {
+		if err := h.validateSeatZone(&tt); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&tt).Error; err != nil {
+			return err
+		}
+		return h.Inventory.Resize(tx, tt.ID, tt.Capacity-oldTT.Capacity)
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to update ticket type: "+err.Error())
+		return
+	}
+	h.DB.Scopes(service.WithTicketTypeRemaining).First(&tt, "id = ?", tt.ID)
 
 	// Audit Log
 	userIDStr, _ := c.Get("user_id")
@@ -461,10 +618,15 @@ func (h *EventHandler) UpdateTicketType(c *gin.Context) {
 
 func (h *EventHandler) DeleteTicketType(c *gin.Context) {
 	id := c.Param("tt_id")
-	if err := h.DB.Delete(&domain.TicketType{}, "id = ?", id).Error; err != nil {
+	result := h.DB.Delete(&domain.TicketType{}, "id = ? AND event_id = ?", id, c.Param("id"))
+	if result.Error != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete ticket type")
 		return
 	}
+	if result.RowsAffected == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "Ticket type not found")
+		return
+	}
 
 	// Audit Log
 	userIDStr, _ := c.Get("user_id")
@@ -482,3 +644,46 @@ func (h *EventHandler) DeleteTicketType(c *gin.Context) {
 
 	utils.SuccessResponse(c, http.StatusOK, "Ticket type deleted successfully", nil)
 }
+
+// validateTicketTypeRules rejects sales windows and purchase limits that no
+// order could ever satisfy.
+func validateTicketTypeRules(tt *domain.TicketType) error {
+	if tt.SalesStart != nil && tt.SalesEnd != nil && !tt.SalesEnd.After(*tt.SalesStart) {
+		return errors.New("sales_end must be after sales_start")
+	}
+	if tt.MaxPerOrder > 0 && tt.MinPerOrder > tt.MaxPerOrder {
+		return errors.New("max_per_order cannot be less than min_per_order")
+	}
+	if tt.MaxPerUser > 0 && tt.MinPerOrder > tt.MaxPerUser {
+		return errors.New("max_per_user cannot be less than min_per_order")
+	}
+	return nil
+}
+
+// validateSeatZone checks that a reserved-seating ticket type points at a zone
+// of its event's seat map with enough seats for its capacity.
+func (h *EventHandler) validateSeatZone(tt *domain.TicketType) error {
+	if tt.SeatZone == "" {
+		return nil
+	}
+
+	var event domain.Event
+	if err := h.DB.First(&event, "id = ?", tt.EventID).Error; err != nil {
+		return errors.New("event not found")
+	}
+	if event.SeatMapID == nil {
+		return errors.New("seat_zone requires the event to have a seat map")
+	}
+
+	var seats int64
+	if err := h.DB.Model(&domain.Seat{}).Where("seat_map_id = ? AND zone = ?", *event.SeatMapID, tt.SeatZone).Count(&seats).Error; err != nil {
+		return err
+	}
+	if seats == 0 {
+		return fmt.Errorf("seat map has no zone %q", tt.SeatZone)
+	}
+	if int64(tt.Capacity) > seats {
+		return fmt.Errorf("capacity %d exceeds the %d seats in zone %q", tt.Capacity, seats, tt.SeatZone)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/service"
+	"github.com/username/event-ticketing-system/pkg/utils"
+)
+
+type HoldHandler struct {
+	Service *service.HoldService
+}
+
+func NewHoldHandler(s *service.HoldService) *HoldHandler {
+	return &HoldHandler{Service: s}
+}
+
+func (h *HoldHandler) CreateHold(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	var req struct {
+		EventID      uuid.UUID `json:"event_id" binding:"required"`
+		TicketTypeID uuid.UUID `json:"ticket_type_id" binding:"required"`
+		Quantity     int       `json:"quantity" binding:"required,min=1,max=10"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hold, err := h.Service.CreateHold(userID, req.EventID, req.TicketTypeID, req.Quantity, c.GetHeader(queueTokenHeader))
+	if err != nil {
+		respondCheckoutError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Seats held successfully", hold)
+}
+
+func (h *HoldHandler) ConfirmHold(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+	holdID, _ := uuid.Parse(c.Param("id"))
+
+	answers, ok := bindAnswers(c)
+	if !ok {
+		return
+	}
+
+	registrations, err := h.Service.ConfirmHold(userID, holdID, answers)
+	if err != nil {
+		respondCheckoutError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	if registrations[0].Status == domain.RegistrationPendingPayment {
+		utils.SuccessResponse(c, http.StatusAccepted, "Registration awaiting payment", registrations)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Registration confirmed", registrations)
+}
+
+func (h *HoldHandler) ReleaseHold(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+	holdID, _ := uuid.Parse(c.Param("id"))
+
+	if err := h.Service.ReleaseHold(userID, holdID); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Hold released successfully", nil)
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/service"
+	"github.com/username/event-ticketing-system/pkg/utils"
+)
+
+// maxImportSize caps uploaded attendee lists.
+const maxImportSize = 10 << 20
+
+type ImportHandler struct {
+	Service *service.ImportService
+}
+
+func NewImportHandler(s *service.ImportService) *ImportHandler {
+	return &ImportHandler{Service: s}
+}
+
+// ImportAttendees issues comp tickets from an uploaded CSV in the "file" form
+// field. ?dry_run=true only validates it; ?bypass_capacity=true issues general
+// admission comps beyond the ticket types' capacity.
+func (h *ImportHandler) ImportAttendees(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid event ID")
+		return
+	}
+	userIDStr, _ := c.Get("user_id")
+	adminID, _ := uuid.Parse(userIDStr.(string))
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	header, err := c.FormFile("file")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "A CSV file is required in the \"file\" field")
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read the uploaded file")
+		return
+	}
+	defer file.Close()
+
+	opts := service.ImportOptions{
+		DryRun:         c.Query("dry_run") == "true",
+		BypassCapacity: c.Query("bypass_capacity") == "true",
+	}
+
+	result, err := h.Service.ImportAttendees(adminID, eventID, file, opts)
+	if err != nil {
+		if result != nil {
+			// Part of the list was issued before a batch failed
+			c.JSON(http.StatusConflict, utils.APIResponse{Success: false, Error: err.Error(), Data: result})
+			return
+		}
+		respondCheckoutError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case opts.DryRun && len(result.Errors) > 0:
+		utils.SuccessResponse(c, http.StatusOK, "Attendee list has errors", result)
+	case opts.DryRun:
+		utils.SuccessResponse(c, http.StatusOK, "Attendee list is valid", result)
+	case len(result.Errors) > 0:
+		c.JSON(http.StatusUnprocessableEntity, utils.APIResponse{Success: false, Error: "The attendee list has errors; nothing was issued", Data: result})
+	default:
+		utils.SuccessResponse(c, http.StatusCreated, "Complimentary tickets issued", result)
+	}
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/username/event-ticketing-system/internal/service"
 	"github.com/username/event-ticketing-system/pkg/utils"
+	"go.uber.org/zap"
 )
 
 type LoadTestHandler struct {
@@ -19,11 +21,30 @@ func NewLoadTestHandler(rs *service.RegistrationService) *LoadTestHandler {
 	return &LoadTestHandler{RegistrationService: rs}
 }
 
+type loadTestResult struct {
+	Strategy   string  `json:"strategy"`
+	Total      int     `json:"total"`
+	Success    int     `json:"success"`
+	Failed     int     `json:"failed"`
+	TimeTaken  string  `json:"time_taken"`
+	Throughput float64 `json:"throughput_per_sec"`
+	P50        string  `json:"p50"`
+	P95        string  `json:"p95"`
+	P99        string  `json:"p99"`
+}
+
+// LoadTest fires concurrent checkouts at an event once per inventory strategy
+// and reports throughput and latency percentiles for each. Every strategy gets
+// its own scratch ticket type with the same stock, removed afterwards, and
+// buyers are synthetic IDs, so runs are comparable and touch no real users,
+// stock or inboxes.
 func (h *LoadTestHandler) LoadTest(c *gin.Context) {
 	var req struct {
-		EventID      uuid.UUID `json:"event_id" binding:"required"`
-		TicketTypeID uuid.UUID `json:"ticket_type_id" binding:"required"`
-		Concurrency  int       `json:"concurrency" binding:"required"`
+		EventID     uuid.UUID `json:"event_id" binding:"required"`
+		Concurrency int       `json:"concurrency" binding:"required"`
+		// Stock is the number of seats each strategy starts with; it defaults to Concurrency
+		Stock      int      `json:"stock" binding:"omitempty,min=1"`
+		Strategies []string `json:"strategies"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -35,42 +56,97 @@ func (h *LoadTestHandler) LoadTest(c *gin.Context) {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Concurrency must be greater than 0")
 		return
 	}
+	if req.Stock == 0 {
+		req.Stock = req.Concurrency
+	}
+
+	if len(req.Strategies) == 0 {
+		req.Strategies = []string{service.StrategyRowLock, service.StrategyConditional, service.StrategySharded}
+	}
+	for _, strategy := range req.Strategies {
+		switch strategy {
+		case service.StrategyRowLock, service.StrategyConditional, service.StrategySharded:
+		default:
+			utils.ErrorResponse(c, http.StatusBadRequest, "Unknown strategy: "+strategy)
+			return
+		}
+	}
+
+	results := make([]loadTestResult, 0, len(req.Strategies))
+	for _, strategy := range req.Strategies {
+		rs := *h.RegistrationService
+		rs.Inventory = service.NewInventory(strategy, h.RegistrationService.Inventory.Shards)
+
+		ticketType, err := rs.CreateScratchTicketType(req.EventID, strategy, req.Stock)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		result := runLoadTest(&rs, strategy, req.EventID, ticketType.ID, req.Concurrency)
+		if err := rs.DropScratchTicketType(ticketType.ID); err != nil {
+			utils.Logger.Error("Failed to remove load test ticket type", zap.String("ticket_type_id", ticketType.ID.String()), zap.Error(err))
+		}
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Load test completed",
+		"results": results,
+	})
+}
 
+func runLoadTest(rs *service.RegistrationService, strategy string, eventID uuid.UUID, ticketTypeID uuid.UUID, concurrency int) loadTestResult {
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	successCount := 0
-	failureCount := 0
+	latencies := make([]time.Duration, 0, concurrency)
 	startTime := time.Now()
 
 	// Simulate concurrent requests
-	for i := 0; i < req.Concurrency; i++ {
+	for i := 0; i < concurrency; i++ {
 		wg.Add(1)
-		go func() {
+		go func(buyerID uuid.UUID) {
 			defer wg.Done()
 
-			// Using a random user ID for each request to simulate different users
-			randomUserID := uuid.New()
-			_, err := h.RegistrationService.Register(randomUserID, req.EventID, req.TicketTypeID)
+			began := time.Now()
+			err := rs.SimulateCheckout(buyerID, eventID, ticketTypeID)
+			elapsed := time.Since(began)
 
 			mu.Lock()
 			if err == nil {
 				successCount++
-			} else {
-				failureCount++
 			}
+			latencies = append(latencies, elapsed)
 			mu.Unlock()
-		}()
+		}(uuid.New())
 	}
 
 	wg.Wait()
 	duration := time.Since(startTime)
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":      "Load test completed",
-		"total":        req.Concurrency,
-		"success":      successCount,
-		"failed":       failureCount,
-		"time_taken":   duration.String(),
-		"avg_req_time": (duration / time.Duration(req.Concurrency)).String(),
-	})
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return loadTestResult{
+		Strategy:   strategy,
+		Total:      concurrency,
+		Success:    successCount,
+		Failed:     concurrency - successCount,
+		TimeTaken:  duration.String(),
+		Throughput: float64(concurrency) / duration.Seconds(),
+		P50:        latencyPercentile(latencies, 50).String(),
+		P95:        latencyPercentile(latencies, 95).String(),
+		P99:        latencyPercentile(latencies, 99).String(),
+	}
+}
+
+// latencyPercentile returns the nearest-rank p-th percentile of sorted
+// latencies.
+func latencyPercentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
 }
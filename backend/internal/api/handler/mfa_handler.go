@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/service"
+	"github.com/username/event-ticketing-system/pkg/utils"
+)
+
+type MFAHandler struct {
+	Service *service.MFAService
+}
+
+func NewMFAHandler(s *service.MFAService) *MFAHandler {
+	return &MFAHandler{Service: s}
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// BeginEnrollment returns a new TOTP secret with its QR code (a base64 PNG)
+// for the user to scan.
+func (h *MFAHandler) BeginEnrollment(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	enrollment, err := h.Service.BeginEnrollment(userID)
+	if err != nil {
+		respondMFAError(c, err, "Failed to start two-factor enrollment")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Scan the QR code with your authenticator app, then confirm with a code", enrollment)
+}
+
+// ConfirmEnrollment enables two-factor authentication and returns the
+// recovery codes. Every session is signed out, this one included.
+func (h *MFAHandler) ConfirmEnrollment(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	codes, err := h.Service.ConfirmEnrollment(userID, req.Code)
+	if err != nil {
+		respondMFAError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Two-factor authentication enabled; store these recovery codes and sign in again", gin.H{"recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes.
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	codes, err := h.Service.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		respondMFAError(c, err, "Failed to generate recovery codes")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Recovery codes replaced", gin.H{"recovery_codes": codes})
+}
+
+// Disable turns two-factor authentication off.
+func (h *MFAHandler) Disable(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.Service.Disable(userID, req.Code); err != nil {
+		respondMFAError(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Two-factor authentication disabled", nil)
+}
+
+func respondMFAError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidMFACode):
+		utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, service.ErrMFALocked):
+		utils.ErrorResponse(c, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, service.ErrMFAAlreadyEnabled), errors.Is(err, service.ErrMFANotEnabled), errors.Is(err, service.ErrMFANotEnrolling):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrMFARequired):
+		utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, fallback)
+	}
+}
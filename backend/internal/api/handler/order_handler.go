@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/service"
+	"github.com/username/event-ticketing-system/pkg/utils"
+)
+
+type OrderHandler struct {
+	Service *service.OrderService
+}
+
+func NewOrderHandler(s *service.OrderService) *OrderHandler {
+	return &OrderHandler{Service: s}
+}
+
+func (h *OrderHandler) CreateOrder(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	var req struct {
+		EventID uuid.UUID           `json:"event_id" binding:"required"`
+		Items   []service.OrderLine `json:"items" binding:"required,min=1,dive"`
+		// The buyer's answers to the registration form, keyed by question ID;
+		// attendees may bring their own
+		Answers map[uuid.UUID]json.RawMessage `json:"answers"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	order, err := h.Service.CreateOrder(userID, req.EventID, req.Items, req.Answers, c.GetHeader(queueTokenHeader))
+	if err != nil {
+		respondCheckoutError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	if order.Status == domain.OrderPendingPayment {
+		utils.SuccessResponse(c, http.StatusAccepted, "Order awaiting payment", order)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Order confirmed", order)
+}
+
+func (h *OrderHandler) GetMyOrders(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	orders, err := h.Service.GetUserOrders(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch orders")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Orders fetched successfully", orders)
+}
+
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+	orderID, _ := uuid.Parse(c.Param("id"))
+
+	order, err := h.Service.GetOrder(userID, orderID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Order fetched successfully", order)
+}
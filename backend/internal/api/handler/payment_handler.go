@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/service"
+	"github.com/username/event-ticketing-system/pkg/payment"
+	"github.com/username/event-ticketing-system/pkg/utils"
+)
+
+// PaymentSignatureHeader carries the provider's HMAC of the webhook body.
+const PaymentSignatureHeader = "X-Payment-Signature"
+
+type PaymentHandler struct {
+	Service *service.PaymentService
+}
+
+func NewPaymentHandler(s *service.PaymentService) *PaymentHandler {
+	return &PaymentHandler{Service: s}
+}
+
+func (h *PaymentHandler) GetPayment(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+	paymentID, _ := uuid.Parse(c.Param("id"))
+
+	p, err := h.Service.GetPayment(userID, paymentID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Payment fetched successfully", p)
+}
+
+func (h *PaymentHandler) CapturePayment(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+	paymentID, _ := uuid.Parse(c.Param("id"))
+
+	p, err := h.Service.Capture(userID, paymentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrPaymentNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrPaymentNotPending):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		case errors.Is(err, payment.ErrDeclined):
+			utils.ErrorResponse(c, http.StatusPaymentRequired, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusBadGateway, "Failed to capture payment")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Payment captured successfully", p)
+}
+
+func (h *PaymentHandler) HandleWebhook(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read webhook body")
+		return
+	}
+
+	if err := h.Service.HandleWebhook(body, c.GetHeader(PaymentSignatureHeader)); err != nil {
+		switch {
+		case errors.Is(err, payment.ErrInvalidSignature):
+			utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, service.ErrPaymentNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process webhook")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Webhook processed", nil)
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/service"
+	"github.com/username/event-ticketing-system/pkg/utils"
+)
+
+type PromoHandler struct {
+	Service *service.PromoService
+}
+
+func NewPromoHandler(s *service.PromoService) *PromoHandler {
+	return &PromoHandler{Service: s}
+}
+
+func (h *PromoHandler) CreatePromoCode(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	adminID, _ := uuid.Parse(userIDStr.(string))
+	eventID, _ := uuid.Parse(c.Param("id"))
+
+	var req service.PromoCodeInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	promo, err := h.Service.CreatePromoCode(adminID, eventID, req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Promo code created successfully", promo)
+}
+
+func (h *PromoHandler) ListPromoCodes(c *gin.Context) {
+	eventID, _ := uuid.Parse(c.Param("id"))
+
+	promos, err := h.Service.ListPromoCodes(eventID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch promo codes")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Promo codes fetched successfully", promos)
+}
+
+func (h *PromoHandler) DeactivatePromoCode(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	adminID, _ := uuid.Parse(userIDStr.(string))
+	eventID, _ := uuid.Parse(c.Param("id"))
+	promoID, _ := uuid.Parse(c.Param("promo_id"))
+
+	if err := h.Service.DeactivatePromoCode(adminID, eventID, promoID); err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Promo code deactivated successfully", nil)
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/service"
+	"github.com/username/event-ticketing-system/pkg/utils"
+)
+
+type QuestionHandler struct {
+	Service *service.QuestionService
+}
+
+func NewQuestionHandler(s *service.QuestionService) *QuestionHandler {
+	return &QuestionHandler{Service: s}
+}
+
+// ListQuestions returns an event's registration form. Passing ticket_type_id
+// narrows it to the questions asked of that tier.
+func (h *QuestionHandler) ListQuestions(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid event ID")
+		return
+	}
+
+	var ticketTypeID *uuid.UUID
+	if raw := c.Query("ticket_type_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid ticket_type_id")
+			return
+		}
+		ticketTypeID = &id
+	}
+
+	questions, err := h.Service.ListQuestions(eventID, ticketTypeID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch registration questions")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Registration questions fetched successfully", questions)
+}
+
+func (h *QuestionHandler) CreateQuestion(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	adminID, _ := uuid.Parse(userIDStr.(string))
+	eventID, _ := uuid.Parse(c.Param("id"))
+
+	var req service.QuestionInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	question, err := h.Service.CreateQuestion(adminID, eventID, req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Registration question created successfully", question)
+}
+
+func (h *QuestionHandler) UpdateQuestion(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	adminID, _ := uuid.Parse(userIDStr.(string))
+	eventID, _ := uuid.Parse(c.Param("id"))
+	questionID, _ := uuid.Parse(c.Param("question_id"))
+
+	var req service.QuestionInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	question, err := h.Service.UpdateQuestion(adminID, eventID, questionID, req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Registration question updated successfully", question)
+}
+
+func (h *QuestionHandler) DeleteQuestion(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	adminID, _ := uuid.Parse(userIDStr.(string))
+	eventID, _ := uuid.Parse(c.Param("id"))
+	questionID, _ := uuid.Parse(c.Param("question_id"))
+
+	if err := h.Service.DeleteQuestion(adminID, eventID, questionID); err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Registration question deleted successfully", nil)
+}
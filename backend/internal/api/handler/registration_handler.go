@@ -1,6 +1,9 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -24,8 +27,13 @@ func (h *RegistrationHandler) RegisterForEvent(c *gin.Context) {
 	userID, _ := uuid.Parse(userIDStr.(string))
 
 	var req struct {
-		EventID      uuid.UUID `json:"event_id" binding:"required"`
-		TicketTypeID uuid.UUID `json:"ticket_type_id" binding:"required"`
+		EventID      uuid.UUID  `json:"event_id" binding:"required"`
+		TicketTypeID uuid.UUID  `json:"ticket_type_id" binding:"required"`
+		PromoCode    string     `json:"promo_code"`
+		PromoCodes   []string   `json:"promo_codes"`
+		SeatID       *uuid.UUID `json:"seat_id"`
+		// Answers to the registration form, keyed by question ID
+		Answers map[uuid.UUID]json.RawMessage `json:"answers"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -33,14 +41,24 @@ func (h *RegistrationHandler) RegisterForEvent(c *gin.Context) {
 		return
 	}
 
-	registration, err := h.Service.Register(userID, req.EventID, req.TicketTypeID)
+	opts := service.RegisterOptions{PromoCodes: req.PromoCodes, SeatID: req.SeatID, QueueToken: c.GetHeader(queueTokenHeader), Answers: req.Answers}
+	if req.PromoCode != "" {
+		opts.PromoCodes = append(opts.PromoCodes, req.PromoCode)
+	}
+
+	registration, err := h.Service.Register(userID, req.EventID, req.TicketTypeID, opts)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		respondCheckoutError(c, err, http.StatusInternalServerError)
 		return
 	}
 
 	// Preload for frontend display consistency
-	h.Service.DB.Preload("Event").Preload("TicketType").First(registration, registration.ID)
+	h.Service.DB.Preload("Event").Preload("TicketType").Preload("Payment").Preload("Seat.Section").Preload("Answers").First(registration, registration.ID)
+
+	if registration.Status == domain.RegistrationPendingPayment {
+		utils.SuccessResponse(c, http.StatusAccepted, "Registration awaiting payment", registration)
+		return
+	}
 
 	utils.SuccessResponse(c, http.StatusCreated, "Registration confirmed", registration)
 }
@@ -54,6 +72,7 @@ func (h *RegistrationHandler) GetMyRegistrations(c *gin.Context) {
 		Preload("Event").
 		Preload("TicketType").
 		Preload("Ticket").
+		Preload("Seat.Section").
 		Find(&registrations).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch registrations")
 		return
@@ -67,29 +86,13 @@ func (h *RegistrationHandler) CancelRegistration(c *gin.Context) {
 	userID, _ := uuid.Parse(userIDStr.(string))
 	registrationID, _ := uuid.Parse(c.Param("id"))
 
-	if err := h.Service.CancelRegistration(userID, registrationID); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	utils.SuccessResponse(c, http.StatusOK, "Registration cancelled successfully", nil)
-}
-
-func (h *RegistrationHandler) TransferTicket(c *gin.Context) {
-	userIDStr, _ := c.Get("user_id")
-	senderID, _ := uuid.Parse(userIDStr.(string))
-	registrationID, _ := uuid.Parse(c.Param("id"))
-
-	var req struct {
-		RecipientEmail string `json:"recipient_email" binding:"required,email"`
-	}
-
-	if err := h.Service.TransferTicket(senderID, registrationID, req.RecipientEmail); err != nil {
+	result, err := h.Service.CancelRegistration(userID, registrationID)
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Ticket transferred successfully", nil)
+	utils.SuccessResponse(c, http.StatusOK, "Registration cancelled successfully", result)
 }
 
 func (h *RegistrationHandler) GetAttendees(c *gin.Context) {
@@ -119,7 +122,14 @@ func (h *RegistrationHandler) UpdateRSVP(c *gin.Context) {
 	userID, _ := uuid.Parse(userIDStr.(string))
 
 	if err := h.Service.UpdateRSVP(userID, regID, input.Status); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update RSVP status")
+		switch {
+		case errors.Is(err, service.ErrInvalidRSVP):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrRSVPNotAllowed):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update RSVP status")
+		}
 		return
 	}
 
@@ -143,3 +153,38 @@ func (h *RegistrationHandler) GetQR(c *gin.Context) {
 
 	c.Data(http.StatusOK, "image/png", qrBytes)
 }
+
+// bindAnswers reads the optional checkout answers body, reporting false after rejecting a malformed one.
+func bindAnswers(c *gin.Context) (map[uuid.UUID]json.RawMessage, bool) {
+	var req struct {
+		Answers map[uuid.UUID]json.RawMessage `json:"answers"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return req.Answers, true
+}
+
+// respondCheckoutError maps checkout rule violations to a status and their
+// error code. Errors without a code are reported with fallback.
+func respondCheckoutError(c *gin.Context, err error, fallback int) {
+	var coded *service.CodedError
+	if !errors.As(err, &coded) {
+		utils.ErrorResponse(c, fallback, err.Error())
+		return
+	}
+
+	switch coded.Code {
+	case service.CodeSoldOut:
+		c.JSON(http.StatusConflict, utils.APIResponse{Success: false, Error: err.Error(), Code: coded.Code, Data: gin.H{"waitlist_available": true}})
+	case service.CodeSeatUnavailable:
+		utils.ErrorResponseWithCode(c, http.StatusConflict, coded.Code, err.Error())
+	case service.CodeQueueTokenRequired, service.CodeQueueNotAdmitted, service.CodeQueueTokenInvalid, service.CodeQueueTokenExpired, service.CodeEmailNotVerified:
+		utils.ErrorResponseWithCode(c, http.StatusForbidden, coded.Code, err.Error())
+	case service.CodeEventNotFound, service.CodeTicketTypeNotFound:
+		utils.ErrorResponseWithCode(c, http.StatusNotFound, coded.Code, err.Error())
+	default:
+		utils.ErrorResponseWithCode(c, http.StatusUnprocessableEntity, coded.Code, err.Error())
+	}
+}
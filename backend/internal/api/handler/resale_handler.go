@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/service"
+	"github.com/username/event-ticketing-system/pkg/utils"
+)
+
+type ResaleHandler struct {
+	Service *service.ResaleService
+}
+
+func NewResaleHandler(s *service.ResaleService) *ResaleHandler {
+	return &ResaleHandler{Service: s}
+}
+
+func (h *ResaleHandler) CreateListing(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	sellerID, _ := uuid.Parse(userIDStr.(string))
+	registrationID, _ := uuid.Parse(c.Param("id"))
+
+	var req struct {
+		Price float64 `json:"price" binding:"required,gt=0"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	listing, err := h.Service.CreateListing(sellerID, registrationID, req.Price)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Ticket listed for resale", listing)
+}
+
+func (h *ResaleHandler) GetEventListings(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid event ID")
+		return
+	}
+
+	listings, err := h.Service.GetEventListings(eventID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch resale listings")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Resale listings fetched successfully", listings)
+}
+
+func (h *ResaleHandler) GetMyListings(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	listings, err := h.Service.GetMyListings(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch resale listings")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Resale listings fetched successfully", listings)
+}
+
+func (h *ResaleHandler) WithdrawListing(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+	listingID, _ := uuid.Parse(c.Param("id"))
+
+	listing, err := h.Service.WithdrawListing(userID, listingID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Resale listing withdrawn", listing)
+}
+
+// BuyListing holds a listing for the buyer and returns the pending payment to
+// complete through the payments endpoints.
+func (h *ResaleHandler) BuyListing(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+	listingID, _ := uuid.Parse(c.Param("id"))
+
+	answers, ok := bindAnswers(c)
+	if !ok {
+		return
+	}
+
+	listing, err := h.Service.BuyListing(userID, listingID, answers)
+	if err != nil {
+		respondCheckoutError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusAccepted, "Resale purchase awaiting payment", listing)
+}
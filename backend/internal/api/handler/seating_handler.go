@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/service"
+	"github.com/username/event-ticketing-system/pkg/utils"
+)
+
+type SeatingHandler struct {
+	Service *service.SeatingService
+}
+
+func NewSeatingHandler(s *service.SeatingService) *SeatingHandler {
+	return &SeatingHandler{Service: s}
+}
+
+func (h *SeatingHandler) CreateSeatMap(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	adminID, _ := uuid.Parse(userIDStr.(string))
+
+	var req service.SeatMapInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	seatMap, err := h.Service.CreateSeatMap(adminID, req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Seat map created successfully", seatMap)
+}
+
+func (h *SeatingHandler) ListSeatMaps(c *gin.Context) {
+	seatMaps, err := h.Service.ListSeatMaps()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch seat maps")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Seat maps fetched successfully", seatMaps)
+}
+
+func (h *SeatingHandler) GetSeatMap(c *gin.Context) {
+	id, _ := uuid.Parse(c.Param("id"))
+
+	seatMap, err := h.Service.GetSeatMap(id)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Seat map fetched successfully", seatMap)
+}
+
+func (h *SeatingHandler) GetEventSeating(c *gin.Context) {
+	eventID, _ := uuid.Parse(c.Param("id"))
+
+	seating, err := h.Service.GetEventSeating(eventID)
+	if err != nil {
+		var coded *service.CodedError
+		if errors.As(err, &coded) {
+			utils.ErrorResponseWithCode(c, http.StatusNotFound, coded.Code, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Seat availability fetched successfully", seating)
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/service"
+	"github.com/username/event-ticketing-system/pkg/utils"
+)
+
+type TransferHandler struct {
+	Service *service.TransferService
+}
+
+func NewTransferHandler(s *service.TransferService) *TransferHandler {
+	return &TransferHandler{Service: s}
+}
+
+// CreateTransfer offers one of the user's tickets to another person. Nothing
+// changes hands until the recipient accepts.
+func (h *TransferHandler) CreateTransfer(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	senderID, _ := uuid.Parse(userIDStr.(string))
+	registrationID, _ := uuid.Parse(c.Param("id"))
+
+	var req struct {
+		RecipientEmail string `json:"recipient_email" binding:"required,email"`
+		RecipientName  string `json:"recipient_name"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	transfer, err := h.Service.OfferTransfer(senderID, registrationID, req.RecipientEmail, req.RecipientName)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Transfer offered; the recipient has been emailed a link to accept it", transfer)
+}
+
+func (h *TransferHandler) ListTransfers(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	transfers, err := h.Service.ListTransfers(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch transfers")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Transfers fetched successfully", transfers)
+}
+
+func (h *TransferHandler) RevokeTransfer(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+	transferID, _ := uuid.Parse(c.Param("id"))
+
+	transfer, err := h.Service.RevokeTransfer(userID, transferID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Transfer revoked successfully", transfer)
+}
+
+// GetTransfer lets a recipient see the offer behind their link.
+func (h *TransferHandler) GetTransfer(c *gin.Context) {
+	transfer, err := h.Service.GetOffer(c.Param("token"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Transfer fetched successfully", transfer)
+}
+
+func (h *TransferHandler) AcceptTransfer(c *gin.Context) {
+	transfer, err := h.Service.AcceptTransfer(c.Param("token"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Transfer accepted; the ticket has been emailed to you", transfer)
+}
+
+func (h *TransferHandler) DeclineTransfer(c *gin.Context) {
+	transfer, err := h.Service.DeclineTransfer(c.Param("token"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Transfer declined", transfer)
+}
+
+// GetTicketClaim lets a guest view their transferred ticket through the claim
+// link without an account.
+func (h *TransferHandler) GetTicketClaim(c *gin.Context) {
+	claim, err := h.Service.GetTicketClaim(c.Param("token"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Ticket claim fetched successfully", claim)
+}
+
+func (h *TransferHandler) ClaimTicket(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	registration, err := h.Service.ClaimTicket(userID, c.Param("token"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Ticket claimed successfully", registration)
+}
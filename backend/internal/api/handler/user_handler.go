@@ -4,7 +4,9 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/service"
 	"github.com/username/event-ticketing-system/pkg/utils"
 	"gorm.io/gorm"
 )
@@ -37,12 +39,27 @@ func (h *UserHandler) UpdateUserRole(c *gin.Context) {
 		return
 	}
 
-	if req.Role != domain.RoleAdmin && req.Role != domain.RoleUser {
+	if !req.Role.Valid() {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid role")
 		return
 	}
 
-	if err := h.DB.Model(&domain.User{}).Where("id = ?", id).Update("role", req.Role).Error; err != nil {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	adminIDStr, _ := c.Get("user_id")
+	adminID, _ := uuid.Parse(adminIDStr.(string))
+
+	// Roles are carried in access tokens, so the user signs in again to pick up the new one
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.User{}).Where("id = ?", userID).Update("role", req.Role).Error; err != nil {
+			return err
+		}
+		return service.RevokeUserSessions(tx, adminID, userID)
+	})
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update user role")
 		return
 	}
@@ -51,8 +68,21 @@ func (h *UserHandler) UpdateUserRole(c *gin.Context) {
 }
 
 func (h *UserHandler) DeleteUser(c *gin.Context) {
-	id := c.Param("id")
-	if err := h.DB.Delete(&domain.User{}, "id = ?", id).Error; err != nil {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	adminIDStr, _ := c.Get("user_id")
+	adminID, _ := uuid.Parse(adminIDStr.(string))
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&domain.User{}, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		return service.RevokeUserSessions(tx, adminID, userID)
+	})
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete user")
 		return
 	}
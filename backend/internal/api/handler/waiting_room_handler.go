@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/service"
+	"github.com/username/event-ticketing-system/pkg/utils"
+)
+
+// queueTokenHeader carries an admitted waiting room token on checkout requests.
+const queueTokenHeader = "X-Queue-Token"
+
+type WaitingRoomHandler struct {
+	Service *service.WaitingRoomService
+}
+
+func NewWaitingRoomHandler(s *service.WaitingRoomService) *WaitingRoomHandler {
+	return &WaitingRoomHandler{Service: s}
+}
+
+func (h *WaitingRoomHandler) JoinQueue(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+	eventID, _ := uuid.Parse(c.Param("id"))
+
+	position, err := h.Service.Join(userID, eventID)
+	if err != nil {
+		respondCheckoutError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	respondQueuePosition(c, position)
+}
+
+func (h *WaitingRoomHandler) GetQueuePosition(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	position, err := h.Service.GetPosition(userID, c.Param("token"))
+	if err != nil {
+		respondCheckoutError(c, err, http.StatusNotFound)
+		return
+	}
+
+	respondQueuePosition(c, position)
+}
+
+func respondQueuePosition(c *gin.Context, position *service.QueuePosition) {
+	if position.Status == domain.QueueAdmitted {
+		utils.SuccessResponse(c, http.StatusOK, "Admitted: send the token in the "+queueTokenHeader+" header to check out", position)
+		return
+	}
+	utils.SuccessResponse(c, http.StatusOK, "Waiting in queue", position)
+}
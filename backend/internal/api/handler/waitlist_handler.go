@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/service"
+	"github.com/username/event-ticketing-system/pkg/utils"
+)
+
+type WaitlistHandler struct {
+	Service *service.WaitlistService
+}
+
+func NewWaitlistHandler(s *service.WaitlistService) *WaitlistHandler {
+	return &WaitlistHandler{Service: s}
+}
+
+func (h *WaitlistHandler) JoinWaitlist(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	var req struct {
+		EventID      uuid.UUID `json:"event_id" binding:"required"`
+		TicketTypeID uuid.UUID `json:"ticket_type_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entry, err := h.Service.Join(userID, req.EventID, req.TicketTypeID)
+	if err != nil {
+		respondCheckoutError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Joined waitlist successfully", entry)
+}
+
+func (h *WaitlistHandler) GetMyWaitlist(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	entries, err := h.Service.GetUserEntries(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch waitlist entries")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Waitlist entries fetched successfully", entries)
+}
+
+func (h *WaitlistHandler) LeaveWaitlist(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+	entryID, _ := uuid.Parse(c.Param("id"))
+
+	if err := h.Service.Leave(userID, entryID); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Left waitlist successfully", nil)
+}
+
+func (h *WaitlistHandler) ClaimOffer(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := uuid.Parse(userIDStr.(string))
+	entryID, _ := uuid.Parse(c.Param("id"))
+
+	answers, ok := bindAnswers(c)
+	if !ok {
+		return
+	}
+
+	registration, err := h.Service.Claim(userID, entryID, answers)
+	if err != nil {
+		respondCheckoutError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	h.Service.DB.Preload("Event").Preload("TicketType").Preload("Payment").First(registration, registration.ID)
+
+	if registration.Status == domain.RegistrationPendingPayment {
+		utils.SuccessResponse(c, http.StatusAccepted, "Registration awaiting payment", registration)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Registration confirmed", registration)
+}
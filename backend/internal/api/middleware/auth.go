@@ -1,18 +1,23 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/username/event-ticketing-system/internal/config"
 	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/service"
 	"github.com/username/event-ticketing-system/pkg/utils"
 )
 
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// AuthMiddleware accepts access tokens whose session has not been revoked,
+// so signing out or a change to the account takes effect immediately.
+func AuthMiddleware(cfg *config.Config, sessions *service.SessionService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -49,43 +54,94 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		userIDStr, _ := claims["user_id"].(string)
+		sessionIDStr, _ := claims["sid"].(string)
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token claims")
+			c.Abort()
+			return
+		}
+		sessionID, err := uuid.Parse(sessionIDStr)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token claims")
+			c.Abort()
+			return
+		}
+
+		active, err := sessions.IsActive(sessionID, userID)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check session")
+			c.Abort()
+			return
+		}
+		if !active {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Session has been signed out")
+			c.Abort()
+			return
+		}
+
 		c.Set("user_id", claims["user_id"])
 		c.Set("role", claims["role"])
+		c.Set("session_id", sessionIDStr)
+		c.Set("mfa", claims["mfa"] == true)
 		c.Next()
 	}
 }
 
-func RBACMiddleware(allowedRoles ...domain.UserRole) gin.HandlerFunc {
+// MFAEnrollmentMiddleware keeps admins who haven't enabled two-factor
+// authentication out when it is mandatory for them. They can still sign in,
+// so they can enroll.
+func MFAEnrollmentMiddleware(mfa *service.MFAService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		roleVal, exists := c.Get("role")
-		if !exists {
-			utils.ErrorResponse(c, http.StatusForbidden, "Authentication required")
-			c.Abort()
+		if !mfa.RequiredForAdmins || c.GetString("role") != string(domain.RoleAdmin) || c.GetBool("mfa") {
+			c.Next()
 			return
 		}
 
-		roleStr, ok := roleVal.(string)
-		if !ok {
-			utils.ErrorResponse(c, http.StatusForbidden, "Invalid role format")
+		utils.ErrorResponseWithCode(c, http.StatusForbidden, service.CodeMFAEnrollmentRequired, "Enable two-factor authentication to use admin features")
+		c.Abort()
+	}
+}
+
+// RequirePermission lets a request through when the caller's role grants p.
+func RequirePermission(p domain.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := domain.UserRole(c.GetString("role"))
+		if !role.Can(p) {
+			utils.ErrorResponse(c, http.StatusForbidden, "You do not have permission to access this resource")
 			c.Abort()
 			return
 		}
 
-		currentRole := domain.UserRole(roleStr)
-		isAllowed := false
-		for _, r := range allowedRoles {
-			if currentRole == r {
-				isAllowed = true
-				break
-			}
-		}
+		c.Next()
+	}
+}
 
-		if !isAllowed {
-			utils.ErrorResponse(c, http.StatusForbidden, "You do not have permission to access this resource")
+// RequireEventOwnership limits routes on the event in the :id parameter to
+// the event's organizer, unless the caller's role may manage any event.
+func RequireEventOwnership(access *service.EventAccessService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		eventID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusNotFound, service.ErrEventNotFound.Error())
 			c.Abort()
 			return
 		}
 
-		c.Next()
+		err = access.CheckOwnership(userID, domain.UserRole(c.GetString("role")), eventID)
+		switch {
+		case err == nil:
+			c.Next()
+			return
+		case errors.Is(err, service.ErrEventNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrNotEventOrganizer):
+			utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check event access")
+		}
+		c.Abort()
 	}
 }
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/service"
+	"github.com/username/event-ticketing-system/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// responseRecorder keeps a copy of everything the handler writes.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware makes a route safe to retry. When the request carries
+// an Idempotency-Key header, the first response is stored and replayed to any
+// retry with the same key and body; reusing the key with a different body is
+// rejected with 409. It must run after AuthMiddleware.
+func IdempotencyMiddleware(idempotency *service.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+			c.Abort()
+			return
+		}
+
+		userIDStr, _ := c.Get("user_id")
+		userID, err := uuid.Parse(userIDStr.(string))
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid user ID")
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read request body")
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record, replay, err := idempotency.Begin(userID, key, service.RequestFingerprint(c.Request.Method, c.Request.URL.Path, body))
+		if err != nil {
+			var coded *service.CodedError
+			if errors.As(err, &coded) {
+				utils.ErrorResponseWithCode(c, http.StatusConflict, coded.Code, coded.Message)
+			} else {
+				utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check Idempotency-Key")
+			}
+			c.Abort()
+			return
+		}
+		if replay {
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.StatusCode, "application/json; charset=utf-8", record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		defer func() {
+			if p := recover(); p != nil {
+				idempotency.Abandon(record)
+				panic(p)
+			}
+		}()
+		c.Next()
+
+		// Server errors are not final; let the client retry them with the same key
+		if status := recorder.Status(); status >= http.StatusInternalServerError {
+			if err := idempotency.Abandon(record); err != nil {
+				utils.Logger.Error("Failed to release idempotency key", zap.Error(err))
+			}
+		} else if err := idempotency.Complete(record, status, recorder.body.Bytes()); err != nil {
+			utils.Logger.Error("Failed to store idempotent response", zap.Error(err))
+		}
+	}
+}
@@ -4,25 +4,83 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Port           string
-	DBHost         string
-	DBPort         string
-	DBUser         string
-	DBPassword     string
-	DBName         string
-	DBSSLMode      string
-	JWTSecret      string
-	JWTExpiryHours int
-	SMTPHost       string
-	SMTPPort       string
-	SMTPUser       string
-	SMTPPass       string
-	EmailFrom      string
+	Port       string
+	AppURL     string
+	DBHost     string
+	DBPort     string
+	DBUser     string
+	DBPassword string
+	DBName     string
+	DBSSLMode  string
+	JWTSecret  string
+
+	// Access tokens are short-lived; sessions are kept alive by refresh
+	// tokens until RefreshTokenDays after their last use
+	AccessTokenMinutes int
+	RefreshTokenDays   int
+
+	// Email verification links expire after EmailVerificationHours and can be
+	// resent once every VerificationResendSeconds
+	EmailVerificationHours    int
+	VerificationResendSeconds int
+
+	// Second sign-in step for accounts with two-factor authentication;
+	// MFARequiredForAdmins keeps admins out of admin routes until they enroll
+	MFAIssuer            string
+	MFAChallengeMinutes  int
+	MFARequiredForAdmins bool
+
+	// OpenID Connect providers users can sign in with, and how long a
+	// sign-in started with one of them stays open
+	OIDCProviders    []OIDCProvider
+	OIDCStateMinutes int
+
+	SMTPHost  string
+	SMTPPort  string
+	SMTPUser  string
+	SMTPPass  string
+	EmailFrom string
+
+	TicketSigningKeys  string
+	TicketSigningKeyID string
+
+	WaitlistOfferMinutes int
+	HoldTTLMinutes       int
+	TransferOfferHours   int
+
+	QueueAdmitPerMinute   int
+	QueueAdmissionMinutes int
+
+	InventoryStrategy string
+	InventoryShards   int
+
+	IdempotencyKeyTTLHours     int
+	IdempotencyKeyLeaseSeconds int
+
+	ExportBatchSize int
+	ImportBatchSize int
+
+	PaymentProvider       string
+	PaymentWebhookSecret  string
+	PaymentCurrency       string
+	PaymentTimeoutMinutes int
+}
+
+// OIDCProvider is one OpenID Connect provider, configured through
+// OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET and
+// optionally OIDC_<NAME>_SCOPES for each name listed in OIDC_PROVIDERS.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
 }
 
 func LoadConfig() *Config {
@@ -32,20 +90,58 @@ func LoadConfig() *Config {
 	}
 
 	return &Config{
-		Port:           getEnv("PORT", "8080"),
-		DBHost:         getEnv("DB_HOST", "localhost"),
-		DBPort:         getEnv("DB_PORT", "5432"),
-		DBUser:         getEnv("DB_USER", "postgres"),
-		DBPassword:     getEnv("DB_PASSWORD", "postgres"),
-		DBName:         getEnv("DB_NAME", "event_ticketing"),
-		DBSSLMode:      getEnv("DB_SSLMODE", "disable"),
-		JWTSecret:      getEnv("JWT_SECRET", "secret"),
-		JWTExpiryHours: getEnvAsInt("JWT_EXPIRY_HOURS", 24),
-		SMTPHost:       getEnv("SMTP_HOST", ""),
-		SMTPPort:       getEnv("SMTP_PORT", ""),
-		SMTPUser:       getEnv("SMTP_USER", ""),
-		SMTPPass:       getEnv("SMTP_PASS", ""),
-		EmailFrom:      getEnv("EMAIL_FROM", "noreply@event-ticketing.com"),
+		Port:       getEnv("PORT", "8080"),
+		AppURL:     getEnv("APP_URL", "http://localhost:8080"),
+		DBHost:     getEnv("DB_HOST", "localhost"),
+		DBPort:     getEnv("DB_PORT", "5432"),
+		DBUser:     getEnv("DB_USER", "postgres"),
+		DBPassword: getEnv("DB_PASSWORD", "postgres"),
+		DBName:     getEnv("DB_NAME", "event_ticketing"),
+		DBSSLMode:  getEnv("DB_SSLMODE", "disable"),
+		JWTSecret:  getEnv("JWT_SECRET", "secret"),
+
+		AccessTokenMinutes: getEnvAsInt("ACCESS_TOKEN_MINUTES", 15),
+		RefreshTokenDays:   getEnvAsInt("REFRESH_TOKEN_DAYS", 30),
+
+		EmailVerificationHours:    getEnvAsInt("EMAIL_VERIFICATION_HOURS", 24),
+		VerificationResendSeconds: getEnvAsInt("VERIFICATION_RESEND_SECONDS", 60),
+
+		MFAIssuer:            getEnv("MFA_ISSUER", "Event Ticketing"),
+		MFAChallengeMinutes:  getEnvAsInt("MFA_CHALLENGE_MINUTES", 5),
+		MFARequiredForAdmins: getEnvAsBool("MFA_REQUIRED_FOR_ADMINS", false),
+
+		OIDCProviders:    loadOIDCProviders(),
+		OIDCStateMinutes: getEnvAsInt("OIDC_STATE_MINUTES", 10),
+
+		SMTPHost:  getEnv("SMTP_HOST", ""),
+		SMTPPort:  getEnv("SMTP_PORT", ""),
+		SMTPUser:  getEnv("SMTP_USER", ""),
+		SMTPPass:  getEnv("SMTP_PASS", ""),
+		EmailFrom: getEnv("EMAIL_FROM", "noreply@event-ticketing.com"),
+
+		TicketSigningKeys:  getEnv("TICKET_SIGNING_KEYS", ""),
+		TicketSigningKeyID: getEnv("TICKET_SIGNING_KEY_ID", ""),
+
+		WaitlistOfferMinutes: getEnvAsInt("WAITLIST_OFFER_MINUTES", 30),
+		HoldTTLMinutes:       getEnvAsInt("HOLD_TTL_MINUTES", 10),
+		TransferOfferHours:   getEnvAsInt("TRANSFER_OFFER_HOURS", 48),
+
+		QueueAdmitPerMinute:   getEnvAsInt("QUEUE_ADMIT_PER_MINUTE", 100),
+		QueueAdmissionMinutes: getEnvAsInt("QUEUE_ADMISSION_MINUTES", 10),
+
+		InventoryStrategy: getEnv("INVENTORY_STRATEGY", "sharded"),
+		InventoryShards:   getEnvAsInt("INVENTORY_SHARDS", 8),
+
+		IdempotencyKeyTTLHours:     getEnvAsInt("IDEMPOTENCY_KEY_TTL_HOURS", 24),
+		IdempotencyKeyLeaseSeconds: getEnvAsInt("IDEMPOTENCY_KEY_LEASE_SECONDS", 60),
+
+		ExportBatchSize: getEnvAsInt("EXPORT_BATCH_SIZE", 500),
+		ImportBatchSize: getEnvAsInt("IMPORT_BATCH_SIZE", 100),
+
+		PaymentProvider:       getEnv("PAYMENT_PROVIDER", "fake"),
+		PaymentWebhookSecret:  getEnv("PAYMENT_WEBHOOK_SECRET", "whsec_dev"),
+		PaymentCurrency:       getEnv("PAYMENT_CURRENCY", "USD"),
+		PaymentTimeoutMinutes: getEnvAsInt("PAYMENT_TIMEOUT_MINUTES", 15),
 	}
 }
 
@@ -63,3 +159,36 @@ func getEnvAsInt(name string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(name string, defaultValue bool) bool {
+	valueStr := getEnv(name, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func loadOIDCProviders() []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProvider{
+			Name:         name,
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			log.Printf("Warning: OIDC provider %q needs %sISSUER and %sCLIENT_ID, skipping it", name, prefix, prefix)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
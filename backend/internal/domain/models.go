@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,8 +11,10 @@ import (
 type UserRole string
 
 const (
-	RoleAdmin UserRole = "admin"
-	RoleUser  UserRole = "user"
+	RoleAdmin     UserRole = "admin"
+	RoleOrganizer UserRole = "organizer"
+	RoleStaff     UserRole = "staff"
+	RoleUser      UserRole = "user"
 )
 
 type User struct {
@@ -25,6 +28,18 @@ type User struct {
 	CreatedAt            time.Time
 	UpdatedAt            time.Time
 	DeletedAt            gorm.DeletedAt `gorm:"index"`
+	// EmailVerifiedAt is nil until the owner follows their verification
+	// link; unverified accounts cannot register for events
+	EmailVerifiedAt    *time.Time
+	VerificationSentAt *time.Time
+	// MFASecret is set when enrollment starts; two-factor authentication is
+	// on once MFAEnabledAt is. MFALastStep is the last TOTP step accepted, so
+	// a code can't be used twice
+	MFASecret         string `json:"-"`
+	MFAEnabledAt      *time.Time
+	MFALastStep       int64      `json:"-"`
+	MFAFailedAttempts int        `json:"-"`
+	MFALockedUntil    *time.Time `json:"-"`
 }
 
 type EventStatus string
@@ -36,14 +51,16 @@ const (
 )
 
 type Event struct {
-	ID               uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	Title            string         `gorm:"not null" json:"title"`
-	Description      string         `json:"description"`
-	Category         string         `gorm:"type:string;index" json:"category"`
-	Status           EventStatus    `gorm:"type:string;default:draft;index" json:"status"`
-	StartTime        time.Time      `gorm:"not null" json:"start_time"`
-	TotalTickets     int            `gorm:"not null" binding:"required,gt=0" json:"total_tickets"`
-	RemainingTickets int            `gorm:"not null" json:"remaining_tickets"`
+	ID           uuid.UUID   `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Title        string      `gorm:"not null" json:"title"`
+	Description  string      `json:"description"`
+	Category     string      `gorm:"type:string;index" json:"category"`
+	Status       EventStatus `gorm:"type:string;default:draft;index" json:"status"`
+	StartTime    time.Time   `gorm:"not null" json:"start_time"`
+	TotalTickets int         `gorm:"not null" binding:"required,gt=0" json:"total_tickets"`
+	// RemainingTickets is derived from the ticket types' stock shards and only
+	// filled when queried with the service's remaining-tickets scopes
+	RemainingTickets int            `gorm:"->;-:migration" json:"remaining_tickets"`
 	Price            float64        `gorm:"not null" binding:"required,min=0" json:"price"`
 	ImageURL         string         `json:"image_url"`
 	CreatedAt        time.Time      `json:"created_at"`
@@ -51,42 +68,299 @@ type Event struct {
 	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
 	TicketTypes      []TicketType   `gorm:"foreignKey:EventID" json:"ticket_types,omitempty"`
 	ReminderSent     bool           `gorm:"default:false" json:"reminder_sent,omitempty"`
+	// Refund policy: cancellations more than RefundFullHoursBefore hours before
+	// the start get a full refund, later ones RefundPartialPercent, and none
+	// once the event has started.
+	RefundFullHoursBefore int `gorm:"not null;default:24" json:"refund_full_hours_before"`
+	RefundPartialPercent  int `gorm:"not null;default:50" json:"refund_partial_percent"`
+	// Reserved seating: when set, ticket types with a SeatZone sell seats from this map
+	SeatMapID *uuid.UUID `gorm:"type:uuid;index" json:"seat_map_id,omitempty"`
+	// Waiting room: checkouts need an admitted queue token; admission runs at
+	// QueueAdmitPerMinute, or the configured default when 0
+	WaitingRoom         bool `gorm:"not null;default:false" json:"waiting_room"`
+	QueueAdmitPerMinute int  `gorm:"not null;default:0" json:"queue_admit_per_minute,omitempty"`
+	// Resale: when enabled, owners may list tickets for at most face value plus
+	// ResaleMaxMarkupPercent, and the organizer keeps ResaleFeePercent of each sale
+	ResaleEnabled          bool `gorm:"not null;default:false" json:"resale_enabled"`
+	ResaleMaxMarkupPercent int  `gorm:"not null;default:0" json:"resale_max_markup_percent"`
+	ResaleFeePercent       int  `gorm:"not null;default:0" json:"resale_fee_percent"`
+	// OrganizerID is who created the event; organizers manage only their own
+	// events. Events from before ownership have none and only admins manage them
+	OrganizerID *uuid.UUID `gorm:"type:uuid;index" json:"organizer_id,omitempty"`
 }
 
+// Defaults applied to new events that don't specify a refund policy. They
+// match the column defaults in the schema.
+const (
+	DefaultRefundFullHoursBefore = 24
+	DefaultRefundPartialPercent  = 50
+)
+
 type TicketType struct {
-	ID               uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	EventID          uuid.UUID      `gorm:"type:uuid;not null;index" json:"event_id"`
-	Name             string         `gorm:"not null" json:"name"`
-	Price            float64        `gorm:"not null" binding:"required,min=0" json:"price"`
-	Capacity         int            `gorm:"not null" binding:"required,gt=0" json:"capacity"`
-	RemainingTickets int            `gorm:"not null" json:"remaining_tickets"`
-	CreatedAt        time.Time      `json:"created_at"`
-	UpdatedAt        time.Time      `json:"updated_at"`
-	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+	ID               uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	EventID          uuid.UUID `gorm:"type:uuid;not null;index" json:"event_id"`
+	Name             string    `gorm:"not null" json:"name"`
+	Price            float64   `gorm:"not null" binding:"required,min=0" json:"price"`
+	Capacity         int       `gorm:"not null" binding:"required,gt=0" json:"capacity"`
+	RemainingTickets int       `gorm:"->;-:migration" json:"remaining_tickets"`
+	// Sales window and purchase limits; nil bounds and zero limits are unrestricted
+	SalesStart  *time.Time `json:"sales_start,omitempty"`
+	SalesEnd    *time.Time `json:"sales_end,omitempty"`
+	MinPerOrder int        `gorm:"not null;default:0" binding:"omitempty,min=0" json:"min_per_order"`
+	MaxPerOrder int        `gorm:"not null;default:0" binding:"omitempty,min=0" json:"max_per_order"`
+	MaxPerUser  int        `gorm:"not null;default:0" binding:"omitempty,min=0" json:"max_per_user"`
+	// SeatZone binds the ticket type to the seats of one zone on the event's
+	// seat map; empty means general admission
+	SeatZone  string         `json:"seat_zone,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TicketTypeShard is one counter of a ticket type's stock. Spreading stock over
+// several rows lets concurrent checkouts take seats without queueing on a
+// single row lock.
+type TicketTypeShard struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	TicketTypeID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_ticket_type_shard" json:"ticket_type_id"`
+	Shard        int       `gorm:"not null;uniqueIndex:idx_ticket_type_shard" json:"shard"`
+	Remaining    int       `gorm:"not null" json:"remaining"`
 }
 
 type RegistrationStatus string
 
 const (
-	RegistrationConfirmed RegistrationStatus = "confirmed"
-	RegistrationCancelled RegistrationStatus = "cancelled"
-	RegistrationRSVPYes   RegistrationStatus = "rsvp_yes"
-	RegistrationRSVPNo    RegistrationStatus = "rsvp_no"
-	RegistrationRSVPMaybe RegistrationStatus = "rsvp_maybe"
+	RegistrationPendingPayment RegistrationStatus = "pending_payment"
+	RegistrationConfirmed      RegistrationStatus = "confirmed"
+	RegistrationCancelled      RegistrationStatus = "cancelled"
+	RegistrationRSVPYes        RegistrationStatus = "rsvp_yes"
+	RegistrationRSVPNo         RegistrationStatus = "rsvp_no"
+	RegistrationRSVPMaybe      RegistrationStatus = "rsvp_maybe"
 )
 
 type Registration struct {
-	ID           uuid.UUID          `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	UserID       uuid.UUID          `gorm:"type:uuid;not null;index" json:"user_id"`
-	EventID      uuid.UUID          `gorm:"type:uuid;not null;index" json:"event_id"`
-	TicketTypeID uuid.UUID          `gorm:"type:uuid;not null;index" json:"ticket_type_id"`
-	Status       RegistrationStatus `gorm:"type:string;default:confirmed" json:"status"`
-	CreatedAt    time.Time          `json:"created_at"`
-	UpdatedAt    time.Time          `json:"updated_at"`
-	User         User               `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Event        Event              `gorm:"foreignKey:EventID" json:"event,omitempty"`
-	TicketType   TicketType         `gorm:"foreignKey:TicketTypeID" json:"ticket_type,omitempty"`
-	Ticket       *Ticket            `gorm:"foreignKey:RegistrationID" json:"ticket,omitempty"`
+	ID uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	// UserID is nil for a guest ticket held only by AttendeeName and
+	// AttendeeEmail until the guest claims it
+	UserID        *uuid.UUID           `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	EventID       uuid.UUID            `gorm:"type:uuid;not null;index" json:"event_id"`
+	TicketTypeID  uuid.UUID            `gorm:"type:uuid;not null;index" json:"ticket_type_id"`
+	OrderID       *uuid.UUID           `gorm:"type:uuid;index" json:"order_id,omitempty"`
+	AttendeeName  string               `json:"attendee_name,omitempty"`
+	AttendeeEmail string               `json:"attendee_email,omitempty"`
+	Amount        float64              `gorm:"not null;default:0" json:"amount"`
+	Discount      float64              `gorm:"not null;default:0" json:"discount,omitempty"`
+	PaymentID     *uuid.UUID           `gorm:"type:uuid;index" json:"payment_id,omitempty"`
+	SeatID        *uuid.UUID           `gorm:"type:uuid;index" json:"seat_id,omitempty"`
+	Status        RegistrationStatus   `gorm:"type:string;default:confirmed" json:"status"`
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+	User          *User                `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Event         Event                `gorm:"foreignKey:EventID" json:"event,omitempty"`
+	TicketType    TicketType           `gorm:"foreignKey:TicketTypeID" json:"ticket_type,omitempty"`
+	Ticket        *Ticket              `gorm:"foreignKey:RegistrationID" json:"ticket,omitempty"`
+	Payment       *Payment             `gorm:"foreignKey:PaymentID" json:"payment,omitempty"`
+	Seat          *Seat                `gorm:"foreignKey:SeatID" json:"seat,omitempty"`
+	Answers       []RegistrationAnswer `gorm:"foreignKey:RegistrationID" json:"answers,omitempty"`
+}
+
+// ContactEmail is where notices about a registration go: the owning account,
+// or the attendee for a guest ticket.
+func (r *Registration) ContactEmail() string {
+	if r.User != nil && r.User.Email != "" {
+		return r.User.Email
+	}
+	return r.AttendeeEmail
+}
+
+type ClaimStatus string
+
+const (
+	ClaimPending   ClaimStatus = "pending"
+	ClaimClaimed   ClaimStatus = "claimed"
+	ClaimCancelled ClaimStatus = "cancelled"
+)
+
+// TicketClaim is a single-use link sent with a ticket transferred to an email
+// that has no account. The guest ticket is valid without it; claiming only
+// attaches the registration to the account that follows the link.
+type TicketClaim struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	RegistrationID uuid.UUID    `gorm:"type:uuid;not null;index" json:"registration_id"`
+	Email          string       `gorm:"not null;index" json:"email"`
+	Name           string       `json:"name,omitempty"`
+	Token          string       `gorm:"not null;uniqueIndex" json:"-"`
+	Status         ClaimStatus  `gorm:"type:string;default:pending" json:"status"`
+	ClaimedBy      *uuid.UUID   `gorm:"type:uuid" json:"claimed_by,omitempty"`
+	ClaimedAt      *time.Time   `json:"claimed_at,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	Registration   Registration `gorm:"foreignKey:RegistrationID" json:"registration,omitempty"`
+}
+
+type TransferStatus string
+
+const (
+	TransferPending  TransferStatus = "pending"
+	TransferAccepted TransferStatus = "accepted"
+	TransferDeclined TransferStatus = "declined"
+	TransferRevoked  TransferStatus = "revoked"
+	TransferExpired  TransferStatus = "expired"
+)
+
+// TicketTransfer is an offer to hand a registration to another person. The
+// sender keeps the ticket until the recipient accepts through the emailed
+// token; a registration has at most one pending offer.
+type TicketTransfer struct {
+	ID             uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	RegistrationID uuid.UUID      `gorm:"type:uuid;not null;index" json:"registration_id"`
+	SenderID       uuid.UUID      `gorm:"type:uuid;not null;index" json:"sender_id"`
+	RecipientEmail string         `gorm:"not null;index" json:"recipient_email"`
+	RecipientName  string         `json:"recipient_name,omitempty"`
+	RecipientID    *uuid.UUID     `gorm:"type:uuid" json:"recipient_id,omitempty"`
+	Token          string         `gorm:"not null;uniqueIndex" json:"-"`
+	Status         TransferStatus `gorm:"type:string;default:pending;index" json:"status"`
+	ExpiresAt      time.Time      `gorm:"not null;index" json:"expires_at"`
+	RespondedAt    *time.Time     `json:"responded_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	Registration   *Registration  `gorm:"foreignKey:RegistrationID" json:"registration,omitempty"`
+}
+
+type ResaleStatus string
+
+const (
+	ResaleActive         ResaleStatus = "active"
+	ResalePendingPayment ResaleStatus = "pending_payment"
+	ResaleSold           ResaleStatus = "sold"
+	ResaleWithdrawn      ResaleStatus = "withdrawn"
+	ResaleCancelled      ResaleStatus = "cancelled"
+)
+
+// ResaleListing offers a confirmed registration for sale to other users. While
+// a buyer's payment is pending the listing is held for them; ownership only
+// moves once that payment succeeds. A registration has at most one open
+// listing.
+type ResaleListing struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	RegistrationID uuid.UUID `gorm:"type:uuid;not null;index" json:"registration_id"`
+	EventID        uuid.UUID `gorm:"type:uuid;not null;index" json:"event_id"`
+	SellerID       uuid.UUID `gorm:"type:uuid;not null;index" json:"seller_id"`
+	Price          float64   `gorm:"not null" json:"price"`
+	// Fee is the organizer's cut of Price; the seller is owed the rest
+	Fee            float64      `gorm:"not null;default:0" json:"fee"`
+	SellerProceeds float64      `gorm:"not null;default:0" json:"seller_proceeds"`
+	Status         ResaleStatus `gorm:"type:string;default:active;index" json:"status"`
+	BuyerID        *uuid.UUID   `gorm:"type:uuid;index" json:"buyer_id,omitempty"`
+	PaymentID      *uuid.UUID   `gorm:"type:uuid;index" json:"payment_id,omitempty"`
+	// BuyerAnswers holds the buyer's validated answers to the registration
+	// form, keyed by question ID, until the sale replaces the seller's
+	BuyerAnswers json.RawMessage `gorm:"type:jsonb" json:"-"`
+	SoldAt       *time.Time      `json:"sold_at,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	Registration *Registration   `gorm:"foreignKey:RegistrationID" json:"registration,omitempty"`
+	Payment      *Payment        `gorm:"foreignKey:PaymentID" json:"payment,omitempty"`
+}
+
+// SeatMap is a venue layout that reserved-seating events can share.
+type SeatMap struct {
+	ID        uuid.UUID     `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Name      string        `gorm:"not null" json:"name"`
+	Venue     string        `json:"venue,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Sections  []SeatSection `gorm:"foreignKey:SeatMapID" json:"sections,omitempty"`
+}
+
+type SeatSection struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	SeatMapID uuid.UUID `gorm:"type:uuid;not null;index" json:"seat_map_id"`
+	Name      string    `gorm:"not null" json:"name"`
+	Position  int       `gorm:"not null;default:0" json:"position"`
+	Seats     []Seat    `gorm:"foreignKey:SectionID" json:"seats,omitempty"`
+}
+
+type Seat struct {
+	ID         uuid.UUID    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	SeatMapID  uuid.UUID    `gorm:"type:uuid;not null;index:idx_seats_zone,priority:1" json:"seat_map_id"`
+	SectionID  uuid.UUID    `gorm:"type:uuid;not null;index" json:"section_id"`
+	Row        string       `gorm:"not null" json:"row"`
+	Number     int          `gorm:"not null" json:"number"`
+	Zone       string       `gorm:"not null;index:idx_seats_zone,priority:2" json:"zone"`
+	Accessible bool         `gorm:"not null;default:false" json:"accessible"`
+	Section    *SeatSection `gorm:"foreignKey:SectionID" json:"section,omitempty"`
+}
+
+type HoldStatus string
+
+const (
+	HoldActive    HoldStatus = "active"
+	HoldConfirmed HoldStatus = "confirmed"
+	HoldReleased  HoldStatus = "released"
+	HoldExpired   HoldStatus = "expired"
+)
+
+type SeatHold struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	EventID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"event_id"`
+	TicketTypeID uuid.UUID  `gorm:"type:uuid;not null;index" json:"ticket_type_id"`
+	Quantity     int        `gorm:"not null" json:"quantity"`
+	Status       HoldStatus `gorm:"type:string;default:active;index:idx_seat_holds_expiry,priority:1" json:"status"`
+	ExpiresAt    time.Time  `gorm:"not null;index:idx_seat_holds_expiry,priority:2" json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	Event        Event      `gorm:"foreignKey:EventID" json:"event,omitempty"`
+	TicketType   TicketType `gorm:"foreignKey:TicketTypeID" json:"ticket_type,omitempty"`
+}
+
+type QueueStatus string
+
+const (
+	QueueWaiting  QueueStatus = "waiting"
+	QueueAdmitted QueueStatus = "admitted"
+	QueueUsed     QueueStatus = "used"
+	QueueExpired  QueueStatus = "expired"
+)
+
+// QueueTicket is a user's place in an event's waiting room. Once admitted the
+// token allows one checkout until ExpiresAt. A user has at most one waiting or
+// admitted ticket per event (idx_queue_tickets_active, created by migration).
+type QueueTicket struct {
+	ID         uuid.UUID   `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	EventID    uuid.UUID   `gorm:"type:uuid;not null;index:idx_queue_tickets_order,priority:1" json:"event_id"`
+	UserID     uuid.UUID   `gorm:"type:uuid;not null;index" json:"user_id"`
+	Token      string      `gorm:"not null;uniqueIndex" json:"token"`
+	Status     QueueStatus `gorm:"type:string;default:waiting;index:idx_queue_tickets_order,priority:2" json:"status"`
+	AdmittedAt *time.Time  `gorm:"index" json:"admitted_at,omitempty"`
+	ExpiresAt  *time.Time  `json:"expires_at,omitempty"`
+	CreatedAt  time.Time   `gorm:"index:idx_queue_tickets_order,priority:3" json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+type WaitlistStatus string
+
+const (
+	WaitlistWaiting WaitlistStatus = "waiting"
+	WaitlistOffered WaitlistStatus = "offered"
+	WaitlistClaimed WaitlistStatus = "claimed"
+	WaitlistExpired WaitlistStatus = "expired"
+	WaitlistLeft    WaitlistStatus = "left"
+)
+
+type WaitlistEntry struct {
+	ID             uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID         uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	EventID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"event_id"`
+	TicketTypeID   uuid.UUID      `gorm:"type:uuid;not null;index:idx_waitlist_queue,priority:1" json:"ticket_type_id"`
+	Status         WaitlistStatus `gorm:"type:string;default:waiting;index:idx_waitlist_queue,priority:2" json:"status"`
+	OfferExpiresAt *time.Time     `json:"offer_expires_at,omitempty"`
+	CreatedAt      time.Time      `gorm:"index:idx_waitlist_queue,priority:3" json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	User           User           `gorm:"foreignKey:UserID" json:"-"`
+	Event          Event          `gorm:"foreignKey:EventID" json:"event,omitempty"`
+	TicketType     TicketType     `gorm:"foreignKey:TicketTypeID" json:"ticket_type,omitempty"`
 }
 
 type Ticket struct {
@@ -99,6 +373,203 @@ type Ticket struct {
 	Registration   Registration `gorm:"foreignKey:RegistrationID" json:"registration,omitempty"`
 }
 
+type DiscountType string
+
+const (
+	DiscountPercentage DiscountType = "percentage"
+	DiscountFixed      DiscountType = "fixed"
+)
+
+// PromoCode discounts seats of an event. With no TicketTypes it applies to
+// every tier; zero usage caps mean unlimited and nil bounds mean open-ended.
+type PromoCode struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	EventID        uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_promo_codes_event_code" json:"event_id"`
+	Code           string       `gorm:"not null;uniqueIndex:idx_promo_codes_event_code" json:"code"`
+	DiscountType   DiscountType `gorm:"type:string;not null" json:"discount_type"`
+	Value          float64      `gorm:"not null" json:"value"`
+	MaxUses        int          `gorm:"not null;default:0" json:"max_uses"`
+	MaxUsesPerUser int          `gorm:"not null;default:0" json:"max_uses_per_user"`
+	UsedCount      int          `gorm:"not null;default:0" json:"used_count"`
+	ValidFrom      *time.Time   `json:"valid_from,omitempty"`
+	ValidUntil     *time.Time   `json:"valid_until,omitempty"`
+	Stackable      bool         `gorm:"default:false" json:"stackable"`
+	Active         bool         `gorm:"default:true" json:"active"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	TicketTypes    []TicketType `gorm:"many2many:promo_code_ticket_types" json:"ticket_types,omitempty"`
+}
+
+type QuestionType string
+
+const (
+	QuestionText        QuestionType = "text"
+	QuestionSelect      QuestionType = "select"
+	QuestionMultiSelect QuestionType = "multi_select"
+	QuestionCheckbox    QuestionType = "checkbox"
+	QuestionNumber      QuestionType = "number"
+)
+
+// RegistrationQuestion is one field of an event's registration form. With a
+// TicketTypeID it is only asked of that tier. Options lists the choices of
+// select and multi-select questions; a required checkbox must be ticked.
+type RegistrationQuestion struct {
+	ID           uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	EventID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"event_id"`
+	TicketTypeID *uuid.UUID     `gorm:"type:uuid;index" json:"ticket_type_id,omitempty"`
+	Label        string         `gorm:"not null" json:"label"`
+	Type         QuestionType   `gorm:"type:string;not null" json:"type"`
+	Options      []string       `gorm:"type:jsonb;serializer:json" json:"options,omitempty"`
+	Required     bool           `gorm:"not null;default:false" json:"required"`
+	Position     int            `gorm:"not null;default:0" json:"position"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// RegistrationAnswer is an attendee's answer to one question, stored as the
+// JSON value that was validated against the question's type.
+type RegistrationAnswer struct {
+	ID             uuid.UUID             `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	RegistrationID uuid.UUID             `gorm:"type:uuid;not null;uniqueIndex:idx_registration_answer" json:"registration_id"`
+	QuestionID     uuid.UUID             `gorm:"type:uuid;not null;uniqueIndex:idx_registration_answer" json:"question_id"`
+	Value          json.RawMessage       `gorm:"type:jsonb;not null" json:"value"`
+	CreatedAt      time.Time             `json:"created_at"`
+	Question       *RegistrationQuestion `gorm:"foreignKey:QuestionID" json:"question,omitempty"`
+}
+
+// PromoRedemption records one use of a promo code on a registration. The use
+// is released, and no longer counts against the code's limits, when the
+// registration is cancelled or its payment fails.
+type PromoRedemption struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	PromoCodeID    uuid.UUID  `gorm:"type:uuid;not null;index:idx_promo_redemptions_user,priority:1" json:"promo_code_id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;index:idx_promo_redemptions_user,priority:2" json:"user_id"`
+	RegistrationID uuid.UUID  `gorm:"type:uuid;not null;index" json:"registration_id"`
+	Discount       float64    `gorm:"not null" json:"discount"`
+	ReleasedAt     *time.Time `json:"released_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type PaymentStatus string
+
+const (
+	PaymentPending   PaymentStatus = "pending"
+	PaymentSucceeded PaymentStatus = "succeeded"
+	PaymentFailed    PaymentStatus = "failed"
+	// PaymentRefunded marks a charge that went through after the payment had
+	// already failed or expired, and was refunded in full.
+	PaymentRefunded PaymentStatus = "refunded"
+)
+
+// Payment tracks one provider charge. It may cover a single registration or
+// every seat of an order; those rows point back at it through PaymentID.
+type Payment struct {
+	ID            uuid.UUID     `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID        uuid.UUID     `gorm:"type:uuid;not null;index" json:"user_id"`
+	Provider      string        `gorm:"not null" json:"provider"`
+	IntentID      *string       `gorm:"uniqueIndex" json:"intent_id,omitempty"`
+	ClientSecret  string        `json:"client_secret,omitempty"`
+	Amount        float64       `gorm:"not null" json:"amount"`
+	Currency      string        `gorm:"not null" json:"currency"`
+	Status        PaymentStatus `gorm:"type:string;default:pending;index" json:"status"`
+	FailureReason string        `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+type RefundStatus string
+
+const (
+	RefundPending   RefundStatus = "pending"
+	RefundSucceeded RefundStatus = "succeeded"
+	RefundFailed    RefundStatus = "failed"
+)
+
+type RefundReason string
+
+const (
+	RefundCancellation     RefundReason = "cancellation"
+	RefundLatePayment      RefundReason = "late_payment"
+	RefundResaleUnsellable RefundReason = "resale_unsellable"
+)
+
+// Refund is money owed back to a buyer. It is recorded as pending in the same
+// transaction as the change that owes it and sent to the provider once that
+// commits; a background job retries the ones that did not go through.
+type Refund struct {
+	ID               uuid.UUID    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	RegistrationID   *uuid.UUID   `gorm:"type:uuid;uniqueIndex:idx_refunds_cancellation,where:reason = 'cancellation'" json:"registration_id,omitempty"`
+	PaymentID        uuid.UUID    `gorm:"type:uuid;not null;index" json:"payment_id"`
+	UserID           uuid.UUID    `gorm:"type:uuid;not null;index" json:"user_id"`
+	EventID          *uuid.UUID   `gorm:"type:uuid;index" json:"event_id,omitempty"`
+	Amount           float64      `gorm:"not null" json:"amount"`
+	Percent          int          `gorm:"not null" json:"percent"`
+	Reason           RefundReason `gorm:"type:string;not null" json:"reason"`
+	Status           RefundStatus `gorm:"type:string;default:pending;index" json:"status"`
+	Attempts         int          `gorm:"not null;default:0" json:"attempts"`
+	LastError        string       `json:"last_error,omitempty"`
+	ProviderRefundID string       `json:"provider_refund_id,omitempty"`
+	RefundedAt       *time.Time   `json:"refunded_at,omitempty"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+}
+
+type OrderStatus string
+
+const (
+	OrderPendingPayment OrderStatus = "pending_payment"
+	OrderConfirmed      OrderStatus = "confirmed"
+	OrderCancelled      OrderStatus = "cancelled"
+)
+
+// Order groups several seats, possibly across ticket types of one event, that
+// were bought together. Each seat is still its own Registration and Ticket.
+type Order struct {
+	ID            uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	EventID       uuid.UUID      `gorm:"type:uuid;not null;index" json:"event_id"`
+	Status        OrderStatus    `gorm:"type:string;default:confirmed" json:"status"`
+	TotalAmount   float64        `gorm:"not null" json:"total_amount"`
+	PaymentID     *uuid.UUID     `gorm:"type:uuid;index" json:"payment_id,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Event         Event          `gorm:"foreignKey:EventID" json:"event,omitempty"`
+	Payment       *Payment       `gorm:"foreignKey:PaymentID" json:"payment,omitempty"`
+	Items         []OrderItem    `gorm:"foreignKey:OrderID" json:"items,omitempty"`
+	Registrations []Registration `gorm:"foreignKey:OrderID" json:"registrations,omitempty"`
+}
+
+type OrderItem struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	OrderID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	TicketTypeID uuid.UUID  `gorm:"type:uuid;not null" json:"ticket_type_id"`
+	Quantity     int        `gorm:"not null" json:"quantity"`
+	UnitPrice    float64    `gorm:"not null" json:"unit_price"`
+	TicketType   TicketType `gorm:"foreignKey:TicketTypeID" json:"ticket_type,omitempty"`
+}
+
+type CheckIn struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	TicketID       uuid.UUID `gorm:"type:uuid;not null;index" json:"ticket_id"`
+	RegistrationID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"registration_id"`
+	EventID        uuid.UUID `gorm:"type:uuid;not null;index" json:"event_id"`
+	ScannedBy      uuid.UUID `gorm:"type:uuid;not null" json:"scanned_by"`
+	Gate           string    `json:"gate"`
+	DeviceID       string    `json:"device_id,omitempty"`
+	Offline        bool      `gorm:"default:false" json:"offline"`
+	CheckedInAt    time.Time `gorm:"not null" json:"checked_in_at"`
+}
+
+type TicketRevocation struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	TicketID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"ticket_id"`
+	EventID    uuid.UUID `gorm:"type:uuid;not null;index" json:"event_id"`
+	TicketCode string    `gorm:"not null" json:"-"`
+	Reason     string    `gorm:"not null" json:"reason"`
+	RevokedAt  time.Time `gorm:"not null" json:"revoked_at"`
+}
+
 type AuditLog struct {
 	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
 	UserID     uuid.UUID `gorm:"type:uuid;index"`
@@ -120,6 +591,72 @@ func (a *AuditLog) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+// IdempotencyKey records a client-supplied Idempotency-Key and the response
+// it produced, so a retried request is answered without running twice. A
+// zero StatusCode means the first request is still in flight, holding the key
+// until LockedUntil.
+type IdempotencyKey struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID       uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_idempotency_user_key"`
+	Key          string    `gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	RequestHash  string    `gorm:"not null"`
+	StatusCode   int       `gorm:"not null;default:0"`
+	ResponseBody []byte
+	LockedUntil  *time.Time
+	CreatedAt    time.Time `gorm:"index"`
+}
+
+// Session is one signed-in device. Access tokens name their session, so
+// revoking it signs the device out at once. The refresh token rotates on every
+// use and only SHA-256 hashes are stored; presenting the previous token again
+// means it leaked, and revokes the session.
+type Session struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID            uuid.UUID  `gorm:"type:uuid;not null;index" json:"-"`
+	RefreshTokenHash  string     `gorm:"not null;uniqueIndex" json:"-"`
+	PreviousTokenHash string     `gorm:"index" json:"-"`
+	UserAgent         string     `json:"user_agent,omitempty"`
+	IPAddress         string     `json:"ip_address,omitempty"`
+	ExpiresAt         time.Time  `gorm:"not null;index" json:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// MFARecoveryCode is a one-use code that stands in for a TOTP code when the
+// authenticator device is lost. Only SHA-256 hashes are stored.
+type MFARecoveryCode struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	CodeHash  string    `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// UserIdentity links a user to their account at an OpenID Connect provider,
+// identified by the provider's subject.
+type UserIdentity struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"-"`
+	Provider  string    `gorm:"not null;uniqueIndex:idx_user_identities_provider_subject" json:"provider"`
+	Subject   string    `gorm:"not null;uniqueIndex:idx_user_identities_provider_subject" json:"-"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProviderLoginState is a sign-in in progress at a provider. It holds the PKCE
+// verifier and nonce until the provider redirects back with the state, whose
+// SHA-256 hash is stored.
+type ProviderLoginState struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	Provider     string    `gorm:"not null"`
+	StateHash    string    `gorm:"not null;uniqueIndex"`
+	CodeVerifier string    `gorm:"not null"`
+	Nonce        string    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	CreatedAt    time.Time
+}
+
 type Feedback struct {
 	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
 	EventID   uuid.UUID `gorm:"type:uuid;not null;index"`
@@ -0,0 +1,70 @@
+package domain
+
+// Permission is one thing a role may do through the management API. Routes
+// require a permission rather than a role, so roles can be given exactly the
+// access they need.
+type Permission string
+
+const (
+	PermEventCreate       Permission = "event:create"
+	PermEventUpdate       Permission = "event:update"
+	PermEventDelete       Permission = "event:delete"
+	PermTicketTypesManage Permission = "ticket_types:manage"
+	PermPromoCodesManage  Permission = "promo_codes:manage"
+	PermQuestionsManage   Permission = "questions:manage"
+	PermAttendeesRead     Permission = "attendees:read"
+	PermAttendeesImport   Permission = "attendees:import"
+	PermFeedbackRead      Permission = "feedback:read"
+	PermSeatMapsRead      Permission = "seat_maps:read"
+	PermSeatMapsManage    Permission = "seat_maps:manage"
+	PermUploadsCreate     Permission = "uploads:create"
+	PermTicketsCheckIn    Permission = "tickets:check_in"
+	PermAnalyticsRead     Permission = "analytics:read"
+	PermAuditLogsRead     Permission = "audit_logs:read"
+	PermUsersManage       Permission = "users:manage"
+	PermLoadTestRun       Permission = "load_test:run"
+
+	// PermEventManageAny lifts the ownership check on per-event routes
+	PermEventManageAny Permission = "event:manage_any"
+)
+
+var rolePermissions = map[UserRole][]Permission{
+	RoleAdmin: {
+		PermEventCreate, PermEventUpdate, PermEventDelete, PermEventManageAny,
+		PermTicketTypesManage, PermPromoCodesManage, PermQuestionsManage,
+		PermAttendeesRead, PermAttendeesImport, PermFeedbackRead,
+		PermSeatMapsRead, PermSeatMapsManage, PermUploadsCreate,
+		PermTicketsCheckIn, PermAnalyticsRead, PermAuditLogsRead,
+		PermUsersManage, PermLoadTestRun,
+	},
+	// Organizers run their own events end to end, but see nothing of anyone
+	// else's and nothing system-wide
+	RoleOrganizer: {
+		PermEventCreate, PermEventUpdate, PermEventDelete,
+		PermTicketTypesManage, PermPromoCodesManage, PermQuestionsManage,
+		PermAttendeesRead, PermAttendeesImport, PermFeedbackRead,
+		PermSeatMapsRead, PermUploadsCreate,
+	},
+	RoleStaff: {
+		PermTicketsCheckIn,
+	},
+}
+
+// Can reports whether the role grants p.
+func (r UserRole) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Valid reports whether r is a role users can be given.
+func (r UserRole) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleOrganizer, RoleStaff, RoleUser:
+		return true
+	}
+	return false
+}
@@ -19,17 +19,197 @@ func InitDB(cfg *config.Config) *gorm.DB {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	// Accounts created before email verification existed count as verified
+	backfillVerified := db.Migrator().HasTable(&domain.User{}) && !db.Migrator().HasColumn(&domain.User{}, "email_verified_at")
+
 	// Auto Migration
 	err = db.AutoMigrate(
 		&domain.User{},
+		&domain.SeatMap{},
+		&domain.SeatSection{},
+		&domain.Seat{},
 		&domain.Event{},
+		&domain.TicketType{},
+		&domain.TicketTypeShard{},
+		&domain.Payment{},
+		&domain.Refund{},
+		&domain.Order{},
+		&domain.OrderItem{},
 		&domain.Registration{},
+		&domain.TicketTransfer{},
+		&domain.ResaleListing{},
+		&domain.TicketClaim{},
+		&domain.RegistrationQuestion{},
+		&domain.RegistrationAnswer{},
+		&domain.PromoCode{},
+		&domain.PromoRedemption{},
+		&domain.WaitlistEntry{},
+		&domain.SeatHold{},
+		&domain.QueueTicket{},
 		&domain.Ticket{},
+		&domain.CheckIn{},
+		&domain.TicketRevocation{},
 		&domain.AuditLog{},
+		&domain.IdempotencyKey{},
+		&domain.Session{},
+		&domain.MFARecoveryCode{},
+		&domain.UserIdentity{},
+		&domain.ProviderLoginState{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	// Stock is in shards before partial indexes are built, since clearing
+	// duplicate waitlist offers returns their seats to it
+	if err := migrateInventoryShards(db); err != nil {
+		log.Fatalf("Failed to migrate ticket inventory: %v", err)
+	}
+
+	if err := createPartialIndexes(db); err != nil {
+		log.Fatalf("Failed to create partial indexes: %v", err)
+	}
+
+	if backfillVerified {
+		if err := db.Exec("UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL").Error; err != nil {
+			log.Fatalf("Failed to mark existing accounts as verified: %v", err)
+		}
+	}
+
 	return db
 }
+
+// partialIndex is a unique index over a subset of rows, which struct tags
+// cannot express. dedupe clears the duplicates left from before the index
+// existed so it can be built.
+type partialIndex struct {
+	model  interface{}
+	name   string
+	dedupe string
+	create string
+}
+
+// partialIndexes match the ones in schema.sql.
+var partialIndexes = []partialIndex{
+	{
+		model: &domain.Registration{},
+		name:  "idx_registrations_event_seat",
+		// Later sales of a seat sold twice keep their ticket but lose the
+		// seat, so staff can reseat them
+		dedupe: `UPDATE registrations SET seat_id = NULL
+			WHERE seat_id IS NOT NULL AND status <> 'cancelled' AND id NOT IN (
+				SELECT DISTINCT ON (event_id, seat_id) id FROM registrations
+				WHERE seat_id IS NOT NULL AND status <> 'cancelled'
+				ORDER BY event_id, seat_id, created_at)`,
+		create: `CREATE UNIQUE INDEX idx_registrations_event_seat ON registrations(event_id, seat_id)
+			WHERE seat_id IS NOT NULL AND status <> 'cancelled'`,
+	},
+	{
+		model: &domain.QueueTicket{},
+		name:  "idx_queue_tickets_active",
+		// Keep each user's earliest active place in line
+		dedupe: `UPDATE queue_tickets SET status = 'expired'
+			WHERE status IN ('waiting', 'admitted') AND id NOT IN (
+				SELECT DISTINCT ON (event_id, user_id) id FROM queue_tickets
+				WHERE status IN ('waiting', 'admitted')
+				ORDER BY event_id, user_id, created_at)`,
+		create: `CREATE UNIQUE INDEX idx_queue_tickets_active ON queue_tickets(event_id, user_id)
+			WHERE status IN ('waiting', 'admitted')`,
+	},
+	{
+		model: &domain.TicketTransfer{},
+		name:  "idx_ticket_transfers_pending",
+		// Keep the earliest open offer for each ticket
+		dedupe: `UPDATE ticket_transfers SET status = 'revoked'
+			WHERE status = 'pending' AND id NOT IN (
+				SELECT DISTINCT ON (registration_id) id FROM ticket_transfers
+				WHERE status = 'pending'
+				ORDER BY registration_id, created_at)`,
+		create: `CREATE UNIQUE INDEX idx_ticket_transfers_pending ON ticket_transfers(registration_id)
+			WHERE status = 'pending'`,
+	},
+	{
+		model: &domain.ResaleListing{},
+		name:  "idx_resale_listings_open",
+		// Keep the listing a buyer is paying for, or else the earliest. A
+		// second listing being paid for is cancelled, and its buyer has to
+		// be refunded by hand
+		dedupe: `UPDATE resale_listings
+			SET status = CASE WHEN status = 'pending_payment' THEN 'cancelled' ELSE 'withdrawn' END
+			WHERE status IN ('active', 'pending_payment') AND id NOT IN (
+				SELECT DISTINCT ON (registration_id) id FROM resale_listings
+				WHERE status IN ('active', 'pending_payment')
+				ORDER BY registration_id, status = 'pending_payment' DESC, created_at)`,
+		create: `CREATE UNIQUE INDEX idx_resale_listings_open ON resale_listings(registration_id)
+			WHERE status IN ('active', 'pending_payment')`,
+	},
+	{
+		model: &domain.WaitlistEntry{},
+		name:  "idx_waitlist_entries_active",
+		// Keep each user's offer, or else their earliest place in line. The
+		// others leave the line, and a seat held by a dropped offer goes back
+		// to stock
+		dedupe: `WITH dropped AS (
+				SELECT id, ticket_type_id, status FROM waitlist_entries
+				WHERE status IN ('waiting', 'offered') AND id NOT IN (
+					SELECT DISTINCT ON (user_id, ticket_type_id) id FROM waitlist_entries
+					WHERE status IN ('waiting', 'offered')
+					ORDER BY user_id, ticket_type_id, status = 'offered' DESC, created_at)
+			), returned AS (
+				UPDATE ticket_type_shards s SET remaining = s.remaining + held.n
+				FROM (SELECT ticket_type_id, COUNT(*) AS n FROM dropped WHERE status = 'offered' GROUP BY ticket_type_id) held
+				WHERE s.id = (SELECT id FROM ticket_type_shards WHERE ticket_type_id = held.ticket_type_id ORDER BY shard LIMIT 1)
+			)
+			UPDATE waitlist_entries SET status = CASE WHEN status = 'offered' THEN 'expired' ELSE 'left' END
+			WHERE id IN (SELECT id FROM dropped)`,
+		create: `CREATE UNIQUE INDEX idx_waitlist_entries_active ON waitlist_entries(user_id, ticket_type_id)
+			WHERE status IN ('waiting', 'offered')`,
+	},
+}
+
+// createPartialIndexes builds each partial index that does not exist yet.
+func createPartialIndexes(db *gorm.DB) error {
+	for _, index := range partialIndexes {
+		if db.Migrator().HasIndex(index.model, index.name) {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Exec(index.dedupe)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				log.Printf("Cleared %d rows conflicting with %s", result.RowsAffected, index.name)
+			}
+			return tx.Exec(index.create).Error
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", index.name, err)
+		}
+	}
+	return nil
+}
+
+// migrateInventoryShards moves ticket type stock from the old remaining_tickets
+// columns into counter shards, then drops the columns now derived from them.
+func migrateInventoryShards(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&domain.TicketType{}, "remaining_tickets") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`INSERT INTO ticket_type_shards (id, ticket_type_id, shard, remaining)
+			SELECT gen_random_uuid(), tt.id, 0, tt.remaining_tickets FROM ticket_types tt
+			WHERE NOT EXISTS (SELECT 1 FROM ticket_type_shards s WHERE s.ticket_type_id = tt.id)`).Error; err != nil {
+			return err
+		}
+		if err := tx.Migrator().DropColumn(&domain.TicketType{}, "remaining_tickets"); err != nil {
+			return err
+		}
+		if tx.Migrator().HasColumn(&domain.Event{}, "remaining_tickets") {
+			return tx.Migrator().DropColumn(&domain.Event{}, "remaining_tickets")
+		}
+		return nil
+	})
+}
@@ -12,6 +12,13 @@ CREATE TABLE users (
     role VARCHAR(50) DEFAULT 'user' NOT NULL,
     password_reset_token VARCHAR(255),
     password_reset_expires TIMESTAMP WITH TIME ZONE,
+    email_verified_at TIMESTAMP WITH TIME ZONE,
+    verification_sent_at TIMESTAMP WITH TIME ZONE,
+    mfa_secret VARCHAR(64),
+    mfa_enabled_at TIMESTAMP WITH TIME ZONE,
+    mfa_last_step BIGINT DEFAULT 0 NOT NULL,
+    mfa_failed_attempts INTEGER DEFAULT 0 NOT NULL,
+    mfa_locked_until TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     deleted_at TIMESTAMP WITH TIME ZONE
@@ -22,6 +29,38 @@ CREATE INDEX idx_users_password_reset_token ON users(password_reset_token);
 CREATE INDEX idx_users_email ON users(email);
 CREATE INDEX idx_users_deleted_at ON users(deleted_at);
 
+-- Seat Maps Tables (venue layouts for reserved seating)
+CREATE TABLE seat_maps (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    venue VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE seat_sections (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    seat_map_id UUID NOT NULL REFERENCES seat_maps(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_seat_sections_seat_map_id ON seat_sections(seat_map_id);
+
+CREATE TABLE seats (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    seat_map_id UUID NOT NULL REFERENCES seat_maps(id) ON DELETE CASCADE,
+    section_id UUID NOT NULL REFERENCES seat_sections(id) ON DELETE CASCADE,
+    row VARCHAR(20) NOT NULL,
+    number INTEGER NOT NULL,
+    zone VARCHAR(100) NOT NULL,
+    accessible BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE (section_id, row, number)
+);
+
+CREATE INDEX idx_seats_zone ON seats(seat_map_id, zone);
+CREATE INDEX idx_seats_section_id ON seats(section_id);
+
 -- Events Table
 CREATE TABLE events (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -31,20 +70,31 @@ CREATE TABLE events (
     status VARCHAR(50) DEFAULT 'draft' NOT NULL,
     start_time TIMESTAMP WITH TIME ZONE NOT NULL,
     total_tickets INTEGER NOT NULL,
-    remaining_tickets INTEGER NOT NULL,
     price DECIMAL(10, 2) NOT NULL,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     deleted_at TIMESTAMP WITH TIME ZONE,
-    reminder_sent BOOLEAN DEFAULT FALSE
+    reminder_sent BOOLEAN DEFAULT FALSE,
+    refund_full_hours_before INTEGER NOT NULL DEFAULT 24,
+    refund_partial_percent INTEGER NOT NULL DEFAULT 50 CHECK (refund_partial_percent BETWEEN 0 AND 100),
+    seat_map_id UUID REFERENCES seat_maps(id),
+    waiting_room BOOLEAN NOT NULL DEFAULT FALSE,
+    queue_admit_per_minute INTEGER NOT NULL DEFAULT 0,
+    resale_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    resale_max_markup_percent INTEGER NOT NULL DEFAULT 0 CHECK (resale_max_markup_percent >= 0),
+    resale_fee_percent INTEGER NOT NULL DEFAULT 0 CHECK (resale_fee_percent BETWEEN 0 AND 100),
+    organizer_id UUID REFERENCES users(id) ON DELETE SET NULL
 );
 
+CREATE INDEX idx_events_organizer_id ON events(organizer_id);
+
 CREATE INDEX idx_events_category ON events(category);
 CREATE INDEX idx_events_status ON events(status);
 CREATE INDEX idx_events_title_search ON events USING GIN (to_tsvector('english', title || ' ' || COALESCE(description, '')));
 
 CREATE INDEX idx_events_start_time ON events(start_time);
 CREATE INDEX idx_events_deleted_at ON events(deleted_at);
+CREATE INDEX idx_events_seat_map_id ON events(seat_map_id);
 
 -- Ticket Types Table
 CREATE TABLE ticket_types (
@@ -53,7 +103,12 @@ CREATE TABLE ticket_types (
     name VARCHAR(100) NOT NULL,
     price DECIMAL(10, 2) NOT NULL,
     capacity INTEGER NOT NULL,
-    remaining_tickets INTEGER NOT NULL,
+    sales_start TIMESTAMP WITH TIME ZONE,
+    sales_end TIMESTAMP WITH TIME ZONE,
+    min_per_order INTEGER NOT NULL DEFAULT 0,
+    max_per_order INTEGER NOT NULL DEFAULT 0,
+    max_per_user INTEGER NOT NULL DEFAULT 0,
+    seat_zone VARCHAR(100),
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     deleted_at TIMESTAMP WITH TIME ZONE
@@ -62,21 +117,303 @@ CREATE TABLE ticket_types (
 CREATE INDEX idx_ticket_types_event_id ON ticket_types(event_id);
 CREATE INDEX idx_ticket_types_deleted_at ON ticket_types(deleted_at);
 
+-- Ticket Type Shards Table (stock counters; remaining tickets are their sum)
+CREATE TABLE ticket_type_shards (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ticket_type_id UUID NOT NULL REFERENCES ticket_types(id) ON DELETE CASCADE,
+    shard INTEGER NOT NULL,
+    remaining INTEGER NOT NULL CHECK (remaining >= 0)
+);
+
+CREATE UNIQUE INDEX idx_ticket_type_shard ON ticket_type_shards(ticket_type_id, shard);
+
+-- Payments Table (one provider charge per registration or order)
+CREATE TABLE payments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    provider VARCHAR(50) NOT NULL,
+    intent_id VARCHAR(255) UNIQUE,
+    client_secret VARCHAR(255),
+    amount DECIMAL(10, 2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(50) DEFAULT 'pending' NOT NULL,
+    failure_reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_payments_user_id ON payments(user_id);
+CREATE INDEX idx_payments_status ON payments(status);
+
+-- Orders Table (seats bought together in one checkout)
+CREATE TABLE orders (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    event_id UUID NOT NULL REFERENCES events(id),
+    status VARCHAR(50) DEFAULT 'confirmed' NOT NULL,
+    total_amount DECIMAL(10, 2) NOT NULL DEFAULT 0.00,
+    payment_id UUID REFERENCES payments(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_orders_user_id ON orders(user_id);
+CREATE INDEX idx_orders_event_id ON orders(event_id);
+
+CREATE TABLE order_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    ticket_type_id UUID NOT NULL REFERENCES ticket_types(id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    unit_price DECIMAL(10, 2) NOT NULL
+);
+
+CREATE INDEX idx_order_items_order_id ON order_items(order_id);
+
 -- Registrations (Bookings) Table
 CREATE TABLE registrations (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
-    user_id UUID NOT NULL REFERENCES users(id),
+    user_id UUID REFERENCES users(id), -- NULL for guest tickets
     event_id UUID NOT NULL REFERENCES events(id),
     ticket_type_id UUID NOT NULL REFERENCES ticket_types(id),
+    order_id UUID REFERENCES orders(id),
+    attendee_name VARCHAR(255),
+    attendee_email VARCHAR(255),
+    amount DECIMAL(10, 2) NOT NULL DEFAULT 0.00,
+    discount DECIMAL(10, 2) NOT NULL DEFAULT 0.00,
+    payment_id UUID REFERENCES payments(id),
+    seat_id UUID REFERENCES seats(id),
     status VARCHAR(50) DEFAULT 'confirmed' NOT NULL,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE INDEX idx_registrations_order_id ON registrations(order_id);
+CREATE INDEX idx_registrations_payment_id ON registrations(payment_id);
 CREATE INDEX idx_registrations_user_id ON registrations(user_id);
 CREATE INDEX idx_registrations_event_id ON registrations(event_id);
 CREATE INDEX idx_registrations_ticket_type_id ON registrations(ticket_type_id);
 CREATE INDEX idx_registrations_status ON registrations(status);
+-- A seat can be sold only once per event; cancelled registrations free it
+CREATE UNIQUE INDEX idx_registrations_event_seat ON registrations(event_id, seat_id)
+    WHERE seat_id IS NOT NULL AND status <> 'cancelled';
+
+-- Ticket Transfers Table (offers the recipient accepts before ownership moves)
+CREATE TABLE ticket_transfers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    registration_id UUID NOT NULL REFERENCES registrations(id) ON DELETE CASCADE,
+    sender_id UUID NOT NULL REFERENCES users(id),
+    recipient_email VARCHAR(255) NOT NULL,
+    recipient_name VARCHAR(255),
+    recipient_id UUID REFERENCES users(id),
+    token VARCHAR(64) UNIQUE NOT NULL,
+    status VARCHAR(50) DEFAULT 'pending' NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    responded_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_ticket_transfers_registration_id ON ticket_transfers(registration_id);
+CREATE INDEX idx_ticket_transfers_sender_id ON ticket_transfers(sender_id);
+CREATE INDEX idx_ticket_transfers_recipient_email ON ticket_transfers(recipient_email);
+CREATE INDEX idx_ticket_transfers_status ON ticket_transfers(status);
+CREATE INDEX idx_ticket_transfers_expires_at ON ticket_transfers(expires_at);
+-- One open offer per registration
+CREATE UNIQUE INDEX idx_ticket_transfers_pending ON ticket_transfers(registration_id)
+    WHERE status = 'pending';
+
+-- Resale Listings Table
+CREATE TABLE resale_listings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    registration_id UUID NOT NULL REFERENCES registrations(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    seller_id UUID NOT NULL REFERENCES users(id),
+    price DECIMAL(10, 2) NOT NULL CHECK (price > 0),
+    fee DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    seller_proceeds DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    status VARCHAR(50) DEFAULT 'active' NOT NULL,
+    buyer_id UUID REFERENCES users(id),
+    payment_id UUID REFERENCES payments(id),
+    buyer_answers JSONB,
+    sold_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_resale_listings_registration_id ON resale_listings(registration_id);
+CREATE INDEX idx_resale_listings_event_id ON resale_listings(event_id);
+CREATE INDEX idx_resale_listings_seller_id ON resale_listings(seller_id);
+CREATE INDEX idx_resale_listings_buyer_id ON resale_listings(buyer_id);
+CREATE INDEX idx_resale_listings_payment_id ON resale_listings(payment_id);
+CREATE INDEX idx_resale_listings_status ON resale_listings(status);
+-- One open listing per registration
+CREATE UNIQUE INDEX idx_resale_listings_open ON resale_listings(registration_id)
+    WHERE status IN ('active', 'pending_payment');
+
+-- Ticket Claims Table (single-use links for tickets transferred to guests)
+CREATE TABLE ticket_claims (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    registration_id UUID NOT NULL REFERENCES registrations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    name VARCHAR(255),
+    token VARCHAR(64) UNIQUE NOT NULL,
+    status VARCHAR(50) DEFAULT 'pending' NOT NULL,
+    claimed_by UUID REFERENCES users(id),
+    claimed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_ticket_claims_registration_id ON ticket_claims(registration_id);
+CREATE INDEX idx_ticket_claims_email ON ticket_claims(email);
+
+-- Registration Questions Tables (per-event forms answered at registration)
+CREATE TABLE registration_questions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    ticket_type_id UUID REFERENCES ticket_types(id) ON DELETE CASCADE,
+    label VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('text', 'select', 'multi_select', 'checkbox', 'number')),
+    options JSONB,
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_registration_questions_event_id ON registration_questions(event_id);
+CREATE INDEX idx_registration_questions_ticket_type_id ON registration_questions(ticket_type_id);
+CREATE INDEX idx_registration_questions_deleted_at ON registration_questions(deleted_at);
+
+CREATE TABLE registration_answers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    registration_id UUID NOT NULL REFERENCES registrations(id) ON DELETE CASCADE,
+    question_id UUID NOT NULL REFERENCES registration_questions(id),
+    value JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_registration_answer ON registration_answers(registration_id, question_id);
+CREATE INDEX idx_registration_answers_question_id ON registration_answers(question_id);
+
+-- Promo Codes Tables
+CREATE TABLE promo_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    code VARCHAR(100) NOT NULL,
+    discount_type VARCHAR(20) NOT NULL CHECK (discount_type IN ('percentage', 'fixed')),
+    value DECIMAL(10, 2) NOT NULL,
+    max_uses INTEGER NOT NULL DEFAULT 0,
+    max_uses_per_user INTEGER NOT NULL DEFAULT 0,
+    used_count INTEGER NOT NULL DEFAULT 0,
+    valid_from TIMESTAMP WITH TIME ZONE,
+    valid_until TIMESTAMP WITH TIME ZONE,
+    stackable BOOLEAN DEFAULT FALSE,
+    active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (event_id, code)
+);
+
+-- Ticket types a promo code is limited to; none means every tier of the event
+CREATE TABLE promo_code_ticket_types (
+    promo_code_id UUID NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    ticket_type_id UUID NOT NULL REFERENCES ticket_types(id) ON DELETE CASCADE,
+    PRIMARY KEY (promo_code_id, ticket_type_id)
+);
+
+CREATE TABLE promo_redemptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    promo_code_id UUID NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    registration_id UUID NOT NULL REFERENCES registrations(id),
+    discount DECIMAL(10, 2) NOT NULL,
+    released_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_promo_redemptions_user ON promo_redemptions(promo_code_id, user_id);
+CREATE INDEX idx_promo_redemptions_registration_id ON promo_redemptions(registration_id);
+
+-- Seat Holds Table (seats reserved for a short time before confirmation)
+CREATE TABLE seat_holds (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    ticket_type_id UUID NOT NULL REFERENCES ticket_types(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    status VARCHAR(50) DEFAULT 'active' NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_seat_holds_user_id ON seat_holds(user_id);
+CREATE INDEX idx_seat_holds_event_id ON seat_holds(event_id);
+CREATE INDEX idx_seat_holds_expiry ON seat_holds(status, expires_at);
+
+-- Waiting Room Queue Table (admission to high-demand on-sales)
+CREATE TABLE queue_tickets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token VARCHAR(64) UNIQUE NOT NULL,
+    status VARCHAR(50) DEFAULT 'waiting' NOT NULL,
+    admitted_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_queue_tickets_order ON queue_tickets(event_id, status, created_at);
+CREATE INDEX idx_queue_tickets_user_id ON queue_tickets(user_id);
+CREATE INDEX idx_queue_tickets_admitted_at ON queue_tickets(admitted_at);
+CREATE UNIQUE INDEX idx_queue_tickets_active ON queue_tickets(event_id, user_id)
+    WHERE status IN ('waiting', 'admitted');
+
+-- Waitlist Entries Table (FIFO queue per ticket type)
+CREATE TABLE waitlist_entries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    ticket_type_id UUID NOT NULL REFERENCES ticket_types(id) ON DELETE CASCADE,
+    status VARCHAR(50) DEFAULT 'waiting' NOT NULL,
+    offer_expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_waitlist_entries_user_id ON waitlist_entries(user_id);
+CREATE INDEX idx_waitlist_entries_event_id ON waitlist_entries(event_id);
+CREATE INDEX idx_waitlist_queue ON waitlist_entries(ticket_type_id, status, created_at);
+CREATE UNIQUE INDEX idx_waitlist_entries_active ON waitlist_entries(user_id, ticket_type_id)
+    WHERE status IN ('waiting', 'offered');
+
+-- Refunds Table (money owed back to buyers; pending rows are sent by a retrying job)
+CREATE TABLE refunds (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    registration_id UUID REFERENCES registrations(id),
+    payment_id UUID NOT NULL REFERENCES payments(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    event_id UUID REFERENCES events(id),
+    amount DECIMAL(10, 2) NOT NULL,
+    percent INTEGER NOT NULL,
+    reason VARCHAR(50) NOT NULL,
+    status VARCHAR(50) DEFAULT 'pending' NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    provider_refund_id VARCHAR(255),
+    refunded_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_refunds_cancellation ON refunds(registration_id) WHERE reason = 'cancellation';
+CREATE INDEX idx_refunds_payment_id ON refunds(payment_id);
+CREATE INDEX idx_refunds_event_id ON refunds(event_id);
+CREATE INDEX idx_refunds_status ON refunds(status);
 
 -- Tickets Table
 CREATE TABLE tickets (
@@ -90,6 +427,34 @@ CREATE TABLE tickets (
 CREATE INDEX idx_tickets_ticket_code ON tickets(ticket_code);
 CREATE INDEX idx_tickets_registration_id ON tickets(registration_id);
 
+-- Check-ins Table (one successful scan per registration)
+CREATE TABLE check_ins (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ticket_id UUID NOT NULL,
+    registration_id UUID UNIQUE NOT NULL REFERENCES registrations(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL REFERENCES events(id),
+    scanned_by UUID NOT NULL REFERENCES users(id),
+    gate VARCHAR(100),
+    device_id VARCHAR(100),
+    offline BOOLEAN DEFAULT FALSE,
+    checked_in_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_check_ins_ticket_id ON check_ins(ticket_id);
+CREATE INDEX idx_check_ins_event_id ON check_ins(event_id);
+
+-- Ticket Revocations Table (tickets invalidated by cancellation or transfer)
+CREATE TABLE ticket_revocations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ticket_id UUID UNIQUE NOT NULL,
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    ticket_code VARCHAR(100) NOT NULL,
+    reason VARCHAR(50) NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_ticket_revocations_event_id ON ticket_revocations(event_id);
+
 -- Audit Logs Table
 CREATE TABLE audit_logs (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -107,6 +472,78 @@ CREATE INDEX idx_audit_logs_action ON audit_logs(action);
 CREATE INDEX idx_audit_logs_entity_type_id ON audit_logs(entity_type, entity_id);
 CREATE INDEX idx_audit_logs_created_at ON audit_logs(created_at);
 
+-- Idempotency Keys Table (stored responses replayed to retried requests)
+CREATE TABLE idempotency_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    response_body BYTEA,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_idempotency_user_key ON idempotency_keys(user_id, key);
+CREATE INDEX idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+
+-- Sessions Table (refresh tokens; access tokens name their session)
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    refresh_token_hash VARCHAR(64) NOT NULL,
+    previous_token_hash VARCHAR(64),
+    user_agent TEXT,
+    ip_address VARCHAR(64),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_sessions_refresh_token_hash ON sessions(refresh_token_hash);
+CREATE INDEX idx_sessions_previous_token_hash ON sessions(previous_token_hash);
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX idx_sessions_expires_at ON sessions(expires_at);
+
+-- MFA Recovery Codes Table (one-use stand-ins for TOTP codes)
+CREATE TABLE mfa_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
+
+-- User Identities Table (accounts at OpenID Connect providers)
+CREATE TABLE user_identities (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_user_identities_provider_subject ON user_identities(provider, subject);
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
+-- Provider Login States Table (sign-ins in progress at a provider)
+CREATE TABLE provider_login_states (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    provider VARCHAR(64) NOT NULL,
+    state_hash VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    nonce VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_provider_login_states_state_hash ON provider_login_states(state_hash);
+CREATE INDEX idx_provider_login_states_expires_at ON provider_login_states(expires_at);
+
 -- Feedbacks Table
 CREATE TABLE feedbacks (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
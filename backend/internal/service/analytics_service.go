@@ -18,6 +18,8 @@ type EventStats struct {
 	Title            string  `json:"title"`
 	Category         string  `json:"category"`
 	TotalBookings    int64   `json:"total_bookings"`
+	GrossRevenue     float64 `json:"gross_revenue"`
+	Refunded         float64 `json:"refunded"`
 	Revenue          float64 `json:"revenue"`
 	TicketsRemaining int     `json:"tickets_remaining"`
 }
@@ -31,14 +33,22 @@ type SystemStats struct {
 func (s *AnalyticsService) GetEventStats() ([]EventStats, error) {
 	var stats []EventStats
 
-	// Complex query for analytics
+	// Revenue is what was actually charged for seats, net of refunds
 	err := s.DB.Model(&domain.Event{}).
-		Select("events.id as event_id, events.title, events.category, count(registrations.id) as total_bookings, sum(events.price) as revenue, events.remaining_tickets as tickets_remaining").
-		Joins("left join registrations on registrations.event_id = events.id and registrations.status = 'confirmed'").
-		Group("events.id, events.title, events.category, events.remaining_tickets").
+		Select(`events.id as event_id, events.title, events.category,
+			(SELECT count(*) FROM registrations r WHERE r.event_id = events.id AND r.status = 'confirmed') as total_bookings,
+			COALESCE((SELECT sum(r.amount) FROM registrations r JOIN payments p ON p.id = r.payment_id WHERE r.event_id = events.id AND p.status = 'succeeded'), 0) as gross_revenue,
+			COALESCE((SELECT sum(rf.amount) FROM refunds rf WHERE rf.event_id = events.id AND rf.reason = 'cancellation'), 0) as refunded,
+			(SELECT COALESCE(SUM(s.remaining), 0) FROM ticket_type_shards s JOIN ticket_types tt ON tt.id = s.ticket_type_id WHERE tt.event_id = events.id AND tt.deleted_at IS NULL) as tickets_remaining`).
 		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
 
-	return stats, err
+	for i := range stats {
+		stats[i].Revenue = stats[i].GrossRevenue - stats[i].Refunded
+	}
+	return stats, nil
 }
 
 func (s *AnalyticsService) GetSystemStats() (SystemStats, error) {
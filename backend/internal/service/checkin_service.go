@@ -0,0 +1,487 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/pkg/signing"
+	"github.com/username/event-ticketing-system/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTicketNotFound   = errors.New("ticket not found")
+	ErrTicketNotActive  = errors.New("registration for this ticket is not confirmed")
+	ErrEventNotToday    = errors.New("ticket is not valid for today's events")
+	ErrTicketWrongEvent = errors.New("ticket belongs to a different event")
+)
+
+// AlreadyCheckedInError is returned when a ticket is scanned a second time.
+// It carries the original check-in so door staff can see when it happened.
+type AlreadyCheckedInError struct {
+	CheckIn domain.CheckIn
+}
+
+func (e *AlreadyCheckedInError) Error() string {
+	return fmt.Sprintf("ticket already checked in at %s", e.CheckIn.CheckedInAt.Format(time.RFC3339))
+}
+
+type CheckInService struct {
+	DB     *gorm.DB
+	Signer *signing.Signer
+}
+
+func NewCheckInService(db *gorm.DB, signer *signing.Signer) *CheckInService {
+	return &CheckInService{DB: db, Signer: signer}
+}
+
+// VerifyTicket validates a signed ticket token without a database round trip.
+func (s *CheckInService) VerifyTicket(scan string) (*signing.TicketClaims, error) {
+	return s.Signer.VerifyTicket(ticketCodeFromScan(scan))
+}
+
+func (s *CheckInService) CheckIn(staffID uuid.UUID, scan string, gate string) (*domain.CheckIn, error) {
+	var checkIn domain.CheckIn
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		ticket, registration, err := s.lockScannedRegistration(tx, scan)
+		if err != nil {
+			return err
+		}
+
+		if registration.Event.Status != domain.StatusPublished || !sameDay(registration.Event.StartTime, time.Now()) {
+			return ErrEventNotToday
+		}
+
+		var existing domain.CheckIn
+		if err := tx.First(&existing, "registration_id = ?", registration.ID).Error; err == nil {
+			return &AlreadyCheckedInError{CheckIn: existing}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		checkIn = domain.CheckIn{
+			ID:             uuid.New(),
+			TicketID:       ticket.ID,
+			RegistrationID: registration.ID,
+			EventID:        registration.EventID,
+			ScannedBy:      staffID,
+			Gate:           gate,
+			CheckedInAt:    time.Now(),
+		}
+		if err := tx.Create(&checkIn).Error; err != nil {
+			return err
+		}
+
+		audit := domain.AuditLog{
+			ID:         uuid.New(),
+			UserID:     staffID,
+			Action:     "CHECK_IN",
+			EntityType: "registration",
+			EntityID:   registration.ID,
+			NewValues:  utils.ToJSON(map[string]string{"ticket_code": ticket.TicketCode, "gate": gate}),
+			CreatedAt:  time.Now(),
+		}
+		if err := tx.Create(&audit).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &checkIn, nil
+}
+
+// lockScannedRegistration resolves a scan to its ticket and locks the owning
+// registration so two gates scanning the same ticket serialize.
+func (s *CheckInService) lockScannedRegistration(tx *gorm.DB, scan string) (*domain.Ticket, *domain.Registration, error) {
+	ticket, err := s.findTicket(tx, scan)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var registration domain.Registration
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Event").
+		First(&registration, "id = ?", ticket.RegistrationID).Error; err != nil {
+		return nil, nil, ErrTicketNotFound
+	}
+
+	if !isAttending(registration.Status) {
+		return nil, nil, ErrTicketNotActive
+	}
+	if registration.Status != domain.RegistrationConfirmed {
+		var revoked int64
+		if err := tx.Model(&domain.TicketRevocation{}).Where("ticket_id = ?", ticket.ID).Count(&revoked).Error; err != nil {
+			return nil, nil, err
+		}
+		var paymentStatus *domain.PaymentStatus
+		if registration.PaymentID != nil {
+			var payment domain.Payment
+			if err := tx.Select("status").First(&payment, "id = ?", *registration.PaymentID).Error; err != nil {
+				return nil, nil, err
+			}
+			paymentStatus = &payment.Status
+		}
+		if !admitsEntry(registration.Status, revoked > 0, paymentStatus) {
+			return nil, nil, ErrTicketNotActive
+		}
+	}
+
+	return ticket, &registration, nil
+}
+
+// findTicket resolves a scan to a ticket. Signed QR tokens are verified before
+// the lookup so forged codes never reach the database; plain ticket codes are
+// still accepted for manual entry.
+func (s *CheckInService) findTicket(tx *gorm.DB, scan string) (*domain.Ticket, error) {
+	value := ticketCodeFromScan(scan)
+	if value == "" {
+		return nil, ErrTicketNotFound
+	}
+
+	var ticket domain.Ticket
+	if signing.IsTicketToken(value) {
+		claims, err := s.Signer.VerifyTicket(value)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.First(&ticket, "id = ?", claims.TicketID).Error; err != nil {
+			return nil, ErrTicketNotFound
+		}
+		return &ticket, nil
+	}
+
+	if err := tx.First(&ticket, "ticket_code = ?", value).Error; err != nil {
+		return nil, ErrTicketNotFound
+	}
+	return &ticket, nil
+}
+
+// ticketCodeFromScan accepts either a bare ticket code or token, or the full
+// verify URL encoded in the QR code, and returns the code or token.
+func ticketCodeFromScan(scan string) string {
+	scan = strings.TrimSpace(scan)
+	if i := strings.LastIndex(scan, "/"); i >= 0 {
+		scan = scan[i+1:]
+	}
+	return scan
+}
+
+// AttendingStatuses are the registration statuses that still entitle their
+// holder to entry. RSVP updates overwrite the confirmed status, so those count
+// as well; admitsEntry checks that such an RSVP was given on a confirmed
+// registration.
+var AttendingStatuses = []domain.RegistrationStatus{
+	domain.RegistrationConfirmed,
+	domain.RegistrationRSVPYes,
+	domain.RegistrationRSVPMaybe,
+}
+
+// isAttending reports whether a registration still entitles its holder to entry.
+func isAttending(status domain.RegistrationStatus) bool {
+	for _, s := range AttendingStatuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
+// admitsEntry reports whether a ticket gets its holder in. An RSVP only
+// stands for a confirmed registration while nothing shows the registration
+// was never or is no longer confirmed: its ticket was not revoked and any
+// payment for it went through.
+func admitsEntry(status domain.RegistrationStatus, revoked bool, paymentStatus *domain.PaymentStatus) bool {
+	if status == domain.RegistrationConfirmed {
+		return true
+	}
+	if !isAttending(status) {
+		return false
+	}
+	return !revoked && (paymentStatus == nil || *paymentStatus == domain.PaymentSucceeded)
+}
+
+func sameDay(a, b time.Time) bool {
+	a = a.In(b.Location())
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+type ManifestTicket struct {
+	TicketID     uuid.UUID  `json:"ticket_id"`
+	CodeHash     string     `json:"code_hash"`
+	TicketTypeID uuid.UUID  `json:"ticket_type_id"`
+	CheckedInAt  *time.Time `json:"checked_in_at,omitempty"`
+}
+
+type ManifestRevocation struct {
+	TicketID uuid.UUID `json:"ticket_id"`
+	CodeHash string    `json:"code_hash"`
+	Reason   string    `json:"reason"`
+}
+
+type ScannerManifest struct {
+	EventID     uuid.UUID            `json:"event_id"`
+	GeneratedAt time.Time            `json:"generated_at"`
+	Tickets     []ManifestTicket     `json:"tickets"`
+	Revoked     []ManifestRevocation `json:"revoked"`
+}
+
+// SignedManifest wraps the JSON encoded manifest so devices verify the exact
+// bytes that were signed instead of a re-serialized copy.
+type SignedManifest struct {
+	KeyID     string `json:"key_id"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// ExportManifest builds the list of valid and revoked tickets for an event.
+// Ticket codes are only exported as SHA-256 hashes so a lost device does not
+// leak usable codes; signed QR tokens are validated with the public keys.
+func (s *CheckInService) ExportManifest(eventID uuid.UUID) (*SignedManifest, error) {
+	var event domain.Event
+	if err := s.DB.First(&event, "id = ?", eventID).Error; err != nil {
+		return nil, errors.New("event not found")
+	}
+
+	var rows []struct {
+		TicketID      uuid.UUID
+		TicketCode    string
+		TicketTypeID  uuid.UUID
+		Status        domain.RegistrationStatus
+		CheckedInAt   *time.Time
+		Revoked       bool
+		PaymentStatus *domain.PaymentStatus
+	}
+	err := s.DB.Table("tickets").
+		Select("tickets.id AS ticket_id, tickets.ticket_code, registrations.ticket_type_id, registrations.status, check_ins.checked_in_at, "+
+			"ticket_revocations.id IS NOT NULL AS revoked, payments.status AS payment_status").
+		Joins("JOIN registrations ON registrations.id = tickets.registration_id").
+		Joins("LEFT JOIN check_ins ON check_ins.registration_id = registrations.id").
+		Joins("LEFT JOIN ticket_revocations ON ticket_revocations.ticket_id = tickets.id").
+		Joins("LEFT JOIN payments ON payments.id = registrations.payment_id").
+		Where("registrations.event_id = ?", eventID).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := ScannerManifest{
+		EventID:     eventID,
+		GeneratedAt: time.Now(),
+		Tickets:     []ManifestTicket{},
+		Revoked:     []ManifestRevocation{},
+	}
+
+	for _, row := range rows {
+		if admitsEntry(row.Status, row.Revoked, row.PaymentStatus) {
+			manifest.Tickets = append(manifest.Tickets, ManifestTicket{
+				TicketID:     row.TicketID,
+				CodeHash:     hashTicketCode(row.TicketCode),
+				TicketTypeID: row.TicketTypeID,
+				CheckedInAt:  row.CheckedInAt,
+			})
+		} else {
+			manifest.Revoked = append(manifest.Revoked, ManifestRevocation{
+				TicketID: row.TicketID,
+				CodeHash: hashTicketCode(row.TicketCode),
+				Reason:   string(row.Status),
+			})
+		}
+	}
+
+	// Transferred tickets are deleted and re-issued, so they only survive here
+	var revocations []domain.TicketRevocation
+	if err := s.DB.Where("event_id = ?", eventID).Find(&revocations).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range revocations {
+		manifest.Revoked = append(manifest.Revoked, ManifestRevocation{
+			TicketID: r.TicketID,
+			CodeHash: hashTicketCode(r.TicketCode),
+			Reason:   r.Reason,
+		})
+	}
+
+	payload, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	keyID, signature := s.Signer.Sign(payload)
+	return &SignedManifest{
+		KeyID:     keyID,
+		Payload:   base64.RawURLEncoding.EncodeToString(payload),
+		Signature: signature,
+	}, nil
+}
+
+type OfflineScan struct {
+	TicketCode string    `json:"ticket_code" binding:"required"`
+	Gate       string    `json:"gate"`
+	ScannedAt  time.Time `json:"scanned_at" binding:"required"`
+}
+
+type OfflineScanStatus string
+
+const (
+	OfflineScanAccepted  OfflineScanStatus = "accepted"
+	OfflineScanDuplicate OfflineScanStatus = "duplicate"
+	OfflineScanRejected  OfflineScanStatus = "rejected"
+)
+
+type OfflineScanResult struct {
+	TicketCode  string            `json:"ticket_code"`
+	Status      OfflineScanStatus `json:"status"`
+	Reason      string            `json:"reason,omitempty"`
+	CheckedInAt *time.Time        `json:"checked_in_at,omitempty"`
+}
+
+// maxScanClockSkew bounds how far in the future a device clock may report a scan.
+const maxScanClockSkew = 5 * time.Minute
+
+// SyncOfflineScans ingests check-ins recorded by a device while offline. The
+// earliest scan of a ticket wins, even if a later scan reached the server
+// first; every other scan of the same ticket is flagged as a duplicate.
+// Results are returned in the same order as the submitted scans.
+func (s *CheckInService) SyncOfflineScans(staffID uuid.UUID, eventID uuid.UUID, deviceID string, scans []OfflineScan) ([]OfflineScanResult, error) {
+	order := make([]int, len(scans))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scans[order[a]].ScannedAt.Before(scans[order[b]].ScannedAt)
+	})
+
+	results := make([]OfflineScanResult, len(scans))
+	for _, i := range order {
+		result, err := s.syncOfflineScan(staffID, eventID, deviceID, scans[i])
+		if err != nil {
+			return nil, err
+		}
+		results[i] = *result
+	}
+
+	return results, nil
+}
+
+func (s *CheckInService) syncOfflineScan(staffID uuid.UUID, eventID uuid.UUID, deviceID string, scan OfflineScan) (*OfflineScanResult, error) {
+	result := &OfflineScanResult{TicketCode: scan.TicketCode}
+
+	if scan.ScannedAt.After(time.Now().Add(maxScanClockSkew)) {
+		result.Status = OfflineScanRejected
+		result.Reason = "scan time is in the future"
+		return result, nil
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		ticket, registration, err := s.lockScannedRegistration(tx, scan.TicketCode)
+		if err != nil {
+			return err
+		}
+
+		if registration.EventID != eventID {
+			return ErrTicketWrongEvent
+		}
+		if registration.Event.Status != domain.StatusPublished || !sameDay(registration.Event.StartTime, scan.ScannedAt) {
+			return ErrEventNotToday
+		}
+
+		var existing domain.CheckIn
+		err = tx.First(&existing, "registration_id = ?", registration.ID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err == nil && !scan.ScannedAt.Before(existing.CheckedInAt) {
+			result.Status = OfflineScanDuplicate
+			result.Reason = "ticket was already checked in"
+			result.CheckedInAt = &existing.CheckedInAt
+			return nil
+		}
+
+		action := "OFFLINE_CHECK_IN"
+		oldValues := ""
+		checkIn := domain.CheckIn{ID: uuid.New(), RegistrationID: registration.ID}
+		if err == nil {
+			// This offline scan happened before the recorded one, so it wins
+			action = "OFFLINE_CHECK_IN_SUPERSEDED"
+			oldValues = utils.ToJSON(existing)
+			checkIn.ID = existing.ID
+			result.Reason = fmt.Sprintf("replaced later check-in at %s", existing.CheckedInAt.Format(time.RFC3339))
+		}
+
+		checkIn.TicketID = ticket.ID
+		checkIn.EventID = registration.EventID
+		checkIn.ScannedBy = staffID
+		checkIn.Gate = scan.Gate
+		checkIn.DeviceID = deviceID
+		checkIn.Offline = true
+		checkIn.CheckedInAt = scan.ScannedAt
+		if err := tx.Save(&checkIn).Error; err != nil {
+			return err
+		}
+
+		audit := domain.AuditLog{
+			ID:         uuid.New(),
+			UserID:     staffID,
+			Action:     action,
+			EntityType: "registration",
+			EntityID:   registration.ID,
+			OldValues:  oldValues,
+			NewValues:  utils.ToJSON(checkIn),
+			CreatedAt:  time.Now(),
+		}
+		if err := tx.Create(&audit).Error; err != nil {
+			return err
+		}
+
+		result.Status = OfflineScanAccepted
+		result.CheckedInAt = &checkIn.CheckedInAt
+		return nil
+	})
+
+	if err != nil {
+		if !isScanRejection(err) {
+			return nil, err
+		}
+		result.Status = OfflineScanRejected
+		result.Reason = err.Error()
+	}
+
+	return result, nil
+}
+
+// isScanRejection reports whether err means the scanned ticket is not valid,
+// as opposed to a failure to process the scan at all.
+func isScanRejection(err error) bool {
+	for _, target := range []error{
+		ErrTicketNotFound, ErrTicketNotActive, ErrEventNotToday, ErrTicketWrongEvent,
+		signing.ErrInvalidToken, signing.ErrUnknownKey,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func hashTicketCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/pkg/signing"
+)
+
+func TestTicketCodeFromScan(t *testing.T) {
+	cases := map[string]string{
+		"E-ab12-cd34ef56":                              "E-ab12-cd34ef56",
+		"  E-ab12-cd34ef56\n":                          "E-ab12-cd34ef56",
+		"https://tkt.system/v1/verify/E-ab12-cd34ef56": "E-ab12-cd34ef56",
+		"https://tkt.system/v1/verify/":                "",
+	}
+
+	for scan, want := range cases {
+		if got := ticketCodeFromScan(scan); got != want {
+			t.Errorf("ticketCodeFromScan(%q) = %q, want %q", scan, got, want)
+		}
+	}
+}
+
+func TestSameDay(t *testing.T) {
+	loc := time.FixedZone("UTC+5", 5*60*60)
+	now := time.Date(2026, 5, 1, 1, 0, 0, 0, loc)
+
+	// 2026-04-30 21:00 UTC is already May 1st in the scanner's timezone
+	if !sameDay(time.Date(2026, 4, 30, 21, 0, 0, 0, time.UTC), now) {
+		t.Error("expected event to be on the same local day")
+	}
+	if sameDay(time.Date(2026, 5, 2, 10, 0, 0, 0, loc), now) {
+		t.Error("expected event on the next day to be rejected")
+	}
+}
+
+func newTestCheckInService(t *testing.T) (*CheckInService, sqlmock.Sqlmock) {
+	gormDB, mock := newMockDB(t)
+	signer, _ := signing.NewSigner(map[string]ed25519.PrivateKey{"test": signing.DeriveKey("test")}, "test")
+	return NewCheckInService(gormDB, signer), mock
+}
+
+// expectScannedRegistration expects a plain ticket code to be resolved to its
+// locked registration.
+func expectScannedRegistration(mock sqlmock.Sqlmock, code string, ticketID, registrationID, eventID uuid.UUID, eventStatus domain.EventStatus, start time.Time) {
+	mock.ExpectQuery(`SELECT \* FROM "tickets" WHERE ticket_code = \$1`).
+		WithArgs(code, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "registration_id", "ticket_code"}).AddRow(ticketID, registrationID, code))
+	mock.ExpectQuery(`SELECT \* FROM "registrations" WHERE id = \$1 .* FOR UPDATE`).
+		WithArgs(registrationID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "status"}).AddRow(registrationID, eventID, domain.RegistrationConfirmed))
+	mock.ExpectQuery(`SELECT \* FROM "events" WHERE "events"\."id" = \$1`).
+		WithArgs(eventID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "start_time"}).AddRow(eventID, eventStatus, start))
+}
+
+// yesterdayNoon is an event start that scans shortly after stay on the same
+// day and in the past.
+func yesterdayNoon() time.Time {
+	y := time.Now().AddDate(0, 0, -1)
+	return time.Date(y.Year(), y.Month(), y.Day(), 12, 0, 0, 0, time.Local)
+}
+
+func TestCheckInService_SyncOfflineScans_EarliestScanWins(t *testing.T) {
+	svc, mock := newTestCheckInService(t)
+
+	staffID, eventID := uuid.New(), uuid.New()
+	ticketID, registrationID := uuid.New(), uuid.New()
+	now := yesterdayNoon()
+	early, late := now.Add(10*time.Minute), now.Add(20*time.Minute)
+
+	// The later scan is submitted first but the earlier one is applied first
+	mock.ExpectBegin()
+	expectScannedRegistration(mock, "E-1", ticketID, registrationID, eventID, domain.StatusPublished, now)
+	mock.ExpectQuery(`SELECT \* FROM "check_ins" WHERE registration_id = \$1`).
+		WithArgs(registrationID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`UPDATE "check_ins"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "check_ins"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	expectScannedRegistration(mock, "E-1", ticketID, registrationID, eventID, domain.StatusPublished, now)
+	mock.ExpectQuery(`SELECT \* FROM "check_ins" WHERE registration_id = \$1`).
+		WithArgs(registrationID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "registration_id", "checked_in_at"}).AddRow(uuid.New(), registrationID, early))
+	mock.ExpectCommit()
+
+	results, err := svc.SyncOfflineScans(staffID, eventID, "device-1", []OfflineScan{
+		{TicketCode: "E-1", Gate: "B", ScannedAt: late},
+		{TicketCode: "E-1", Gate: "A", ScannedAt: early},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if results[0].Status != OfflineScanDuplicate || !results[0].CheckedInAt.Equal(early) {
+		t.Errorf("expected the later scan to be a duplicate of the earlier one, got %+v", results[0])
+	}
+	if results[1].Status != OfflineScanAccepted {
+		t.Errorf("expected the earlier scan to be accepted, got %+v", results[1])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCheckInService_SyncOfflineScans_SupersedesLaterCheckIn(t *testing.T) {
+	svc, mock := newTestCheckInService(t)
+
+	staffID, eventID := uuid.New(), uuid.New()
+	ticketID, registrationID, checkInID := uuid.New(), uuid.New(), uuid.New()
+	now := yesterdayNoon()
+	scanned, recorded := now.Add(5*time.Minute), now.Add(30*time.Minute)
+
+	mock.ExpectBegin()
+	expectScannedRegistration(mock, "E-1", ticketID, registrationID, eventID, domain.StatusPublished, now)
+	mock.ExpectQuery(`SELECT \* FROM "check_ins" WHERE registration_id = \$1`).
+		WithArgs(registrationID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "registration_id", "checked_in_at"}).AddRow(checkInID, registrationID, recorded))
+	// The recorded check-in is rewritten in place with the earlier scan
+	mock.ExpectExec(`UPDATE "check_ins" SET .* WHERE "id" = \$\d+`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(staffID, "OFFLINE_CHECK_IN_SUPERSEDED", "registration", registrationID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	results, err := svc.SyncOfflineScans(staffID, eventID, "device-1", []OfflineScan{{TicketCode: "E-1", ScannedAt: scanned}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].Status != OfflineScanAccepted || !results[0].CheckedInAt.Equal(scanned) {
+		t.Errorf("expected the earlier offline scan to replace the recorded check-in, got %+v", results[0])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCheckInService_SyncOfflineScans_RejectsCancelledEvent(t *testing.T) {
+	svc, mock := newTestCheckInService(t)
+
+	eventID := uuid.New()
+	now := yesterdayNoon()
+
+	mock.ExpectBegin()
+	expectScannedRegistration(mock, "E-1", uuid.New(), uuid.New(), eventID, domain.StatusCancelled, now)
+	mock.ExpectRollback()
+
+	results, err := svc.SyncOfflineScans(uuid.New(), eventID, "device-1", []OfflineScan{{TicketCode: "E-1", ScannedAt: now.Add(time.Minute)}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].Status != OfflineScanRejected || results[0].Reason != ErrEventNotToday.Error() {
+		t.Errorf("expected scans for a cancelled event to be rejected, got %+v", results[0])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAdmitsEntry(t *testing.T) {
+	succeeded, pending := domain.PaymentSucceeded, domain.PaymentPending
+	tests := []struct {
+		name          string
+		status        domain.RegistrationStatus
+		revoked       bool
+		paymentStatus *domain.PaymentStatus
+		want          bool
+	}{
+		{"confirmed", domain.RegistrationConfirmed, false, nil, true},
+		{"RSVP on a free ticket", domain.RegistrationRSVPYes, false, nil, true},
+		{"RSVP on a paid ticket", domain.RegistrationRSVPMaybe, false, &succeeded, true},
+		{"declined RSVP", domain.RegistrationRSVPNo, false, nil, false},
+		{"RSVP on a cancelled ticket", domain.RegistrationRSVPYes, true, nil, false},
+		{"RSVP on an unpaid ticket", domain.RegistrationRSVPYes, false, &pending, false},
+		{"cancelled", domain.RegistrationCancelled, true, nil, false},
+	}
+	for _, tt := range tests {
+		if got := admitsEntry(tt.status, tt.revoked, tt.paymentStatus); got != tt.want {
+			t.Errorf("%s: admitsEntry = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckInService_CheckIn_RejectsRSVPOnCancelledRegistration(t *testing.T) {
+	svc, mock := newTestCheckInService(t)
+
+	ticketID, registrationID, eventID := uuid.New(), uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "tickets" WHERE ticket_code = \$1`).
+		WithArgs("E-ab12-cd34ef56", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "registration_id", "ticket_code"}).AddRow(ticketID, registrationID, "E-ab12-cd34ef56"))
+	mock.ExpectQuery(`SELECT \* FROM "registrations" WHERE id = \$1 .* FOR UPDATE`).
+		WithArgs(registrationID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "status"}).AddRow(registrationID, eventID, domain.RegistrationRSVPYes))
+	mock.ExpectQuery(`SELECT \* FROM "events" WHERE "events"\."id" = \$1`).
+		WithArgs(eventID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "start_time"}).AddRow(eventID, domain.StatusPublished, time.Now()))
+	// The cancellation revoked the ticket, so the RSVP written after it counts for nothing
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "ticket_revocations" WHERE ticket_id = \$1`).
+		WithArgs(ticketID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	if _, err := svc.CheckIn(uuid.New(), "E-ab12-cd34ef56", "north"); !errors.Is(err, ErrTicketNotActive) {
+		t.Errorf("expected the cancelled ticket to be refused, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
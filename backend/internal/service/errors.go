@@ -0,0 +1,45 @@
+package service
+
+import "fmt"
+
+// Error codes returned to clients for checkout rule violations, request
+// replays and missing account security.
+const (
+	CodeEventNotFound      = "event_not_found"
+	CodeEventNotPublished  = "event_not_published"
+	CodeTicketTypeNotFound = "ticket_type_not_found"
+	CodeSoldOut            = "sold_out"
+	CodeSalesNotStarted    = "sales_not_started"
+	CodeSalesEnded         = "sales_ended"
+	CodeBelowMinPerOrder   = "below_min_per_order"
+	CodeAboveMaxPerOrder   = "above_max_per_order"
+	CodeUserLimitReached   = "user_limit_reached"
+	CodeInvalidPromoCode   = "invalid_promo_code"
+	CodeSeatUnavailable    = "seat_unavailable"
+	CodeQueueTokenRequired = "queue_token_required"
+	CodeQueueNotAdmitted   = "queue_not_admitted"
+	CodeQueueTokenInvalid  = "queue_token_invalid"
+	CodeQueueTokenExpired  = "queue_token_expired"
+	CodeInvalidAnswer      = "invalid_answer"
+	CodeEmailNotVerified   = "email_not_verified"
+
+	CodeMFAEnrollmentRequired = "mfa_enrollment_required"
+
+	CodeIdempotencyKeyReused     = "idempotency_key_reused"
+	CodeIdempotencyKeyInProgress = "idempotency_key_in_progress"
+)
+
+// CodedError is a business rule violation carrying a stable code. Handlers
+// use the code to pick the status and return it to the client.
+type CodedError struct {
+	Code    string
+	Message string
+}
+
+func (e *CodedError) Error() string {
+	return e.Message
+}
+
+func newCodedError(code string, format string, args ...interface{}) *CodedError {
+	return &CodedError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEventNotFound     = errors.New("event not found")
+	ErrNotEventOrganizer = errors.New("you can only manage events you organize")
+)
+
+// EventAccessService decides who may manage an event: its organizer, and roles
+// allowed to manage any event.
+type EventAccessService struct {
+	DB *gorm.DB
+}
+
+func NewEventAccessService(db *gorm.DB) *EventAccessService {
+	return &EventAccessService{DB: db}
+}
+
+// CheckOwnership returns nil when a user with role may manage the event.
+func (s *EventAccessService) CheckOwnership(userID uuid.UUID, role domain.UserRole, eventID uuid.UUID) error {
+	if role.Can(domain.PermEventManageAny) {
+		return nil
+	}
+
+	var event domain.Event
+	if err := s.DB.Select("id", "organizer_id").First(&event, "id = ?", eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrEventNotFound
+		}
+		return err
+	}
+	if event.OrganizerID == nil || *event.OrganizerID != userID {
+		return ErrNotEventOrganizer
+	}
+	return nil
+}
+
+// ManagedEvents scopes an event query to the events a user may manage.
+func ManagedEvents(userID uuid.UUID, role domain.UserRole) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if role.Can(domain.PermEventManageAny) {
+			return db
+		}
+		return db.Where("events.organizer_id = ?", userID)
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+)
+
+func TestEventAccessService_CheckOwnership(t *testing.T) {
+	gormDB, mock := newMockDB(t)
+	svc := NewEventAccessService(gormDB)
+
+	organizerID := uuid.New()
+	eventID := uuid.New()
+
+	tests := []struct {
+		name      string
+		userID    uuid.UUID
+		role      domain.UserRole
+		owner     *uuid.UUID
+		found     bool
+		wantQuery bool
+		want      error
+	}{
+		{"admin manages any event", uuid.New(), domain.RoleAdmin, nil, true, false, nil},
+		{"organizer manages own event", organizerID, domain.RoleOrganizer, &organizerID, true, true, nil},
+		{"organizer refused another's event", uuid.New(), domain.RoleOrganizer, &organizerID, true, true, ErrNotEventOrganizer},
+		{"organizer refused unowned event", organizerID, domain.RoleOrganizer, nil, true, true, ErrNotEventOrganizer},
+		{"missing event", organizerID, domain.RoleOrganizer, nil, false, true, ErrEventNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantQuery {
+				rows := sqlmock.NewRows([]string{"id", "organizer_id"})
+				if tt.found {
+					rows.AddRow(eventID, tt.owner)
+				}
+				mock.ExpectQuery(`SELECT "id","organizer_id" FROM "events" WHERE id = \$1`).
+					WithArgs(eventID, 1).
+					WillReturnRows(rows)
+			}
+
+			if err := svc.CheckOwnership(tt.userID, tt.role, eventID); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Expectations were not met: %s", err)
+			}
+		})
+	}
+}
+
+func TestUserRole_Can(t *testing.T) {
+	tests := []struct {
+		role domain.UserRole
+		perm domain.Permission
+		want bool
+	}{
+		{domain.RoleAdmin, domain.PermUsersManage, true},
+		{domain.RoleOrganizer, domain.PermEventUpdate, true},
+		{domain.RoleOrganizer, domain.PermAttendeesRead, true},
+		{domain.RoleOrganizer, domain.PermEventManageAny, false},
+		{domain.RoleOrganizer, domain.PermAnalyticsRead, false},
+		{domain.RoleStaff, domain.PermTicketsCheckIn, true},
+		{domain.RoleStaff, domain.PermAttendeesRead, false},
+		{domain.RoleUser, domain.PermTicketsCheckIn, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.Can(tt.perm); got != tt.want {
+			t.Errorf("%s.Can(%s) = %v, want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"gorm.io/gorm"
+)
+
+// ExportService streams attendee lists for organizers. Registrations are read
+// in batches of BatchSize so exports of any size use bounded memory.
+type ExportService struct {
+	DB        *gorm.DB
+	BatchSize int
+}
+
+func NewExportService(db *gorm.DB, batchSize int) *ExportService {
+	return &ExportService{DB: db, BatchSize: batchSize}
+}
+
+// attendeeRow is one registration with everything an export column may read.
+type attendeeRow struct {
+	registration *domain.Registration
+	checkIn      *domain.CheckIn
+	answers      map[uuid.UUID]json.RawMessage
+}
+
+type exportColumn struct {
+	key    string
+	header string
+	value  func(row *attendeeRow) string
+}
+
+// answersColumnKey selects every registration question as its own column.
+const answersColumnKey = "answers"
+
+var attendeeColumns = []exportColumn{
+	{"registration_id", "Registration ID", func(r *attendeeRow) string { return r.registration.ID.String() }},
+	{"ticket_code", "Ticket Code", func(r *attendeeRow) string {
+		if r.registration.Ticket == nil {
+			return ""
+		}
+		return r.registration.Ticket.TicketCode
+	}},
+	{"name", "Name", func(r *attendeeRow) string {
+		if r.registration.AttendeeName == "" && r.registration.User != nil {
+			return r.registration.User.Name
+		}
+		return r.registration.AttendeeName
+	}},
+	{"email", "Email", func(r *attendeeRow) string { return r.registration.ContactEmail() }},
+	{"ticket_type", "Ticket Type", func(r *attendeeRow) string { return r.registration.TicketType.Name }},
+	{"status", "RSVP Status", func(r *attendeeRow) string { return string(r.registration.Status) }},
+	{"amount", "Amount Paid", func(r *attendeeRow) string { return strconv.FormatFloat(r.registration.Amount, 'f', 2, 64) }},
+	{"seat", "Seat", func(r *attendeeRow) string { return seatLabel(r.registration.Seat) }},
+	{"registered_at", "Registered At", func(r *attendeeRow) string { return r.registration.CreatedAt.UTC().Format(time.RFC3339) }},
+	{"checked_in", "Checked In", func(r *attendeeRow) string {
+		if r.checkIn == nil {
+			return "no"
+		}
+		return "yes"
+	}},
+	{"checked_in_at", "Checked In At", func(r *attendeeRow) string {
+		if r.checkIn == nil {
+			return ""
+		}
+		return r.checkIn.CheckedInAt.UTC().Format(time.RFC3339)
+	}},
+	{"gate", "Gate", func(r *attendeeRow) string {
+		if r.checkIn == nil {
+			return ""
+		}
+		return r.checkIn.Gate
+	}},
+}
+
+// AttendeeExport is a prepared export whose header is known before any row
+// is read, so callers can reject bad requests before they start streaming.
+type AttendeeExport struct {
+	Event   domain.Event
+	Header  []string
+	columns []exportColumn
+	service *ExportService
+}
+
+// PrepareAttendeeExport resolves the requested column keys for an event. No
+// keys selects every column; "answers" expands to one column per registration
+// question.
+func (s *ExportService) PrepareAttendeeExport(eventID uuid.UUID, keys []string) (*AttendeeExport, error) {
+	var event domain.Event
+	if err := s.DB.First(&event, "id = ?", eventID).Error; err != nil {
+		return nil, errors.New("event not found")
+	}
+
+	// Deleted questions still hold answers worth exporting
+	var questions []domain.RegistrationQuestion
+	if err := s.DB.Unscoped().Where("event_id = ?", eventID).Order("position, created_at").Find(&questions).Error; err != nil {
+		return nil, err
+	}
+
+	columns, err := selectAttendeeColumns(keys, questions)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &AttendeeExport{Event: event, columns: columns, service: s}
+	for _, column := range columns {
+		export.Header = append(export.Header, column.header)
+	}
+	return export, nil
+}
+
+// Stream reads the event's attendees batch by batch and hands each row to
+// write, in column order, calling flush after every batch. It stops at the
+// first error either returns.
+func (e *AttendeeExport) Stream(write func(row []string) error, flush func() error) error {
+	withAnswers := false
+	for _, column := range e.columns {
+		if strings.HasPrefix(column.key, answersColumnKey+":") {
+			withAnswers = true
+		}
+	}
+
+	var batch []domain.Registration
+	db := e.service.DB
+	result := db.Preload("User").
+		Preload("TicketType").
+		Preload("Ticket").
+		Preload("Seat.Section").
+		Where("event_id = ? AND status NOT IN ?", e.Event.ID, []domain.RegistrationStatus{domain.RegistrationCancelled, domain.RegistrationPendingPayment}).
+		FindInBatches(&batch, e.service.BatchSize, func(tx *gorm.DB, _ int) error {
+			ids := make([]uuid.UUID, len(batch))
+			for i := range batch {
+				ids[i] = batch[i].ID
+			}
+
+			var checkIns []domain.CheckIn
+			if err := db.Where("registration_id IN ?", ids).Find(&checkIns).Error; err != nil {
+				return err
+			}
+			checkInsByRegistration := make(map[uuid.UUID]*domain.CheckIn, len(checkIns))
+			for i := range checkIns {
+				checkInsByRegistration[checkIns[i].RegistrationID] = &checkIns[i]
+			}
+
+			answersByRegistration := map[uuid.UUID]map[uuid.UUID]json.RawMessage{}
+			if withAnswers {
+				var answers []domain.RegistrationAnswer
+				if err := db.Where("registration_id IN ?", ids).Find(&answers).Error; err != nil {
+					return err
+				}
+				for _, answer := range answers {
+					if answersByRegistration[answer.RegistrationID] == nil {
+						answersByRegistration[answer.RegistrationID] = map[uuid.UUID]json.RawMessage{}
+					}
+					answersByRegistration[answer.RegistrationID][answer.QuestionID] = answer.Value
+				}
+			}
+
+			for i := range batch {
+				row := attendeeRow{
+					registration: &batch[i],
+					checkIn:      checkInsByRegistration[batch[i].ID],
+					answers:      answersByRegistration[batch[i].ID],
+				}
+				cells := make([]string, len(e.columns))
+				for j, column := range e.columns {
+					cells[j] = column.value(&row)
+				}
+				if err := write(cells); err != nil {
+					return err
+				}
+			}
+			return flush()
+		})
+	return result.Error
+}
+
+// selectAttendeeColumns maps requested keys to columns, in the order asked.
+func selectAttendeeColumns(keys []string, questions []domain.RegistrationQuestion) ([]exportColumn, error) {
+	answerColumns := make([]exportColumn, 0, len(questions))
+	for _, question := range questions {
+		questionID := question.ID
+		answerColumns = append(answerColumns, exportColumn{
+			key:    answersColumnKey + ":" + questionID.String(),
+			header: question.Label,
+			value: func(r *attendeeRow) string {
+				return formatAnswer(r.answers[questionID])
+			},
+		})
+	}
+
+	if len(keys) == 0 {
+		return append(append([]exportColumn{}, attendeeColumns...), answerColumns...), nil
+	}
+
+	var columns []exportColumn
+	seen := map[string]bool{}
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if key == answersColumnKey {
+			columns = append(columns, answerColumns...)
+			continue
+		}
+		found := false
+		for _, column := range attendeeColumns {
+			if column.key == key {
+				columns = append(columns, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown column %q", key)
+		}
+	}
+	return columns, nil
+}
+
+// formatAnswer renders a stored answer as spreadsheet text: choices are joined
+// with "; " and checkboxes become yes or no.
+func formatAnswer(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return string(raw)
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "yes"
+		}
+		return "no"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, "; ")
+	}
+	return string(raw)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+)
+
+func TestSelectAttendeeColumns(t *testing.T) {
+	diet := domain.RegistrationQuestion{ID: uuid.New(), Label: "Diet"}
+	company := domain.RegistrationQuestion{ID: uuid.New(), Label: "Company"}
+	form := []domain.RegistrationQuestion{diet, company}
+
+	headers := func(columns []exportColumn) []string {
+		var out []string
+		for _, column := range columns {
+			out = append(out, column.header)
+		}
+		return out
+	}
+
+	all, err := selectAttendeeColumns(nil, form)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(all) != len(attendeeColumns)+2 {
+		t.Fatalf("expected every column, got %d", len(all))
+	}
+
+	picked, err := selectAttendeeColumns([]string{"email", " name", "answers", "email"}, form)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := headers(picked), []string{"Email", "Name", "Diet", "Company"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("headers = %v, want %v", got, want)
+	}
+
+	if _, err := selectAttendeeColumns([]string{"email", "password"}, form); err == nil {
+		t.Fatal("expected an unknown column to be rejected")
+	}
+}
+
+func TestFormatAnswer(t *testing.T) {
+	tests := map[string]string{
+		``:              "",
+		`"Vegan"`:       "Vegan",
+		`42`:            "42",
+		`2.5`:           "2.5",
+		`true`:          "yes",
+		`["AM","PM"]`:   "AM; PM",
+		`{"odd":"val"}`: `{"odd":"val"}`,
+	}
+	for raw, want := range tests {
+		if got := formatAnswer(json.RawMessage(raw)); got != want {
+			t.Errorf("formatAnswer(%s) = %q, want %q", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/worker"
+	"github.com/username/event-ticketing-system/pkg/email"
+	"github.com/username/event-ticketing-system/pkg/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type HoldService struct {
+	DB            *gorm.DB
+	TicketService *TicketService
+	EmailService  email.EmailService
+	Pool          *worker.WorkerPool
+	Inventory     *Inventory
+	Waitlist      *WaitlistService
+	Payments      *PaymentService
+	HoldTTL       time.Duration
+}
+
+func NewHoldService(db *gorm.DB, ts *TicketService, es email.EmailService, pool *worker.WorkerPool, inv *Inventory, wl *WaitlistService, payments *PaymentService, holdTTL time.Duration) *HoldService {
+	return &HoldService{DB: db, TicketService: ts, EmailService: es, Pool: pool, Inventory: inv, Waitlist: wl, Payments: payments, HoldTTL: holdTTL}
+}
+
+// CreateHold reserves seats of a ticket type for HoldTTL. The seats leave
+// stock immediately, the same way as for a direct registration.
+// queueToken is only needed for events with a waiting room.
+func (s *HoldService) CreateHold(userID uuid.UUID, eventID uuid.UUID, ticketTypeID uuid.UUID, quantity int, queueToken string) (*domain.SeatHold, error) {
+	if quantity <= 0 {
+		return nil, errors.New("quantity must be greater than 0")
+	}
+
+	var user domain.User
+	if err := s.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	if err := checkEmailVerified(&user); err != nil {
+		return nil, err
+	}
+
+	admission, err := checkQueueAdmission(s.DB, eventID, userID, queueToken)
+	if err != nil {
+		return nil, err
+	}
+
+	hold := domain.SeatHold{
+		ID:           uuid.New(),
+		UserID:       userID,
+		EventID:      eventID,
+		TicketTypeID: ticketTypeID,
+		Quantity:     quantity,
+		Status:       domain.HoldActive,
+		ExpiresAt:    time.Now().Add(s.HoldTTL),
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if _, _, err := reserveSeats(tx, s.Inventory, userID, eventID, ticketTypeID, quantity); err != nil {
+			return err
+		}
+		if err := spendQueueTicket(tx, admission); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&hold).Error; err != nil {
+			return err
+		}
+
+		audit := domain.AuditLog{
+			ID:         uuid.New(),
+			UserID:     userID,
+			Action:     "CREATE_HOLD",
+			EntityType: "seat_hold",
+			EntityID:   hold.ID,
+			NewValues:  utils.ToJSON(hold),
+			CreatedAt:  time.Now(),
+		}
+		return tx.Create(&audit).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &hold, nil
+}
+
+// ConfirmHold converts an active hold into one registration per seat, each
+// with the given answers to the event's registration form. Paid seats share a
+// single payment and are confirmed once it settles.
+func (s *HoldService) ConfirmHold(userID uuid.UUID, holdID uuid.UUID, answers map[uuid.UUID]json.RawMessage) ([]domain.Registration, error) {
+	var registrations []domain.Registration
+	var hold domain.SeatHold
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&hold, "id = ? AND user_id = ?", holdID, userID).Error; err != nil {
+			return errors.New("hold not found")
+		}
+
+		if hold.Status != domain.HoldActive {
+			return fmt.Errorf("hold is %s", hold.Status)
+		}
+		if time.Now().After(hold.ExpiresAt) {
+			return errors.New("hold has expired")
+		}
+
+		var event domain.Event
+		if err := tx.First(&event, "id = ?", hold.EventID).Error; err != nil || event.Status != domain.StatusPublished {
+			return errors.New("cannot register for an event that is not published")
+		}
+
+		var ticketType domain.TicketType
+		if err := tx.First(&ticketType, "id = ?", hold.TicketTypeID).Error; err != nil {
+			return errors.New("ticket type not found")
+		}
+
+		payment, err := s.Payments.Begin(tx, userID, ticketType.Price*float64(hold.Quantity))
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < hold.Quantity; i++ {
+			registration, err := issueRegistration(tx, s.TicketService, userID, hold.EventID, &ticketType, payment, answers, "CONFIRM_HOLD")
+			if err != nil {
+				return err
+			}
+			registrations = append(registrations, *registration)
+		}
+
+		hold.Status = domain.HoldConfirmed
+		return tx.Save(&hold).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if registrations[0].Status != domain.RegistrationConfirmed {
+		if err := s.Payments.Open(registrations[0].Payment); err != nil {
+			return nil, err
+		}
+		return registrations, nil
+	}
+
+	var user domain.User
+	var event domain.Event
+	if err := s.DB.First(&user, "id = ?", userID).Error; err == nil {
+		s.DB.First(&event, "id = ?", hold.EventID)
+		for _, registration := range registrations {
+			queueTicketEmail(s.Pool, s.EmailService, user.Email, registration.ID, event.Title)
+		}
+	}
+
+	return registrations, nil
+}
+
+func (s *HoldService) ReleaseHold(userID uuid.UUID, holdID uuid.UUID) error {
+	return s.release(holdID, &userID, domain.HoldReleased)
+}
+
+// ExpireHolds releases every active hold past its deadline. It runs from the
+// background sweeper.
+func (s *HoldService) ExpireHolds() {
+	var expired []domain.SeatHold
+	if err := s.DB.Where("status = ? AND expires_at < ?", domain.HoldActive, time.Now()).Find(&expired).Error; err != nil {
+		utils.Logger.Error("Failed to fetch expired seat holds", zap.Error(err))
+		return
+	}
+
+	for _, hold := range expired {
+		if err := s.release(hold.ID, nil, domain.HoldExpired); err != nil {
+			utils.Logger.Error("Failed to release expired seat hold", zap.String("hold_id", hold.ID.String()), zap.Error(err))
+		}
+	}
+}
+
+// release returns the seats of an active hold to the waitlist or inventory.
+// A nil userID means the system is acting, so ownership is not checked.
+func (s *HoldService) release(holdID uuid.UUID, userID *uuid.UUID, status domain.HoldStatus) error {
+	var offers []*domain.WaitlistEntry
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", holdID)
+		if userID != nil {
+			query = query.Where("user_id = ?", *userID)
+		}
+
+		var hold domain.SeatHold
+		if err := query.First(&hold).Error; err != nil {
+			return errors.New("hold not found")
+		}
+
+		if hold.Status != domain.HoldActive {
+			// Confirmed or released since the sweeper listed it
+			if userID == nil {
+				return nil
+			}
+			return fmt.Errorf("hold is %s", hold.Status)
+		}
+
+		hold.Status = status
+		if err := tx.Save(&hold).Error; err != nil {
+			return err
+		}
+
+		for i := 0; i < hold.Quantity; i++ {
+			offer, err := s.Waitlist.ReleaseSeat(tx, hold.EventID, hold.TicketTypeID)
+			if err != nil {
+				return err
+			}
+			if offer != nil {
+				offers = append(offers, offer)
+			}
+		}
+
+		actorID := hold.UserID
+		if userID != nil {
+			actorID = *userID
+		}
+		audit := domain.AuditLog{
+			ID:         uuid.New(),
+			UserID:     actorID,
+			Action:     "RELEASE_HOLD",
+			EntityType: "seat_hold",
+			EntityID:   hold.ID,
+			NewValues:  fmt.Sprintf(`{"status": "%s", "quantity": %d}`, status, hold.Quantity),
+			CreatedAt:  time.Now(),
+		}
+		return tx.Create(&audit).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, offer := range offers {
+		s.Waitlist.NotifyOffer(offer.ID)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+)
+
+func newTestHoldService(t *testing.T) (*HoldService, sqlmock.Sqlmock, func()) {
+	rs, mock, cleanup := newTestRegistrationService(t)
+	return NewHoldService(rs.DB, rs.TicketService, rs.EmailService, rs.Pool, rs.Inventory, rs.Waitlist, rs.Payments, 10*time.Minute), mock, cleanup
+}
+
+func TestHoldService_CreateHold_TakesStock(t *testing.T) {
+	svc, mock, cleanup := newTestHoldService(t)
+	defer cleanup()
+
+	userID, eventID, ticketTypeID := uuid.New(), uuid.New(), uuid.New()
+
+	mock.ExpectQuery(`SELECT \* FROM "users"`).
+		WithArgs(userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "email_verified_at"}).AddRow(userID, "user@test.com", time.Now()))
+	mock.ExpectQuery(`SELECT "id","waiting_room" FROM "events"`).
+		WithArgs(eventID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "waiting_room"}).AddRow(eventID, false))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "events"`).
+		WithArgs(eventID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(eventID, domain.StatusPublished))
+	mock.ExpectQuery(`SELECT \* FROM "ticket_types"`).
+		WithArgs(ticketTypeID, eventID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "name", "price"}).AddRow(ticketTypeID, eventID, "GA", 20.0))
+	mock.ExpectExec(`UPDATE ticket_type_shards SET remaining = remaining - \$1`).
+		WithArgs(3, ticketTypeID, 3, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "seat_holds"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(userID, "CREATE_HOLD", "seat_hold", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	hold, err := svc.CreateHold(userID, eventID, ticketTypeID, 3, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hold.Status != domain.HoldActive || hold.Quantity != 3 || time.Until(hold.ExpiresAt) <= 9*time.Minute {
+		t.Errorf("expected an active hold of 3 seats for the hold TTL, got %+v", hold)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestHoldService_ConfirmHold_SharesOnePayment(t *testing.T) {
+	svc, mock, cleanup := newTestHoldService(t)
+	defer cleanup()
+
+	userID, holdID, eventID, ticketTypeID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "seat_holds" WHERE id = \$1 AND user_id = \$2 .* FOR UPDATE`).
+		WithArgs(holdID, userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "event_id", "ticket_type_id", "quantity", "status", "expires_at"}).
+			AddRow(holdID, userID, eventID, ticketTypeID, 2, domain.HoldActive, time.Now().Add(time.Minute)))
+	mock.ExpectQuery(`SELECT \* FROM "events"`).
+		WithArgs(eventID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(eventID, domain.StatusPublished))
+	mock.ExpectQuery(`SELECT \* FROM "ticket_types"`).
+		WithArgs(ticketTypeID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "name", "price"}).AddRow(ticketTypeID, eventID, "GA", 20.0))
+	mock.ExpectQuery(`INSERT INTO "payments"`).
+		WithArgs(userID, "fake", sqlmock.AnyArg(), sqlmock.AnyArg(), 40.0, "USD", domain.PaymentPending, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery(`SELECT \* FROM "registration_questions"`).
+			WithArgs(eventID, ticketTypeID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery(`INSERT INTO "registrations"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectQuery(`INSERT INTO "audit_logs"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	}
+	mock.ExpectExec(`UPDATE "seat_holds"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`UPDATE "payments" SET "client_secret"=\$1,"intent_id"=\$2`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	registrations, err := svc.ConfirmHold(userID, holdID, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(registrations) != 2 {
+		t.Fatalf("expected one registration per held seat, got %d", len(registrations))
+	}
+	for _, r := range registrations {
+		if r.Status != domain.RegistrationPendingPayment || r.PaymentID == nil || *r.PaymentID != *registrations[0].PaymentID {
+			t.Errorf("expected both seats to await the same payment, got %+v", r)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestHoldService_ConfirmHold_RequiresFormAnswers(t *testing.T) {
+	svc, mock, cleanup := newTestHoldService(t)
+	defer cleanup()
+
+	userID, holdID, eventID, ticketTypeID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "seat_holds"`).
+		WithArgs(holdID, userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "event_id", "ticket_type_id", "quantity", "status", "expires_at"}).
+			AddRow(holdID, userID, eventID, ticketTypeID, 1, domain.HoldActive, time.Now().Add(time.Minute)))
+	mock.ExpectQuery(`SELECT \* FROM "events"`).
+		WithArgs(eventID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(eventID, domain.StatusPublished))
+	mock.ExpectQuery(`SELECT \* FROM "ticket_types"`).
+		WithArgs(ticketTypeID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "name", "price"}).AddRow(ticketTypeID, eventID, "GA", 0.0))
+	mock.ExpectQuery(`SELECT \* FROM "registration_questions"`).
+		WithArgs(eventID, ticketTypeID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "label", "type", "required"}).
+			AddRow(uuid.New(), eventID, "Dietary needs", domain.QuestionText, true))
+	mock.ExpectRollback()
+
+	_, err := svc.ConfirmHold(userID, holdID, nil)
+	var coded *CodedError
+	if !errors.As(err, &coded) || coded.Code != CodeInvalidAnswer {
+		t.Errorf("expected the unanswered form to be refused, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestHoldService_ConfirmHold_RejectsExpiredHold(t *testing.T) {
+	svc, mock, cleanup := newTestHoldService(t)
+	defer cleanup()
+
+	userID, holdID := uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "seat_holds"`).
+		WithArgs(holdID, userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status", "expires_at"}).
+			AddRow(holdID, userID, domain.HoldActive, time.Now().Add(-time.Second)))
+	mock.ExpectRollback()
+
+	if _, err := svc.ConfirmHold(userID, holdID, nil); err == nil || err.Error() != "hold has expired" {
+		t.Errorf("expected an expired hold to be refused, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestHoldService_ExpireHolds_ReturnsSeats(t *testing.T) {
+	svc, mock, cleanup := newTestHoldService(t)
+	defer cleanup()
+
+	holdID, userID, eventID, ticketTypeID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	columns := []string{"id", "user_id", "event_id", "ticket_type_id", "quantity", "status", "expires_at"}
+	lapsed := time.Now().Add(-time.Minute)
+
+	mock.ExpectQuery(`SELECT \* FROM "seat_holds" WHERE status = \$1 AND expires_at < \$2`).
+		WithArgs(domain.HoldActive, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(holdID, userID, eventID, ticketTypeID, 2, domain.HoldActive, lapsed))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "seat_holds" WHERE id = \$1 .* FOR UPDATE`).
+		WithArgs(holdID, 1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(holdID, userID, eventID, ticketTypeID, 2, domain.HoldActive, lapsed))
+	mock.ExpectExec(`UPDATE "seat_holds"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	// Nobody is waiting, so each seat goes back to stock
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery(`SELECT \* FROM "waitlist_entries" .* FOR UPDATE SKIP LOCKED`).
+			WithArgs(ticketTypeID, domain.WaitlistWaiting, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec(`UPDATE ticket_type_shards SET remaining = remaining \+ \$1`).
+			WithArgs(1, ticketTypeID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(userID, "RELEASE_HOLD", "seat_hold", holdID, sqlmock.AnyArg(), `{"status": "expired", "quantity": 2}`, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	svc.ExpireHolds()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
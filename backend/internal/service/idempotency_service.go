@@ -0,0 +1,146 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/pkg/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyService stores Idempotency-Key headers with the response of the
+// first request that used them. Keys are scoped to the user and kept for TTL.
+// A request holds its key for Lease; if it has not finished by then, say
+// because the server died mid-request, a retry may take the key over.
+type IdempotencyService struct {
+	DB    *gorm.DB
+	TTL   time.Duration
+	Lease time.Duration
+}
+
+func NewIdempotencyService(db *gorm.DB, ttl time.Duration, lease time.Duration) *IdempotencyService {
+	return &IdempotencyService{DB: db, TTL: ttl, Lease: lease}
+}
+
+// newLease returns when a lease taken now runs out. It is cut to the
+// microsecond the database keeps, so it can be matched to release the key.
+func (s *IdempotencyService) newLease() *time.Time {
+	lease := time.Now().Add(s.Lease).Truncate(time.Microsecond)
+	return &lease
+}
+
+// Begin claims key for a request with the given fingerprint. It returns the new
+// record with replay false when the request should run, or the stored record
+// with replay true when its response should be sent back instead. Reusing a
+// key for a different request, or while the first one still holds its lease,
+// is a coded error.
+func (s *IdempotencyService) Begin(userID uuid.UUID, key string, requestHash string) (*domain.IdempotencyKey, bool, error) {
+	record := domain.IdempotencyKey{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Key:         key,
+		RequestHash: requestHash,
+		LockedUntil: s.newLease(),
+		CreatedAt:   time.Now(),
+	}
+
+	// A key past its TTL that has not been purged yet is free to use again
+	if err := s.DB.Where("user_id = ? AND key = ? AND created_at < ?", userID, key, time.Now().Add(-s.TTL)).
+		Delete(&domain.IdempotencyKey{}).Error; err != nil {
+		return nil, false, err
+	}
+
+	result := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return &record, false, nil
+	}
+
+	var existing domain.IdempotencyKey
+	if err := s.DB.First(&existing, "user_id = ? AND key = ?", userID, key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The first request was abandoned between our insert and this read
+			return nil, false, newCodedError(CodeIdempotencyKeyInProgress, "a request with this Idempotency-Key is still being processed")
+		}
+		return nil, false, err
+	}
+	if existing.RequestHash != requestHash {
+		return nil, false, newCodedError(CodeIdempotencyKeyReused, "Idempotency-Key was already used for a different request")
+	}
+	if existing.StatusCode != 0 {
+		return &existing, true, nil
+	}
+
+	// Still in flight: take the key over only once its holder's lease has run out
+	lease := s.newLease()
+	result = s.DB.Model(&domain.IdempotencyKey{}).
+		Where("id = ? AND status_code = 0 AND (locked_until IS NULL OR locked_until < ?)", existing.ID, time.Now()).
+		Update("locked_until", lease)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, false, newCodedError(CodeIdempotencyKeyInProgress, "a request with this Idempotency-Key is still being processed")
+	}
+	utils.Logger.Warn("Took over idempotency key with a stale lease", zap.String("user_id", userID.String()), zap.String("key", key))
+	existing.LockedUntil = lease
+	return &existing, false, nil
+}
+
+// Complete stores the response produced for a claimed key. It fails if the
+// lease was lost to a retry, whose response is then the one kept.
+func (s *IdempotencyService) Complete(record *domain.IdempotencyKey, statusCode int, body []byte) error {
+	result := s.DB.Model(&domain.IdempotencyKey{}).
+		Where("id = ? AND locked_until = ?", record.ID, record.LockedUntil).
+		Updates(map[string]interface{}{
+			"status_code":   statusCode,
+			"response_body": body,
+			"locked_until":  nil,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("idempotency key lease was taken over by a retry")
+	}
+	return nil
+}
+
+// Abandon releases a claimed key whose request failed without a response
+// worth replaying, so the client can retry with the same key. A key already
+// taken over by a retry is left alone.
+func (s *IdempotencyService) Abandon(record *domain.IdempotencyKey) error {
+	return s.DB.Where("id = ? AND locked_until = ?", record.ID, record.LockedUntil).Delete(&domain.IdempotencyKey{}).Error
+}
+
+// PurgeExpired deletes keys older than TTL. It runs from the background sweeper.
+func (s *IdempotencyService) PurgeExpired() {
+	result := s.DB.Where("created_at < ?", time.Now().Add(-s.TTL)).Delete(&domain.IdempotencyKey{})
+	if result.Error != nil {
+		utils.Logger.Error("Failed to purge idempotency keys", zap.Error(result.Error))
+		return
+	}
+	if result.RowsAffected > 0 {
+		utils.Logger.Info("Purged idempotency keys", zap.Int64("count", result.RowsAffected))
+	}
+}
+
+// RequestFingerprint identifies a request by method, path and body, so a key
+// replayed against a different request can be told apart from a retry.
+func RequestFingerprint(method string, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func TestRequestFingerprint(t *testing.T) {
+	base := RequestFingerprint("POST", "/api/v1/registrations/", []byte(`{"event_id":"a"}`))
+
+	if got := RequestFingerprint("POST", "/api/v1/registrations/", []byte(`{"event_id":"a"}`)); got != base {
+		t.Errorf("same request fingerprinted differently: %s vs %s", got, base)
+	}
+
+	different := map[string]string{
+		"body":   RequestFingerprint("POST", "/api/v1/registrations/", []byte(`{"event_id":"b"}`)),
+		"path":   RequestFingerprint("POST", "/api/v1/registrations/x/transfer", []byte(`{"event_id":"a"}`)),
+		"method": RequestFingerprint("DELETE", "/api/v1/registrations/", []byte(`{"event_id":"a"}`)),
+		// Field boundaries must not be ambiguous
+		"split": RequestFingerprint("POST", "/api/v1/registrations/{", []byte(`"event_id":"a"}`)),
+	}
+	for name, got := range different {
+		if got == base {
+			t.Errorf("request differing by %s has the same fingerprint", name)
+		}
+	}
+}
+
+// expectClaimedKey expects Begin to find the key already claimed by an
+// unfinished request whose lease ends at lockedUntil.
+func expectClaimedKey(mock sqlmock.Sqlmock, recordID uuid.UUID, userID uuid.UUID, lockedUntil time.Time) {
+	mock.ExpectExec(`DELETE FROM "idempotency_keys"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "idempotency_keys" .* ON CONFLICT DO NOTHING`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "idempotency_keys"`).
+		WithArgs(userID, "key-1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "key", "request_hash", "status_code", "locked_until"}).
+			AddRow(recordID, userID, "key-1", "hash", 0, lockedUntil))
+}
+
+func TestIdempotencyService_Begin_TakesOverStaleLease(t *testing.T) {
+	gormDB, mock := newMockDB(t)
+	svc := NewIdempotencyService(gormDB, time.Hour, time.Minute)
+
+	recordID, userID := uuid.New(), uuid.New()
+	expectClaimedKey(mock, recordID, userID, time.Now().Add(-time.Second))
+	mock.ExpectExec(`UPDATE "idempotency_keys" SET "locked_until"=\$1 WHERE id = \$2 AND status_code = 0 AND \(locked_until IS NULL OR locked_until < \$3\)`).
+		WithArgs(sqlmock.AnyArg(), recordID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	record, replay, err := svc.Begin(userID, "key-1", "hash")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if replay || record.ID != recordID || record.LockedUntil == nil || !record.LockedUntil.After(time.Now()) {
+		t.Errorf("expected the retry to run under a fresh lease, got replay=%v %+v", replay, record)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestIdempotencyService_Begin_RejectsLiveLease(t *testing.T) {
+	gormDB, mock := newMockDB(t)
+	svc := NewIdempotencyService(gormDB, time.Hour, time.Minute)
+
+	recordID, userID := uuid.New(), uuid.New()
+	expectClaimedKey(mock, recordID, userID, time.Now().Add(time.Minute))
+	// The holder's lease has not run out, so the conditional update matches nothing
+	mock.ExpectExec(`UPDATE "idempotency_keys" SET "locked_until"`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	_, _, err := svc.Begin(userID, "key-1", "hash")
+	var coded *CodedError
+	if !errors.As(err, &coded) || coded.Code != CodeIdempotencyKeyInProgress {
+		t.Errorf("expected the key to stay with its holder, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
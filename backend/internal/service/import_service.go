@@ -0,0 +1,427 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/worker"
+	"github.com/username/event-ticketing-system/pkg/email"
+	"github.com/username/event-ticketing-system/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// ImportService issues complimentary tickets from an attendee list. Rows whose
+// email has an account are ticketed to it; the others get a guest account,
+// which has no password until its owner resets it.
+type ImportService struct {
+	DB            *gorm.DB
+	TicketService *TicketService
+	EmailService  email.EmailService
+	Pool          *worker.WorkerPool
+	Inventory     *Inventory
+	BatchSize     int
+}
+
+func NewImportService(db *gorm.DB, ts *TicketService, es email.EmailService, pool *worker.WorkerPool, inv *Inventory, batchSize int) *ImportService {
+	return &ImportService{DB: db, TicketService: ts, EmailService: es, Pool: pool, Inventory: inv, BatchSize: batchSize}
+}
+
+// ImportOptions controls how an attendee list is applied.
+type ImportOptions struct {
+	// DryRun validates the list and reports what would happen without
+	// issuing anything.
+	DryRun bool
+	// BypassCapacity issues general admission comps on top of the ticket
+	// type's capacity, which grows to fit them, instead of out of its stock.
+	// Reserved-seating tiers are always limited to their free seats.
+	BypassCapacity bool
+}
+
+// ImportRowIssue points at one row of the uploaded list. Row is the line in
+// the file, counting the header as row 1.
+type ImportRowIssue struct {
+	Row     int    `json:"row"`
+	Email   string `json:"email,omitempty"`
+	Message string `json:"message"`
+}
+
+type ImportResult struct {
+	DryRun bool `json:"dry_run"`
+	Rows   int  `json:"rows"`
+	// Valid rows would be (or were) issued a ticket
+	Valid  int `json:"valid"`
+	Issued int `json:"issued"`
+	// Guests is how many of the issued tickets went to new guest accounts
+	Guests int `json:"guests"`
+	// Errors block the whole import; skipped rows already hold a ticket for
+	// the event, so a partly applied list can be uploaded again
+	Errors  []ImportRowIssue `json:"errors"`
+	Skipped []ImportRowIssue `json:"skipped"`
+}
+
+// compTicket is an issued comp with the address its ticket is emailed to.
+// Guest is set when the owner's account was created for it.
+type compTicket struct {
+	Registration domain.Registration
+	Email        string
+	Guest        bool
+}
+
+// importRow is one line of the list. TicketType is set once the row is valid.
+type importRow struct {
+	Line          int
+	Name          string
+	Email         string
+	TicketTypeRef string
+	TicketType    *domain.TicketType
+}
+
+var importColumns = []string{"name", "email", "ticket_type"}
+
+// ImportAttendees validates every row of a CSV with name, email and
+// ticket_type columns (the ticket type by name or ID) and, unless the list has
+// errors or this is a dry run, issues the tickets in batches. A failed batch
+// stops the import; earlier batches stay issued.
+func (s *ImportService) ImportAttendees(adminID uuid.UUID, eventID uuid.UUID, r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	var event domain.Event
+	if err := s.DB.First(&event, "id = ?", eventID).Error; err != nil {
+		return nil, newCodedError(CodeEventNotFound, "event not found")
+	}
+	if event.Status == domain.StatusCancelled {
+		return nil, errors.New("cannot issue tickets for a cancelled event")
+	}
+
+	rows, err := parseImportCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var ticketTypes []domain.TicketType
+	if err := s.DB.Scopes(WithTicketTypeRemaining).Where("event_id = ?", eventID).Find(&ticketTypes).Error; err != nil {
+		return nil, err
+	}
+	registered, err := s.registeredEmails(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	valid, result := checkImportRows(rows, ticketTypes, registered, opts.BypassCapacity)
+	result.DryRun = opts.DryRun
+	if opts.DryRun || len(result.Errors) > 0 || len(valid) == 0 {
+		return result, nil
+	}
+
+	for start := 0; start < len(valid); start += s.BatchSize {
+		end := start + s.BatchSize
+		if end > len(valid) {
+			end = len(valid)
+		}
+		batch := valid[start:end]
+
+		issued, err := s.issueBatch(adminID, eventID, batch, opts.BypassCapacity)
+		if err != nil {
+			return result, fmt.Errorf("issued %d of %d tickets; rows from %d on were not imported: %w", result.Issued, len(valid), batch[0].Line, err)
+		}
+
+		for _, comp := range issued {
+			if comp.Guest {
+				result.Guests++
+			}
+			queueTicketEmail(s.Pool, s.EmailService, comp.Email, comp.Registration.ID, event.Title)
+		}
+		result.Issued += len(issued)
+	}
+	return result, nil
+}
+
+// registeredEmails returns the lowercased contact emails that already hold a
+// live registration for the event.
+func (s *ImportService) registeredEmails(eventID uuid.UUID) (map[string]bool, error) {
+	var emails []string
+	if err := s.DB.Model(&domain.Registration{}).
+		Joins("LEFT JOIN users ON users.id = registrations.user_id").
+		Where("registrations.event_id = ? AND registrations.status <> ?", eventID, domain.RegistrationCancelled).
+		Pluck("LOWER(COALESCE(users.email, registrations.attendee_email))", &emails).Error; err != nil {
+		return nil, err
+	}
+	registered := make(map[string]bool, len(emails))
+	for _, e := range emails {
+		registered[e] = true
+	}
+	return registered, nil
+}
+
+// issueBatch tickets a batch of validated rows in one transaction, creating a
+// guest account for each email that has none.
+func (s *ImportService) issueBatch(adminID uuid.UUID, eventID uuid.UUID, batch []importRow, bypass bool) ([]compTicket, error) {
+	emails := make([]string, len(batch))
+	for i, row := range batch {
+		emails[i] = strings.ToLower(row.Email)
+	}
+
+	counts := map[uuid.UUID]int{}
+	ticketTypes := map[uuid.UUID]*domain.TicketType{}
+	for _, row := range batch {
+		counts[row.TicketType.ID]++
+		ticketTypes[row.TicketType.ID] = row.TicketType
+	}
+	// Stock is taken in ticket type ID order, so concurrent imports and
+	// orders lock rows in the same order
+	ids := make([]uuid.UUID, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	issued := make([]compTicket, 0, len(batch))
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, id := range ids {
+			if bypass && ticketTypes[id].SeatZone == "" {
+				if err := tx.Model(&domain.TicketType{}).Where("id = ?", id).
+					UpdateColumn("capacity", gorm.Expr("capacity + ?", counts[id])).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err := s.Inventory.Take(tx, eventID, id, counts[id]); err != nil {
+				return err
+			}
+		}
+
+		accounts, guests, err := s.attendeeAccounts(tx, adminID, batch, emails)
+		if err != nil {
+			return err
+		}
+
+		for i, row := range batch {
+			seats, err := assignSeats(tx, eventID, row.TicketType, nil, 1)
+			if err != nil {
+				return err
+			}
+
+			userID := accounts[emails[i]]
+			registration := domain.Registration{
+				ID:           uuid.New(),
+				UserID:       &userID,
+				EventID:      eventID,
+				TicketTypeID: row.TicketType.ID,
+				SeatID:       seatIDAt(seats, 0),
+				Status:       domain.RegistrationConfirmed,
+			}
+			if err := issueCompRegistration(tx, s.TicketService, adminID, &registration); err != nil {
+				return err
+			}
+			issued = append(issued, compTicket{Registration: registration, Email: row.Email, Guest: guests[emails[i]]})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+// attendeeAccounts maps each lowercased email of the batch to its account,
+// creating guest accounts for the ones that have none. The guests are
+// reported in the second map. A guest has no password and an unverified
+// email; its owner signs in by resetting the password, or with a provider
+// that vouches for the address.
+func (s *ImportService) attendeeAccounts(tx *gorm.DB, adminID uuid.UUID, batch []importRow, emails []string) (map[string]uuid.UUID, map[string]bool, error) {
+	var users []domain.User
+	if err := tx.Select("id", "email").Where("LOWER(email) IN ?", emails).Find(&users).Error; err != nil {
+		return nil, nil, err
+	}
+	accounts := make(map[string]uuid.UUID, len(batch))
+	for _, user := range users {
+		accounts[strings.ToLower(user.Email)] = user.ID
+	}
+
+	guests := map[string]bool{}
+	for i, row := range batch {
+		if _, ok := accounts[emails[i]]; ok {
+			continue
+		}
+		guest := domain.User{
+			ID:    uuid.New(),
+			Email: row.Email,
+			Name:  row.Name,
+			Role:  domain.RoleUser,
+		}
+		// Someone signing up with the address meanwhile fails the batch on
+		// the unique email; uploading the list again tickets their account
+		if err := tx.Create(&guest).Error; err != nil {
+			return nil, nil, err
+		}
+		audit := domain.AuditLog{
+			ID:         uuid.New(),
+			UserID:     adminID,
+			Action:     "CREATE_GUEST_USER",
+			EntityType: "user",
+			EntityID:   guest.ID,
+			NewValues:  utils.ToJSON(map[string]interface{}{"email": guest.Email, "name": guest.Name}),
+			CreatedAt:  time.Now(),
+		}
+		if err := tx.Create(&audit).Error; err != nil {
+			return nil, nil, err
+		}
+		accounts[emails[i]] = guest.ID
+		guests[emails[i]] = true
+	}
+	return accounts, guests, nil
+}
+
+// issueCompRegistration creates a free, confirmed registration with its ticket
+// and audits it against the admin who issued it.
+func issueCompRegistration(tx *gorm.DB, ts *TicketService, adminID uuid.UUID, registration *domain.Registration) error {
+	if err := tx.Create(registration).Error; err != nil {
+		return err
+	}
+	ticket, err := ts.GenerateTicket(tx, registration.ID)
+	if err != nil {
+		return err
+	}
+	registration.Ticket = ticket
+
+	audit := domain.AuditLog{
+		ID:         uuid.New(),
+		UserID:     adminID,
+		Action:     "ISSUE_COMP_TICKET",
+		EntityType: "registration",
+		EntityID:   registration.ID,
+		NewValues: utils.ToJSON(map[string]interface{}{
+			"event_id":       registration.EventID,
+			"ticket_type_id": registration.TicketTypeID,
+			"user_id":        registration.UserID,
+		}),
+		CreatedAt: time.Now(),
+	}
+	return tx.Create(&audit).Error
+}
+
+// parseImportCSV reads the list's header and rows. Columns are matched by
+// name, in any order and case; extra columns are ignored.
+func parseImportCSV(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+
+	index := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		index[name] = i
+	}
+	for _, column := range importColumns {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("missing %q column; the header must name %s", column, strings.Join(importColumns, ", "))
+		}
+	}
+
+	field := func(record []string, column string) string {
+		if i := index[column]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, importRow{
+			Line:          line,
+			Name:          field(record, "name"),
+			Email:         field(record, "email"),
+			TicketTypeRef: field(record, "ticket_type"),
+		})
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("the file has no attendee rows")
+	}
+	return rows, nil
+}
+
+// checkImportRows validates every row against the event's ticket types, its
+// existing registrations and each ticket type's remaining stock. It returns
+// the rows to issue and the report of the others.
+func checkImportRows(rows []importRow, ticketTypes []domain.TicketType, registered map[string]bool, bypass bool) ([]importRow, *ImportResult) {
+	result := &ImportResult{Rows: len(rows), Errors: []ImportRowIssue{}, Skipped: []ImportRowIssue{}}
+	reject := func(row importRow, format string, args ...interface{}) {
+		result.Errors = append(result.Errors, ImportRowIssue{Row: row.Line, Email: row.Email, Message: fmt.Sprintf(format, args...)})
+	}
+
+	byRef := make(map[string]*domain.TicketType, 2*len(ticketTypes))
+	for i := range ticketTypes {
+		byRef[ticketTypes[i].ID.String()] = &ticketTypes[i]
+		byRef[strings.ToLower(ticketTypes[i].Name)] = &ticketTypes[i]
+	}
+
+	var valid []importRow
+	seen := map[string]int{}
+	taken := map[uuid.UUID]int{}
+	for _, row := range rows {
+		key := strings.ToLower(row.Email)
+		switch {
+		case row.Name == "":
+			reject(row, "name is required")
+			continue
+		case row.Email == "":
+			reject(row, "email is required")
+			continue
+		}
+		if addr, err := mail.ParseAddress(row.Email); err != nil || addr.Address != row.Email {
+			reject(row, "%q is not a valid email address", row.Email)
+			continue
+		}
+		if first, ok := seen[key]; ok {
+			reject(row, "duplicate of row %d", first)
+			continue
+		}
+		seen[key] = row.Line
+
+		ticketType := byRef[strings.ToLower(row.TicketTypeRef)]
+		if ticketType == nil {
+			reject(row, "unknown ticket type %q", row.TicketTypeRef)
+			continue
+		}
+		if registered[key] {
+			result.Skipped = append(result.Skipped, ImportRowIssue{Row: row.Line, Email: row.Email, Message: "already has a ticket for this event"})
+			continue
+		}
+
+		if !bypass || ticketType.SeatZone != "" {
+			if taken[ticketType.ID] >= ticketType.RemainingTickets {
+				reject(row, "only %d %s tickets remain", ticketType.RemainingTickets, ticketType.Name)
+				continue
+			}
+		}
+		taken[ticketType.ID]++
+
+		row.TicketType = ticketType
+		valid = append(valid, row)
+	}
+
+	result.Valid = len(valid)
+	return valid, result
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+)
+
+func TestParseImportCSV(t *testing.T) {
+	rows, err := parseImportCSV(strings.NewReader("\ufeffEmail,Name,Company,Ticket_Type\nada@example.com, Ada ,Acme,Speaker\n\nbob@example.com,Bob\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if rows[0] != (importRow{Line: 2, Name: "Ada", Email: "ada@example.com", TicketTypeRef: "Speaker"}) {
+		t.Errorf("unexpected first row: %+v", rows[0])
+	}
+	if rows[1].Line != 4 || rows[1].TicketTypeRef != "" {
+		t.Errorf("unexpected second row: %+v", rows[1])
+	}
+
+	if _, err := parseImportCSV(strings.NewReader("name,email\nAda,ada@example.com\n")); err == nil {
+		t.Error("expected a missing ticket_type column to be rejected")
+	}
+	if _, err := parseImportCSV(strings.NewReader("name,email,ticket_type\n")); err == nil {
+		t.Error("expected a list without rows to be rejected")
+	}
+}
+
+func TestCheckImportRows(t *testing.T) {
+	speaker := domain.TicketType{ID: uuid.New(), Name: "Speaker", RemainingTickets: 1}
+	floor := domain.TicketType{ID: uuid.New(), Name: "Floor", SeatZone: "floor", RemainingTickets: 0}
+	ticketTypes := []domain.TicketType{speaker, floor}
+	registered := map[string]bool{"old@example.com": true}
+
+	rows := []importRow{
+		{Line: 2, Name: "Ada", Email: "ada@example.com", TicketTypeRef: "speaker"},
+		{Line: 3, Name: "Bob", Email: "bob@example.com", TicketTypeRef: speaker.ID.String()},
+		{Line: 4, Name: "Ada again", Email: "ADA@example.com", TicketTypeRef: "Speaker"},
+		{Line: 5, Name: "", Email: "noname@example.com", TicketTypeRef: "Speaker"},
+		{Line: 6, Name: "Eve", Email: "not-an-email", TicketTypeRef: "Speaker"},
+		{Line: 7, Name: "Old", Email: "old@example.com", TicketTypeRef: "Speaker"},
+		{Line: 8, Name: "Zed", Email: "zed@example.com", TicketTypeRef: "Sponsor"},
+		{Line: 9, Name: "Flo", Email: "flo@example.com", TicketTypeRef: "Floor"},
+	}
+
+	tests := []struct {
+		name    string
+		bypass  bool
+		valid   []int
+		errors  []int
+		skipped []int
+	}{
+		{"capacity respected", false, []int{2}, []int{3, 4, 5, 6, 8, 9}, []int{7}},
+		{"capacity bypassed for general admission", true, []int{2, 3}, []int{4, 5, 6, 8, 9}, []int{7}},
+	}
+
+	lines := func(issues []ImportRowIssue) []int {
+		var out []int
+		for _, issue := range issues {
+			out = append(out, issue.Row)
+		}
+		return out
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, result := checkImportRows(rows, ticketTypes, registered, tt.bypass)
+
+			var validLines []int
+			for _, row := range valid {
+				if row.TicketType == nil || row.TicketType.ID != speaker.ID {
+					t.Errorf("row %d resolved to the wrong ticket type", row.Line)
+				}
+				validLines = append(validLines, row.Line)
+			}
+			if !reflect.DeepEqual(validLines, tt.valid) {
+				t.Errorf("valid rows = %v, want %v", validLines, tt.valid)
+			}
+			if got := lines(result.Errors); !reflect.DeepEqual(got, tt.errors) {
+				t.Errorf("error rows = %v, want %v", got, tt.errors)
+			}
+			if got := lines(result.Skipped); !reflect.DeepEqual(got, tt.skipped) {
+				t.Errorf("skipped rows = %v, want %v", got, tt.skipped)
+			}
+			if result.Rows != len(rows) || result.Valid != len(tt.valid) {
+				t.Errorf("unexpected counts: %+v", result)
+			}
+		})
+	}
+}
+
+// errIssueStop ends a test once the import reaches its first registration.
+var errIssueStop = errors.New("stop at registration")
+
+func TestImportService_IssueBatch_CreatesGuestAccounts(t *testing.T) {
+	rs, mock, cleanup := newTestRegistrationService(t)
+	defer cleanup()
+	svc := NewImportService(rs.DB, rs.TicketService, rs.EmailService, rs.Pool, rs.Inventory, 50)
+
+	adminID, eventID, bobID := uuid.New(), uuid.New(), uuid.New()
+	speaker := &domain.TicketType{ID: uuid.MustParse("00000000-0000-0000-0000-00000000000b"), Name: "Speaker"}
+	sponsor := &domain.TicketType{ID: uuid.MustParse("00000000-0000-0000-0000-00000000000a"), Name: "Sponsor"}
+	batch := []importRow{
+		{Line: 2, Name: "Ada", Email: "Ada@example.com", TicketType: speaker},
+		{Line: 3, Name: "Bob", Email: "bob@example.com", TicketType: sponsor},
+	}
+
+	mock.ExpectBegin()
+	// Capacity is locked in ticket type ID order whatever order the rows are in
+	for _, id := range []uuid.UUID{sponsor.ID, speaker.ID} {
+		mock.ExpectExec(`UPDATE "ticket_types" SET "capacity"=capacity \+ \$1`).
+			WithArgs(1, id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectQuery(`SELECT "id","email" FROM "users" WHERE LOWER\(email\) IN \(\$1,\$2\)`).
+		WithArgs("ada@example.com", "bob@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(bobID, "bob@example.com"))
+	// ada@example.com has no account, so one is made without a password
+	mock.ExpectQuery(`INSERT INTO "users"`).
+		WithArgs("Ada@example.com", "", "Ada", domain.RoleUser, "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, nil, nil, "", nil, int64(0), 0, nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(adminID, "CREATE_GUEST_USER", "user", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(`INSERT INTO "registrations"`).WillReturnError(errIssueStop)
+	mock.ExpectRollback()
+
+	if _, err := svc.issueBatch(adminID, eventID, batch, true); !errors.Is(err, errIssueStop) {
+		t.Fatalf("expected the batch to reach its first registration, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
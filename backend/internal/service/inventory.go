@@ -0,0 +1,292 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Inventory strategies. All of them keep stock in ticket_type_shards and
+// differ only in how a checkout takes from it.
+const (
+	// StrategyRowLock locks the event and ticket type rows before touching
+	// stock, serialising every checkout of an event.
+	StrategyRowLock = "row_lock"
+	// StrategyConditional takes stock with a single conditional UPDATE, so
+	// checkouts only contend on the counter row itself.
+	StrategyConditional = "conditional"
+	// StrategySharded spreads stock over several counter rows and takes from
+	// a random one that is not locked by another checkout.
+	StrategySharded = "sharded"
+)
+
+// Inventory owns ticket type stock. Stock is split over counter shards; an
+// event's and ticket type's remaining tickets are the sums of their shards.
+type Inventory struct {
+	Strategy string
+	Shards   int
+}
+
+func NewInventory(strategy string, shards int) *Inventory {
+	switch strategy {
+	case StrategyRowLock, StrategyConditional, StrategySharded:
+	default:
+		strategy = StrategySharded
+	}
+	if shards < 1 || strategy != StrategySharded {
+		shards = 1
+	}
+	return &Inventory{Strategy: strategy, Shards: shards}
+}
+
+// Provision creates the counter shards for a new ticket type of capacity seats.
+func (inv *Inventory) Provision(tx *gorm.DB, ticketTypeID uuid.UUID, capacity int) error {
+	shards := splitStock(capacity, inv.Shards)
+	rows := make([]domain.TicketTypeShard, len(shards))
+	for i, remaining := range shards {
+		rows[i] = domain.TicketTypeShard{ID: uuid.New(), TicketTypeID: ticketTypeID, Shard: i, Remaining: remaining}
+	}
+	return tx.Create(&rows).Error
+}
+
+// Take removes n seats of a ticket type from stock, or returns ErrSoldOut.
+func (inv *Inventory) Take(tx *gorm.DB, eventID uuid.UUID, ticketTypeID uuid.UUID, n int) error {
+	switch inv.Strategy {
+	case StrategyRowLock:
+		// Event first, then ticket type, so concurrent checkouts cannot deadlock
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&domain.Event{}, "id = ?", eventID).Error; err != nil {
+			return newCodedError(CodeEventNotFound, "event not found")
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&domain.TicketType{}, "id = ?", ticketTypeID).Error; err != nil {
+			return newCodedError(CodeTicketTypeNotFound, "ticket type not found for this event")
+		}
+	case StrategyConditional:
+		taken, err := takeFromShard(tx, ticketTypeID, n, false)
+		if err != nil || taken {
+			return err
+		}
+	case StrategySharded:
+		taken, err := takeFromShard(tx, ticketTypeID, n, true)
+		if err != nil || taken {
+			return err
+		}
+	}
+
+	// No single shard could cover n, or they were all busy
+	return takeAcrossShards(tx, ticketTypeID, n)
+}
+
+// Put returns n seats of a ticket type to stock.
+func (inv *Inventory) Put(tx *gorm.DB, ticketTypeID uuid.UUID, n int) error {
+	return tx.Exec(`UPDATE ticket_type_shards SET remaining = remaining + ?
+		WHERE id = (SELECT id FROM ticket_type_shards WHERE ticket_type_id = ? ORDER BY random() LIMIT 1)`,
+		n, ticketTypeID).Error
+}
+
+// Resize applies a capacity change of delta seats to a ticket type's stock. It
+// fails when the seats to remove have already been sold.
+func (inv *Inventory) Resize(tx *gorm.DB, ticketTypeID uuid.UUID, delta int) error {
+	switch {
+	case delta > 0:
+		return inv.Put(tx, ticketTypeID, delta)
+	case delta < 0:
+		if err := takeAcrossShards(tx, ticketTypeID, -delta); err != nil {
+			if errors.Is(err, ErrSoldOut) {
+				return fmt.Errorf("cannot reduce capacity by %d: not enough unsold tickets", -delta)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// takeFromShard takes n seats from one shard that still has them with a single
+// conditional UPDATE. With skipLocked the shard is picked at random among those
+// no other checkout holds. It reports false when no shard could be used.
+func takeFromShard(tx *gorm.DB, ticketTypeID uuid.UUID, n int, skipLocked bool) (bool, error) {
+	pick := "ORDER BY shard LIMIT 1 FOR UPDATE"
+	if skipLocked {
+		pick = "ORDER BY random() LIMIT 1 FOR UPDATE SKIP LOCKED"
+	}
+	result := tx.Exec(`UPDATE ticket_type_shards SET remaining = remaining - ?
+		WHERE id = (SELECT id FROM ticket_type_shards WHERE ticket_type_id = ? AND remaining >= ? `+pick+`)
+		AND remaining >= ?`,
+		n, ticketTypeID, n, n)
+	return result.RowsAffected > 0, result.Error
+}
+
+// takeAcrossShards locks every shard of a ticket type and takes n seats from
+// as many of them as needed.
+func takeAcrossShards(tx *gorm.DB, ticketTypeID uuid.UUID, n int) error {
+	var shards []domain.TicketTypeShard
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("ticket_type_id = ?", ticketTypeID).
+		Order("shard").
+		Find(&shards).Error; err != nil {
+		return err
+	}
+
+	available := 0
+	for _, s := range shards {
+		available += s.Remaining
+	}
+	if available < n {
+		return ErrSoldOut
+	}
+
+	for i := 0; n > 0; i++ {
+		take := min(shards[i].Remaining, n)
+		if take == 0 {
+			continue
+		}
+		if err := tx.Model(&shards[i]).Update("remaining", gorm.Expr("remaining - ?", take)).Error; err != nil {
+			return err
+		}
+		n -= take
+	}
+	return nil
+}
+
+// splitStock divides capacity as evenly as possible over at most shards
+// counters, never creating an empty one beyond the first.
+func splitStock(capacity int, shards int) []int {
+	shards = max(1, min(shards, capacity))
+	out := make([]int, shards)
+	for i := range out {
+		out[i] = capacity / shards
+		if i < capacity%shards {
+			out[i]++
+		}
+	}
+	return out
+}
+
+// WithEventRemaining selects events with their remaining tickets summed from
+// the stock of their ticket types.
+func WithEventRemaining(db *gorm.DB) *gorm.DB {
+	return db.Select(`events.*, (SELECT COALESCE(SUM(s.remaining), 0) FROM ticket_type_shards s
+		JOIN ticket_types tt ON tt.id = s.ticket_type_id
+		WHERE tt.event_id = events.id AND tt.deleted_at IS NULL) AS remaining_tickets`)
+}
+
+// WithTicketTypeRemaining selects ticket types with their remaining tickets
+// summed from their shards.
+func WithTicketTypeRemaining(db *gorm.DB) *gorm.DB {
+	return db.Select(`ticket_types.*, (SELECT COALESCE(SUM(s.remaining), 0) FROM ticket_type_shards s
+		WHERE s.ticket_type_id = ticket_types.id) AS remaining_tickets`)
+}
+
+// reserveSeats checks that the event is on sale, enforces the ticket type's
+// sales window and purchase limits for userID, and takes n seats from stock.
+// The event row is share-locked so it cannot be unpublished or cancelled while
+// the seats are taken; the row lock strategy locks it for update up front, as
+// Take would, rather than upgrading the share lock.
+func reserveSeats(tx *gorm.DB, inv *Inventory, userID uuid.UUID, eventID uuid.UUID, ticketTypeID uuid.UUID, n int) (*domain.Event, *domain.TicketType, error) {
+	strength := "SHARE"
+	if inv.Strategy == StrategyRowLock {
+		strength = "UPDATE"
+	}
+
+	var event domain.Event
+	if err := tx.Clauses(clause.Locking{Strength: strength}).First(&event, "id = ?", eventID).Error; err != nil {
+		return nil, nil, newCodedError(CodeEventNotFound, "event not found")
+	}
+
+	if event.Status != domain.StatusPublished {
+		return nil, nil, newCodedError(CodeEventNotPublished, "cannot register for an event that is not published")
+	}
+
+	var ticketType domain.TicketType
+	if err := tx.First(&ticketType, "id = ? AND event_id = ?", ticketTypeID, eventID).Error; err != nil {
+		return nil, nil, newCodedError(CodeTicketTypeNotFound, "ticket type not found for this event")
+	}
+
+	if err := checkPurchaseRules(&ticketType, n, time.Now()); err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkUserLimit(tx, userID, &ticketType, n); err != nil {
+		return nil, nil, err
+	}
+
+	if err := inv.Take(tx, eventID, ticketTypeID, n); err != nil {
+		return nil, nil, err
+	}
+
+	return &event, &ticketType, nil
+}
+
+// checkUserLimit refuses n more tickets of a type to a user who would then be
+// over its MaxPerUser.
+func checkUserLimit(tx *gorm.DB, userID uuid.UUID, ticketType *domain.TicketType, n int) error {
+	if ticketType.MaxPerUser <= 0 {
+		return nil
+	}
+	// The user row lock serialises this count against the user's other checkouts
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&domain.User{}, "id = ?", userID).Error; err != nil {
+		return err
+	}
+	held, err := seatsHeldByUser(tx, userID, ticketType.ID)
+	if err != nil {
+		return err
+	}
+	if held+int64(n) > int64(ticketType.MaxPerUser) {
+		return newCodedError(CodeUserLimitReached, "you can hold at most %d %s tickets (you already have %d)", ticketType.MaxPerUser, ticketType.Name, held)
+	}
+	return nil
+}
+
+// checkPurchaseRules applies a ticket type's sales window and per-order limits
+// to a request for n seats at now.
+func checkPurchaseRules(ticketType *domain.TicketType, n int, now time.Time) error {
+	if ticketType.SalesStart != nil && now.Before(*ticketType.SalesStart) {
+		return newCodedError(CodeSalesNotStarted, "sales for %s open at %s", ticketType.Name, ticketType.SalesStart.Format(time.RFC3339))
+	}
+	if ticketType.SalesEnd != nil && !now.Before(*ticketType.SalesEnd) {
+		return newCodedError(CodeSalesEnded, "sales for %s closed at %s", ticketType.Name, ticketType.SalesEnd.Format(time.RFC3339))
+	}
+	if ticketType.MinPerOrder > 0 && n < ticketType.MinPerOrder {
+		return newCodedError(CodeBelowMinPerOrder, "%s must be bought at least %d at a time", ticketType.Name, ticketType.MinPerOrder)
+	}
+	if ticketType.MaxPerOrder > 0 && n > ticketType.MaxPerOrder {
+		return newCodedError(CodeAboveMaxPerOrder, "%s can be bought at most %d at a time", ticketType.Name, ticketType.MaxPerOrder)
+	}
+	return nil
+}
+
+// seatsHeldByUser counts the seats of a ticket type a user currently has,
+// whether registered, awaiting payment, sitting in an active hold or being
+// bought on resale.
+func seatsHeldByUser(tx *gorm.DB, userID uuid.UUID, ticketTypeID uuid.UUID) (int64, error) {
+	var registered int64
+	if err := tx.Model(&domain.Registration{}).
+		Where("user_id = ? AND ticket_type_id = ? AND status <> ?", userID, ticketTypeID, domain.RegistrationCancelled).
+		Count(&registered).Error; err != nil {
+		return 0, err
+	}
+
+	var held int64
+	if err := tx.Model(&domain.SeatHold{}).
+		Where("user_id = ? AND ticket_type_id = ? AND status = ?", userID, ticketTypeID, domain.HoldActive).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&held).Error; err != nil {
+		return 0, err
+	}
+
+	// Resale tickets the user is still paying for are theirs once the payment lands
+	var buying int64
+	if err := tx.Model(&domain.ResaleListing{}).
+		Joins("JOIN registrations ON registrations.id = resale_listings.registration_id").
+		Where("resale_listings.buyer_id = ? AND resale_listings.status = ? AND registrations.ticket_type_id = ?",
+			userID, domain.ResalePendingPayment, ticketTypeID).
+		Count(&buying).Error; err != nil {
+		return 0, err
+	}
+
+	return registered + held + buying, nil
+}
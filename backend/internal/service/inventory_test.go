@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/username/event-ticketing-system/internal/domain"
+)
+
+func TestCheckPurchaseRules(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		ticketType domain.TicketType
+		n          int
+		wantCode   string
+	}{
+		{"unrestricted", domain.TicketType{}, 3, ""},
+		{"inside window", domain.TicketType{SalesStart: &before, SalesEnd: &after}, 1, ""},
+		{"not started", domain.TicketType{SalesStart: &after}, 1, CodeSalesNotStarted},
+		{"ended", domain.TicketType{SalesEnd: &before}, 1, CodeSalesEnded},
+		{"ends exactly now", domain.TicketType{SalesEnd: &now}, 1, CodeSalesEnded},
+		{"below minimum", domain.TicketType{MinPerOrder: 2}, 1, CodeBelowMinPerOrder},
+		{"at minimum", domain.TicketType{MinPerOrder: 2}, 2, ""},
+		{"above maximum", domain.TicketType{MaxPerOrder: 4}, 5, CodeAboveMaxPerOrder},
+		{"at maximum", domain.TicketType{MaxPerOrder: 4}, 4, ""},
+	}
+
+	for _, tt := range tests {
+		err := checkPurchaseRules(&tt.ticketType, tt.n, now)
+		if tt.wantCode == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+
+		var coded *CodedError
+		if !errors.As(err, &coded) || coded.Code != tt.wantCode {
+			t.Errorf("%s: expected code %s, got %v", tt.name, tt.wantCode, err)
+		}
+	}
+}
+
+func TestSplitStock(t *testing.T) {
+	tests := []struct {
+		capacity int
+		shards   int
+		want     []int
+	}{
+		{capacity: 100, shards: 8, want: []int{13, 13, 13, 13, 12, 12, 12, 12}},
+		{capacity: 3, shards: 8, want: []int{1, 1, 1}},
+		{capacity: 10, shards: 1, want: []int{10}},
+		{capacity: 0, shards: 8, want: []int{0}},
+	}
+
+	for _, tt := range tests {
+		got := splitStock(tt.capacity, tt.shards)
+		if len(got) != len(tt.want) {
+			t.Fatalf("splitStock(%d, %d) = %v, want %v", tt.capacity, tt.shards, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("splitStock(%d, %d) = %v, want %v", tt.capacity, tt.shards, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"gorm.io/gorm"
+)
+
+// CreateScratchTicketType adds a free ticket type with stock seats to an event
+// for one load test run. Its stock is laid out for the service's inventory
+// strategy. Drop it with DropScratchTicketType when the run is over.
+func (s *RegistrationService) CreateScratchTicketType(eventID uuid.UUID, label string, stock int) (*domain.TicketType, error) {
+	var event domain.Event
+	if err := s.DB.Select("id", "status").First(&event, "id = ?", eventID).Error; err != nil {
+		return nil, newCodedError(CodeEventNotFound, "event not found")
+	}
+	if event.Status != domain.StatusPublished {
+		return nil, newCodedError(CodeEventNotPublished, "load tests need a published event")
+	}
+
+	ticketType := domain.TicketType{
+		ID:       uuid.New(),
+		EventID:  eventID,
+		Name:     fmt.Sprintf("Load test (%s)", label),
+		Capacity: stock,
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&ticketType).Error; err != nil {
+			return err
+		}
+		return s.Inventory.Provision(tx, ticketType.ID, stock)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ticketType, nil
+}
+
+// DropScratchTicketType removes a ticket type made by CreateScratchTicketType
+// together with its stock.
+func (s *RegistrationService) DropScratchTicketType(ticketTypeID uuid.UUID) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("ticket_type_id = ?", ticketTypeID).Delete(&domain.TicketTypeShard{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&domain.TicketType{}, "id = ?", ticketTypeID).Error
+	})
+}
+
+// SimulateCheckout runs the contended part of a registration for a synthetic
+// buyer: the event and ticket type checks and taking one seat from stock,
+// committed like a real checkout. No registration, ticket or email is made,
+// so buyerID need not belong to an account.
+func (s *RegistrationService) SimulateCheckout(buyerID uuid.UUID, eventID uuid.UUID, ticketTypeID uuid.UUID) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		_, _, err := reserveSeats(tx, s.Inventory, buyerID, eventID, ticketTypeID, 1)
+		return err
+	})
+}
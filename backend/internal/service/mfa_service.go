@@ -0,0 +1,381 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/pkg/qr"
+	"github.com/username/event-ticketing-system/pkg/totp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// mfaChallengePurpose keeps a half-finished sign-in from being accepted
+	// as any other kind of token signed with the same secret
+	mfaChallengePurpose = "mfa_challenge"
+
+	// Codes from one step either side of now are accepted, for clock drift
+	mfaSkew = 1
+
+	mfaMaxAttempts     = 5
+	mfaLockout         = 15 * time.Minute
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+var (
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrMFANotEnrolling     = errors.New("start two-factor enrollment first")
+	ErrMFARequired         = errors.New("two-factor authentication is required for admin accounts")
+	ErrInvalidMFACode      = errors.New("invalid authentication code")
+	ErrMFALocked           = errors.New("too many invalid authentication codes; try again later")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired sign-in challenge; sign in again")
+)
+
+// MFAService manages TOTP two-factor authentication. Accounts with it enabled
+// sign in in two steps: the password earns a short-lived challenge token, and
+// the challenge token plus a code from the authenticator app (or a recovery
+// code) opens the session.
+type MFAService struct {
+	DB                *gorm.DB
+	Secret            string
+	Issuer            string
+	ChallengeTTL      time.Duration
+	RequiredForAdmins bool
+}
+
+func NewMFAService(db *gorm.DB, secret string, issuer string, challengeTTL time.Duration, requiredForAdmins bool) *MFAService {
+	return &MFAService{DB: db, Secret: secret, Issuer: issuer, ChallengeTTL: challengeTTL, RequiredForAdmins: requiredForAdmins}
+}
+
+// MFAEnrollment is what the user needs to add the account to an authenticator
+// app. QRCode is a PNG of ProvisioningURI.
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	QRCode          []byte `json:"qr_code"`
+}
+
+// MFAChallenge is returned instead of a session when the password was right
+// but a second factor is still needed.
+type MFAChallenge struct {
+	MFARequired bool      `json:"mfa_required"`
+	Token       string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// EnrollmentRequired reports whether a user must enable two-factor
+// authentication before using their role's routes.
+func (s *MFAService) EnrollmentRequired(user *domain.User) bool {
+	return s.RequiredForAdmins && user.Role == domain.RoleAdmin && user.MFAEnabledAt == nil
+}
+
+// BeginEnrollment generates a new TOTP secret for the user. It takes effect
+// once ConfirmEnrollment sees a code generated from it.
+func (s *MFAService) BeginEnrollment(userID uuid.UUID) (*MFAEnrollment, error) {
+	var user domain.User
+	if err := s.DB.Select("id", "email", "mfa_enabled_at").First(&user, "id = ?", userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.MFAEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	// Starting over replaces a secret that was never confirmed
+	if err := s.DB.Model(&domain.User{}).Where("id = ? AND mfa_enabled_at IS NULL", userID).Update("mfa_secret", secret).Error; err != nil {
+		return nil, err
+	}
+
+	uri := totp.ProvisioningURI(s.Issuer, user.Email, secret)
+	png, err := qr.GenerateQRCode(uri)
+	if err != nil {
+		return nil, err
+	}
+	return &MFAEnrollment{Secret: secret, ProvisioningURI: uri, QRCode: png}, nil
+}
+
+// ConfirmEnrollment turns two-factor authentication on once the user proves
+// their app generates codes, and returns their recovery codes. These are only
+// ever shown here. Every session is signed out, so from now on each one has
+// passed the second step.
+func (s *MFAService) ConfirmEnrollment(userID uuid.UUID, code string) ([]string, error) {
+	var codes []string
+	err := s.withUser(userID, func(tx *gorm.DB, user *domain.User) error {
+		if user.MFAEnabledAt != nil {
+			return ErrMFAAlreadyEnabled
+		}
+		if user.MFASecret == "" {
+			return ErrMFANotEnrolling
+		}
+		step, ok := totp.Validate(user.MFASecret, code, time.Now(), mfaSkew)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+
+		now := time.Now()
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"mfa_enabled_at":      now,
+			"mfa_last_step":       step,
+			"mfa_failed_attempts": 0,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if codes, err = replaceRecoveryCodes(tx, user.ID); err != nil {
+			return err
+		}
+		if err := RevokeUserSessions(tx, user.ID, user.ID); err != nil {
+			return err
+		}
+		return tx.Create(mfaAudit(user.ID, "MFA_ENABLED")).Error
+	})
+	return codes, err
+}
+
+// Disable turns two-factor authentication off. It takes a current code so a
+// stolen session alone can't remove the second factor.
+func (s *MFAService) Disable(userID uuid.UUID, code string) error {
+	return s.withUser(userID, func(tx *gorm.DB, user *domain.User) error {
+		if user.MFAEnabledAt == nil {
+			return ErrMFANotEnabled
+		}
+		if s.RequiredForAdmins && user.Role == domain.RoleAdmin {
+			return ErrMFARequired
+		}
+		if err := verifySecondFactor(tx, user, code); err != nil {
+			return err
+		}
+
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"mfa_secret":     "",
+			"mfa_enabled_at": nil,
+			"mfa_last_step":  0,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&domain.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(mfaAudit(user.ID, "MFA_DISABLED")).Error
+	})
+}
+
+// RegenerateRecoveryCodes replaces all of the user's recovery codes, used or
+// not, with new ones.
+func (s *MFAService) RegenerateRecoveryCodes(userID uuid.UUID, code string) ([]string, error) {
+	var codes []string
+	err := s.withUser(userID, func(tx *gorm.DB, user *domain.User) error {
+		if user.MFAEnabledAt == nil {
+			return ErrMFANotEnabled
+		}
+		if err := verifySecondFactor(tx, user, code); err != nil {
+			return err
+		}
+
+		var err error
+		if codes, err = replaceRecoveryCodes(tx, user.ID); err != nil {
+			return err
+		}
+		return tx.Create(mfaAudit(user.ID, "MFA_RECOVERY_CODES_REGENERATED")).Error
+	})
+	return codes, err
+}
+
+// Challenge starts the second sign-in step for a user whose password checked
+// out.
+func (s *MFAService) Challenge(user *domain.User) (*MFAChallenge, error) {
+	expiresAt := time.Now().Add(s.ChallengeTTL)
+	claims := jwt.MapClaims{
+		"sub":     user.ID.String(),
+		"purpose": mfaChallengePurpose,
+		"exp":     expiresAt.Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.Secret))
+	if err != nil {
+		return nil, err
+	}
+	return &MFAChallenge{MFARequired: true, Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// CompleteChallenge finishes signing in with a TOTP or recovery code and
+// returns the user to open a session for.
+func (s *MFAService) CompleteChallenge(challengeToken string, code string) (*domain.User, error) {
+	userID, err := parseMFAChallenge(s.Secret, challengeToken, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var signedIn domain.User
+	err = s.withUser(userID, func(tx *gorm.DB, user *domain.User) error {
+		if user.MFAEnabledAt == nil {
+			return ErrInvalidMFAChallenge
+		}
+		if err := verifySecondFactor(tx, user, code); err != nil {
+			return err
+		}
+		signedIn = *user
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &signedIn, nil
+}
+
+// withUser runs fn with the user locked. A wrong code counts towards the
+// lockout even though fn's transaction is rolled back.
+func (s *MFAService) withUser(userID uuid.UUID, fn func(tx *gorm.DB, user *domain.User) error) error {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var user domain.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("user not found")
+			}
+			return err
+		}
+		if user.MFALockedUntil != nil && time.Now().Before(*user.MFALockedUntil) {
+			return ErrMFALocked
+		}
+		return fn(tx, &user)
+	})
+	if errors.Is(err, ErrInvalidMFACode) {
+		if lockErr := s.recordFailedCode(userID); lockErr != nil {
+			return lockErr
+		}
+	}
+	return err
+}
+
+// recordFailedCode counts a wrong code and locks the second factor for
+// mfaLockout once mfaMaxAttempts are reached in a row.
+func (s *MFAService) recordFailedCode(userID uuid.UUID) error {
+	return s.DB.Model(&domain.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"mfa_failed_attempts": gorm.Expr("CASE WHEN mfa_failed_attempts + 1 >= ? THEN 0 ELSE mfa_failed_attempts + 1 END", mfaMaxAttempts),
+		"mfa_locked_until":    gorm.Expr("CASE WHEN mfa_failed_attempts + 1 >= ? THEN ?::timestamptz ELSE mfa_locked_until END", mfaMaxAttempts, time.Now().Add(mfaLockout)),
+	}).Error
+}
+
+// verifySecondFactor accepts a TOTP code newer than the last one used, or an
+// unused recovery code, which is then spent. The user must be locked by tx.
+func verifySecondFactor(tx *gorm.DB, user *domain.User, code string) error {
+	now := time.Now()
+	if step, ok := totp.Validate(user.MFASecret, code, now, mfaSkew); ok {
+		if step <= user.MFALastStep {
+			return ErrInvalidMFACode
+		}
+		user.MFALastStep = step
+		return tx.Model(user).Updates(map[string]interface{}{
+			"mfa_last_step":       step,
+			"mfa_failed_attempts": 0,
+		}).Error
+	}
+
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) != recoveryCodeLength {
+		return ErrInvalidMFACode
+	}
+	result := tx.Model(&domain.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(normalized)).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+	if err := tx.Model(user).Update("mfa_failed_attempts", 0).Error; err != nil {
+		return err
+	}
+	return tx.Create(mfaAudit(user.ID, "MFA_RECOVERY_CODE_USED")).Error
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&domain.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]domain.MFARecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		rows[i] = domain.MFARecoveryCode{
+			ID:       uuid.New(),
+			UserID:   userID,
+			CodeHash: hashToken(normalizeRecoveryCode(code)),
+		}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// recoveryAlphabet leaves out characters that are easy to misread.
+const recoveryAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// newRecoveryCode returns a code like "K7PQ2-XM4RT".
+func newRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = recoveryAlphabet[int(b[i])%len(recoveryAlphabet)]
+	}
+	half := recoveryCodeLength / 2
+	return string(b[:half]) + "-" + string(b[half:]), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// parseMFAChallenge checks a challenge token's signature, purpose and expiry
+// at now, and returns the user it was issued to.
+func parseMFAChallenge(secret string, token string, now time.Time) (uuid.UUID, error) {
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired(), jwt.WithTimeFunc(func() time.Time { return now }))
+	if err != nil || !parsed.Valid {
+		return uuid.Nil, ErrInvalidMFAChallenge
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != mfaChallengePurpose {
+		return uuid.Nil, ErrInvalidMFAChallenge
+	}
+	sub, _ := claims["sub"].(string)
+	userID, err := uuid.Parse(sub)
+	if err != nil {
+		return uuid.Nil, ErrInvalidMFAChallenge
+	}
+	return userID, nil
+}
+
+func mfaAudit(userID uuid.UUID, action string) *domain.AuditLog {
+	return &domain.AuditLog{
+		ID:         uuid.New(),
+		UserID:     userID,
+		Action:     action,
+		EntityType: "user",
+		EntityID:   userID,
+		CreatedAt:  time.Now(),
+	}
+}
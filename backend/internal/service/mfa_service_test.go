@@ -0,0 +1,88 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/pkg/totp"
+)
+
+func TestParseMFAChallenge(t *testing.T) {
+	svc := NewMFAService(nil, "test-secret", "Test", 5*time.Minute, false)
+	user := &domain.User{ID: uuid.New()}
+
+	challenge, err := svc.Challenge(user)
+	if err != nil {
+		t.Fatalf("failed to issue challenge: %v", err)
+	}
+	if userID, err := parseMFAChallenge("test-secret", challenge.Token, time.Now()); err != nil || userID != user.ID {
+		t.Fatalf("expected challenge for %s, got %s %v", user.ID, userID, err)
+	}
+
+	// A verification link is signed with the same secret but isn't a challenge
+	link, _ := signVerificationToken("test-secret", user.ID, "ada@example.com", time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name  string
+		token string
+		now   time.Time
+	}{
+		{"expired", challenge.Token, time.Now().Add(10 * time.Minute)},
+		{"wrong purpose", link, time.Now()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseMFAChallenge("test-secret", tt.token, tt.now); !errors.Is(err, ErrInvalidMFAChallenge) {
+				t.Errorf("expected ErrInvalidMFAChallenge, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewRecoveryCode(t *testing.T) {
+	code, err := newRecoveryCode()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !regexp.MustCompile(`^[A-HJ-NP-Z2-9]{5}-[A-HJ-NP-Z2-9]{5}$`).MatchString(code) {
+		t.Errorf("unexpected recovery code format %q", code)
+	}
+	if normalizeRecoveryCode(" k7pq2-xm4rt ") != "K7PQ2XM4RT" {
+		t.Errorf("expected codes to be accepted in any case and with or without the dash")
+	}
+}
+
+func TestMFAService_CompleteChallenge_RejectsReusedCode(t *testing.T) {
+	gormDB, mock := newMockDB(t)
+	svc := NewMFAService(gormDB, "test-secret", "Test", 5*time.Minute, false)
+
+	userID := uuid.New()
+	secret, _ := totp.GenerateSecret()
+	step := totp.Step(time.Now())
+	code, _ := totp.Code(secret, step)
+	challenge, _ := svc.Challenge(&domain.User{ID: userID})
+
+	// The code's step was already used to sign in
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1 .* FOR UPDATE`).
+		WithArgs(userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "mfa_secret", "mfa_enabled_at", "mfa_last_step"}).
+			AddRow(userID, secret, time.Now(), step))
+	mock.ExpectRollback()
+	mock.ExpectExec(`UPDATE "users" SET "mfa_failed_attempts"=CASE`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := svc.CompleteChallenge(challenge.Token, code); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("expected ErrInvalidMFACode, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met: %s", err)
+	}
+}
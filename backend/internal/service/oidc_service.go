@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/pkg/oidc"
+	"github.com/username/event-ticketing-system/pkg/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUnknownOIDCProvider  = errors.New("unknown sign-in provider")
+	ErrInvalidOIDCState     = errors.New("sign-in expired or was not started here; try again")
+	ErrOIDCLoginFailed      = errors.New("sign-in with the provider failed")
+	ErrOIDCEmailNotVerified = errors.New("the provider has not verified your email address")
+)
+
+// OIDCService signs users in through OpenID Connect providers. Identities are
+// linked to accounts by provider subject, or on first sign-in by an email the
+// provider has verified.
+type OIDCService struct {
+	DB        *gorm.DB
+	Providers map[string]*oidc.Provider
+	StateTTL  time.Duration
+}
+
+func NewOIDCService(db *gorm.DB, providers map[string]*oidc.Provider, stateTTL time.Duration) *OIDCService {
+	return &OIDCService{DB: db, Providers: providers, StateTTL: stateTTL}
+}
+
+// ProviderNames lists the providers users can sign in with.
+func (s *OIDCService) ProviderNames() []string {
+	names := make([]string, 0, len(s.Providers))
+	for name := range s.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Begin starts a sign-in and returns the provider URL to send the user to,
+// and the state the callback must come back with.
+func (s *OIDCService) Begin(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return "", "", ErrUnknownOIDCProvider
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+
+	login := domain.ProviderLoginState{
+		ID:           uuid.New(),
+		Provider:     providerName,
+		StateHash:    hashToken(state),
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(s.StateTTL),
+	}
+	if err := s.DB.Create(&login).Error; err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+// Complete finishes a sign-in with the code the provider redirected back with
+// and returns the user it belongs to, creating or linking the account on
+// first sign-in.
+func (s *OIDCService) Complete(ctx context.Context, providerName string, state string, code string) (*domain.User, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+
+	login, err := s.consumeState(providerName, state)
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, code, login.CodeVerifier)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, login.Nonce, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+
+	return s.linkIdentity(providerName, claims)
+}
+
+// PurgeExpired deletes sign-ins that were never finished. It runs from the
+// background sweeper.
+func (s *OIDCService) PurgeExpired() {
+	result := s.DB.Where("expires_at < ?", time.Now()).Delete(&domain.ProviderLoginState{})
+	if result.Error != nil {
+		utils.Logger.Error("Failed to purge OIDC login states", zap.Error(result.Error))
+		return
+	}
+	if result.RowsAffected > 0 {
+		utils.Logger.Info("Purged OIDC login states", zap.Int64("count", result.RowsAffected))
+	}
+}
+
+// revokeUnverifiedCredentials clears the password and two-factor
+// authentication of an account whose email was never verified, and signs out
+// its sessions. Its owner signs in with the provider or resets the password.
+func revokeUnverifiedCredentials(tx *gorm.DB, user *domain.User) error {
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"password_hash":        "",
+		"password_reset_token": "",
+		"mfa_secret":           "",
+		"mfa_enabled_at":       nil,
+		"mfa_last_step":        0,
+	}).Error; err != nil {
+		return err
+	}
+	user.PasswordHash = ""
+	user.PasswordResetToken = ""
+	user.MFASecret = ""
+	user.MFAEnabledAt = nil
+	user.MFALastStep = 0
+
+	if err := tx.Where("user_id = ?", user.ID).Delete(&domain.MFARecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return RevokeUserSessions(tx, user.ID, user.ID)
+}
+
+// consumeState looks up and deletes a sign-in, so each state works once.
+func (s *OIDCService) consumeState(providerName string, state string) (*domain.ProviderLoginState, error) {
+	var login domain.ProviderLoginState
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&login, "state_hash = ?", hashToken(state)).Error; err != nil {
+			return ErrInvalidOIDCState
+		}
+		return tx.Delete(&login).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if login.Provider != providerName || !time.Now().Before(login.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+	return &login, nil
+}
+
+// linkIdentity finds the account for a provider identity. An unknown identity
+// is linked to the account with the same email, or gets a new account, but
+// only if the provider vouches for the email.
+// An account whose email was never verified loses its password and sessions
+// when linked, since the provider has now shown who owns the address.
+func (s *OIDCService) linkIdentity(providerName string, claims *oidc.Claims) (*domain.User, error) {
+	var user domain.User
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var identity domain.UserIdentity
+		err := tx.First(&identity, "provider = ? AND subject = ?", providerName, claims.Subject).Error
+		if err == nil {
+			return tx.First(&user, "id = ?", identity.UserID).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if claims.Email == "" || !claims.EmailVerified {
+			return ErrOIDCEmailNotVerified
+		}
+
+		now := time.Now()
+		err = tx.Where("LOWER(email) = LOWER(?)", claims.Email).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// Accounts made through a provider have no password until the
+			// owner resets it
+			user = domain.User{
+				ID:              uuid.New(),
+				Email:           claims.Email,
+				Name:            claims.Name,
+				Role:            domain.RoleUser,
+				EmailVerifiedAt: &now,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(oidcAudit(user.ID, "USER_REGISTER", providerName)).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case user.EmailVerifiedAt == nil:
+			// The provider has just verified the address for us
+			if err := tx.Model(&user).Update("email_verified_at", now).Error; err != nil {
+				return err
+			}
+			user.EmailVerifiedAt = &now
+			if user.PasswordHash != "" {
+				// Whoever chose the password never proved they own the
+				// address, so the account is taken from them
+				if err := revokeUnverifiedCredentials(tx, &user); err != nil {
+					return err
+				}
+			}
+		}
+
+		identity = domain.UserIdentity{
+			ID:       uuid.New(),
+			UserID:   user.ID,
+			Provider: providerName,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		}
+		if err := tx.Create(&identity).Error; err != nil {
+			return err
+		}
+		return tx.Create(oidcAudit(user.ID, "OIDC_IDENTITY_LINKED", providerName)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func oidcAudit(userID uuid.UUID, action string, providerName string) *domain.AuditLog {
+	return &domain.AuditLog{
+		ID:         uuid.New(),
+		UserID:     userID,
+		Action:     action,
+		EntityType: "user",
+		EntityID:   userID,
+		NewValues:  utils.ToJSON(map[string]interface{}{"provider": providerName}),
+		CreatedAt:  time.Now(),
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/pkg/oidc"
+)
+
+func newTestOIDCService(t *testing.T) (*OIDCService, sqlmock.Sqlmock) {
+	gormDB, mock := newMockDB(t)
+	return NewOIDCService(gormDB, map[string]*oidc.Provider{}, 10*time.Minute), mock
+}
+
+func TestOIDCService_LinkIdentity_ExistingAccountByVerifiedEmail(t *testing.T) {
+	svc, mock := newTestOIDCService(t)
+
+	userID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "user_identities" WHERE provider = \$1 AND subject = \$2`).
+		WithArgs("corp", "sub-1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE LOWER\(email\) = LOWER\(\$1\)`).
+		WithArgs("Ada@Example.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "email_verified_at"}).AddRow(userID, "ada@example.com", nil))
+	mock.ExpectExec(`UPDATE "users" SET "email_verified_at"=\$1`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "user_identities"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	user, err := svc.linkIdentity("corp", &oidc.Claims{Subject: "sub-1", Email: "Ada@Example.com", EmailVerified: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.ID != userID {
+		t.Errorf("expected the identity to be linked to %s, got %s", userID, user.ID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met: %s", err)
+	}
+}
+
+func TestOIDCService_LinkIdentity_TakesOverUnverifiedPassword(t *testing.T) {
+	svc, mock := newTestOIDCService(t)
+
+	userID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "user_identities" WHERE provider = \$1 AND subject = \$2`).
+		WithArgs("corp", "sub-1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE LOWER\(email\) = LOWER\(\$1\)`).
+		WithArgs("ada@example.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password_hash", "mfa_secret", "mfa_enabled_at", "email_verified_at"}).
+			AddRow(userID, "ada@example.com", "$2a$10$squatter", "SECRET", time.Now(), nil))
+	mock.ExpectExec(`UPDATE "users" SET "email_verified_at"=\$1`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Someone who registered the address without proving it must not keep a way in
+	mock.ExpectExec(`UPDATE "users" SET .*"password_hash"=`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "mfa_recovery_codes" WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`UPDATE "sessions" SET "revoked_at"=\$1`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(userID, "REVOKE_USER_SESSIONS", "user", userID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(`INSERT INTO "user_identities"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	user, err := svc.linkIdentity("corp", &oidc.Claims{Subject: "sub-1", Email: "ada@example.com", EmailVerified: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.PasswordHash != "" || user.MFAEnabledAt != nil || user.EmailVerifiedAt == nil {
+		t.Errorf("expected a verified account without the old credentials, got %+v", user)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met: %s", err)
+	}
+}
+
+func TestOIDCService_LinkIdentity_UnverifiedEmail(t *testing.T) {
+	svc, mock := newTestOIDCService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "user_identities" WHERE provider = \$1 AND subject = \$2`).
+		WithArgs("corp", "sub-1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	// An unverified email must not take over the account that owns it
+	_, err := svc.linkIdentity("corp", &oidc.Claims{Subject: "sub-1", Email: "ada@example.com", EmailVerified: false})
+	if !errors.Is(err, ErrOIDCEmailNotVerified) {
+		t.Fatalf("expected ErrOIDCEmailNotVerified, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met: %s", err)
+	}
+}
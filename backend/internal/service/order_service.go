@@ -0,0 +1,317 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/worker"
+	"github.com/username/event-ticketing-system/pkg/email"
+	"github.com/username/event-ticketing-system/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// MaxOrderSeats caps how many seats a single order may reserve.
+const MaxOrderSeats = 50
+
+type OrderService struct {
+	DB            *gorm.DB
+	TicketService *TicketService
+	EmailService  email.EmailService
+	Pool          *worker.WorkerPool
+	Inventory     *Inventory
+	Payments      *PaymentService
+}
+
+func NewOrderService(db *gorm.DB, ts *TicketService, es email.EmailService, pool *worker.WorkerPool, inv *Inventory, payments *PaymentService) *OrderService {
+	return &OrderService{DB: db, TicketService: ts, EmailService: es, Pool: pool, Inventory: inv, Payments: payments}
+}
+
+type OrderAttendee struct {
+	Name  string `json:"name"`
+	Email string `json:"email" binding:"omitempty,email"`
+	// Answers to the registration form for this seat, keyed by question ID;
+	// without them the seat takes the buyer's answers
+	Answers map[uuid.UUID]json.RawMessage `json:"answers"`
+}
+
+// OrderLine asks for Quantity seats of one ticket type. Attendees are optional
+// and fill the seats in order; seats without one belong to the buyer. For
+// reserved seating, SeatIDs picks seats in the same order and any seats not
+// picked, or picked as uuid.Nil, are assigned automatically.
+type OrderLine struct {
+	TicketTypeID uuid.UUID       `json:"ticket_type_id" binding:"required"`
+	Quantity     int             `json:"quantity" binding:"required,min=1"`
+	Attendees    []OrderAttendee `json:"attendees" binding:"omitempty,dive"`
+	SeatIDs      []uuid.UUID     `json:"seat_ids"`
+}
+
+// CreateOrder reserves every requested seat and issues one ticket per seat in a
+// single transaction. If any ticket type cannot be filled nothing is reserved.
+// answers are the buyer's answers to the registration form, used for every
+// seat whose attendee brings none. queueToken is only needed for events with a
+// waiting room.
+func (s *OrderService) CreateOrder(userID uuid.UUID, eventID uuid.UUID, lines []OrderLine, answers map[uuid.UUID]json.RawMessage, queueToken string) (*domain.Order, error) {
+	lines, err := mergeOrderLines(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	var user domain.User
+	if err := s.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	if err := checkEmailVerified(&user); err != nil {
+		return nil, err
+	}
+
+	admission, err := checkQueueAdmission(s.DB, eventID, userID, queueToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var payment *domain.Payment
+	order := domain.Order{
+		ID:      uuid.New(),
+		UserID:  userID,
+		EventID: eventID,
+		Status:  domain.OrderConfirmed,
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// Lines are sorted by ticket type, so concurrent orders lock rows in the same order
+		seats := make([][]domain.Seat, len(lines))
+		for j, line := range lines {
+			_, ticketType, err := reserveSeats(tx, s.Inventory, userID, eventID, line.TicketTypeID, line.Quantity)
+			if err != nil {
+				if errors.Is(err, ErrSoldOut) {
+					return fmt.Errorf("%s: %w", ticketTypeLabel(tx, line.TicketTypeID), err)
+				}
+				return err
+			}
+
+			if seats[j], err = assignSeats(tx, eventID, ticketType, line.SeatIDs, line.Quantity); err != nil {
+				return err
+			}
+
+			order.Items = append(order.Items, domain.OrderItem{
+				ID:           uuid.New(),
+				OrderID:      order.ID,
+				TicketTypeID: line.TicketTypeID,
+				Quantity:     line.Quantity,
+				UnitPrice:    ticketType.Price,
+			})
+			order.TotalAmount += ticketType.Price * float64(line.Quantity)
+		}
+
+		if err := spendQueueTicket(tx, admission); err != nil {
+			return err
+		}
+
+		payment, err = s.Payments.Begin(tx, userID, order.TotalAmount)
+		if err != nil {
+			return err
+		}
+		if payment != nil {
+			order.PaymentID = &payment.ID
+			order.Status = domain.OrderPendingPayment
+		}
+
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+
+		for j, line := range lines {
+			for i := 0; i < line.Quantity; i++ {
+				registration := domain.Registration{
+					ID:           uuid.New(),
+					UserID:       &userID,
+					EventID:      eventID,
+					TicketTypeID: line.TicketTypeID,
+					OrderID:      &order.ID,
+					Amount:       order.Items[j].UnitPrice,
+					SeatID:       seatIDAt(seats[j], i),
+					Status:       domain.RegistrationConfirmed,
+				}
+				attachPayment(&registration, payment)
+				seatAnswers := answers
+				if i < len(line.Attendees) {
+					registration.AttendeeName = line.Attendees[i].Name
+					registration.AttendeeEmail = line.Attendees[i].Email
+					if line.Attendees[i].Answers != nil {
+						seatAnswers = line.Attendees[i].Answers
+					}
+				}
+
+				if err := createRegistration(tx, s.TicketService, &registration, seatAnswers, "CREATE_ORDER_REGISTRATION"); err != nil {
+					return err
+				}
+				order.Registrations = append(order.Registrations, registration)
+			}
+		}
+
+		audit := domain.AuditLog{
+			ID:         uuid.New(),
+			UserID:     userID,
+			Action:     "CREATE_ORDER",
+			EntityType: "order",
+			EntityID:   order.ID,
+			NewValues:  utils.ToJSON(order.Items),
+			CreatedAt:  time.Now(),
+		}
+		return tx.Create(&audit).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Paid orders are emailed once the payment settles
+	if order.Status == domain.OrderConfirmed {
+		queueOrderEmail(s.DB, s.Pool, s.EmailService, user.Email, &order)
+	} else if err := s.Payments.Open(payment); err != nil {
+		return nil, err
+	}
+
+	return s.GetOrder(userID, order.ID)
+}
+
+func (s *OrderService) GetOrder(userID uuid.UUID, orderID uuid.UUID) (*domain.Order, error) {
+	var order domain.Order
+	if err := s.DB.Preload("Event").
+		Preload("Payment").
+		Preload("Items.TicketType").
+		Preload("Registrations.TicketType").
+		Preload("Registrations.Ticket").
+		Preload("Registrations.Seat.Section").
+		First(&order, "id = ? AND user_id = ?", orderID, userID).Error; err != nil {
+		return nil, errors.New("order not found")
+	}
+	return &order, nil
+}
+
+func (s *OrderService) GetUserOrders(userID uuid.UUID) ([]domain.Order, error) {
+	var orders []domain.Order
+	err := s.DB.Preload("Event").
+		Preload("Items.TicketType").
+		Where("user_id = ?", userID).
+		Order("created_at desc").
+		Find(&orders).Error
+	return orders, err
+}
+
+// queueOrderEmail sends one confirmation listing every ticket in the order.
+// order.Registrations must be loaded with their tickets.
+func queueOrderEmail(db *gorm.DB, pool *worker.WorkerPool, es email.EmailService, to string, order *domain.Order) {
+	var event domain.Event
+	db.First(&event, "id = ?", order.EventID)
+
+	var ticketTypes []domain.TicketType
+	db.Where("event_id = ?", order.EventID).Find(&ticketTypes)
+	names := make(map[uuid.UUID]string, len(ticketTypes))
+	for _, tt := range ticketTypes {
+		names[tt.ID] = tt.Name
+	}
+
+	tickets := make([]email.OrderTicket, 0, len(order.Registrations))
+	for _, registration := range order.Registrations {
+		attendee := registration.AttendeeName
+		if attendee == "" {
+			attendee = registration.AttendeeEmail
+		}
+		if attendee == "" {
+			attendee = to
+		}
+
+		line := email.OrderTicket{TicketType: names[registration.TicketTypeID], Attendee: attendee}
+		if registration.Ticket != nil {
+			line.TicketCode = registration.Ticket.TicketCode
+		}
+		tickets = append(tickets, line)
+	}
+
+	pool.Submit(worker.Task{
+		Type: worker.TaskReminder,
+		Payload: map[string]interface{}{
+			"email":       to,
+			"event_title": event.Title,
+			"order_id":    order.ID.String(),
+			"tickets":     tickets,
+		},
+		Callback: func(t worker.Task) error {
+			return es.SendOrderConfirmationEmail(
+				t.Payload["email"].(string),
+				t.Payload["event_title"].(string),
+				t.Payload["order_id"].(string),
+				t.Payload["tickets"].([]email.OrderTicket),
+			)
+		},
+	})
+}
+
+// mergeOrderLines validates the requested lines, folds repeated ticket types
+// into one line and sorts them by ticket type ID for a stable lock order.
+func mergeOrderLines(lines []OrderLine) ([]OrderLine, error) {
+	if len(lines) == 0 {
+		return nil, errors.New("order must contain at least one item")
+	}
+
+	merged := make(map[uuid.UUID]*OrderLine)
+	total := 0
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			return nil, errors.New("quantity must be greater than 0")
+		}
+		if len(line.Attendees) > line.Quantity {
+			return nil, errors.New("more attendees than tickets in order item")
+		}
+		if len(line.SeatIDs) > line.Quantity {
+			return nil, errors.New("more seats than tickets in order item")
+		}
+		total += line.Quantity
+
+		if existing, ok := merged[line.TicketTypeID]; ok {
+			// Keep each attendee and picked seat on a seat of their own line
+			if len(line.Attendees) > 0 {
+				for len(existing.Attendees) < existing.Quantity {
+					existing.Attendees = append(existing.Attendees, OrderAttendee{})
+				}
+			}
+			if len(line.SeatIDs) > 0 {
+				for len(existing.SeatIDs) < existing.Quantity {
+					existing.SeatIDs = append(existing.SeatIDs, uuid.Nil)
+				}
+			}
+			existing.Quantity += line.Quantity
+			existing.Attendees = append(existing.Attendees, line.Attendees...)
+			existing.SeatIDs = append(existing.SeatIDs, line.SeatIDs...)
+			continue
+		}
+		l := line
+		merged[line.TicketTypeID] = &l
+	}
+
+	if total > MaxOrderSeats {
+		return nil, fmt.Errorf("an order cannot contain more than %d tickets", MaxOrderSeats)
+	}
+
+	result := make([]OrderLine, 0, len(merged))
+	for _, line := range merged {
+		result = append(result, *line)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].TicketTypeID.String() < result[j].TicketTypeID.String()
+	})
+	return result, nil
+}
+
+func ticketTypeLabel(tx *gorm.DB, ticketTypeID uuid.UUID) string {
+	var ticketType domain.TicketType
+	if err := tx.Select("name").First(&ticketType, "id = ?", ticketTypeID).Error; err != nil {
+		return ticketTypeID.String()
+	}
+	return ticketType.Name
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+)
+
+func TestMergeOrderLines(t *testing.T) {
+	a := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	b := uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+
+	lines, err := mergeOrderLines([]OrderLine{
+		{TicketTypeID: b, Quantity: 1},
+		{TicketTypeID: a, Quantity: 2, Attendees: []OrderAttendee{{Name: "Ada"}}},
+		{TicketTypeID: a, Quantity: 1, Attendees: []OrderAttendee{{Name: "Grace"}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(lines) != 2 || lines[0].TicketTypeID != a || lines[1].TicketTypeID != b {
+		t.Fatalf("expected one line per ticket type in ID order, got %+v", lines)
+	}
+	if lines[0].Quantity != 3 {
+		t.Errorf("expected repeated ticket types to be summed, got %d", lines[0].Quantity)
+	}
+	names := []string{}
+	for _, attendee := range lines[0].Attendees {
+		names = append(names, attendee.Name)
+	}
+	if strings.Join(names, ",") != "Ada,,Grace" {
+		t.Errorf("expected attendees to keep the seats of their own line, got %q", names)
+	}
+
+	// A seat picked on the second line stays with that line's attendee
+	seat := uuid.New()
+	lines, err = mergeOrderLines([]OrderLine{
+		{TicketTypeID: a, Quantity: 2, Attendees: []OrderAttendee{{Name: "Ada"}, {Name: "Alan"}}},
+		{TicketTypeID: a, Quantity: 1, Attendees: []OrderAttendee{{Name: "Grace"}}, SeatIDs: []uuid.UUID{seat}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := lines[0].SeatIDs; len(got) != 3 || got[0] != uuid.Nil || got[1] != uuid.Nil || got[2] != seat {
+		t.Errorf("expected the picked seat to follow the auto-assigned seats of the first line, got %v", got)
+	}
+
+	tests := []struct {
+		name  string
+		lines []OrderLine
+	}{
+		{"empty order", nil},
+		{"zero quantity", []OrderLine{{TicketTypeID: a}}},
+		{"too many attendees", []OrderLine{{TicketTypeID: a, Quantity: 1, Attendees: make([]OrderAttendee, 2)}}},
+		{"too many seats", []OrderLine{{TicketTypeID: a, Quantity: 1, SeatIDs: []uuid.UUID{uuid.New(), uuid.New()}}}},
+		{"over the order cap", []OrderLine{{TicketTypeID: a, Quantity: MaxOrderSeats}, {TicketTypeID: b, Quantity: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := mergeOrderLines(tt.lines); err == nil {
+				t.Error("expected the order to be refused")
+			}
+		})
+	}
+}
+
+func TestOrderService_CreateOrder_PaidOrderAwaitsPayment(t *testing.T) {
+	rs, mock, cleanup := newTestRegistrationService(t)
+	defer cleanup()
+	svc := NewOrderService(rs.DB, rs.TicketService, rs.EmailService, rs.Pool, rs.Inventory, rs.Payments)
+
+	userID, eventID := uuid.New(), uuid.New()
+	gaID := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	vipID := uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+
+	mock.ExpectQuery(`SELECT \* FROM "users"`).
+		WithArgs(userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "email_verified_at"}).AddRow(userID, "user@test.com", time.Now()))
+	mock.ExpectQuery(`SELECT "id","waiting_room" FROM "events"`).
+		WithArgs(eventID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "waiting_room"}).AddRow(eventID, false))
+
+	mock.ExpectBegin()
+	// Ticket types are reserved in ID order whatever order they were asked for in
+	for _, tt := range []struct {
+		id    uuid.UUID
+		price float64
+		n     int
+	}{{gaID, 20, 2}, {vipID, 50, 1}} {
+		mock.ExpectQuery(`SELECT \* FROM "events"`).
+			WithArgs(eventID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(eventID, domain.StatusPublished))
+		mock.ExpectQuery(`SELECT \* FROM "ticket_types"`).
+			WithArgs(tt.id, eventID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "price"}).AddRow(tt.id, eventID, tt.price))
+		mock.ExpectExec(`UPDATE ticket_type_shards SET remaining = remaining - \$1`).
+			WithArgs(tt.n, tt.id, tt.n, tt.n).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectQuery(`INSERT INTO "payments"`).
+		WithArgs(userID, "fake", sqlmock.AnyArg(), sqlmock.AnyArg(), 90.0, "USD", domain.PaymentPending, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(`INSERT INTO "orders"`).
+		WithArgs(userID, eventID, domain.OrderPendingPayment, 90.0, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(`INSERT INTO "order_items"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()).AddRow(uuid.New()))
+	// Every seat's answers are checked against the form of its own ticket type
+	for _, ticketTypeID := range []uuid.UUID{gaID, gaID, vipID} {
+		mock.ExpectQuery(`SELECT \* FROM "registration_questions"`).
+			WithArgs(eventID, ticketTypeID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery(`INSERT INTO "registrations"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectQuery(`INSERT INTO "audit_logs"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	}
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(userID, "CREATE_ORDER", "order", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+	mock.ExpectExec(`UPDATE "payments" SET "client_secret"=\$1,"intent_id"=\$2`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// The stored order is read back for the response
+	orderID := uuid.New()
+	mock.ExpectQuery(`SELECT \* FROM "orders"`).
+		WithArgs(sqlmock.AnyArg(), userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "event_id", "status", "total_amount"}).
+			AddRow(orderID, userID, eventID, domain.OrderPendingPayment, 90.0))
+	mock.ExpectQuery(`SELECT \* FROM "events"`).WithArgs(eventID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(eventID))
+	mock.ExpectQuery(`SELECT \* FROM "order_items"`).WithArgs(orderID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "registrations"`).WithArgs(orderID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	order, err := svc.CreateOrder(userID, eventID, []OrderLine{
+		{TicketTypeID: vipID, Quantity: 1},
+		{TicketTypeID: gaID, Quantity: 2},
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.Status != domain.OrderPendingPayment || order.TotalAmount != 90 {
+		t.Errorf("expected a pending order of 90, got %+v", order)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,533 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/worker"
+	"github.com/username/event-ticketing-system/pkg/email"
+	"github.com/username/event-ticketing-system/pkg/payment"
+	"github.com/username/event-ticketing-system/pkg/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPaymentNotFound   = errors.New("payment not found")
+	ErrPaymentNotPending = errors.New("payment is no longer pending")
+)
+
+type PaymentService struct {
+	DB            *gorm.DB
+	Provider      payment.PaymentProvider
+	TicketService *TicketService
+	EmailService  email.EmailService
+	Pool          *worker.WorkerPool
+	Waitlist      *WaitlistService
+	Currency      string
+	Timeout       time.Duration
+}
+
+func NewPaymentService(db *gorm.DB, provider payment.PaymentProvider, ts *TicketService, es email.EmailService, pool *worker.WorkerPool, wl *WaitlistService, currency string, timeout time.Duration) *PaymentService {
+	return &PaymentService{DB: db, Provider: provider, TicketService: ts, EmailService: es, Pool: pool, Waitlist: wl, Currency: currency, Timeout: timeout}
+}
+
+// maxRefundAttempts is how many times a refund is sent to the provider before
+// it is marked failed and left for someone to resolve by hand.
+const maxRefundAttempts = 10
+
+// Begin records a pending payment for amount inside the caller's transaction.
+// The provider is only contacted by Open once that transaction has committed,
+// so no row locks are held across the network call. Free checkouts need no
+// payment, in which case it returns nil.
+func (s *PaymentService) Begin(tx *gorm.DB, userID uuid.UUID, amount float64) (*domain.Payment, error) {
+	if amount <= 0 {
+		return nil, nil
+	}
+
+	p := domain.Payment{
+		ID:       uuid.New(),
+		UserID:   userID,
+		Provider: s.Provider.Name(),
+		Amount:   amount,
+		Currency: s.Currency,
+		Status:   domain.PaymentPending,
+	}
+	if err := tx.Create(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Open creates the provider intent for a payment recorded by Begin. It must
+// run after Begin's transaction commits. If the provider refuses, the payment
+// fails and the seats it covers are released. A nil payment is a no-op.
+func (s *PaymentService) Open(p *domain.Payment) error {
+	if p == nil || p.IntentID != nil {
+		return nil
+	}
+
+	// The payment ID is the provider's idempotency reference, so opening the
+	// same payment again returns the same intent
+	intent, err := s.Provider.CreateIntent(p.Amount, p.Currency, p.ID.String())
+	if err != nil {
+		if settleErr := s.settle(p.ID, false, "intent_failed"); settleErr != nil {
+			utils.Logger.Error("Failed to release seats of unopened payment", zap.String("payment_id", p.ID.String()), zap.Error(settleErr))
+		}
+		return fmt.Errorf("failed to create payment: %w", err)
+	}
+
+	if err := s.DB.Model(&domain.Payment{}).Where("id = ?", p.ID).Updates(map[string]interface{}{
+		"intent_id":     intent.ID,
+		"client_secret": intent.ClientSecret,
+	}).Error; err != nil {
+		return err
+	}
+	p.IntentID = &intent.ID
+	p.ClientSecret = intent.ClientSecret
+	return nil
+}
+
+func (s *PaymentService) GetPayment(userID uuid.UUID, paymentID uuid.UUID) (*domain.Payment, error) {
+	var p domain.Payment
+	if err := s.DB.First(&p, "id = ? AND user_id = ?", paymentID, userID).Error; err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	return &p, nil
+}
+
+// Capture charges a pending payment synchronously. A decline fails the payment
+// and releases its seats, exactly as a failure webhook would.
+func (s *PaymentService) Capture(userID uuid.UUID, paymentID uuid.UUID) (*domain.Payment, error) {
+	p, err := s.GetPayment(userID, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != domain.PaymentPending {
+		return nil, ErrPaymentNotPending
+	}
+	if err := s.Open(p); err != nil {
+		return nil, err
+	}
+
+	intent, err := s.Provider.Capture(*p.IntentID)
+	if err != nil {
+		if errors.Is(err, payment.ErrDeclined) {
+			reason := "card_declined"
+			if intent != nil && intent.FailureReason != "" {
+				reason = intent.FailureReason
+			}
+			if settleErr := s.settle(p.ID, false, reason); settleErr != nil {
+				return nil, settleErr
+			}
+		}
+		return nil, err
+	}
+
+	if err := s.settle(p.ID, true, ""); err != nil {
+		return nil, err
+	}
+	return s.GetPayment(userID, paymentID)
+}
+
+// HandleWebhook applies an asynchronous payment result sent by the provider.
+// Results for payments that were already settled are ignored, so providers
+// can safely retry deliveries.
+func (s *PaymentService) HandleWebhook(body []byte, signature string) error {
+	event, err := s.Provider.VerifyWebhook(body, signature)
+	if err != nil {
+		return err
+	}
+
+	var p domain.Payment
+	if err := s.DB.First(&p, "intent_id = ?", event.IntentID).Error; err != nil {
+		return ErrPaymentNotFound
+	}
+
+	switch event.Type {
+	case payment.EventPaymentSucceeded:
+		return s.settle(p.ID, true, "")
+	case payment.EventPaymentFailed:
+		reason := event.FailureReason
+		if reason == "" {
+			reason = "payment_failed"
+		}
+		return s.settle(p.ID, false, reason)
+	default:
+		utils.Logger.Info("Ignoring unhandled payment webhook", zap.String("type", event.Type))
+		return nil
+	}
+}
+
+// ExpirePayments fails payments left pending past Timeout so their seats go
+// back on sale, and cancels their intents so they can no longer be charged.
+// It runs from the background sweeper.
+func (s *PaymentService) ExpirePayments() {
+	var stale []domain.Payment
+	if err := s.DB.Where("status = ? AND created_at < ?", domain.PaymentPending, time.Now().Add(-s.Timeout)).Find(&stale).Error; err != nil {
+		utils.Logger.Error("Failed to fetch stale payments", zap.Error(err))
+		return
+	}
+
+	for _, p := range stale {
+		if err := s.settle(p.ID, false, "expired"); err != nil {
+			utils.Logger.Error("Failed to expire payment", zap.String("payment_id", p.ID.String()), zap.Error(err))
+			continue
+		}
+		s.cancelIntent(&p)
+	}
+}
+
+// settle moves a pending payment to its final state. A success reported for a
+// payment that already failed is refunded, since its seats may be gone.
+func (s *PaymentService) settle(paymentID uuid.UUID, succeeded bool, reason string) error {
+	var p domain.Payment
+	var done *settlement
+	var late *domain.Refund
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&p, "id = ?", paymentID).Error; err != nil {
+			return ErrPaymentNotFound
+		}
+		if succeeded && p.Status == domain.PaymentFailed {
+			var err error
+			late, err = refundLatePayment(tx, &p)
+			return err
+		}
+		if p.Status != domain.PaymentPending {
+			return nil
+		}
+
+		var err error
+		done, err = s.settleLocked(tx, &p, succeeded, reason)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if late != nil {
+		utils.Logger.Warn("Payment succeeded after it had failed; refunding it",
+			zap.String("payment_id", p.ID.String()), zap.String("failure_reason", p.FailureReason), zap.Float64("amount", p.Amount))
+		s.sendRefund(late.ID)
+	}
+	s.finishSettlement(done)
+	return nil
+}
+
+// settlement is the work left once a settled payment's transaction commits.
+type settlement struct {
+	payment       *domain.Payment
+	registrations []domain.Registration
+	offers        []*domain.WaitlistEntry
+	sold          []domain.ResaleListing
+	refunds       []*domain.Refund
+}
+
+// settleLocked moves p, which the caller has locked inside tx and found
+// pending, to its final state. On success every seat it covers is confirmed
+// and ticketed; on failure the seats are cancelled and released to the
+// waitlist or back into inventory. Pass the result to finishSettlement once
+// tx commits.
+func (s *PaymentService) settleLocked(tx *gorm.DB, p *domain.Payment, succeeded bool, reason string) (*settlement, error) {
+	done := &settlement{payment: p}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("payment_id = ? AND status = ?", p.ID, domain.RegistrationPendingPayment).
+		Find(&done.registrations).Error; err != nil {
+		return nil, err
+	}
+	registrations := done.registrations
+
+	action := "PAYMENT_SUCCEEDED"
+	orderStatus := domain.OrderConfirmed
+	if succeeded {
+		p.Status = domain.PaymentSucceeded
+		for i := range registrations {
+			registrations[i].Status = domain.RegistrationConfirmed
+			if err := tx.Model(&registrations[i]).Update("status", domain.RegistrationConfirmed).Error; err != nil {
+				return nil, err
+			}
+			if _, err := s.TicketService.GenerateTicket(tx, registrations[i].ID); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		action = "PAYMENT_FAILED"
+		orderStatus = domain.OrderCancelled
+		p.Status = domain.PaymentFailed
+		p.FailureReason = reason
+		cancelled := make([]uuid.UUID, len(registrations))
+		for i := range registrations {
+			registrations[i].Status = domain.RegistrationCancelled
+			if err := tx.Model(&registrations[i]).Update("status", domain.RegistrationCancelled).Error; err != nil {
+				return nil, err
+			}
+			offer, err := s.Waitlist.ReleaseSeat(tx, registrations[i].EventID, registrations[i].TicketTypeID)
+			if err != nil {
+				return nil, err
+			}
+			if offer != nil {
+				done.offers = append(done.offers, offer)
+			}
+			cancelled[i] = registrations[i].ID
+		}
+		if err := releasePromoRedemptions(tx, cancelled); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if done.sold, done.refunds, err = s.settleResales(tx, p, succeeded); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Save(p).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(&domain.Order{}).
+		Where("payment_id = ? AND status = ?", p.ID, domain.OrderPendingPayment).
+		Update("status", orderStatus).Error; err != nil {
+		return nil, err
+	}
+
+	audit := domain.AuditLog{
+		ID:         uuid.New(),
+		UserID:     p.UserID,
+		Action:     action,
+		EntityType: "payment",
+		EntityID:   p.ID,
+		NewValues:  fmt.Sprintf(`{"status": "%s", "seats": %d, "reason": "%s"}`, p.Status, len(registrations), reason),
+		CreatedAt:  time.Now(),
+	}
+	if err := tx.Create(&audit).Error; err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+// finishSettlement sends the offers and emails held back while a payment was
+// settling. A nil settlement is a no-op.
+func (s *PaymentService) finishSettlement(done *settlement) {
+	if done == nil {
+		return
+	}
+
+	for _, offer := range done.offers {
+		s.Waitlist.NotifyOffer(offer.ID)
+	}
+
+	if done.payment.Status == domain.PaymentSucceeded && len(done.registrations) > 0 {
+		s.notifyConfirmed(done.payment.UserID, done.registrations)
+	}
+	for _, listing := range done.sold {
+		queueTicketEmail(s.Pool, s.EmailService, listing.Registration.AttendeeEmail, listing.RegistrationID, listing.Registration.Event.Title)
+	}
+	for _, refund := range done.refunds {
+		s.sendRefund(refund.ID)
+	}
+}
+
+// cancelIntent voids the provider intent of a payment that will no longer be
+// honoured. A charge that beats the cancellation arrives as a late success
+// and is refunded, so failures are only logged.
+func (s *PaymentService) cancelIntent(p *domain.Payment) {
+	if p.IntentID == nil {
+		return
+	}
+	if err := s.Provider.Cancel(*p.IntentID); err != nil {
+		utils.Logger.Warn("Failed to cancel payment intent", zap.String("payment_id", p.ID.String()), zap.Error(err))
+	}
+}
+
+// notifyConfirmed sends the confirmation emails that were held back while the
+// payment was pending: one summary for an order, otherwise one per ticket.
+func (s *PaymentService) notifyConfirmed(userID uuid.UUID, registrations []domain.Registration) {
+	var user domain.User
+	if err := s.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return
+	}
+
+	if orderID := registrations[0].OrderID; orderID != nil {
+		var order domain.Order
+		if err := s.DB.Preload("Registrations.Ticket").First(&order, "id = ?", *orderID).Error; err == nil {
+			queueOrderEmail(s.DB, s.Pool, s.EmailService, user.Email, &order)
+		}
+		return
+	}
+
+	var event domain.Event
+	s.DB.First(&event, "id = ?", registrations[0].EventID)
+	for _, registration := range registrations {
+		queueTicketEmail(s.Pool, s.EmailService, user.Email, registration.ID, event.Title)
+	}
+}
+
+// RefundRegistration records a pending refund of percent of what was paid for
+// a registration inside the caller's transaction; pass it to sendRefund once
+// that commits. Registrations that were free or never paid for need no refund
+// and return nil.
+func (s *PaymentService) RefundRegistration(tx *gorm.DB, registration *domain.Registration, percent int) (*domain.Refund, error) {
+	if registration.PaymentID == nil || registration.Amount <= 0 {
+		return nil, nil
+	}
+
+	var p domain.Payment
+	if err := tx.First(&p, "id = ?", *registration.PaymentID).Error; err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	if p.Status != domain.PaymentSucceeded {
+		return nil, nil
+	}
+
+	amount := refundAmount(registration.Amount, percent)
+	if amount <= 0 {
+		return nil, nil
+	}
+
+	refund := domain.Refund{
+		ID:             uuid.New(),
+		RegistrationID: &registration.ID,
+		PaymentID:      p.ID,
+		UserID:         p.UserID,
+		EventID:        &registration.EventID,
+		Amount:         amount,
+		Percent:        percent,
+		Reason:         domain.RefundCancellation,
+		Status:         domain.RefundPending,
+	}
+	if err := tx.Create(&refund).Error; err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// refundLatePayment records a full refund for a charge that succeeded after
+// its payment had failed or expired, and marks the payment refunded so that
+// repeated deliveries of the same result change nothing. The refund is sent
+// once the caller's transaction commits.
+func refundLatePayment(tx *gorm.DB, p *domain.Payment) (*domain.Refund, error) {
+	refund := domain.Refund{
+		ID:        uuid.New(),
+		PaymentID: p.ID,
+		UserID:    p.UserID,
+		Amount:    p.Amount,
+		Percent:   100,
+		Reason:    domain.RefundLatePayment,
+		Status:    domain.RefundPending,
+	}
+	if err := tx.Create(&refund).Error; err != nil {
+		return nil, err
+	}
+
+	p.Status = domain.PaymentRefunded
+	if err := tx.Model(p).Update("status", p.Status).Error; err != nil {
+		return nil, err
+	}
+
+	audit := domain.AuditLog{
+		ID:         uuid.New(),
+		UserID:     p.UserID,
+		Action:     "PAYMENT_LATE_SUCCESS_REFUNDED",
+		EntityType: "payment",
+		EntityID:   p.ID,
+		OldValues:  fmt.Sprintf(`{"status": "failed", "reason": "%s"}`, p.FailureReason),
+		NewValues:  fmt.Sprintf(`{"status": "refunded", "refund_id": "%s", "amount": %.2f}`, refund.ID, refund.Amount),
+		CreatedAt:  time.Now(),
+	}
+	if err := tx.Create(&audit).Error; err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// ProcessRefunds sends pending refunds to the provider. Refunds are normally
+// sent right after the transaction that recorded them commits; this runs from
+// the background sweeper to retry the ones that did not go through.
+func (s *PaymentService) ProcessRefunds() {
+	var pending []domain.Refund
+	if err := s.DB.Select("id").Where("status = ?", domain.RefundPending).Order("created_at").Find(&pending).Error; err != nil {
+		utils.Logger.Error("Failed to fetch pending refunds", zap.Error(err))
+		return
+	}
+
+	for _, refund := range pending {
+		s.sendRefund(refund.ID)
+	}
+}
+
+// sendRefund sends one pending refund to the provider and records the outcome.
+// The refund row stays locked during the call so two workers never send it
+// at once, and its ID is the provider's idempotency reference in case a
+// response is lost. It returns the refund as it stands afterwards, or nil if
+// another worker holds it.
+func (s *PaymentService) sendRefund(refundID uuid.UUID) *domain.Refund {
+	var refund domain.Refund
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			First(&refund, "id = ? AND status = ?", refundID, domain.RefundPending).Error; err != nil {
+			return err
+		}
+
+		var p domain.Payment
+		if err := tx.First(&p, "id = ?", refund.PaymentID).Error; err != nil {
+			return err
+		}
+
+		refund.Attempts++
+		var result *payment.Refund
+		err := errors.New("payment has no provider intent")
+		if p.IntentID != nil {
+			result, err = s.Provider.Refund(*p.IntentID, refund.Amount, refund.ID.String())
+		}
+		if err != nil {
+			refund.LastError = err.Error()
+			if refund.Attempts >= maxRefundAttempts || p.IntentID == nil || errors.Is(err, payment.ErrRefundTooLarge) {
+				refund.Status = domain.RefundFailed
+				utils.Logger.Error("Refund failed and needs manual attention",
+					zap.String("refund_id", refund.ID.String()), zap.String("payment_id", p.ID.String()), zap.Float64("amount", refund.Amount), zap.Error(err))
+			} else {
+				utils.Logger.Warn("Refund attempt failed; will retry", zap.String("refund_id", refund.ID.String()), zap.Int("attempts", refund.Attempts), zap.Error(err))
+			}
+			return tx.Save(&refund).Error
+		}
+
+		now := time.Now()
+		refund.Status = domain.RefundSucceeded
+		refund.ProviderRefundID = result.ID
+		refund.RefundedAt = &now
+		refund.LastError = ""
+		return tx.Save(&refund).Error
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Logger.Error("Failed to send refund", zap.String("refund_id", refundID.String()), zap.Error(err))
+		}
+		return nil
+	}
+	return &refund
+}
+
+// refundPercent applies an event's cancellation policy at the given time.
+func refundPercent(event *domain.Event, now time.Time) int {
+	if !now.Before(event.StartTime) {
+		return 0
+	}
+	fullUntil := event.StartTime.Add(-time.Duration(event.RefundFullHoursBefore) * time.Hour)
+	if now.Before(fullUntil) {
+		return 100
+	}
+	return event.RefundPartialPercent
+}
+
+// refundAmount returns percent of amount, rounded to the cent.
+func refundAmount(amount float64, percent int) float64 {
+	cents := math.Round(amount * 100)
+	return math.Round(cents*float64(percent)/100) / 100
+}
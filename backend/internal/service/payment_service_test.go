@@ -0,0 +1,191 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/pkg/payment"
+)
+
+func newTestPaymentService(t *testing.T) (*PaymentService, *payment.FakeProvider, sqlmock.Sqlmock, func()) {
+	rs, mock, cleanup := newTestRegistrationService(t)
+	return rs.Payments, rs.Payments.Provider.(*payment.FakeProvider), mock, cleanup
+}
+
+func TestRefundPercent(t *testing.T) {
+	start := time.Date(2026, 6, 1, 18, 0, 0, 0, time.UTC)
+	event := &domain.Event{StartTime: start, RefundFullHoursBefore: 48, RefundPartialPercent: 25}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want int
+	}{
+		{"well before the full refund cutoff", start.Add(-72 * time.Hour), 100},
+		{"exactly at the cutoff", start.Add(-48 * time.Hour), 25},
+		{"inside the partial window", start.Add(-time.Hour), 25},
+		{"at the start", start, 0},
+		{"after the start", start.Add(time.Hour), 0},
+	}
+
+	for _, tt := range tests {
+		if got := refundPercent(event, tt.now); got != tt.want {
+			t.Errorf("%s: refundPercent = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRefundAmountRoundsToCents(t *testing.T) {
+	if got := refundAmount(19.99, 50); got != 10.00 {
+		t.Errorf("refundAmount(19.99, 50) = %.4f, want 10.00", got)
+	}
+	if got := refundAmount(33.33, 100); got != 33.33 {
+		t.Errorf("refundAmount(33.33, 100) = %.4f, want 33.33", got)
+	}
+}
+
+func TestPaymentService_HandleWebhook_RefundsLateSuccess(t *testing.T) {
+	svc, provider, mock, cleanup := newTestPaymentService(t)
+	defer cleanup()
+
+	paymentID, userID, refundID := uuid.New(), uuid.New(), uuid.New()
+	intent, _ := provider.CreateIntent(20, "USD", paymentID.String())
+	columns := []string{"id", "user_id", "intent_id", "amount", "currency", "status", "failure_reason"}
+	expired := sqlmock.NewRows(columns).AddRow(paymentID, userID, intent.ID, 20.0, "USD", domain.PaymentFailed, "expired")
+
+	mock.ExpectQuery(`SELECT \* FROM "payments" WHERE intent_id = \$1`).
+		WithArgs(intent.ID, 1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(paymentID, userID, intent.ID, 20.0, "USD", domain.PaymentFailed, "expired"))
+
+	// The seats are gone, so the charge is recorded as owed back instead of confirming anything
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "payments" WHERE id = \$1 .* FOR UPDATE`).
+		WithArgs(paymentID, 1).
+		WillReturnRows(expired)
+	mock.ExpectQuery(`INSERT INTO "refunds"`).
+		WithArgs(nil, paymentID, userID, nil, 20.0, 100, domain.RefundLatePayment, domain.RefundPending, 0, "", "", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(refundID))
+	mock.ExpectExec(`UPDATE "payments" SET "status"=\$1`).
+		WithArgs(domain.PaymentRefunded, sqlmock.AnyArg(), paymentID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(userID, "PAYMENT_LATE_SUCCESS_REFUNDED", "payment", paymentID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	// The refund is sent as soon as the payment is marked
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "refunds" .* FOR UPDATE SKIP LOCKED`).
+		WithArgs(sqlmock.AnyArg(), domain.RefundPending, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payment_id", "user_id", "amount", "percent", "reason", "status", "attempts"}).
+			AddRow(refundID, paymentID, userID, 20.0, 100, domain.RefundLatePayment, domain.RefundPending, 0))
+	mock.ExpectQuery(`SELECT \* FROM "payments" WHERE id = \$1`).
+		WithArgs(paymentID, 1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(paymentID, userID, intent.ID, 20.0, "USD", domain.PaymentRefunded, "expired"))
+	mock.ExpectExec(`UPDATE "refunds"`).
+		WithArgs(nil, paymentID, userID, nil, 20.0, 100, domain.RefundLatePayment, domain.RefundSucceeded, 1, "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), refundID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	body := []byte(`{"type":"payment.succeeded","intent_id":"` + intent.ID + `"}`)
+	if err := svc.HandleWebhook(body, provider.SignWebhook(body)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	// The whole charge went back, so nothing is left to refund
+	if _, err := provider.Refund(intent.ID, 0.01, uuid.NewString()); !errors.Is(err, payment.ErrRefundTooLarge) {
+		t.Errorf("expected the charge to be fully refunded, got %v", err)
+	}
+}
+
+func TestPaymentService_ExpirePayments_CancelsIntent(t *testing.T) {
+	svc, provider, mock, cleanup := newTestPaymentService(t)
+	defer cleanup()
+
+	paymentID, userID, eventID, ticketTypeID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	intent, _ := provider.CreateIntent(20, "USD", paymentID.String())
+	columns := []string{"id", "user_id", "intent_id", "amount", "currency", "status"}
+
+	mock.ExpectQuery(`SELECT \* FROM "payments" WHERE status = \$1 AND created_at < \$2`).
+		WithArgs(domain.PaymentPending, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(paymentID, userID, intent.ID, 20.0, "USD", domain.PaymentPending))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "payments" WHERE id = \$1 .* FOR UPDATE`).
+		WithArgs(paymentID, 1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(paymentID, userID, intent.ID, 20.0, "USD", domain.PaymentPending))
+	mock.ExpectQuery(`SELECT \* FROM "registrations" WHERE payment_id = \$1 AND status = \$2 FOR UPDATE`).
+		WithArgs(paymentID, domain.RegistrationPendingPayment).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "ticket_type_id", "status"}).
+			AddRow(uuid.New(), eventID, ticketTypeID, domain.RegistrationPendingPayment))
+	mock.ExpectExec(`UPDATE "registrations" SET "status"=\$1`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "waitlist_entries" .* FOR UPDATE SKIP LOCKED`).
+		WithArgs(ticketTypeID, domain.WaitlistWaiting, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`UPDATE ticket_type_shards SET remaining = remaining \+ \$1`).
+		WithArgs(1, ticketTypeID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "promo_redemptions"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "resale_listings" WHERE payment_id = \$1 AND status = \$2 FOR UPDATE`).
+		WithArgs(paymentID, domain.ResalePendingPayment).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`UPDATE "payments"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "orders"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(userID, "PAYMENT_FAILED", "payment", paymentID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	svc.ExpirePayments()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+	if _, err := provider.Capture(intent.ID); !errors.Is(err, payment.ErrIntentCanceled) {
+		t.Errorf("expected the expired intent to be canceled, got %v", err)
+	}
+}
+
+func TestPaymentService_ProcessRefunds_RetriesThenGivesUp(t *testing.T) {
+	svc, _, mock, cleanup := newTestPaymentService(t)
+	defer cleanup()
+
+	refundID, paymentID := uuid.New(), uuid.New()
+	// Not a fake intent, so the provider refuses every attempt
+	intentID := "pi_unknown"
+
+	expectAttempt := func(attempts int, status domain.RefundStatus) {
+		mock.ExpectQuery(`SELECT "id" FROM "refunds" WHERE status = \$1`).
+			WithArgs(domain.RefundPending).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(refundID))
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT \* FROM "refunds" .* FOR UPDATE SKIP LOCKED`).
+			WithArgs(refundID, domain.RefundPending, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "payment_id", "amount", "reason", "status", "attempts"}).
+				AddRow(refundID, paymentID, 5.0, domain.RefundCancellation, domain.RefundPending, attempts))
+		mock.ExpectQuery(`SELECT \* FROM "payments" WHERE id = \$1`).
+			WithArgs(paymentID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "intent_id"}).AddRow(paymentID, intentID))
+		mock.ExpectExec(`UPDATE "refunds"`).
+			WithArgs(nil, paymentID, sqlmock.AnyArg(), nil, 5.0, 0, domain.RefundCancellation, status, attempts+1, payment.ErrIntentNotFound.Error(), "", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), refundID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	// An early failure stays pending for the next run; the last allowed attempt gives up
+	expectAttempt(0, domain.RefundPending)
+	svc.ProcessRefunds()
+	expectAttempt(maxRefundAttempts-1, domain.RefundFailed)
+	svc.ProcessRefunds()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
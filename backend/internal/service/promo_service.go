@@ -0,0 +1,301 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidPromoCode = &CodedError{Code: CodeInvalidPromoCode, Message: "invalid promo code"}
+
+type PromoService struct {
+	DB *gorm.DB
+}
+
+func NewPromoService(db *gorm.DB) *PromoService {
+	return &PromoService{DB: db}
+}
+
+type PromoCodeInput struct {
+	Code           string              `json:"code" binding:"required"`
+	DiscountType   domain.DiscountType `json:"discount_type" binding:"required,oneof=percentage fixed"`
+	Value          float64             `json:"value" binding:"required,gt=0"`
+	MaxUses        int                 `json:"max_uses" binding:"min=0"`
+	MaxUsesPerUser int                 `json:"max_uses_per_user" binding:"min=0"`
+	ValidFrom      *time.Time          `json:"valid_from"`
+	ValidUntil     *time.Time          `json:"valid_until"`
+	Stackable      bool                `json:"stackable"`
+	TicketTypeIDs  []uuid.UUID         `json:"ticket_type_ids"`
+}
+
+func (s *PromoService) CreatePromoCode(adminID uuid.UUID, eventID uuid.UUID, input PromoCodeInput) (*domain.PromoCode, error) {
+	if input.DiscountType == domain.DiscountPercentage && input.Value > 100 {
+		return nil, errors.New("percentage discount cannot exceed 100")
+	}
+	if input.ValidFrom != nil && input.ValidUntil != nil && !input.ValidUntil.After(*input.ValidFrom) {
+		return nil, errors.New("valid_until must be after valid_from")
+	}
+
+	var event domain.Event
+	if err := s.DB.First(&event, "id = ?", eventID).Error; err != nil {
+		return nil, errors.New("event not found")
+	}
+
+	var ticketTypes []domain.TicketType
+	if len(input.TicketTypeIDs) > 0 {
+		if err := s.DB.Where("id IN ? AND event_id = ?", input.TicketTypeIDs, eventID).Find(&ticketTypes).Error; err != nil {
+			return nil, err
+		}
+		if len(ticketTypes) != len(input.TicketTypeIDs) {
+			return nil, errors.New("all ticket types must belong to this event")
+		}
+	}
+
+	promo := domain.PromoCode{
+		ID:             uuid.New(),
+		EventID:        eventID,
+		Code:           normalizePromoCode(input.Code),
+		DiscountType:   input.DiscountType,
+		Value:          input.Value,
+		MaxUses:        input.MaxUses,
+		MaxUsesPerUser: input.MaxUsesPerUser,
+		ValidFrom:      input.ValidFrom,
+		ValidUntil:     input.ValidUntil,
+		Stackable:      input.Stackable,
+		Active:         true,
+		TicketTypes:    ticketTypes,
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&domain.PromoCode{}).Where("event_id = ? AND code = ?", eventID, promo.Code).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return errors.New("a promo code with this code already exists for the event")
+		}
+
+		if err := tx.Omit("TicketTypes.*").Create(&promo).Error; err != nil {
+			return err
+		}
+
+		audit := domain.AuditLog{
+			ID:         uuid.New(),
+			UserID:     adminID,
+			Action:     "CREATE_PROMO_CODE",
+			EntityType: "promo_code",
+			EntityID:   promo.ID,
+			NewValues:  fmt.Sprintf(`{"code": %q, "discount_type": "%s", "value": %.2f}`, promo.Code, promo.DiscountType, promo.Value),
+			CreatedAt:  time.Now(),
+		}
+		return tx.Create(&audit).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &promo, nil
+}
+
+func (s *PromoService) ListPromoCodes(eventID uuid.UUID) ([]domain.PromoCode, error) {
+	var promos []domain.PromoCode
+	err := s.DB.Preload("TicketTypes").Where("event_id = ?", eventID).Order("created_at").Find(&promos).Error
+	return promos, err
+}
+
+// DeactivatePromoCode stops a code from being redeemed. Redemptions already
+// made keep their discount.
+func (s *PromoService) DeactivatePromoCode(adminID uuid.UUID, eventID uuid.UUID, promoID uuid.UUID) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.PromoCode{}).Where("id = ? AND event_id = ?", promoID, eventID).Update("active", false)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("promo code not found")
+		}
+
+		audit := domain.AuditLog{
+			ID:         uuid.New(),
+			UserID:     adminID,
+			Action:     "DEACTIVATE_PROMO_CODE",
+			EntityType: "promo_code",
+			EntityID:   promoID,
+			CreatedAt:  time.Now(),
+		}
+		return tx.Create(&audit).Error
+	})
+}
+
+// lockPromoCodes loads and row-locks the requested codes of an event. Rows are
+// locked in ID order so concurrent redemptions of the same codes can't deadlock.
+func lockPromoCodes(tx *gorm.DB, eventID uuid.UUID, codes []string) ([]domain.PromoCode, error) {
+	seen := make(map[string]bool)
+	var normalized []string
+	for _, code := range codes {
+		code = normalizePromoCode(code)
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		normalized = append(normalized, code)
+	}
+	if len(normalized) == 0 {
+		return nil, nil
+	}
+
+	var promos []domain.PromoCode
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("TicketTypes").
+		Where("event_id = ? AND code IN ?", eventID, normalized).
+		Order("id").
+		Find(&promos).Error; err != nil {
+		return nil, err
+	}
+
+	if len(promos) != len(normalized) {
+		found := make(map[string]bool, len(promos))
+		for _, promo := range promos {
+			found[promo.Code] = true
+		}
+		for _, code := range normalized {
+			if !found[code] {
+				return nil, fmt.Errorf("%w: %s does not exist for this event", ErrInvalidPromoCode, code)
+			}
+		}
+	}
+	return promos, nil
+}
+
+// checkPromoCode verifies a code can be applied to one seat of a ticket type
+// at now, given how many times the user has already redeemed it.
+func checkPromoCode(promo *domain.PromoCode, ticketTypeID uuid.UUID, now time.Time, userUses int64) error {
+	if !promo.Active {
+		return fmt.Errorf("%w: %s is no longer active", ErrInvalidPromoCode, promo.Code)
+	}
+	if promo.ValidFrom != nil && now.Before(*promo.ValidFrom) {
+		return fmt.Errorf("%w: %s is not valid yet", ErrInvalidPromoCode, promo.Code)
+	}
+	if promo.ValidUntil != nil && !now.Before(*promo.ValidUntil) {
+		return fmt.Errorf("%w: %s has expired", ErrInvalidPromoCode, promo.Code)
+	}
+	if promo.MaxUses > 0 && promo.UsedCount >= promo.MaxUses {
+		return fmt.Errorf("%w: %s has been fully redeemed", ErrInvalidPromoCode, promo.Code)
+	}
+	if promo.MaxUsesPerUser > 0 && userUses >= int64(promo.MaxUsesPerUser) {
+		return fmt.Errorf("%w: you have already used %s the maximum number of times", ErrInvalidPromoCode, promo.Code)
+	}
+
+	if len(promo.TicketTypes) > 0 {
+		for _, tt := range promo.TicketTypes {
+			if tt.ID == ticketTypeID {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s does not apply to this ticket type", ErrInvalidPromoCode, promo.Code)
+	}
+	return nil
+}
+
+// applyDiscounts prices one seat. Several codes may only be combined when all
+// of them are stackable; percentage codes apply first, each to the running
+// price, then fixed amounts. The price never drops below zero. It returns the
+// final price and the discount each code contributed, in input order.
+func applyDiscounts(price float64, promos []domain.PromoCode) (float64, []float64, error) {
+	if len(promos) > 1 {
+		for _, promo := range promos {
+			if !promo.Stackable {
+				return 0, nil, fmt.Errorf("%w: %s cannot be combined with other codes", ErrInvalidPromoCode, promo.Code)
+			}
+		}
+	}
+
+	order := make([]int, len(promos))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return promos[order[a]].DiscountType == domain.DiscountPercentage && promos[order[b]].DiscountType != domain.DiscountPercentage
+	})
+
+	discounts := make([]float64, len(promos))
+	for _, i := range order {
+		var off float64
+		switch promos[i].DiscountType {
+		case domain.DiscountPercentage:
+			off = roundCents(price * promos[i].Value / 100)
+		case domain.DiscountFixed:
+			off = promos[i].Value
+		}
+		off = math.Min(off, price)
+		discounts[i] = off
+		price = roundCents(price - off)
+	}
+	return price, discounts, nil
+}
+
+// redeemPromoCodes records each applied code against a registration and
+// counts it towards the code's usage cap.
+func redeemPromoCodes(tx *gorm.DB, userID uuid.UUID, registrationID uuid.UUID, promos []domain.PromoCode, discounts []float64) error {
+	for i, promo := range promos {
+		redemption := domain.PromoRedemption{
+			ID:             uuid.New(),
+			PromoCodeID:    promo.ID,
+			UserID:         userID,
+			RegistrationID: registrationID,
+			Discount:       discounts[i],
+		}
+		if err := tx.Create(&redemption).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&domain.PromoCode{}).Where("id = ?", promo.ID).
+			UpdateColumn("used_count", gorm.Expr("used_count + 1")).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releasePromoRedemptions gives back the promo code uses of registrations that
+// were cancelled or never paid for, so they count against neither the code's
+// cap nor the user's. Codes are updated in ID order, matching lockPromoCodes.
+func releasePromoRedemptions(tx *gorm.DB, registrationIDs []uuid.UUID) error {
+	if len(registrationIDs) == 0 {
+		return nil
+	}
+
+	var redemptions []domain.PromoRedemption
+	if err := tx.Where("registration_id IN ? AND released_at IS NULL", registrationIDs).
+		Order("promo_code_id").
+		Find(&redemptions).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range redemptions {
+		if err := tx.Model(&redemptions[i]).Update("released_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&domain.PromoCode{}).Where("id = ? AND used_count > 0", redemptions[i].PromoCodeID).
+			UpdateColumn("used_count", gorm.Expr("used_count - 1")).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+)
+
+func TestApplyDiscounts(t *testing.T) {
+	pct := func(v float64, stackable bool) domain.PromoCode {
+		return domain.PromoCode{Code: "PCT", DiscountType: domain.DiscountPercentage, Value: v, Stackable: stackable}
+	}
+	fixed := func(v float64, stackable bool) domain.PromoCode {
+		return domain.PromoCode{Code: "FIXED", DiscountType: domain.DiscountFixed, Value: v, Stackable: stackable}
+	}
+
+	tests := []struct {
+		name      string
+		price     float64
+		promos    []domain.PromoCode
+		want      float64
+		discounts []float64
+		wantErr   bool
+	}{
+		{"no codes", 50, nil, 50, []float64{}, false},
+		{"percentage", 80, []domain.PromoCode{pct(25, false)}, 60, []float64{20}, false},
+		{"fixed", 80, []domain.PromoCode{fixed(15, false)}, 65, []float64{15}, false},
+		{"fixed larger than price", 10, []domain.PromoCode{fixed(15, false)}, 0, []float64{10}, false},
+		{"percentage applies before fixed", 100, []domain.PromoCode{fixed(10, true), pct(50, true)}, 40, []float64{10, 50}, false},
+		{"rounds to cents", 9.99, []domain.PromoCode{pct(33, false)}, 6.69, []float64{3.30}, false},
+		{"non-stackable combination", 100, []domain.PromoCode{pct(10, true), fixed(5, false)}, 0, nil, true},
+	}
+
+	for _, tt := range tests {
+		got, discounts, err := applyDiscounts(tt.price, tt.promos)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidPromoCode) {
+				t.Errorf("%s: expected ErrInvalidPromoCode, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: price = %.2f, want %.2f", tt.name, got, tt.want)
+		}
+		for i := range tt.discounts {
+			if discounts[i] != tt.discounts[i] {
+				t.Errorf("%s: discount[%d] = %.2f, want %.2f", tt.name, i, discounts[i], tt.discounts[i])
+			}
+		}
+	}
+}
+
+func TestCheckPromoCode(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	vip, general := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name     string
+		promo    domain.PromoCode
+		userUses int64
+		wantErr  bool
+	}{
+		{"valid", domain.PromoCode{Active: true}, 0, false},
+		{"inactive", domain.PromoCode{Active: false}, 0, true},
+		{"not started", domain.PromoCode{Active: true, ValidFrom: &future}, 0, true},
+		{"expired", domain.PromoCode{Active: true, ValidUntil: &past}, 0, true},
+		{"globally exhausted", domain.PromoCode{Active: true, MaxUses: 5, UsedCount: 5}, 0, true},
+		{"per-user cap reached", domain.PromoCode{Active: true, MaxUsesPerUser: 1}, 1, true},
+		{"scoped to ticket type", domain.PromoCode{Active: true, TicketTypes: []domain.TicketType{{ID: vip}}}, 0, false},
+		{"other ticket type", domain.PromoCode{Active: true, TicketTypes: []domain.TicketType{{ID: general}}}, 0, true},
+	}
+
+	for _, tt := range tests {
+		err := checkPromoCode(&tt.promo, vip, now, tt.userUses)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// QuestionService manages the registration form organizers attach to an
+// event.
+type QuestionService struct {
+	DB *gorm.DB
+}
+
+func NewQuestionService(db *gorm.DB) *QuestionService {
+	return &QuestionService{DB: db}
+}
+
+type QuestionInput struct {
+	Label        string              `json:"label" binding:"required"`
+	Type         domain.QuestionType `json:"type" binding:"required,oneof=text select multi_select checkbox number"`
+	Options      []string            `json:"options"`
+	Required     bool                `json:"required"`
+	Position     int                 `json:"position"`
+	TicketTypeID *uuid.UUID          `json:"ticket_type_id"`
+}
+
+func (s *QuestionService) CreateQuestion(adminID uuid.UUID, eventID uuid.UUID, input QuestionInput) (*domain.RegistrationQuestion, error) {
+	if err := s.DB.First(&domain.Event{}, "id = ?", eventID).Error; err != nil {
+		return nil, errors.New("event not found")
+	}
+
+	question := domain.RegistrationQuestion{ID: uuid.New(), EventID: eventID}
+	if err := s.applyInput(&question, input); err != nil {
+		return nil, err
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&question).Error; err != nil {
+			return err
+		}
+		return auditQuestion(tx, adminID, &question, "CREATE_REGISTRATION_QUESTION")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &question, nil
+}
+
+// UpdateQuestion replaces a question's definition. Answers already given keep
+// the value they were validated with.
+func (s *QuestionService) UpdateQuestion(adminID uuid.UUID, eventID uuid.UUID, questionID uuid.UUID, input QuestionInput) (*domain.RegistrationQuestion, error) {
+	var question domain.RegistrationQuestion
+	if err := s.DB.First(&question, "id = ? AND event_id = ?", questionID, eventID).Error; err != nil {
+		return nil, errors.New("question not found")
+	}
+	if err := s.applyInput(&question, input); err != nil {
+		return nil, err
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&question).Error; err != nil {
+			return err
+		}
+		return auditQuestion(tx, adminID, &question, "UPDATE_REGISTRATION_QUESTION")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &question, nil
+}
+
+// DeleteQuestion removes a question from the form. It is soft-deleted so
+// attendee lists still show the answers given to it.
+func (s *QuestionService) DeleteQuestion(adminID uuid.UUID, eventID uuid.UUID, questionID uuid.UUID) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var question domain.RegistrationQuestion
+		if err := tx.First(&question, "id = ? AND event_id = ?", questionID, eventID).Error; err != nil {
+			return errors.New("question not found")
+		}
+		if err := tx.Delete(&question).Error; err != nil {
+			return err
+		}
+		return auditQuestion(tx, adminID, &question, "DELETE_REGISTRATION_QUESTION")
+	})
+}
+
+// ListQuestions returns an event's form in display order. With a ticket type,
+// only the questions asked of that tier are returned.
+func (s *QuestionService) ListQuestions(eventID uuid.UUID, ticketTypeID *uuid.UUID) ([]domain.RegistrationQuestion, error) {
+	return formQuestions(s.DB, eventID, ticketTypeID)
+}
+
+func (s *QuestionService) applyInput(question *domain.RegistrationQuestion, input QuestionInput) error {
+	if input.TicketTypeID != nil {
+		if err := s.DB.First(&domain.TicketType{}, "id = ? AND event_id = ?", *input.TicketTypeID, question.EventID).Error; err != nil {
+			return errors.New("ticket type not found for this event")
+		}
+	}
+	options, err := normalizeOptions(input.Type, input.Options)
+	if err != nil {
+		return err
+	}
+
+	question.Label = strings.TrimSpace(input.Label)
+	question.Type = input.Type
+	question.Options = options
+	question.Required = input.Required
+	question.Position = input.Position
+	question.TicketTypeID = input.TicketTypeID
+	return nil
+}
+
+// normalizeOptions trims the choices of a select question and rejects empty or
+// duplicate ones. Other types take no options.
+func normalizeOptions(questionType domain.QuestionType, options []string) ([]string, error) {
+	if questionType != domain.QuestionSelect && questionType != domain.QuestionMultiSelect {
+		if len(options) > 0 {
+			return nil, fmt.Errorf("%s questions do not take options", questionType)
+		}
+		return nil, nil
+	}
+
+	if len(options) == 0 {
+		return nil, errors.New("select questions need at least one option")
+	}
+	normalized := make([]string, 0, len(options))
+	seen := make(map[string]bool, len(options))
+	for _, option := range options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return nil, errors.New("options cannot be empty")
+		}
+		if seen[option] {
+			return nil, fmt.Errorf("duplicate option %q", option)
+		}
+		seen[option] = true
+		normalized = append(normalized, option)
+	}
+	return normalized, nil
+}
+
+// formQuestions loads the questions asked when registering for an event; a nil
+// ticket type returns the whole form.
+func formQuestions(db *gorm.DB, eventID uuid.UUID, ticketTypeID *uuid.UUID) ([]domain.RegistrationQuestion, error) {
+	query := db.Where("event_id = ?", eventID)
+	if ticketTypeID != nil {
+		query = query.Where("ticket_type_id IS NULL OR ticket_type_id = ?", *ticketTypeID)
+	}
+	var questions []domain.RegistrationQuestion
+	err := query.Order("position, created_at").Find(&questions).Error
+	return questions, err
+}
+
+// validateAnswers checks answers, keyed by question ID, against the form and
+// returns them ready to store. Every required question must be answered and
+// no answer may be given to a question that is not on the form.
+func validateAnswers(questions []domain.RegistrationQuestion, answers map[uuid.UUID]json.RawMessage) ([]domain.RegistrationAnswer, error) {
+	byID := make(map[uuid.UUID]*domain.RegistrationQuestion, len(questions))
+	for i := range questions {
+		byID[questions[i].ID] = &questions[i]
+	}
+	for id := range answers {
+		if byID[id] == nil {
+			return nil, newCodedError(CodeInvalidAnswer, "question %s is not part of this registration form", id)
+		}
+	}
+
+	var result []domain.RegistrationAnswer
+	for i := range questions {
+		question := &questions[i]
+		raw, ok := answers[question.ID]
+		if !ok || string(raw) == "null" {
+			if question.Required {
+				return nil, newCodedError(CodeInvalidAnswer, "%q is required", question.Label)
+			}
+			continue
+		}
+
+		value, answered, err := parseAnswer(question, raw)
+		if err != nil {
+			return nil, newCodedError(CodeInvalidAnswer, "%q: %s", question.Label, err.Error())
+		}
+		if !answered {
+			if question.Required {
+				return nil, newCodedError(CodeInvalidAnswer, "%q is required", question.Label)
+			}
+			continue
+		}
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, domain.RegistrationAnswer{
+			ID:         uuid.New(),
+			QuestionID: question.ID,
+			Value:      encoded,
+		})
+	}
+	return result, nil
+}
+
+// parseAnswer decodes raw as the question's type. answered is false for an
+// empty text, an unticked checkbox or no selected choices.
+func parseAnswer(question *domain.RegistrationQuestion, raw json.RawMessage) (value interface{}, answered bool, err error) {
+	switch question.Type {
+	case domain.QuestionText:
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return nil, false, errors.New("expected text")
+		}
+		text = strings.TrimSpace(text)
+		return text, text != "", nil
+
+	case domain.QuestionNumber:
+		var number float64
+		if err := json.Unmarshal(raw, &number); err != nil {
+			return nil, false, errors.New("expected a number")
+		}
+		return number, true, nil
+
+	case domain.QuestionCheckbox:
+		var checked bool
+		if err := json.Unmarshal(raw, &checked); err != nil {
+			return nil, false, errors.New("expected true or false")
+		}
+		return checked, checked, nil
+
+	case domain.QuestionSelect:
+		var choice string
+		if err := json.Unmarshal(raw, &choice); err != nil {
+			return nil, false, errors.New("expected one of the options")
+		}
+		if choice == "" {
+			return choice, false, nil
+		}
+		if !containsOption(question.Options, choice) {
+			return nil, false, fmt.Errorf("%q is not one of the options", choice)
+		}
+		return choice, true, nil
+
+	case domain.QuestionMultiSelect:
+		var choices []string
+		if err := json.Unmarshal(raw, &choices); err != nil {
+			return nil, false, errors.New("expected a list of options")
+		}
+		seen := make(map[string]bool, len(choices))
+		for _, choice := range choices {
+			if !containsOption(question.Options, choice) {
+				return nil, false, fmt.Errorf("%q is not one of the options", choice)
+			}
+			if seen[choice] {
+				return nil, false, fmt.Errorf("%q is selected twice", choice)
+			}
+			seen[choice] = true
+		}
+		return choices, len(choices) > 0, nil
+	}
+	return nil, false, fmt.Errorf("unsupported question type %q", question.Type)
+}
+
+func containsOption(options []string, choice string) bool {
+	for _, option := range options {
+		if option == choice {
+			return true
+		}
+	}
+	return false
+}
+
+// saveAnswers stores validated answers against a new registration inside the
+// caller's transaction.
+func saveAnswers(tx *gorm.DB, registrationID uuid.UUID, answers []domain.RegistrationAnswer) error {
+	if len(answers) == 0 {
+		return nil
+	}
+	for i := range answers {
+		answers[i].RegistrationID = registrationID
+	}
+	return tx.Create(&answers).Error
+}
+
+func auditQuestion(tx *gorm.DB, adminID uuid.UUID, question *domain.RegistrationQuestion, action string) error {
+	audit := domain.AuditLog{
+		ID:         uuid.New(),
+		UserID:     adminID,
+		Action:     action,
+		EntityType: "registration_question",
+		EntityID:   question.ID,
+		NewValues:  utils.ToJSON(question),
+		CreatedAt:  time.Now(),
+	}
+	return tx.Create(&audit).Error
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+)
+
+func TestValidateAnswers(t *testing.T) {
+	diet := domain.RegistrationQuestion{ID: uuid.New(), Label: "Diet", Type: domain.QuestionSelect, Options: []string{"None", "Vegan"}, Required: true}
+	sessions := domain.RegistrationQuestion{ID: uuid.New(), Label: "Sessions", Type: domain.QuestionMultiSelect, Options: []string{"AM", "PM"}}
+	company := domain.RegistrationQuestion{ID: uuid.New(), Label: "Company", Type: domain.QuestionText}
+	age := domain.RegistrationQuestion{ID: uuid.New(), Label: "Age", Type: domain.QuestionNumber}
+	terms := domain.RegistrationQuestion{ID: uuid.New(), Label: "Terms", Type: domain.QuestionCheckbox, Required: true}
+	form := []domain.RegistrationQuestion{diet, sessions, company, age, terms}
+
+	answers := func(pairs map[uuid.UUID]string) map[uuid.UUID]json.RawMessage {
+		out := make(map[uuid.UUID]json.RawMessage, len(pairs))
+		for id, raw := range pairs {
+			out[id] = json.RawMessage(raw)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		answers map[uuid.UUID]json.RawMessage
+		stored  int
+		wantErr bool
+	}{
+		{"required only", answers(map[uuid.UUID]string{diet.ID: `"Vegan"`, terms.ID: `true`}), 2, false},
+		{"everything", answers(map[uuid.UUID]string{diet.ID: `"None"`, sessions.ID: `["AM","PM"]`, company.ID: `" Acme "`, age.ID: `42`, terms.ID: `true`}), 5, false},
+		{"blank optional text is skipped", answers(map[uuid.UUID]string{diet.ID: `"None"`, company.ID: `"  "`, terms.ID: `true`}), 2, false},
+		{"missing required select", answers(map[uuid.UUID]string{terms.ID: `true`}), 0, true},
+		{"required checkbox unticked", answers(map[uuid.UUID]string{diet.ID: `"None"`, terms.ID: `false`}), 0, true},
+		{"unknown option", answers(map[uuid.UUID]string{diet.ID: `"Keto"`, terms.ID: `true`}), 0, true},
+		{"repeated choice", answers(map[uuid.UUID]string{diet.ID: `"None"`, sessions.ID: `["AM","AM"]`, terms.ID: `true`}), 0, true},
+		{"wrong type", answers(map[uuid.UUID]string{diet.ID: `"None"`, age.ID: `"forty"`, terms.ID: `true`}), 0, true},
+		{"question from another form", answers(map[uuid.UUID]string{diet.ID: `"None"`, terms.ID: `true`, uuid.New(): `"x"`}), 0, true},
+	}
+
+	for _, tt := range tests {
+		stored, err := validateAnswers(form, tt.answers)
+		if tt.wantErr {
+			var coded *CodedError
+			if !errors.As(err, &coded) || coded.Code != CodeInvalidAnswer {
+				t.Errorf("%s: expected %s, got %v", tt.name, CodeInvalidAnswer, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if len(stored) != tt.stored {
+			t.Errorf("%s: stored %d answers, want %d", tt.name, len(stored), tt.stored)
+		}
+	}
+}
+
+func TestNormalizeOptions(t *testing.T) {
+	if _, err := normalizeOptions(domain.QuestionSelect, nil); err == nil {
+		t.Error("expected a select question without options to be rejected")
+	}
+	if _, err := normalizeOptions(domain.QuestionSelect, []string{"S", " S "}); err == nil {
+		t.Error("expected duplicate options to be rejected")
+	}
+	if _, err := normalizeOptions(domain.QuestionText, []string{"S"}); err == nil {
+		t.Error("expected options on a text question to be rejected")
+	}
+	got, err := normalizeOptions(domain.QuestionMultiSelect, []string{" S", "M "})
+	if err != nil || len(got) != 2 || got[0] != "S" || got[1] != "M" {
+		t.Errorf("normalizeOptions = %v, %v", got, err)
+	}
+}
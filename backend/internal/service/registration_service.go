@@ -1,6 +1,7 @@
 package service
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -9,97 +10,148 @@ import (
 	"github.com/username/event-ticketing-system/internal/domain"
 	"github.com/username/event-ticketing-system/internal/worker"
 	"github.com/username/event-ticketing-system/pkg/email"
+	"github.com/username/event-ticketing-system/pkg/utils"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+var ErrSoldOut = &CodedError{Code: CodeSoldOut, Message: "no tickets available for this ticket type"}
+
+var (
+	ErrInvalidRSVP    = errors.New("RSVP status must be rsvp_yes, rsvp_no or rsvp_maybe")
+	ErrRSVPNotAllowed = errors.New("registration not found, or not confirmed")
+)
+
+// rsvpStatuses are the answers an attendee can give. rsvpChangeable are the
+// states they can answer from: a confirmed registration, or an earlier RSVP.
+var (
+	rsvpStatuses   = []domain.RegistrationStatus{domain.RegistrationRSVPYes, domain.RegistrationRSVPNo, domain.RegistrationRSVPMaybe}
+	rsvpChangeable = append([]domain.RegistrationStatus{domain.RegistrationConfirmed}, rsvpStatuses...)
+)
+
 type RegistrationService struct {
 	DB            *gorm.DB
 	TicketService *TicketService
 	EmailService  email.EmailService
 	Pool          *worker.WorkerPool
+	Inventory     *Inventory
+	Waitlist      *WaitlistService
+	Payments      *PaymentService
 }
 
-func NewRegistrationService(db *gorm.DB, ts *TicketService, es email.EmailService, pool *worker.WorkerPool) *RegistrationService {
-	return &RegistrationService{DB: db, TicketService: ts, EmailService: es, Pool: pool}
+func NewRegistrationService(db *gorm.DB, ts *TicketService, es email.EmailService, pool *worker.WorkerPool, inv *Inventory, wl *WaitlistService, payments *PaymentService) *RegistrationService {
+	return &RegistrationService{DB: db, TicketService: ts, EmailService: es, Pool: pool, Inventory: inv, Waitlist: wl, Payments: payments}
 }
 
-func (s *RegistrationService) Register(userID uuid.UUID, eventID uuid.UUID, ticketTypeID uuid.UUID) (*domain.Registration, error) {
+// RegisterOptions carries optional inputs to Register.
+type RegisterOptions struct {
+	PromoCodes []string
+	// SeatID picks a seat for reserved-seating ticket types; without one the
+	// best free seat is assigned.
+	SeatID *uuid.UUID
+	// QueueToken is the admitted waiting room token for events that have one.
+	QueueToken string
+	// Answers to the event's registration questions, keyed by question ID.
+	Answers map[uuid.UUID]json.RawMessage
+}
+
+func (s *RegistrationService) Register(userID uuid.UUID, eventID uuid.UUID, ticketTypeID uuid.UUID, opts RegisterOptions) (*domain.Registration, error) {
 	var registration domain.Registration
 
 	var user domain.User
 	if err := s.DB.First(&user, "id = ?", userID).Error; err != nil {
 		return nil, errors.New("user not found")
 	}
+	if err := checkEmailVerified(&user); err != nil {
+		return nil, err
+	}
 
-	err := s.DB.Transaction(func(tx *gorm.DB) error {
-		var event domain.Event
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, "id = ?", eventID).Error; err != nil {
-			return errors.New("event not found")
-		}
+	admission, err := checkQueueAdmission(s.DB, eventID, userID, opts.QueueToken)
+	if err != nil {
+		return nil, err
+	}
 
-		if event.Status != domain.StatusPublished {
-			return errors.New("cannot register for an event that is not published")
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		_, ticketType, err := reserveSeats(tx, s.Inventory, userID, eventID, ticketTypeID, 1)
+		if err != nil {
+			return err
 		}
-
-		// Fetch and lock specific TicketType
-		var ticketType domain.TicketType
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			First(&ticketType, "id = ? AND event_id = ?", ticketTypeID, eventID).Error; err != nil {
-			return errors.New("ticket type not found for this event")
+		if err := spendQueueTicket(tx, admission); err != nil {
+			return err
 		}
 
-		if ticketType.RemainingTickets <= 0 {
-			return errors.New("no tickets available for this ticket type")
+		var requested []uuid.UUID
+		if opts.SeatID != nil {
+			requested = []uuid.UUID{*opts.SeatID}
 		}
-
-		// Decrement tiered and event aggregate
-		ticketType.RemainingTickets--
-		if err := tx.Save(&ticketType).Error; err != nil {
+		seats, err := assignSeats(tx, eventID, ticketType, requested, 1)
+		if err != nil {
 			return err
 		}
 
-		event.RemainingTickets--
-		if err := tx.Save(&event).Error; err != nil {
+		// Promo codes are validated under their row locks so usage caps hold under concurrency
+		price := ticketType.Price
+		var promos []domain.PromoCode
+		var discounts []float64
+		if len(opts.PromoCodes) > 0 {
+			if promos, err = lockPromoCodes(tx, eventID, opts.PromoCodes); err != nil {
+				return err
+			}
+
+			now := time.Now()
+			for i := range promos {
+				var uses int64
+				if err := tx.Model(&domain.PromoRedemption{}).
+					Where("promo_code_id = ? AND user_id = ? AND released_at IS NULL", promos[i].ID, userID).
+					Count(&uses).Error; err != nil {
+					return err
+				}
+				if err := checkPromoCode(&promos[i], ticketTypeID, now, uses); err != nil {
+					return err
+				}
+			}
+
+			if price, discounts, err = applyDiscounts(ticketType.Price, promos); err != nil {
+				return err
+			}
+		}
+
+		payment, err := s.Payments.Begin(tx, userID, price)
+		if err != nil {
 			return err
 		}
 
 		registration = domain.Registration{
 			ID:           uuid.New(),
-			UserID:       userID,
+			UserID:       &userID,
 			EventID:      eventID,
 			TicketTypeID: ticketTypeID,
+			Amount:       price,
+			Discount:     roundCents(ticketType.Price - price),
+			SeatID:       seatIDAt(seats, 0),
 			Status:       domain.RegistrationConfirmed,
 		}
-		if err := tx.Create(&registration).Error; err != nil {
-			return err
-		}
+		attachPayment(&registration, payment)
 
-		_, err := s.TicketService.GenerateTicket(tx, registration.ID)
-		if err != nil {
-			return err
-		}
-
-		audit := domain.AuditLog{
-			ID:         uuid.New(),
-			UserID:     userID,
-			Action:     "CREATE_REGISTRATION",
-			EntityType: "registration",
-			EntityID:   registration.ID,
-			NewValues:  fmt.Sprintf(`{"event_id": "%s", "ticket_type_id": "%s"}`, eventID, ticketTypeID),
-			CreatedAt:  time.Now(),
-		}
-		if err := tx.Create(&audit).Error; err != nil {
+		if err := createRegistration(tx, s.TicketService, &registration, opts.Answers, "CREATE_REGISTRATION"); err != nil {
 			return err
 		}
 
-		return nil
+		return redeemPromoCodes(tx, userID, registration.ID, promos, discounts)
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	// Paid seats are emailed once the payment settles
+	if registration.Status != domain.RegistrationConfirmed {
+		if err := s.Payments.Open(registration.Payment); err != nil {
+			return nil, err
+		}
+		return &registration, nil
+	}
+
 	// Async email notification
 	eventTitle := ""
 	var event domain.Event
@@ -107,112 +159,148 @@ func (s *RegistrationService) Register(userID uuid.UUID, eventID uuid.UUID, tick
 		eventTitle = event.Title
 	}
 
-	s.Pool.Submit(worker.Task{
-		Type: worker.TaskReminder,
-		Payload: map[string]interface{}{
-			"email":           user.Email,
-			"registration_id": registration.ID.String(),
-			"event_title":     eventTitle,
-		},
-		Callback: func(t worker.Task) error {
-			return s.EmailService.SendTicketEmail(
-				t.Payload["email"].(string),
-				t.Payload["registration_id"].(string),
-				t.Payload["event_title"].(string),
-			)
-		},
-	})
+	queueTicketEmail(s.Pool, s.EmailService, user.Email, registration.ID, eventTitle)
 
 	return &registration, nil
 }
 
-func (s *RegistrationService) TransferTicket(senderID uuid.UUID, registrationID uuid.UUID, recipientEmail string) error {
-	var recipient domain.User
-	if err := s.DB.First(&recipient, "email = ?", recipientEmail).Error; err != nil {
-		return errors.New("recipient user not found")
+// issueRegistration creates a registration for a seat that has already been
+// taken from inventory. Without a payment it is confirmed and ticketed right
+// away; otherwise it waits on the payment to settle.
+func issueRegistration(tx *gorm.DB, ts *TicketService, userID, eventID uuid.UUID, ticketType *domain.TicketType, payment *domain.Payment, answers map[uuid.UUID]json.RawMessage, action string) (*domain.Registration, error) {
+	seats, err := assignSeats(tx, eventID, ticketType, nil, 1)
+	if err != nil {
+		return nil, err
 	}
 
-	err := s.DB.Transaction(func(tx *gorm.DB) error {
-		var registration domain.Registration
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Preload("Event").
-			First(&registration, "id = ? AND user_id = ?", registrationID, senderID).Error; err != nil {
-			return errors.New("registration not found or you are not the owner")
-		}
+	registration := domain.Registration{
+		ID:           uuid.New(),
+		UserID:       &userID,
+		EventID:      eventID,
+		TicketTypeID: ticketType.ID,
+		Amount:       ticketType.Price,
+		SeatID:       seatIDAt(seats, 0),
+		Status:       domain.RegistrationConfirmed,
+	}
+	attachPayment(&registration, payment)
 
-		if registration.Status != domain.RegistrationConfirmed {
-			return errors.New("cannot transfer a registration that is not confirmed")
-		}
+	if err := createRegistration(tx, ts, &registration, answers, action); err != nil {
+		return nil, err
+	}
+	return &registration, nil
+}
 
-		if registration.Event.Status != domain.StatusPublished {
-			return errors.New("cannot transfer registration for an event that is not published")
-		}
+// attachPayment marks a registration as waiting on payment, if there is one.
+func attachPayment(registration *domain.Registration, payment *domain.Payment) {
+	if payment == nil {
+		return
+	}
+	registration.PaymentID = &payment.ID
+	registration.Payment = payment
+	registration.Status = domain.RegistrationPendingPayment
+}
 
-		oldUserID := registration.UserID
-		registration.UserID = recipient.ID
-		if err := tx.Save(&registration).Error; err != nil {
-			return err
-		}
+// createRegistration checks answers, keyed by question ID, against the
+// event's registration form, then persists a prepared registration with them
+// and audits it inside the caller's transaction. Confirmed registrations also
+// get their ticket; pending ones are ticketed when their payment succeeds. The
+// registration must belong to a user, who is recorded as the actor.
+func createRegistration(tx *gorm.DB, ts *TicketService, registration *domain.Registration, answers map[uuid.UUID]json.RawMessage, action string) error {
+	if registration.UserID == nil {
+		return errors.New("registration has no user")
+	}
 
-		// Delete old ticket record and file path references to trigger regeneration
-		// Actually, we'll just let GenerateTicket handle the update or we can manually delete and recreate.
-		// Let's delete the old ticket so GenerateTicket creates a fresh one with new user data in PDF.
-		if err := tx.Where("registration_id = ?", registration.ID).Delete(&domain.Ticket{}).Error; err != nil {
-			return fmt.Errorf("failed to clear old ticket: %w", err)
-		}
+	questions, err := formQuestions(tx, registration.EventID, &registration.TicketTypeID)
+	if err != nil {
+		return err
+	}
+	validated, err := validateAnswers(questions, answers)
+	if err != nil {
+		return err
+	}
 
-		// Regenerate PDF for the new owner
-		_, err := s.TicketService.GenerateTicket(tx, registration.ID)
-		if err != nil {
-			return fmt.Errorf("failed to regenerate ticket for recipient: %w", err)
-		}
+	// The payment row already exists; don't let gorm upsert it again
+	if err := tx.Omit("Payment").Create(registration).Error; err != nil {
+		return err
+	}
+	if err := saveAnswers(tx, registration.ID, validated); err != nil {
+		return err
+	}
+	registration.Answers = validated
 
-		// Audit Log
-		audit := domain.AuditLog{
-			ID:         uuid.New(),
-			UserID:     senderID,
-			Action:     "TRANSFER_TICKET",
-			EntityType: "registration",
-			EntityID:   registration.ID,
-			OldValues:  fmt.Sprintf(`{"user_id": "%s"}`, oldUserID),
-			NewValues:  fmt.Sprintf(`{"user_id": "%s", "recipient_email": "%s"}`, recipient.ID, recipientEmail),
-			CreatedAt:  time.Now(),
-		}
-		if err := tx.Create(&audit).Error; err != nil {
+	if registration.Status == domain.RegistrationConfirmed {
+		ticket, err := ts.GenerateTicket(tx, registration.ID)
+		if err != nil {
 			return err
 		}
+		registration.Ticket = ticket
+	}
 
-		return nil
-	})
-
-	if err != nil {
-		return err
+	audit := domain.AuditLog{
+		ID:         uuid.New(),
+		UserID:     *registration.UserID,
+		Action:     action,
+		EntityType: "registration",
+		EntityID:   registration.ID,
+		NewValues:  utils.ToJSON(map[string]interface{}{"event_id": registration.EventID, "ticket_type_id": registration.TicketTypeID, "seat_id": registration.SeatID}),
+		CreatedAt:  time.Now(),
 	}
+	return tx.Create(&audit).Error
+}
 
-	// Async email notifications
-	s.Pool.Submit(worker.Task{
+func queueTicketEmail(pool *worker.WorkerPool, es email.EmailService, to string, registrationID uuid.UUID, eventTitle string) {
+	pool.Submit(worker.Task{
 		Type: worker.TaskReminder,
 		Payload: map[string]interface{}{
-			"email":           recipientEmail,
+			"email":           to,
 			"registration_id": registrationID.String(),
-			"event_title":     "Transferred Ticket",
+			"event_title":     eventTitle,
 		},
 		Callback: func(t worker.Task) error {
-			return s.EmailService.SendTicketEmail(
+			return es.SendTicketEmail(
 				t.Payload["email"].(string),
 				t.Payload["registration_id"].(string),
 				t.Payload["event_title"].(string),
 			)
 		},
 	})
+}
 
-	return nil
+// CancellationResult reports what the event's refund policy returned for a
+// cancelled registration.
+type CancellationResult struct {
+	RefundPercent int            `json:"refund_percent"`
+	RefundAmount  float64        `json:"refund_amount"`
+	Refund        *domain.Refund `json:"refund,omitempty"`
 }
 
-func (s *RegistrationService) CancelRegistration(userID uuid.UUID, registrationID uuid.UUID) error {
-	return s.DB.Transaction(func(tx *gorm.DB) error {
+// CancelRegistration cancels a registration under its event's refund policy.
+// A seat still awaiting payment voids that payment instead, which cancels
+// every seat it covers. Refunds are sent once the cancellation commits, so a
+// provider outage never blocks it.
+func (s *RegistrationService) CancelRegistration(userID uuid.UUID, registrationID uuid.UUID) (*CancellationResult, error) {
+	var offer *domain.WaitlistEntry
+	var voided *settlement
+	result := &CancellationResult{}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the payment before its seats, in the same order as settling
+		// does, so a cancellation racing a payment result cannot deadlock
+		var current domain.Registration
+		if err := tx.Select("payment_id").First(&current, "id = ? AND user_id = ?", registrationID, userID).Error; err != nil {
+			return errors.New("registration not found")
+		}
+		var p *domain.Payment
+		if current.PaymentID != nil {
+			p = &domain.Payment{}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(p, "id = ?", *current.PaymentID).Error; err != nil {
+				return ErrPaymentNotFound
+			}
+		}
+
 		var registration domain.Registration
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&registration, "id = ? AND user_id = ?", registrationID, userID).Error; err != nil {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Event").
+			First(&registration, "id = ? AND user_id = ?", registrationID, userID).Error; err != nil {
 			return errors.New("registration not found")
 		}
 
@@ -220,27 +308,46 @@ func (s *RegistrationService) CancelRegistration(userID uuid.UUID, registrationI
 			return errors.New("registration already cancelled")
 		}
 
-		var event domain.Event
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, "id = ?", registration.EventID).Error; err != nil {
-			return errors.New("event not found")
-		}
+		if registration.Status == domain.RegistrationPendingPayment && p != nil && p.Status == domain.PaymentPending {
+			var err error
+			if voided, err = s.Payments.settleLocked(tx, p, false, "cancelled"); err != nil {
+				return err
+			}
+		} else {
+			result.RefundPercent = refundPercent(&registration.Event, time.Now())
+			refund, err := s.Payments.RefundRegistration(tx, &registration, result.RefundPercent)
+			if err != nil {
+				return err
+			}
+			if refund != nil {
+				result.Refund = refund
+				result.RefundAmount = refund.Amount
+			}
 
-		// Update status
-		registration.Status = domain.RegistrationCancelled
-		if err := tx.Save(&registration).Error; err != nil {
-			return err
-		}
+			// Update status
+			registration.Status = domain.RegistrationCancelled
+			if err := tx.Omit(clause.Associations).Save(&registration).Error; err != nil {
+				return err
+			}
 
-		// Return seat to pools
-		event.RemainingTickets++
-		if err := tx.Save(&event).Error; err != nil {
-			return err
-		}
+			if err := s.TicketService.RevokeTicket(tx, registration.ID, "cancelled"); err != nil {
+				return err
+			}
+
+			// A cancelled ticket can no longer be handed over or sold
+			if err := revokePendingTransfers(tx, userID, registration.ID); err != nil {
+				return err
+			}
+			if err := withdrawRegistrationListing(tx, userID, registration.ID); err != nil {
+				return err
+			}
+			if err := releasePromoRedemptions(tx, []uuid.UUID{registration.ID}); err != nil {
+				return err
+			}
 
-		var ticketType domain.TicketType
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ticketType, "id = ?", registration.TicketTypeID).Error; err == nil {
-			ticketType.RemainingTickets++
-			if err := tx.Save(&ticketType).Error; err != nil {
+			// Offer the seat to the waitlist, or return it to the pools if nobody is waiting
+			offer, err = s.Waitlist.ReleaseSeat(tx, registration.EventID, registration.TicketTypeID)
+			if err != nil {
 				return err
 			}
 		}
@@ -252,7 +359,7 @@ func (s *RegistrationService) CancelRegistration(userID uuid.UUID, registrationI
 			Action:     "CANCEL_REGISTRATION",
 			EntityType: "registration",
 			EntityID:   registration.ID,
-			NewValues:  `{"status": "cancelled"}`,
+			NewValues:  fmt.Sprintf(`{"status": "cancelled", "refund_percent": %d, "refund_amount": %.2f}`, result.RefundPercent, result.RefundAmount),
 			CreatedAt:  time.Now(),
 		}
 		if err := tx.Create(&audit).Error; err != nil {
@@ -261,19 +368,109 @@ func (s *RegistrationService) CancelRegistration(userID uuid.UUID, registrationI
 
 		return nil
 	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if voided != nil {
+		s.Payments.cancelIntent(voided.payment)
+		s.Payments.finishSettlement(voided)
+	}
+	if result.Refund != nil {
+		if sent := s.Payments.sendRefund(result.Refund.ID); sent != nil {
+			result.Refund = sent
+		}
+	}
+
+	if offer != nil {
+		s.Waitlist.NotifyOffer(offer.ID)
+	}
+
+	return result, nil
+}
+
+// Attendee is one confirmed registration of an event as shown to organizers,
+// with the answers given to the registration form.
+type Attendee struct {
+	RegistrationID uuid.UUID                 `json:"registration_id"`
+	UserID         *uuid.UUID                `json:"user_id,omitempty"`
+	Name           string                    `json:"name"`
+	Email          string                    `json:"email"`
+	TicketType     string                    `json:"ticket_type"`
+	Status         domain.RegistrationStatus `json:"status"`
+	Answers        []AttendeeAnswer          `json:"answers"`
 }
 
-func (s *RegistrationService) GetEventAttendees(eventID uuid.UUID) ([]domain.User, error) {
-	var users []domain.User
-	err := s.DB.Model(&domain.User{}).
-		Joins("JOIN registrations ON registrations.user_id = users.id").
-		Where("registrations.event_id = ? AND registrations.status = ?", eventID, domain.RegistrationConfirmed).
-		Find(&users).Error
-	return users, err
+type AttendeeAnswer struct {
+	QuestionID uuid.UUID           `json:"question_id"`
+	Label      string              `json:"label"`
+	Type       domain.QuestionType `json:"type"`
+	Value      json.RawMessage     `json:"value"`
 }
 
+func (s *RegistrationService) GetEventAttendees(eventID uuid.UUID) ([]Attendee, error) {
+	var registrations []domain.Registration
+	err := s.DB.Preload("User").
+		Preload("TicketType").
+		// Answers to questions removed from the form since are still shown
+		Preload("Answers.Question", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("event_id = ? AND status IN ?", eventID, AttendingStatuses).
+		Order("created_at").
+		Find(&registrations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	attendees := make([]Attendee, 0, len(registrations))
+	for _, registration := range registrations {
+		attendee := Attendee{
+			RegistrationID: registration.ID,
+			UserID:         registration.UserID,
+			Name:           registration.AttendeeName,
+			Email:          registration.ContactEmail(),
+			TicketType:     registration.TicketType.Name,
+			Status:         registration.Status,
+			Answers:        make([]AttendeeAnswer, 0, len(registration.Answers)),
+		}
+		if registration.User != nil && attendee.Name == "" {
+			attendee.Name = registration.User.Name
+		}
+		for _, answer := range registration.Answers {
+			a := AttendeeAnswer{QuestionID: answer.QuestionID, Value: answer.Value}
+			if answer.Question != nil {
+				a.Label = answer.Question.Label
+				a.Type = answer.Question.Type
+			}
+			attendee.Answers = append(attendee.Answers, a)
+		}
+		attendees = append(attendees, attendee)
+	}
+	return attendees, nil
+}
+
+// UpdateRSVP records the owner's answer on a confirmed registration. The
+// status is only changed while the registration is confirmed or already an
+// RSVP, so a cancelled or unpaid registration cannot be made attending.
 func (s *RegistrationService) UpdateRSVP(userID uuid.UUID, registrationID uuid.UUID, status domain.RegistrationStatus) error {
-	return s.DB.Model(&domain.Registration{}).
-		Where("id = ? AND user_id = ?", registrationID, userID).
-		Update("status", status).Error
+	valid := false
+	for _, answer := range rsvpStatuses {
+		if status == answer {
+			valid = true
+		}
+	}
+	if !valid {
+		return ErrInvalidRSVP
+	}
+
+	result := s.DB.Model(&domain.Registration{}).
+		Where("id = ? AND user_id = ? AND status IN ?", registrationID, userID, rsvpChangeable).
+		Update("status", status)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRSVPNotAllowed
+	}
+	return nil
 }
@@ -2,47 +2,45 @@ package service
 
 import (
 	"context"
+	"crypto/ed25519"
+	"errors"
 	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
 	"github.com/username/event-ticketing-system/internal/worker"
 	"github.com/username/event-ticketing-system/pkg/email"
-	"github.com/username/event-ticketing-system/pkg/utils"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
+	"github.com/username/event-ticketing-system/pkg/payment"
+	"github.com/username/event-ticketing-system/pkg/signing"
 )
 
-func TestRegistrationService_Register_Success(t *testing.T) {
-	// Initialize Logger for test to prevent nil pointer in async workers
-	utils.InitLogger()
-
-	// Setup sqlmock
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("failed to open sqlmock: %s", err)
-	}
-	defer db.Close()
-
-	gormDB, err := gorm.Open(postgres.New(postgres.Config{
-		Conn: db,
-	}), &gorm.Config{
-		SkipDefaultTransaction: true,
-	})
-	if err != nil {
-		t.Fatalf("failed to open gorm: %s", err)
-	}
+// newTestRegistrationService wires a RegistrationService to a sqlmock-backed
+// database, the fake payment provider and a running worker pool.
+func newTestRegistrationService(t *testing.T) (*RegistrationService, sqlmock.Sqlmock, func()) {
+	gormDB, mock := newMockDB(t)
 
 	// Mock Services
 	pool := worker.NewWorkerPool(1, 10)
 	pool.Start(context.Background())
-	defer pool.Shutdown()
 
-	ts := NewTicketService(gormDB)
+	signer, _ := signing.NewSigner(map[string]ed25519.PrivateKey{"test": signing.DeriveKey("test")}, "test")
+	ts := NewTicketService(gormDB, signer)
 	es := email.NewEmailService("smtp.test.com", "587", "user", "pass", "test@test.com")
-	svc := NewRegistrationService(gormDB, ts, es, pool)
+	inv := NewInventory(StrategyConditional, 1)
+	wl := NewWaitlistService(gormDB, ts, es, pool, inv, time.Minute)
+	payments := NewPaymentService(gormDB, payment.NewFakeProvider("test"), ts, es, pool, wl, "USD", time.Minute)
+	wl.Payments = payments
+	svc := NewRegistrationService(gormDB, ts, es, pool, inv, wl, payments)
+
+	return svc, mock, pool.Shutdown
+}
+
+func TestRegistrationService_Register_Success(t *testing.T) {
+	svc, mock, cleanup := newTestRegistrationService(t)
+	defer cleanup()
 
 	userID := uuid.New()
 	eventID := uuid.New()
@@ -54,35 +52,40 @@ func TestRegistrationService_Register_Success(t *testing.T) {
 	// 1. Fetch User (now at start of Register)
 	mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 		WithArgs(userID, 1).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(userID, "user@test.com"))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "email_verified_at"}).AddRow(userID, "user@test.com", time.Now()))
+
+	// Waiting room check, before the transaction opens
+	mock.ExpectQuery(`SELECT "id","waiting_room" FROM "events"`).
+		WithArgs(eventID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "waiting_room"}).AddRow(eventID, false))
 
 	mock.ExpectBegin()
 
-	// 2. Fetch Event with Lock
-	mock.ExpectQuery(`SELECT \* FROM "events" WHERE id = \$1 AND "events"\."deleted_at" IS NULL ORDER BY "events"\."id" LIMIT \$2 FOR UPDATE`).
+	// 2. Fetch Event, without a lock
+	mock.ExpectQuery(`SELECT \* FROM "events" WHERE id = \$1 AND "events"\."deleted_at" IS NULL ORDER BY "events"\."id" LIMIT \$2 FOR SHARE$`).
 		WithArgs(eventID, 1).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status", "remaining_tickets", "total_tickets"}).
-			AddRow(eventID, eventTitle, "published", 10, 10))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status", "total_tickets"}).
+			AddRow(eventID, eventTitle, "published", 10))
 
-	// 3. Fetch TicketType with Lock
+	// 3. Fetch TicketType (a free tier confirms without payment)
 	mock.ExpectQuery(`SELECT \* FROM "ticket_types"`).
 		WithArgs(ticketTypeID, eventID, 1).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "name", "price", "remaining_tickets"}).
-			AddRow(ticketTypeID, eventID, ticketTypeName, 100.0, 5))
-
-	// 4. Update TicketType
-	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "ticket_types"`)).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "name", "price"}).
+			AddRow(ticketTypeID, eventID, ticketTypeName, 0.0))
 
-	// 5. Update Event
-	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "events"`)).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	// 4. Take the seat from stock with one conditional update
+	mock.ExpectExec(`UPDATE ticket_type_shards SET remaining = remaining - \$1`).
+		WithArgs(1, ticketTypeID, 1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	// 6. Create Registration
+	// 5. Check the registration form, then create the registration
+	mock.ExpectQuery(`SELECT \* FROM "registration_questions"`).
+		WithArgs(eventID, ticketTypeID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
 	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "registrations"`)).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
 
-	// 7. TicketService.GenerateTicket Preloads
+	// 6. TicketService.GenerateTicket Preloads
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "registrations"`)).
 		WithArgs(sqlmock.AnyArg(), 1).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "event_id", "ticket_type_id"}).AddRow(uuid.New(), userID, eventID, ticketTypeID))
@@ -102,11 +105,11 @@ func TestRegistrationService_Register_Success(t *testing.T) {
 		WithArgs(userID).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(userID, "user@test.com"))
 
-	// 8. Create Ticket
+	// 7. Create Ticket
 	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "tickets"`)).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
 
-	// 9. Create Audit Log
+	// 8. Create Audit Log
 	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
 
@@ -118,7 +121,7 @@ func TestRegistrationService_Register_Success(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "category", "status"}).AddRow(eventID, eventTitle, "", "published"))
 
 	// Execute
-	reg, err := svc.Register(userID, eventID, ticketTypeID)
+	reg, err := svc.Register(userID, eventID, ticketTypeID, RegisterOptions{})
 
 	// Assert
 	if err != nil {
@@ -135,3 +138,331 @@ func TestRegistrationService_Register_Success(t *testing.T) {
 		t.Errorf("Expectations were not met: %s", err)
 	}
 }
+
+func TestRegistrationService_Register_PaidTierAwaitsPayment(t *testing.T) {
+	svc, mock, cleanup := newTestRegistrationService(t)
+	defer cleanup()
+
+	userID := uuid.New()
+	eventID := uuid.New()
+	ticketTypeID := uuid.New()
+
+	mock.ExpectQuery(`SELECT \* FROM "users"`).
+		WithArgs(userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "email_verified_at"}).AddRow(userID, "user@test.com", time.Now()))
+
+	mock.ExpectQuery(`SELECT "id","waiting_room" FROM "events"`).
+		WithArgs(eventID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "waiting_room"}).AddRow(eventID, false))
+
+	mock.ExpectBegin()
+
+	mock.ExpectQuery(`SELECT \* FROM "events"`).
+		WithArgs(eventID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(eventID, "published"))
+
+	mock.ExpectQuery(`SELECT \* FROM "ticket_types"`).
+		WithArgs(ticketTypeID, eventID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "name", "price"}).
+			AddRow(ticketTypeID, eventID, "VIP", 100.0))
+
+	mock.ExpectExec(`UPDATE ticket_type_shards`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// The payment is opened before the seat, and no ticket is issued yet
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "payments"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(`SELECT \* FROM "registration_questions"`).
+		WithArgs(eventID, ticketTypeID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "registrations"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+
+	mock.ExpectCommit()
+
+	// The provider intent is only created once the seat locks are released
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "payments" SET "client_secret"=$1,"intent_id"=$2`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	reg, err := svc.Register(userID, eventID, ticketTypeID, RegisterOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if reg.Status != domain.RegistrationPendingPayment {
+		t.Errorf("expected pending_payment, got %s", reg.Status)
+	}
+	if reg.Payment == nil || reg.Payment.Amount != 100.0 || reg.Payment.IntentID == nil {
+		t.Errorf("expected a 100.00 payment intent, got %+v", reg.Payment)
+	}
+	if reg.Ticket != nil {
+		t.Error("expected no ticket before payment")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met: %s", err)
+	}
+}
+
+func TestRegistrationService_Register_WaitingRoomRequiresAdmission(t *testing.T) {
+	svc, mock, cleanup := newTestRegistrationService(t)
+	defer cleanup()
+
+	userID := uuid.New()
+	eventID := uuid.New()
+	ticketTypeID := uuid.New()
+
+	expectWaitingRoom := func() {
+		mock.ExpectQuery(`SELECT \* FROM "users"`).
+			WithArgs(userID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "email_verified_at"}).AddRow(userID, "user@test.com", time.Now()))
+		mock.ExpectQuery(`SELECT "id","waiting_room" FROM "events"`).
+			WithArgs(eventID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "waiting_room"}).AddRow(eventID, true))
+	}
+
+	// Without a token the request is turned away before any transaction or row lock
+	expectWaitingRoom()
+	_, err := svc.Register(userID, eventID, ticketTypeID, RegisterOptions{})
+	var coded *CodedError
+	if !errors.As(err, &coded) || coded.Code != CodeQueueTokenRequired {
+		t.Fatalf("expected %s, got %v", CodeQueueTokenRequired, err)
+	}
+
+	// A token still waiting in line is not enough
+	expectWaitingRoom()
+	mock.ExpectQuery(`SELECT \* FROM "queue_tickets"`).
+		WithArgs("tok", eventID, userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "user_id", "token", "status"}).
+			AddRow(uuid.New(), eventID, userID, "tok", "waiting"))
+	_, err = svc.Register(userID, eventID, ticketTypeID, RegisterOptions{QueueToken: "tok"})
+	if !errors.As(err, &coded) || coded.Code != CodeQueueNotAdmitted {
+		t.Fatalf("expected %s, got %v", CodeQueueNotAdmitted, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met: %s", err)
+	}
+}
+
+func TestRegistrationService_CancelRegistration_RefundsAfterCommit(t *testing.T) {
+	svc, mock, cleanup := newTestRegistrationService(t)
+	defer cleanup()
+
+	userID, registrationID, paymentID, refundID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	eventID, ticketTypeID := uuid.New(), uuid.New()
+	intent, _ := svc.Payments.Provider.CreateIntent(100, "USD", paymentID.String())
+	payments := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "user_id", "intent_id", "amount", "status"}).
+			AddRow(paymentID, userID, intent.ID, 100.0, domain.PaymentSucceeded)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "payment_id" FROM "registrations"`).
+		WithArgs(registrationID, userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"payment_id"}).AddRow(paymentID))
+	mock.ExpectQuery(`SELECT \* FROM "payments" WHERE id = \$1 .* FOR UPDATE`).WithArgs(paymentID, 1).WillReturnRows(payments())
+	mock.ExpectQuery(`SELECT \* FROM "registrations" WHERE id = \$1 AND user_id = \$2 .* FOR UPDATE`).
+		WithArgs(registrationID, userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "event_id", "ticket_type_id", "payment_id", "amount", "status"}).
+			AddRow(registrationID, userID, eventID, ticketTypeID, paymentID, 100.0, domain.RegistrationConfirmed))
+	mock.ExpectQuery(`SELECT \* FROM "events"`).
+		WithArgs(eventID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "start_time", "refund_full_hours_before", "refund_partial_percent"}).
+			AddRow(eventID, time.Now().Add(72*time.Hour), 24, 50))
+	mock.ExpectQuery(`SELECT \* FROM "payments" WHERE id = \$1`).WithArgs(paymentID, 1).WillReturnRows(payments())
+
+	// The refund is only recorded while the seat is released
+	mock.ExpectQuery(`INSERT INTO "refunds"`).
+		WithArgs(registrationID, paymentID, userID, eventID, 100.0, 100, domain.RefundCancellation, domain.RefundPending, 0, "", "", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(refundID))
+	mock.ExpectExec(`UPDATE "registrations"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "tickets"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "ticket_transfers"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "resale_listings"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "promo_redemptions"`).
+		WithArgs(registrationID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "waitlist_entries"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`UPDATE ticket_type_shards SET remaining = remaining \+ \$1`).
+		WithArgs(1, ticketTypeID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(userID, "CANCEL_REGISTRATION", "registration", registrationID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	// ...and sent to the provider once the cancellation has committed
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "refunds" .* FOR UPDATE SKIP LOCKED`).
+		WithArgs(sqlmock.AnyArg(), domain.RefundPending, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "registration_id", "payment_id", "user_id", "event_id", "amount", "percent", "reason", "status", "attempts"}).
+			AddRow(refundID, registrationID, paymentID, userID, eventID, 100.0, 100, domain.RefundCancellation, domain.RefundPending, 0))
+	mock.ExpectQuery(`SELECT \* FROM "payments" WHERE id = \$1`).WithArgs(paymentID, 1).WillReturnRows(payments())
+	mock.ExpectExec(`UPDATE "refunds"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	result, err := svc.CancelRegistration(userID, registrationID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.RefundPercent != 100 || result.RefundAmount != 100 {
+		t.Errorf("expected a full refund of 100, got %+v", result)
+	}
+	if result.Refund == nil || result.Refund.Status != domain.RefundSucceeded || result.Refund.ProviderRefundID == "" {
+		t.Errorf("expected the refund to have gone through, got %+v", result.Refund)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRegistrationService_CancelRegistration_VoidsPendingPayment(t *testing.T) {
+	svc, mock, cleanup := newTestRegistrationService(t)
+	defer cleanup()
+
+	userID, registrationID, otherID, paymentID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	eventID, ticketTypeID := uuid.New(), uuid.New()
+	intent, _ := svc.Payments.Provider.CreateIntent(40, "USD", paymentID.String())
+	registrationColumns := []string{"id", "user_id", "event_id", "ticket_type_id", "payment_id", "amount", "status"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "payment_id" FROM "registrations"`).
+		WithArgs(registrationID, userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"payment_id"}).AddRow(paymentID))
+	mock.ExpectQuery(`SELECT \* FROM "payments" WHERE id = \$1 .* FOR UPDATE`).
+		WithArgs(paymentID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "intent_id", "amount", "status"}).
+			AddRow(paymentID, userID, intent.ID, 40.0, domain.PaymentPending))
+	mock.ExpectQuery(`SELECT \* FROM "registrations" WHERE id = \$1 AND user_id = \$2 .* FOR UPDATE`).
+		WithArgs(registrationID, userID, 1).
+		WillReturnRows(sqlmock.NewRows(registrationColumns).
+			AddRow(registrationID, userID, eventID, ticketTypeID, paymentID, 20.0, domain.RegistrationPendingPayment))
+	mock.ExpectQuery(`SELECT \* FROM "events"`).WithArgs(eventID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(eventID))
+
+	// Voiding the payment cancels both seats it was opened for
+	mock.ExpectQuery(`SELECT \* FROM "registrations" WHERE payment_id = \$1 AND status = \$2 FOR UPDATE`).
+		WithArgs(paymentID, domain.RegistrationPendingPayment).
+		WillReturnRows(sqlmock.NewRows(registrationColumns).
+			AddRow(registrationID, userID, eventID, ticketTypeID, paymentID, 20.0, domain.RegistrationPendingPayment).
+			AddRow(otherID, userID, eventID, ticketTypeID, paymentID, 20.0, domain.RegistrationPendingPayment))
+	for i := 0; i < 2; i++ {
+		mock.ExpectExec(`UPDATE "registrations" SET "status"=\$1`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`SELECT \* FROM "waitlist_entries"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec(`UPDATE ticket_type_shards SET remaining = remaining \+ \$1`).
+			WithArgs(1, ticketTypeID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	// The promo code used on the order counts as unused again
+	promoID := uuid.New()
+	mock.ExpectQuery(`SELECT \* FROM "promo_redemptions" WHERE registration_id IN \(\$1,\$2\) AND released_at IS NULL ORDER BY promo_code_id`).
+		WithArgs(registrationID, otherID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "promo_code_id", "user_id", "registration_id", "discount"}).
+			AddRow(uuid.New(), promoID, userID, registrationID, 5.0))
+	mock.ExpectExec(`UPDATE "promo_redemptions" SET "released_at"=\$1`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "promo_codes" SET "used_count"=used_count - 1 WHERE id = \$1 AND used_count > 0`).
+		WithArgs(promoID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "resale_listings"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`UPDATE "payments"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "orders"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(userID, "PAYMENT_FAILED", "payment", paymentID, sqlmock.AnyArg(), `{"status": "failed", "seats": 2, "reason": "cancelled"}`, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(userID, "CANCEL_REGISTRATION", "registration", registrationID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	result, err := svc.CancelRegistration(userID, registrationID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Refund != nil {
+		t.Errorf("expected nothing to refund for an unpaid seat, got %+v", result.Refund)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+	if _, err := svc.Payments.Provider.Capture(intent.ID); !errors.Is(err, payment.ErrIntentCanceled) {
+		t.Errorf("expected the voided payment's intent to be canceled, got %v", err)
+	}
+}
+
+func TestCreateRegistration_RefusesRegistrationWithoutUser(t *testing.T) {
+	svc, mock, cleanup := newTestRegistrationService(t)
+	defer cleanup()
+
+	registration := domain.Registration{ID: uuid.New(), EventID: uuid.New(), TicketTypeID: uuid.New(), Status: domain.RegistrationConfirmed}
+	if err := createRegistration(svc.DB, svc.TicketService, &registration, nil, "CREATE_REGISTRATION"); err == nil {
+		t.Error("expected a registration without a user to be refused")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRegistrationService_UpdateRSVP_OnlyFromConfirmed(t *testing.T) {
+	tests := []struct {
+		name    string
+		current domain.RegistrationStatus
+	}{
+		{"cancelled registration", domain.RegistrationCancelled},
+		{"unpaid registration", domain.RegistrationPendingPayment},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock, cleanup := newTestRegistrationService(t)
+			defer cleanup()
+
+			userID, registrationID := uuid.New(), uuid.New()
+
+			// The update only matches confirmed and RSVP rows, so a registration
+			// in any other state is left as it is
+			mock.ExpectExec(`UPDATE "registrations" SET "status"=\$1,"updated_at"=\$2 WHERE id = \$3 AND user_id = \$4 AND status IN \(\$5,\$6,\$7,\$8\)`).
+				WithArgs(domain.RegistrationRSVPYes, sqlmock.AnyArg(), registrationID, userID,
+					domain.RegistrationConfirmed, domain.RegistrationRSVPYes, domain.RegistrationRSVPNo, domain.RegistrationRSVPMaybe).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			if err := svc.UpdateRSVP(userID, registrationID, domain.RegistrationRSVPYes); !errors.Is(err, ErrRSVPNotAllowed) {
+				t.Errorf("expected an RSVP on a %s registration to be refused, got %v", tt.current, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestRegistrationService_UpdateRSVP_RejectsOtherStatuses(t *testing.T) {
+	svc, mock, cleanup := newTestRegistrationService(t)
+	defer cleanup()
+
+	for _, status := range []domain.RegistrationStatus{domain.RegistrationConfirmed, domain.RegistrationCancelled, "attending"} {
+		if err := svc.UpdateRSVP(uuid.New(), uuid.New(), status); !errors.Is(err, ErrInvalidRSVP) {
+			t.Errorf("expected %q to be refused as an RSVP, got %v", status, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRegistrationService_GetEventAttendees_IncludesRSVPs(t *testing.T) {
+	svc, mock, cleanup := newTestRegistrationService(t)
+	defer cleanup()
+
+	eventID := uuid.New()
+
+	// Attendees who answered an RSVP still hold a ticket and are listed
+	mock.ExpectQuery(`SELECT \* FROM "registrations" WHERE event_id = \$1 AND status IN \(\$2,\$3,\$4\)`).
+		WithArgs(eventID, domain.RegistrationConfirmed, domain.RegistrationRSVPYes, domain.RegistrationRSVPMaybe).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	if _, err := svc.GetEventAttendees(eventID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
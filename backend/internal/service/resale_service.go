@@ -0,0 +1,479 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ResaleService runs the fan-to-fan resale marketplace. Buyers pay through the
+// regular payment flow; the ticket changes hands when PaymentService settles
+// that payment.
+type ResaleService struct {
+	DB       *gorm.DB
+	Payments *PaymentService
+}
+
+func NewResaleService(db *gorm.DB, payments *PaymentService) *ResaleService {
+	return &ResaleService{DB: db, Payments: payments}
+}
+
+var openResaleStatuses = []domain.ResaleStatus{domain.ResaleActive, domain.ResalePendingPayment}
+
+// CreateListing puts a confirmed registration up for sale at price, which may
+// not exceed the ticket's face value plus the event's maximum markup.
+func (s *ResaleService) CreateListing(sellerID uuid.UUID, registrationID uuid.UUID, price float64) (*domain.ResaleListing, error) {
+	var listing domain.ResaleListing
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var registration domain.Registration
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Event").
+			Preload("TicketType").
+			First(&registration, "id = ? AND user_id = ?", registrationID, sellerID).Error; err != nil {
+			return errors.New("registration not found or you are not the owner")
+		}
+		if registration.Status != domain.RegistrationConfirmed {
+			return errors.New("only confirmed tickets can be resold")
+		}
+		event := &registration.Event
+		if event.Status != domain.StatusPublished || !time.Now().Before(event.StartTime) {
+			return errors.New("tickets for this event can no longer be resold")
+		}
+		if !event.ResaleEnabled {
+			return errors.New("resale is not enabled for this event")
+		}
+
+		maxPrice := resalePriceCap(registration.TicketType.Price, event.ResaleMaxMarkupPercent)
+		if price > maxPrice {
+			return fmt.Errorf("price exceeds the resale cap of %.2f for this ticket", maxPrice)
+		}
+
+		var open int64
+		if err := tx.Model(&domain.ResaleListing{}).
+			Where("registration_id = ? AND status IN ?", registrationID, openResaleStatuses).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return errors.New("this ticket is already listed for resale")
+		}
+
+		var pendingTransfers int64
+		if err := tx.Model(&domain.TicketTransfer{}).
+			Where("registration_id = ? AND status = ?", registrationID, domain.TransferPending).
+			Count(&pendingTransfers).Error; err != nil {
+			return err
+		}
+		if pendingTransfers > 0 {
+			return errors.New("this ticket has a pending transfer; revoke it first")
+		}
+
+		fee := roundCents(price * float64(event.ResaleFeePercent) / 100)
+		listing = domain.ResaleListing{
+			ID:             uuid.New(),
+			RegistrationID: registrationID,
+			EventID:        registration.EventID,
+			SellerID:       sellerID,
+			Price:          price,
+			Fee:            fee,
+			SellerProceeds: roundCents(price - fee),
+			Status:         domain.ResaleActive,
+		}
+		if err := tx.Create(&listing).Error; err != nil {
+			return err
+		}
+
+		return auditResale(tx, sellerID, &listing, "CREATE_RESALE_LISTING")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &listing, nil
+}
+
+// ResaleOffer is a listing as shown to prospective buyers, without the
+// seller's details.
+type ResaleOffer struct {
+	ID         uuid.UUID          `json:"id"`
+	EventID    uuid.UUID          `json:"event_id"`
+	Price      float64            `json:"price"`
+	TicketType *domain.TicketType `json:"ticket_type,omitempty"`
+	Seat       *domain.Seat       `json:"seat,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+// GetEventListings returns the listings currently for sale for an event,
+// cheapest first.
+func (s *ResaleService) GetEventListings(eventID uuid.UUID) ([]ResaleOffer, error) {
+	var listings []domain.ResaleListing
+	if err := s.DB.Preload("Registration.TicketType").
+		Preload("Registration.Seat.Section").
+		Where("event_id = ? AND status = ?", eventID, domain.ResaleActive).
+		Order("price asc, created_at asc").
+		Find(&listings).Error; err != nil {
+		return nil, err
+	}
+
+	offers := make([]ResaleOffer, 0, len(listings))
+	for _, listing := range listings {
+		offer := ResaleOffer{ID: listing.ID, EventID: listing.EventID, Price: listing.Price, CreatedAt: listing.CreatedAt}
+		if listing.Registration != nil {
+			offer.TicketType = &listing.Registration.TicketType
+			offer.Seat = listing.Registration.Seat
+		}
+		offers = append(offers, offer)
+	}
+	return offers, nil
+}
+
+// GetMyListings returns every listing a user has created.
+func (s *ResaleService) GetMyListings(sellerID uuid.UUID) ([]domain.ResaleListing, error) {
+	var listings []domain.ResaleListing
+	err := s.DB.Preload("Registration.Event").
+		Preload("Registration.TicketType").
+		Where("seller_id = ?", sellerID).
+		Order("created_at desc").
+		Find(&listings).Error
+	return listings, err
+}
+
+// WithdrawListing takes a listing off the market. A listing held for a buyer's
+// pending payment cannot be withdrawn.
+func (s *ResaleService) WithdrawListing(sellerID uuid.UUID, listingID uuid.UUID) (*domain.ResaleListing, error) {
+	var listing domain.ResaleListing
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&listing, "id = ? AND seller_id = ?", listingID, sellerID).Error; err != nil {
+			return errors.New("listing not found")
+		}
+		if listing.Status == domain.ResalePendingPayment {
+			return errors.New("a buyer is paying for this listing; it cannot be withdrawn now")
+		}
+		if listing.Status != domain.ResaleActive {
+			return fmt.Errorf("listing is already %s", listing.Status)
+		}
+
+		listing.Status = domain.ResaleWithdrawn
+		if err := tx.Model(&listing).Update("status", listing.Status).Error; err != nil {
+			return err
+		}
+		return auditResale(tx, sellerID, &listing, "WITHDRAW_RESALE_LISTING")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &listing, nil
+}
+
+// BuyListing holds a listing for buyerID and opens a payment for its price.
+// answers are the buyer's answers to the event's registration form. The
+// ticket moves to the buyer, with those answers, when the payment succeeds; if
+// it fails or expires the listing goes back on sale.
+func (s *ResaleService) BuyListing(buyerID uuid.UUID, listingID uuid.UUID, answers map[uuid.UUID]json.RawMessage) (*domain.ResaleListing, error) {
+	if err := requireVerifiedEmail(s.DB, buyerID); err != nil {
+		return nil, err
+	}
+
+	var listing domain.ResaleListing
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Registration.TicketType").
+			First(&listing, "id = ?", listingID).Error; err != nil {
+			return errors.New("listing not found")
+		}
+		if listing.Status != domain.ResaleActive {
+			return errors.New("this listing is no longer available")
+		}
+		if listing.SellerID == buyerID {
+			return errors.New("you cannot buy your own listing")
+		}
+
+		var event domain.Event
+		if err := tx.First(&event, "id = ?", listing.EventID).Error; err != nil {
+			return errors.New("event not found")
+		}
+		if event.Status != domain.StatusPublished || !time.Now().Before(event.StartTime) {
+			return errors.New("tickets for this event can no longer be resold")
+		}
+
+		if limit := listing.Registration.TicketType.MaxPerUser; limit > 0 {
+			// The buyer row lock serialises this count against the buyer's other checkouts
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&domain.User{}, "id = ?", buyerID).Error; err != nil {
+				return err
+			}
+			owned, err := seatsHeldByUser(tx, buyerID, listing.Registration.TicketTypeID)
+			if err != nil {
+				return err
+			}
+			if owned >= int64(limit) {
+				return newCodedError(CodeUserLimitReached, "at most %d tickets of this type per user", limit)
+			}
+		}
+
+		questions, err := formQuestions(tx, listing.EventID, &listing.Registration.TicketTypeID)
+		if err != nil {
+			return err
+		}
+		validated, err := validateAnswers(questions, answers)
+		if err != nil {
+			return err
+		}
+		buyerAnswers := make(map[uuid.UUID]json.RawMessage, len(validated))
+		for _, answer := range validated {
+			buyerAnswers[answer.QuestionID] = answer.Value
+		}
+		encoded, err := json.Marshal(buyerAnswers)
+		if err != nil {
+			return err
+		}
+
+		p, err := s.Payments.Begin(tx, buyerID, listing.Price)
+		if err != nil {
+			return err
+		}
+
+		listing.Status = domain.ResalePendingPayment
+		listing.BuyerID = &buyerID
+		listing.PaymentID = &p.ID
+		listing.Payment = p
+		if err := tx.Model(&listing).Omit(clause.Associations).Updates(map[string]interface{}{
+			"status":        listing.Status,
+			"buyer_id":      buyerID,
+			"payment_id":    p.ID,
+			"buyer_answers": encoded,
+		}).Error; err != nil {
+			return err
+		}
+
+		return auditResale(tx, buyerID, &listing, "BUY_RESALE_LISTING")
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Payments.Open(listing.Payment); err != nil {
+		return nil, err
+	}
+	listing.Registration = nil
+	return &listing, nil
+}
+
+// settleResales completes the listings held for a payment PaymentService is
+// settling. On success each ticket moves to the buyer with a fresh code, unless
+// it can no longer be sold, in which case a refund to the buyer is recorded.
+// On failure the listings go back on sale. It returns the listings that were
+// sold and the refunds to send once tx commits.
+func (s *PaymentService) settleResales(tx *gorm.DB, p *domain.Payment, succeeded bool) ([]domain.ResaleListing, []*domain.Refund, error) {
+	var listings []domain.ResaleListing
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("payment_id = ? AND status = ?", p.ID, domain.ResalePendingPayment).
+		Find(&listings).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var sold []domain.ResaleListing
+	var refunds []*domain.Refund
+	for i := range listings {
+		listing := &listings[i]
+
+		var registration domain.Registration
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Event").
+			First(&registration, "id = ?", listing.RegistrationID).Error; err != nil {
+			return nil, nil, err
+		}
+		sellable := registration.UserID != nil && *registration.UserID == listing.SellerID &&
+			registration.Status == domain.RegistrationConfirmed &&
+			registration.Event.Status == domain.StatusPublished
+
+		if !succeeded {
+			status := domain.ResaleActive
+			if !sellable {
+				status = domain.ResaleCancelled
+			}
+			listing.Status = status
+			if err := tx.Model(listing).Updates(map[string]interface{}{
+				"status":        status,
+				"buyer_id":      nil,
+				"payment_id":    nil,
+				"buyer_answers": nil,
+			}).Error; err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		if !sellable {
+			refund := domain.Refund{
+				ID:        uuid.New(),
+				PaymentID: p.ID,
+				UserID:    *listing.BuyerID,
+				EventID:   &listing.EventID,
+				Amount:    listing.Price,
+				Percent:   100,
+				Reason:    domain.RefundResaleUnsellable,
+				Status:    domain.RefundPending,
+			}
+			if err := tx.Create(&refund).Error; err != nil {
+				return nil, nil, err
+			}
+			refunds = append(refunds, &refund)
+
+			listing.Status = domain.ResaleCancelled
+			if err := tx.Model(listing).Update("status", listing.Status).Error; err != nil {
+				return nil, nil, err
+			}
+			if err := auditResale(tx, *listing.BuyerID, listing, "REFUND_RESALE_PURCHASE"); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		var buyer domain.User
+		if err := tx.First(&buyer, "id = ?", *listing.BuyerID).Error; err != nil {
+			return nil, nil, err
+		}
+
+		registration.UserID = &buyer.ID
+		registration.AttendeeName = buyer.Name
+		registration.AttendeeEmail = buyer.Email
+		registration.Amount = listing.Price
+		registration.Discount = 0
+		registration.PaymentID = &p.ID
+		registration.OrderID = nil
+		if err := tx.Omit(clause.Associations).Save(&registration).Error; err != nil {
+			return nil, nil, err
+		}
+		if err := replaceAnswers(tx, registration.ID, listing.BuyerAnswers); err != nil {
+			return nil, nil, err
+		}
+
+		if err := revokePendingTransfers(tx, listing.SellerID, registration.ID); err != nil {
+			return nil, nil, err
+		}
+		if err := s.TicketService.RevokeTicket(tx, registration.ID, "resold"); err != nil {
+			return nil, nil, fmt.Errorf("failed to revoke old ticket: %w", err)
+		}
+		if err := tx.Where("registration_id = ?", registration.ID).Delete(&domain.Ticket{}).Error; err != nil {
+			return nil, nil, fmt.Errorf("failed to clear old ticket: %w", err)
+		}
+		if _, err := s.TicketService.GenerateTicket(tx, registration.ID); err != nil {
+			return nil, nil, fmt.Errorf("failed to issue ticket to buyer: %w", err)
+		}
+
+		now := time.Now()
+		listing.Status = domain.ResaleSold
+		listing.SoldAt = &now
+		if err := tx.Model(listing).Updates(map[string]interface{}{
+			"status":  listing.Status,
+			"sold_at": now,
+		}).Error; err != nil {
+			return nil, nil, err
+		}
+		if err := auditResale(tx, buyer.ID, listing, "RESALE_SOLD"); err != nil {
+			return nil, nil, err
+		}
+
+		listing.Registration = &registration
+		sold = append(sold, *listing)
+	}
+	return sold, refunds, nil
+}
+
+// replaceAnswers swaps a resold registration's form answers for the buyer's,
+// as stored on the listing by BuyListing.
+func replaceAnswers(tx *gorm.DB, registrationID uuid.UUID, buyerAnswers json.RawMessage) error {
+	if err := tx.Where("registration_id = ?", registrationID).Delete(&domain.RegistrationAnswer{}).Error; err != nil {
+		return err
+	}
+	if len(buyerAnswers) == 0 {
+		return nil
+	}
+
+	var byQuestion map[uuid.UUID]json.RawMessage
+	if err := json.Unmarshal(buyerAnswers, &byQuestion); err != nil {
+		return err
+	}
+	answers := make([]domain.RegistrationAnswer, 0, len(byQuestion))
+	for questionID, value := range byQuestion {
+		answers = append(answers, domain.RegistrationAnswer{ID: uuid.New(), QuestionID: questionID, Value: value})
+	}
+	return saveAnswers(tx, registrationID, answers)
+}
+
+// WithdrawEventListings cancels the open listings of an event that is being
+// cancelled. Listings held for a pending payment are left for settlement,
+// which refunds the buyer once it sees the event is no longer on.
+func WithdrawEventListings(tx *gorm.DB, actorID uuid.UUID, eventID uuid.UUID) error {
+	var listings []domain.ResaleListing
+	if err := tx.Where("event_id = ? AND status = ?", eventID, domain.ResaleActive).Find(&listings).Error; err != nil {
+		return err
+	}
+	for i := range listings {
+		listings[i].Status = domain.ResaleCancelled
+		if err := tx.Model(&listings[i]).Update("status", domain.ResaleCancelled).Error; err != nil {
+			return err
+		}
+		if err := auditResale(tx, actorID, &listings[i], "CANCEL_RESALE_LISTING"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withdrawRegistrationListing takes a registration's active listing off the
+// market before it leaves its owner some other way. A listing held for a
+// buyer's payment blocks that.
+func withdrawRegistrationListing(tx *gorm.DB, actorID uuid.UUID, registrationID uuid.UUID) error {
+	var listing domain.ResaleListing
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("registration_id = ? AND status IN ?", registrationID, openResaleStatuses).
+		First(&listing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if listing.Status == domain.ResalePendingPayment {
+		return errors.New("this ticket is being bought on resale and cannot be changed")
+	}
+
+	listing.Status = domain.ResaleWithdrawn
+	if err := tx.Model(&listing).Update("status", listing.Status).Error; err != nil {
+		return err
+	}
+	return auditResale(tx, actorID, &listing, "WITHDRAW_RESALE_LISTING")
+}
+
+// resalePriceCap is the highest price a ticket with the given face value may be
+// resold for.
+func resalePriceCap(faceValue float64, maxMarkupPercent int) float64 {
+	return roundCents(faceValue * float64(100+maxMarkupPercent) / 100)
+}
+
+func auditResale(tx *gorm.DB, actorID uuid.UUID, listing *domain.ResaleListing, action string) error {
+	audit := domain.AuditLog{
+		ID:         uuid.New(),
+		UserID:     actorID,
+		Action:     action,
+		EntityType: "resale_listing",
+		EntityID:   listing.ID,
+		NewValues: utils.ToJSON(map[string]interface{}{
+			"registration_id": listing.RegistrationID,
+			"seller_id":       listing.SellerID,
+			"buyer_id":        listing.BuyerID,
+			"price":           listing.Price,
+			"fee":             listing.Fee,
+			"status":          listing.Status,
+		}),
+		CreatedAt: time.Now(),
+	}
+	return tx.Create(&audit).Error
+}
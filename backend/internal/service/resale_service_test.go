@@ -0,0 +1,269 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/pkg/payment"
+)
+
+func TestResalePriceCap(t *testing.T) {
+	tests := []struct {
+		name      string
+		faceValue float64
+		markup    int
+		want      float64
+	}{
+		{"face value only", 50, 0, 50},
+		{"ten percent markup", 50, 10, 55},
+		{"rounds to cents", 19.99, 15, 22.99},
+		{"free tickets cannot be sold", 0, 50, 0},
+	}
+
+	for _, tt := range tests {
+		if got := resalePriceCap(tt.faceValue, tt.markup); got != tt.want {
+			t.Errorf("%s: resalePriceCap(%.2f, %d) = %.4f, want %.2f", tt.name, tt.faceValue, tt.markup, got, tt.want)
+		}
+	}
+}
+
+// expectBuyChecks expects BuyListing's checks of an active listing whose
+// ticket type allows maxPerUser seats, for a buyer who already has owned.
+func expectBuyChecks(mock sqlmock.Sqlmock, buyerID uuid.UUID, listingID uuid.UUID, eventID uuid.UUID, ticketTypeID uuid.UUID, maxPerUser int, owned int) {
+	registrationID := uuid.New()
+
+	mock.ExpectQuery(`SELECT "id","email_verified_at" FROM "users"`).
+		WithArgs(buyerID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email_verified_at"}).AddRow(buyerID, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "resale_listings" WHERE id = \$1 .* FOR UPDATE`).
+		WithArgs(listingID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "registration_id", "event_id", "seller_id", "price", "status"}).
+			AddRow(listingID, registrationID, eventID, uuid.New(), 30.0, domain.ResaleActive))
+	mock.ExpectQuery(`SELECT \* FROM "registrations"`).
+		WithArgs(registrationID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "ticket_type_id"}).AddRow(registrationID, ticketTypeID))
+	mock.ExpectQuery(`SELECT \* FROM "ticket_types"`).
+		WithArgs(ticketTypeID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "max_per_user"}).AddRow(ticketTypeID, maxPerUser))
+	mock.ExpectQuery(`SELECT \* FROM "events"`).
+		WithArgs(eventID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "start_time"}).AddRow(eventID, domain.StatusPublished, time.Now().Add(24*time.Hour)))
+
+	// The buyer row is locked before their seats are counted
+	mock.ExpectQuery(`SELECT "id" FROM "users" WHERE id = \$1 .* FOR UPDATE`).
+		WithArgs(buyerID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(buyerID))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "registrations"`).
+		WithArgs(buyerID, ticketTypeID, domain.RegistrationCancelled).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(quantity\), 0\) FROM "seat_holds"`).
+		WithArgs(buyerID, ticketTypeID, domain.HoldActive).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(0))
+	// Another resale ticket the buyer is still paying for counts
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "resale_listings" JOIN registrations`).
+		WithArgs(buyerID, domain.ResalePendingPayment, ticketTypeID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(owned))
+}
+
+func TestResaleService_BuyListing_HoldsListingForPayment(t *testing.T) {
+	rs, mock, cleanup := newTestRegistrationService(t)
+	defer cleanup()
+	svc := NewResaleService(rs.DB, rs.Payments)
+
+	buyerID, listingID, eventID, ticketTypeID, questionID := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	expectBuyChecks(mock, buyerID, listingID, eventID, ticketTypeID, 2, 1)
+	// The buyer answers the registration form as any other registrant would
+	mock.ExpectQuery(`SELECT \* FROM "registration_questions"`).
+		WithArgs(eventID, ticketTypeID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "label", "type", "required"}).
+			AddRow(questionID, eventID, "Company", domain.QuestionText, true))
+	mock.ExpectQuery(`INSERT INTO "payments"`).
+		WithArgs(buyerID, "fake", sqlmock.AnyArg(), sqlmock.AnyArg(), 30.0, "USD", domain.PaymentPending, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectExec(`UPDATE "resale_listings" SET "buyer_answers"=\$1,"buyer_id"=\$2,"payment_id"=\$3,"status"=\$4`).
+		WithArgs([]byte(`{"`+questionID.String()+`":"Acme"}`), buyerID, sqlmock.AnyArg(), domain.ResalePendingPayment, sqlmock.AnyArg(), listingID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(buyerID, "BUY_RESALE_LISTING", "resale_listing", listingID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+	mock.ExpectExec(`UPDATE "payments" SET "client_secret"=\$1,"intent_id"=\$2`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	listing, err := svc.BuyListing(buyerID, listingID, map[uuid.UUID]json.RawMessage{questionID: json.RawMessage(`" Acme "`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if listing.Status != domain.ResalePendingPayment || listing.BuyerID == nil || *listing.BuyerID != buyerID || listing.PaymentID == nil {
+		t.Errorf("expected the listing to be held for the buyer's payment, got %+v", listing)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestResaleService_BuyListing_EnforcesMaxPerUser(t *testing.T) {
+	rs, mock, cleanup := newTestRegistrationService(t)
+	defer cleanup()
+	svc := NewResaleService(rs.DB, rs.Payments)
+
+	buyerID, listingID := uuid.New(), uuid.New()
+
+	expectBuyChecks(mock, buyerID, listingID, uuid.New(), uuid.New(), 1, 1)
+	mock.ExpectRollback()
+
+	_, err := svc.BuyListing(buyerID, listingID, nil)
+	var coded *CodedError
+	if !errors.As(err, &coded) || coded.Code != CodeUserLimitReached {
+		t.Errorf("expected the buyer's limit to be enforced, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// expectResaleSettlement expects a payment.succeeded webhook for a payment that
+// only covers one resale listing, bought with buyerAnswers, up to the
+// listing's registration being locked with the given status.
+func expectResaleSettlement(mock sqlmock.Sqlmock, intentID string, paymentID uuid.UUID, buyerID uuid.UUID, listingID uuid.UUID, registrationID uuid.UUID, eventID uuid.UUID, status domain.RegistrationStatus, buyerAnswers []byte) {
+	columns := []string{"id", "user_id", "intent_id", "amount", "currency", "status"}
+	mock.ExpectQuery(`SELECT \* FROM "payments" WHERE intent_id = \$1`).
+		WithArgs(intentID, 1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(paymentID, buyerID, intentID, 30.0, "USD", domain.PaymentPending))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "payments" WHERE id = \$1 .* FOR UPDATE`).
+		WithArgs(paymentID, 1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(paymentID, buyerID, intentID, 30.0, "USD", domain.PaymentPending))
+	mock.ExpectQuery(`SELECT \* FROM "registrations" WHERE payment_id = \$1 AND status = \$2 FOR UPDATE`).
+		WithArgs(paymentID, domain.RegistrationPendingPayment).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "resale_listings" WHERE payment_id = \$1 AND status = \$2 FOR UPDATE`).
+		WithArgs(paymentID, domain.ResalePendingPayment).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "registration_id", "event_id", "seller_id", "buyer_id", "payment_id", "price", "status", "buyer_answers"}).
+			AddRow(listingID, registrationID, eventID, sellerOf(registrationID), buyerID, paymentID, 30.0, domain.ResalePendingPayment, buyerAnswers))
+	mock.ExpectQuery(`SELECT \* FROM "registrations" WHERE id = \$1 .* FOR UPDATE`).
+		WithArgs(registrationID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "event_id", "ticket_type_id", "status"}).
+			AddRow(registrationID, sellerOf(registrationID), eventID, uuid.Nil, status))
+	mock.ExpectQuery(`SELECT \* FROM "events"`).
+		WithArgs(eventID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status"}).AddRow(eventID, "Gig", domain.StatusPublished))
+}
+
+// sellerOf derives a stable seller ID for a registration in these tests.
+func sellerOf(registrationID uuid.UUID) uuid.UUID {
+	return uuid.NewSHA1(registrationID, []byte("seller"))
+}
+
+// expectPaymentSucceeded expects the end of a successful settlement.
+func expectPaymentSucceeded(mock sqlmock.Sqlmock, paymentID uuid.UUID, buyerID uuid.UUID) {
+	mock.ExpectExec(`UPDATE "payments"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "orders"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(buyerID, "PAYMENT_SUCCEEDED", "payment", paymentID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+}
+
+func TestPaymentService_HandleWebhook_SettlesResaleToBuyer(t *testing.T) {
+	svc, provider, mock, cleanup := newTestPaymentService(t)
+	defer cleanup()
+
+	paymentID, buyerID, listingID, registrationID, eventID, questionID := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	intent, _ := provider.CreateIntent(30, "USD", paymentID.String())
+
+	expectResaleSettlement(mock, intent.ID, paymentID, buyerID, listingID, registrationID, eventID, domain.RegistrationConfirmed, []byte(`{"`+questionID.String()+`":"Acme"}`))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).
+		WithArgs(buyerID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}).AddRow(buyerID, "Buyer", "buyer@test.com"))
+	mock.ExpectExec(`UPDATE "registrations"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	// The seller's form answers give way to the buyer's
+	mock.ExpectExec(`DELETE FROM "registration_answers"`).WithArgs(registrationID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "registration_answers"`).
+		WithArgs(registrationID, questionID, []byte(`"Acme"`), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(`SELECT \* FROM "ticket_transfers"`).
+		WithArgs(registrationID, domain.TransferPending).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "tickets"`).
+		WithArgs(registrationID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`DELETE FROM "tickets"`).WithArgs(registrationID).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// The buyer gets a fresh ticket in their name
+	mock.ExpectQuery(`SELECT \* FROM "registrations"`).
+		WithArgs(registrationID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "event_id", "attendee_email"}).AddRow(registrationID, buyerID, eventID, "buyer@test.com"))
+	mock.ExpectQuery(`SELECT \* FROM "events"`).WithArgs(eventID).WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(eventID, "Gig"))
+	mock.ExpectQuery(`SELECT \* FROM "users"`).WithArgs(buyerID).WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(buyerID, "buyer@test.com"))
+	mock.ExpectQuery(`INSERT INTO "tickets"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+
+	mock.ExpectExec(`UPDATE "resale_listings" SET "sold_at"=\$1,"status"=\$2`).
+		WithArgs(sqlmock.AnyArg(), domain.ResaleSold, sqlmock.AnyArg(), listingID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(buyerID, "RESALE_SOLD", "resale_listing", listingID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	expectPaymentSucceeded(mock, paymentID, buyerID)
+
+	body := []byte(`{"type":"payment.succeeded","intent_id":"` + intent.ID + `"}`)
+	if err := svc.HandleWebhook(body, provider.SignWebhook(body)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPaymentService_HandleWebhook_RefundsUnsellableResaleAfterCommit(t *testing.T) {
+	svc, provider, mock, cleanup := newTestPaymentService(t)
+	defer cleanup()
+
+	paymentID, buyerID, listingID, registrationID, eventID, refundID := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	intent, _ := provider.CreateIntent(30, "USD", paymentID.String())
+
+	// The seller cancelled while the buyer was paying, so the ticket cannot move
+	expectResaleSettlement(mock, intent.ID, paymentID, buyerID, listingID, registrationID, eventID, domain.RegistrationCancelled, nil)
+	mock.ExpectQuery(`INSERT INTO "refunds"`).
+		WithArgs(nil, paymentID, buyerID, eventID, 30.0, 100, domain.RefundResaleUnsellable, domain.RefundPending, 0, "", "", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(refundID))
+	mock.ExpectExec(`UPDATE "resale_listings" SET "status"=\$1`).
+		WithArgs(domain.ResaleCancelled, sqlmock.AnyArg(), listingID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(buyerID, "REFUND_RESALE_PURCHASE", "resale_listing", listingID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	expectPaymentSucceeded(mock, paymentID, buyerID)
+
+	// Only once the settlement has committed does the refund go to the provider
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "refunds" .* FOR UPDATE SKIP LOCKED`).
+		WithArgs(refundID, domain.RefundPending, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payment_id", "user_id", "event_id", "amount", "percent", "reason", "status", "attempts"}).
+			AddRow(refundID, paymentID, buyerID, eventID, 30.0, 100, domain.RefundResaleUnsellable, domain.RefundPending, 0))
+	mock.ExpectQuery(`SELECT \* FROM "payments" WHERE id = \$1`).
+		WithArgs(paymentID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "intent_id", "amount"}).AddRow(paymentID, intent.ID, 30.0))
+	mock.ExpectExec(`UPDATE "refunds"`).
+		WithArgs(nil, paymentID, buyerID, eventID, 30.0, 100, domain.RefundResaleUnsellable, domain.RefundSucceeded, 1, "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), refundID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	body := []byte(`{"type":"payment.succeeded","intent_id":"` + intent.ID + `"}`)
+	if err := svc.HandleWebhook(body, provider.SignWebhook(body)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+	if _, err := provider.Refund(intent.ID, 0.01, uuid.NewString()); !errors.Is(err, payment.ErrRefundTooLarge) {
+		t.Errorf("expected the buyer to be refunded in full, got %v", err)
+	}
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SeatingService struct {
+	DB *gorm.DB
+}
+
+func NewSeatingService(db *gorm.DB) *SeatingService {
+	return &SeatingService{DB: db}
+}
+
+// SeatRowInput describes one row of seats numbered 1..Seats. Zone overrides
+// the section's zone for this row.
+type SeatRowInput struct {
+	Label      string `json:"label" binding:"required"`
+	Seats      int    `json:"seats" binding:"required,min=1"`
+	Accessible []int  `json:"accessible"`
+	Zone       string `json:"zone"`
+}
+
+// SeatSectionInput describes a section. Its zone defaults to its name.
+type SeatSectionInput struct {
+	Name string         `json:"name" binding:"required"`
+	Zone string         `json:"zone"`
+	Rows []SeatRowInput `json:"rows" binding:"required,min=1,dive"`
+}
+
+type SeatMapInput struct {
+	Name     string             `json:"name" binding:"required"`
+	Venue    string             `json:"venue"`
+	Sections []SeatSectionInput `json:"sections" binding:"required,min=1,dive"`
+}
+
+func (s *SeatingService) CreateSeatMap(adminID uuid.UUID, input SeatMapInput) (*domain.SeatMap, error) {
+	seatMap, err := buildSeatMap(input)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Sections").Create(seatMap).Error; err != nil {
+			return err
+		}
+
+		seats := 0
+		for i := range seatMap.Sections {
+			if err := tx.Omit("Seats").Create(&seatMap.Sections[i]).Error; err != nil {
+				return err
+			}
+			if err := tx.CreateInBatches(seatMap.Sections[i].Seats, 500).Error; err != nil {
+				return err
+			}
+			seats += len(seatMap.Sections[i].Seats)
+		}
+
+		audit := domain.AuditLog{
+			ID:         uuid.New(),
+			UserID:     adminID,
+			Action:     "CREATE_SEAT_MAP",
+			EntityType: "seat_map",
+			EntityID:   seatMap.ID,
+			NewValues:  fmt.Sprintf(`{"name": %q, "sections": %d, "seats": %d}`, seatMap.Name, len(seatMap.Sections), seats),
+			CreatedAt:  time.Now(),
+		}
+		return tx.Create(&audit).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return seatMap, nil
+}
+
+func (s *SeatingService) ListSeatMaps() ([]domain.SeatMap, error) {
+	var seatMaps []domain.SeatMap
+	err := s.DB.Order("name").Find(&seatMaps).Error
+	return seatMaps, err
+}
+
+func (s *SeatingService) GetSeatMap(id uuid.UUID) (*domain.SeatMap, error) {
+	var seatMap domain.SeatMap
+	if err := s.DB.
+		Preload("Sections", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Sections.Seats", func(db *gorm.DB) *gorm.DB { return db.Order("row, number") }).
+		First(&seatMap, "id = ?", id).Error; err != nil {
+		return nil, errors.New("seat map not found")
+	}
+	return &seatMap, nil
+}
+
+// SeatAvailability is a seat of an event's map and whether it can still be bought.
+type SeatAvailability struct {
+	domain.Seat
+	Available bool `json:"available"`
+}
+
+type SectionAvailability struct {
+	ID    uuid.UUID          `json:"id"`
+	Name  string             `json:"name"`
+	Seats []SeatAvailability `json:"seats"`
+}
+
+// EventSeating is an event's seat map with live availability. Zones lists the
+// ticket types that sell each zone.
+type EventSeating struct {
+	EventID   uuid.UUID              `json:"event_id"`
+	SeatMapID uuid.UUID              `json:"seat_map_id"`
+	Sections  []SectionAvailability  `json:"sections"`
+	Zones     map[string][]uuid.UUID `json:"zones"`
+}
+
+func (s *SeatingService) GetEventSeating(eventID uuid.UUID) (*EventSeating, error) {
+	var event domain.Event
+	if err := s.DB.Preload("TicketTypes").First(&event, "id = ?", eventID).Error; err != nil {
+		return nil, newCodedError(CodeEventNotFound, "event not found")
+	}
+	if event.SeatMapID == nil {
+		return nil, errors.New("event does not use reserved seating")
+	}
+
+	seatMap, err := s.GetSeatMap(*event.SeatMapID)
+	if err != nil {
+		return nil, err
+	}
+
+	var taken []uuid.UUID
+	if err := s.DB.Model(&domain.Registration{}).
+		Where("event_id = ? AND seat_id IS NOT NULL AND status <> ?", eventID, domain.RegistrationCancelled).
+		Pluck("seat_id", &taken).Error; err != nil {
+		return nil, err
+	}
+	sold := make(map[uuid.UUID]bool, len(taken))
+	for _, id := range taken {
+		sold[id] = true
+	}
+
+	seating := &EventSeating{EventID: eventID, SeatMapID: seatMap.ID, Zones: make(map[string][]uuid.UUID)}
+	for _, section := range seatMap.Sections {
+		sa := SectionAvailability{ID: section.ID, Name: section.Name, Seats: make([]SeatAvailability, 0, len(section.Seats))}
+		for _, seat := range section.Seats {
+			sa.Seats = append(sa.Seats, SeatAvailability{Seat: seat, Available: !sold[seat.ID]})
+		}
+		seating.Sections = append(seating.Sections, sa)
+	}
+	for _, tt := range event.TicketTypes {
+		if tt.SeatZone != "" {
+			seating.Zones[tt.SeatZone] = append(seating.Zones[tt.SeatZone], tt.ID)
+		}
+	}
+
+	return seating, nil
+}
+
+// buildSeatMap expands a layout into sections and numbered seats with their IDs
+// assigned.
+func buildSeatMap(input SeatMapInput) (*domain.SeatMap, error) {
+	seatMap := &domain.SeatMap{ID: uuid.New(), Name: input.Name, Venue: input.Venue}
+
+	sectionNames := make(map[string]bool)
+	for i, in := range input.Sections {
+		if sectionNames[in.Name] {
+			return nil, fmt.Errorf("duplicate section %q", in.Name)
+		}
+		sectionNames[in.Name] = true
+
+		section := domain.SeatSection{ID: uuid.New(), SeatMapID: seatMap.ID, Name: in.Name, Position: i}
+		rowLabels := make(map[string]bool)
+		for _, row := range in.Rows {
+			if rowLabels[row.Label] {
+				return nil, fmt.Errorf("duplicate row %q in section %q", row.Label, in.Name)
+			}
+			rowLabels[row.Label] = true
+
+			accessible := make(map[int]bool, len(row.Accessible))
+			for _, n := range row.Accessible {
+				if n < 1 || n > row.Seats {
+					return nil, fmt.Errorf("accessible seat %d is outside row %q of section %q", n, row.Label, in.Name)
+				}
+				accessible[n] = true
+			}
+
+			zone := row.Zone
+			if zone == "" {
+				zone = in.Zone
+			}
+			if zone == "" {
+				zone = in.Name
+			}
+
+			for n := 1; n <= row.Seats; n++ {
+				section.Seats = append(section.Seats, domain.Seat{
+					ID:         uuid.New(),
+					SeatMapID:  seatMap.ID,
+					SectionID:  section.ID,
+					Row:        row.Label,
+					Number:     n,
+					Zone:       zone,
+					Accessible: accessible[n],
+				})
+			}
+		}
+		seatMap.Sections = append(seatMap.Sections, section)
+	}
+
+	return seatMap, nil
+}
+
+// assignSeats takes n seats of the ticket type's zone for an event, starting
+// with the requested ones and filling the rest with the best seats still free.
+// A uuid.Nil in requested leaves that position to be filled automatically.
+// Seat rows are locked so two checkouts can never take the same seat. General
+// admission ticket types need no seats and return nil.
+func assignSeats(tx *gorm.DB, eventID uuid.UUID, ticketType *domain.TicketType, requested []uuid.UUID, n int) ([]domain.Seat, error) {
+	var picked []uuid.UUID
+	for _, id := range requested {
+		if id != uuid.Nil {
+			picked = append(picked, id)
+		}
+	}
+
+	if ticketType.SeatZone == "" {
+		if len(picked) > 0 {
+			return nil, newCodedError(CodeSeatUnavailable, "%s is general admission and has no seat selection", ticketType.Name)
+		}
+		return nil, nil
+	}
+	if len(requested) > n {
+		return nil, newCodedError(CodeSeatUnavailable, "more seats selected than tickets requested")
+	}
+
+	var event domain.Event
+	if err := tx.Select("id", "seat_map_id").First(&event, "id = ?", eventID).Error; err != nil {
+		return nil, newCodedError(CodeEventNotFound, "event not found")
+	}
+	if event.SeatMapID == nil {
+		return nil, fmt.Errorf("ticket type %s is bound to zone %q but the event has no seat map", ticketType.Name, ticketType.SeatZone)
+	}
+
+	var chosen []domain.Seat
+	if len(picked) > 0 {
+		seen := make(map[uuid.UUID]bool, len(picked))
+		for _, id := range picked {
+			if seen[id] {
+				return nil, newCodedError(CodeSeatUnavailable, "seat %s selected more than once", id)
+			}
+			seen[id] = true
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ? AND seat_map_id = ? AND zone = ?", picked, *event.SeatMapID, ticketType.SeatZone).
+			Order("id").
+			Find(&chosen).Error; err != nil {
+			return nil, err
+		}
+		if len(chosen) != len(picked) {
+			return nil, newCodedError(CodeSeatUnavailable, "selected seats are not in the %s zone", ticketType.SeatZone)
+		}
+
+		var sold int64
+		if err := tx.Model(&domain.Registration{}).
+			Where("event_id = ? AND seat_id IN ? AND status <> ?", eventID, picked, domain.RegistrationCancelled).
+			Count(&sold).Error; err != nil {
+			return nil, err
+		}
+		if sold > 0 {
+			return nil, newCodedError(CodeSeatUnavailable, "one or more selected seats have already been taken")
+		}
+	}
+
+	var free []domain.Seat
+	if missing := n - len(picked); missing > 0 {
+		// Skip seats another checkout is in the middle of taking
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "seats"}, Options: "SKIP LOCKED"}).
+			Joins("JOIN seat_sections ON seat_sections.id = seats.section_id").
+			Where("seats.seat_map_id = ? AND seats.zone = ?", *event.SeatMapID, ticketType.SeatZone).
+			Where("NOT EXISTS (SELECT 1 FROM registrations r WHERE r.event_id = ? AND r.seat_id = seats.id AND r.status <> ?)", eventID, domain.RegistrationCancelled)
+		if len(picked) > 0 {
+			query = query.Where("seats.id NOT IN ?", picked)
+		}
+
+		if err := query.Select("seats.*").
+			Order("seat_sections.position, seats.row, seats.number").
+			Limit(missing).
+			Find(&free).Error; err != nil {
+			return nil, err
+		}
+		if len(free) < missing {
+			return nil, ErrSoldOut
+		}
+	}
+
+	// Keep the caller's order so seats line up with attendees
+	byID := make(map[uuid.UUID]domain.Seat, len(chosen))
+	for _, seat := range chosen {
+		byID[seat.ID] = seat
+	}
+	seats := make([]domain.Seat, 0, n)
+	for i := 0; i < n; i++ {
+		if i < len(requested) && requested[i] != uuid.Nil {
+			seats = append(seats, byID[requested[i]])
+			continue
+		}
+		seats = append(seats, free[0])
+		free = free[1:]
+	}
+
+	return seats, nil
+}
+
+// seatIDAt returns the ID of the i-th assigned seat, or nil for general
+// admission.
+func seatIDAt(seats []domain.Seat, i int) *uuid.UUID {
+	if i >= len(seats) {
+		return nil
+	}
+	return &seats[i].ID
+}
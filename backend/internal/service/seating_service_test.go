@@ -0,0 +1,74 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestBuildSeatMap(t *testing.T) {
+	seatMap, err := buildSeatMap(SeatMapInput{
+		Name: "Main Hall",
+		Sections: []SeatSectionInput{
+			{Name: "Orchestra", Rows: []SeatRowInput{
+				{Label: "A", Seats: 4, Accessible: []int{1, 4}},
+				{Label: "B", Seats: 3, Zone: "premium"},
+			}},
+			{Name: "Balcony", Zone: "upper", Rows: []SeatRowInput{{Label: "A", Seats: 2}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(seatMap.Sections) != 2 {
+		t.Fatalf("expected 2 sections, got %d", len(seatMap.Sections))
+	}
+
+	orchestra := seatMap.Sections[0]
+	if len(orchestra.Seats) != 7 {
+		t.Fatalf("expected 7 orchestra seats, got %d", len(orchestra.Seats))
+	}
+	first := orchestra.Seats[0]
+	if first.Row != "A" || first.Number != 1 || !first.Accessible || first.Zone != "Orchestra" {
+		t.Errorf("unexpected first seat %+v", first)
+	}
+	if orchestra.Seats[1].Accessible {
+		t.Errorf("seat A2 should not be accessible")
+	}
+	if orchestra.Seats[4].Zone != "premium" {
+		t.Errorf("row zone should override section default, got %q", orchestra.Seats[4].Zone)
+	}
+	for _, seat := range orchestra.Seats {
+		if seat.SectionID != orchestra.ID || seat.SeatMapID != seatMap.ID {
+			t.Fatalf("seat %s%d not linked to its section and map", seat.Row, seat.Number)
+		}
+	}
+
+	balcony := seatMap.Sections[1]
+	if balcony.Position != 1 || balcony.Seats[0].Zone != "upper" {
+		t.Errorf("unexpected balcony %+v", balcony)
+	}
+}
+
+func TestBuildSeatMap_RejectsInvalidLayouts(t *testing.T) {
+	tests := []struct {
+		name  string
+		input SeatMapInput
+	}{
+		{"duplicate section", SeatMapInput{Name: "Hall", Sections: []SeatSectionInput{
+			{Name: "Stalls", Rows: []SeatRowInput{{Label: "A", Seats: 1}}},
+			{Name: "Stalls", Rows: []SeatRowInput{{Label: "B", Seats: 1}}},
+		}}},
+		{"duplicate row", SeatMapInput{Name: "Hall", Sections: []SeatSectionInput{
+			{Name: "Stalls", Rows: []SeatRowInput{{Label: "A", Seats: 1}, {Label: "A", Seats: 2}}},
+		}}},
+		{"accessible seat out of range", SeatMapInput{Name: "Hall", Sections: []SeatSectionInput{
+			{Name: "Stalls", Rows: []SeatRowInput{{Label: "A", Seats: 3, Accessible: []int{4}}}},
+		}}},
+	}
+
+	for _, tt := range tests {
+		if _, err := buildSeatMap(tt.input); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/pkg/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; please sign in again")
+)
+
+// SessionService issues short-lived access tokens backed by server-side
+// sessions. A session stays signed in for RefreshTTL after its last refresh.
+type SessionService struct {
+	DB         *gorm.DB
+	Secret     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+func NewSessionService(db *gorm.DB, secret string, accessTTL time.Duration, refreshTTL time.Duration) *SessionService {
+	return &SessionService{DB: db, Secret: secret, AccessTTL: accessTTL, RefreshTTL: refreshTTL}
+}
+
+// TokenPair is what a client keeps after signing in. The access token goes in
+// the Authorization header until ExpiresAt; the refresh token gets a new pair.
+type TokenPair struct {
+	AccessToken  string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	// MFAEnrollmentRequired tells an admin the session won't reach admin
+	// routes until they enable two-factor authentication
+	MFAEnrollmentRequired bool `json:"mfa_enrollment_required,omitempty"`
+}
+
+// Start opens a session for a user who has just proven who they are.
+func (s *SessionService) Start(user *domain.User, userAgent string, ip string) (*TokenPair, error) {
+	refreshToken, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := domain.Session{
+		ID:               uuid.New(),
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		UserAgent:        userAgent,
+		IPAddress:        ip,
+		ExpiresAt:        now.Add(s.RefreshTTL),
+		LastUsedAt:       now,
+	}
+	if err := s.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return s.tokenPair(user, session.ID, refreshToken)
+}
+
+// Refresh trades a refresh token for a new pair, rotating the refresh token.
+// The access token carries the user's current role.
+func (s *SessionService) Refresh(refreshToken string, userAgent string, ip string) (*TokenPair, error) {
+	hash := hashToken(refreshToken)
+	newToken, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	var user domain.User
+	var session domain.Session
+	reused := false
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, "refresh_token_hash = ?", hash).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// A rotated-out token coming back means someone else holds it
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, "previous_token_hash = ?", hash).Error; err != nil {
+				return ErrInvalidRefreshToken
+			}
+			if session.RevokedAt == nil {
+				reused = true
+				return revokeSession(tx, &session, "SESSION_TOKEN_REUSED")
+			}
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if session.RevokedAt != nil || !now.Before(session.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+		if err := tx.First(&user, "id = ?", session.UserID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		return tx.Model(&session).Updates(map[string]interface{}{
+			"refresh_token_hash":  hashToken(newToken),
+			"previous_token_hash": hash,
+			"user_agent":          userAgent,
+			"ip_address":          ip,
+			"expires_at":          now.Add(s.RefreshTTL),
+			"last_used_at":        now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+	return s.tokenPair(&user, session.ID, newToken)
+}
+
+// Revoke signs a user out of one of their sessions.
+func (s *SessionService) Revoke(userID uuid.UUID, sessionID uuid.UUID) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var session domain.Session
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, "id = ? AND user_id = ?", sessionID, userID).Error; err != nil {
+			return errors.New("session not found")
+		}
+		if session.RevokedAt != nil {
+			return nil
+		}
+		return revokeSession(tx, &session, "USER_LOGOUT")
+	})
+}
+
+// IsActive reports whether an access token's session still lets it through.
+func (s *SessionService) IsActive(sessionID uuid.UUID, userID uuid.UUID) (bool, error) {
+	var count int64
+	err := s.DB.Model(&domain.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, userID, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// PurgeExpired deletes sessions that can no longer be refreshed. It runs from
+// the background sweeper.
+func (s *SessionService) PurgeExpired() {
+	// Revoked sessions are kept until they would have expired, so a reused
+	// token is still recognised
+	result := s.DB.Where("expires_at < ?", time.Now()).Delete(&domain.Session{})
+	if result.Error != nil {
+		utils.Logger.Error("Failed to purge sessions", zap.Error(result.Error))
+		return
+	}
+	if result.RowsAffected > 0 {
+		utils.Logger.Info("Purged sessions", zap.Int64("count", result.RowsAffected))
+	}
+}
+
+// RevokeUserSessions signs a user out everywhere inside the caller's
+// transaction. Access tokens already issued stop working on their next request.
+func RevokeUserSessions(tx *gorm.DB, actorID uuid.UUID, userID uuid.UUID) error {
+	result := tx.Model(&domain.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	audit := domain.AuditLog{
+		ID:         uuid.New(),
+		UserID:     actorID,
+		Action:     "REVOKE_USER_SESSIONS",
+		EntityType: "user",
+		EntityID:   userID,
+		NewValues:  utils.ToJSON(map[string]interface{}{"sessions": result.RowsAffected}),
+		CreatedAt:  time.Now(),
+	}
+	return tx.Create(&audit).Error
+}
+
+func revokeSession(tx *gorm.DB, session *domain.Session, action string) error {
+	now := time.Now()
+	if err := tx.Model(session).Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	session.RevokedAt = &now
+
+	audit := domain.AuditLog{
+		ID:         uuid.New(),
+		UserID:     session.UserID,
+		Action:     action,
+		EntityType: "session",
+		EntityID:   session.ID,
+		CreatedAt:  now,
+	}
+	return tx.Create(&audit).Error
+}
+
+func (s *SessionService) tokenPair(user *domain.User, sessionID uuid.UUID, refreshToken string) (*TokenPair, error) {
+	expiresAt := time.Now().Add(s.AccessTTL)
+	accessToken, err := utils.GenerateJWT(user.ID, user.Role, sessionID, user.MFAEnabledAt != nil, s.Secret, s.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{AccessToken: accessToken, RefreshToken: refreshToken, ExpiresAt: expiresAt}, nil
+}
+
+// hashToken is how refresh tokens are stored, so a database leak doesn't hand
+// out live sessions.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+)
+
+func newTestSessionService(t *testing.T) (*SessionService, sqlmock.Sqlmock) {
+	gormDB, mock := newMockDB(t)
+	return NewSessionService(gormDB, "test-secret", 15*time.Minute, 24*time.Hour), mock
+}
+
+func TestSessionService_Refresh_RotatesToken(t *testing.T) {
+	svc, mock := newTestSessionService(t)
+
+	sessionID := uuid.New()
+	userID := uuid.New()
+	oldHash := hashToken("old-token")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "sessions" WHERE refresh_token_hash = \$1 .* FOR UPDATE`).
+		WithArgs(oldHash, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "refresh_token_hash", "expires_at"}).
+			AddRow(sessionID, userID, oldHash, time.Now().Add(time.Hour)))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).
+		WithArgs(userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "role"}).AddRow(userID, "admin@test.com", domain.RoleUser))
+	mock.ExpectExec(`UPDATE "sessions" SET .*"previous_token_hash"=\$`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tokens, err := svc.Refresh("old-token", "test-agent", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tokens.RefreshToken == "" || tokens.RefreshToken == "old-token" {
+		t.Errorf("expected a new refresh token, got %q", tokens.RefreshToken)
+	}
+
+	// The access token names the session and carries the user's current role
+	token, err := jwt.Parse(tokens.AccessToken, func(*jwt.Token) (interface{}, error) { return []byte("test-secret"), nil })
+	if err != nil {
+		t.Fatalf("access token does not verify: %v", err)
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if claims["sid"] != sessionID.String() || claims["role"] != string(domain.RoleUser) {
+		t.Errorf("unexpected claims: %v", claims)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met: %s", err)
+	}
+}
+
+func TestSessionService_Refresh_ReuseRevokesSession(t *testing.T) {
+	svc, mock := newTestSessionService(t)
+
+	sessionID := uuid.New()
+	userID := uuid.New()
+	staleHash := hashToken("stale-token")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "sessions" WHERE refresh_token_hash = \$1 .* FOR UPDATE`).
+		WithArgs(staleHash, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "sessions" WHERE previous_token_hash = \$1 .* FOR UPDATE`).
+		WithArgs(staleHash, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "refresh_token_hash", "previous_token_hash", "expires_at"}).
+			AddRow(sessionID, userID, hashToken("current-token"), staleHash, time.Now().Add(time.Hour)))
+	mock.ExpectExec(`UPDATE "sessions" SET "revoked_at"=\$1 WHERE "id" = \$2`).
+		WithArgs(sqlmock.AnyArg(), sessionID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	if _, err := svc.Refresh("stale-token", "test-agent", "127.0.0.1"); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met: %s", err)
+	}
+}
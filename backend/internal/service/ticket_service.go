@@ -1,21 +1,26 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/username/event-ticketing-system/internal/domain"
 	"github.com/username/event-ticketing-system/pkg/pdf"
 	"github.com/username/event-ticketing-system/pkg/qr"
+	"github.com/username/event-ticketing-system/pkg/signing"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TicketService struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Signer *signing.Signer
 }
 
-func NewTicketService(db *gorm.DB) *TicketService {
-	return &TicketService{DB: db}
+func NewTicketService(db *gorm.DB, signer *signing.Signer) *TicketService {
+	return &TicketService{DB: db, Signer: signer}
 }
 
 func (s *TicketService) GenerateTicket(tx *gorm.DB, registrationID uuid.UUID) (*domain.Ticket, error) {
@@ -23,21 +28,45 @@ func (s *TicketService) GenerateTicket(tx *gorm.DB, registrationID uuid.UUID) (*
 		tx = s.DB
 	}
 
-	// Fetch registration with preloaded User, Event and TicketType
+	// Fetch registration with preloaded User, Event, TicketType and reserved seat
 	var reg domain.Registration
-	if err := tx.Preload("User").Preload("Event").Preload("TicketType").First(&reg, "id = ?", registrationID).Error; err != nil {
+	if err := tx.Preload("User").Preload("Event").Preload("TicketType").Preload("Seat.Section").First(&reg, "id = ?", registrationID).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch registration: %w", err)
 	}
 
+	ticketID := uuid.New()
 	ticketCode := fmt.Sprintf("E-%s-%s", uuid.New().String()[:4], uuid.New().String()[:8])
 
+	// The QR carries a signed token so scanners can reject forged tickets offline;
+	// the short ticket code stays on the PDF for manual entry at the door.
+	token, err := s.Signer.SignTicket(signing.TicketClaims{
+		TicketID:     ticketID,
+		EventID:      reg.EventID,
+		TicketTypeID: reg.TicketTypeID,
+		IssuedAt:     time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign ticket: %w", err)
+	}
+
 	// Generate QR Code
-	qrContent := fmt.Sprintf("https://tkt.system/v1/verify/%s", ticketCode)
+	qrContent := fmt.Sprintf("https://tkt.system/v1/verify/%s", token)
 	qrBytes, err := qr.GenerateQRCode(qrContent)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate QR code: %w", err)
 	}
 
+	// Seats bought in a group order name their own attendee
+	attendee := reg.ContactEmail()
+	if reg.AttendeeEmail != "" {
+		attendee = reg.AttendeeEmail
+		if reg.AttendeeName != "" {
+			attendee = fmt.Sprintf("%s <%s>", reg.AttendeeName, reg.AttendeeEmail)
+		}
+	} else if reg.AttendeeName != "" {
+		attendee = reg.AttendeeName
+	}
+
 	// Generate PDF
 	_, err = pdf.GenerateTicketPDF(pdf.TicketData{
 		TicketCode:       ticketCode,
@@ -46,7 +75,8 @@ func (s *TicketService) GenerateTicket(tx *gorm.DB, registrationID uuid.UUID) (*
 		EventDescription: reg.Event.Description,
 		EventTime:        reg.Event.StartTime.Format("Jan 02, 2006 15:04 MST"),
 		Price:            fmt.Sprintf("%.2f", reg.TicketType.Price),
-		UserEmail:        reg.User.Email,
+		UserEmail:        attendee,
+		Seat:             seatLabel(reg.Seat),
 		QRBytes:          qrBytes,
 	})
 	if err != nil {
@@ -54,10 +84,10 @@ func (s *TicketService) GenerateTicket(tx *gorm.DB, registrationID uuid.UUID) (*
 	}
 
 	ticket := &domain.Ticket{
-		ID:             uuid.New(),
+		ID:             ticketID,
 		RegistrationID: registrationID,
 		TicketCode:     ticketCode,
-		QRCodeURL:      qrContent, // Placeholder URL for verification
+		QRCodeURL:      qrContent,
 		PdfURL:         fmt.Sprintf("/tickets/ticket_%s.pdf", ticketCode),
 	}
 
@@ -67,3 +97,42 @@ func (s *TicketService) GenerateTicket(tx *gorm.DB, registrationID uuid.UUID) (*
 
 	return ticket, nil
 }
+
+// RevokeTicket records that the ticket issued for a registration is no longer
+// valid so offline scanners can reject it. It is a no-op if no ticket exists.
+func (s *TicketService) RevokeTicket(tx *gorm.DB, registrationID uuid.UUID, reason string) error {
+	if tx == nil {
+		tx = s.DB
+	}
+
+	var ticket domain.Ticket
+	if err := tx.Preload("Registration").First(&ticket, "registration_id = ?", registrationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	revocation := domain.TicketRevocation{
+		ID:         uuid.New(),
+		TicketID:   ticket.ID,
+		EventID:    ticket.Registration.EventID,
+		TicketCode: ticket.TicketCode,
+		Reason:     reason,
+		RevokedAt:  time.Now(),
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revocation).Error
+}
+
+// seatLabel renders a reserved seat as printed on the ticket, or "" for
+// general admission.
+func seatLabel(seat *domain.Seat) string {
+	if seat == nil {
+		return ""
+	}
+	section := ""
+	if seat.Section != nil {
+		section = seat.Section.Name
+	}
+	return fmt.Sprintf("Section %s, Row %s, Seat %d", section, seat.Row, seat.Number)
+}
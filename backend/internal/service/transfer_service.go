@@ -0,0 +1,559 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/worker"
+	"github.com/username/event-ticketing-system/pkg/email"
+	"github.com/username/event-ticketing-system/pkg/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TransferService runs two-step ticket transfers: the sender offers a ticket,
+// and ownership only moves when the recipient accepts before OfferTTL runs
+// out. Recipients without an account end up with a guest ticket and a claim
+// link to attach it to one later.
+type TransferService struct {
+	DB            *gorm.DB
+	TicketService *TicketService
+	EmailService  email.EmailService
+	Pool          *worker.WorkerPool
+	OfferTTL      time.Duration
+	// AppURL is the public base URL that offer and claim links point at
+	AppURL string
+}
+
+func NewTransferService(db *gorm.DB, ts *TicketService, es email.EmailService, pool *worker.WorkerPool, offerTTL time.Duration, appURL string) *TransferService {
+	return &TransferService{DB: db, TicketService: ts, EmailService: es, Pool: pool, OfferTTL: offerTTL, AppURL: appURL}
+}
+
+// OfferTransfer starts a transfer of a confirmed registration to recipientEmail
+// and emails them a link to accept or decline it.
+func (s *TransferService) OfferTransfer(senderID uuid.UUID, registrationID uuid.UUID, recipientEmail string, recipientName string) (*domain.TicketTransfer, error) {
+	var sender domain.User
+	if err := s.DB.First(&sender, "id = ?", senderID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	if strings.EqualFold(sender.Email, recipientEmail) {
+		return nil, errors.New("you cannot transfer a ticket to yourself")
+	}
+
+	var transfer domain.TicketTransfer
+	var eventTitle string
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var registration domain.Registration
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Event").
+			First(&registration, "id = ? AND user_id = ?", registrationID, senderID).Error; err != nil {
+			return errors.New("registration not found or you are not the owner")
+		}
+		if err := checkTransferable(&registration); err != nil {
+			return err
+		}
+		eventTitle = registration.Event.Title
+
+		var pending int64
+		if err := tx.Model(&domain.TicketTransfer{}).
+			Where("registration_id = ? AND status = ?", registrationID, domain.TransferPending).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return errors.New("this ticket already has a pending transfer; revoke it first")
+		}
+
+		var listed int64
+		if err := tx.Model(&domain.ResaleListing{}).
+			Where("registration_id = ? AND status IN ?", registrationID, openResaleStatuses).
+			Count(&listed).Error; err != nil {
+			return err
+		}
+		if listed > 0 {
+			return errors.New("this ticket is listed for resale; withdraw the listing first")
+		}
+
+		token, err := newSecretToken()
+		if err != nil {
+			return err
+		}
+		transfer = domain.TicketTransfer{
+			ID:             uuid.New(),
+			RegistrationID: registrationID,
+			SenderID:       senderID,
+			RecipientEmail: recipientEmail,
+			RecipientName:  recipientName,
+			Token:          token,
+			Status:         domain.TransferPending,
+			ExpiresAt:      time.Now().Add(s.OfferTTL),
+		}
+		if err := tx.Create(&transfer).Error; err != nil {
+			return err
+		}
+
+		return auditTransfer(tx, senderID, &transfer, "OFFER_TICKET_TRANSFER")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	offerURL := fmt.Sprintf("%s/api/v1/transfers/%s", strings.TrimRight(s.AppURL, "/"), transfer.Token)
+	expiresAt := transfer.ExpiresAt
+	s.Pool.Submit(worker.Task{
+		Type: worker.TaskUpdate,
+		Payload: map[string]interface{}{
+			"email":       recipientEmail,
+			"event_title": eventTitle,
+		},
+		Callback: func(t worker.Task) error {
+			return s.EmailService.SendTransferOfferEmail(recipientEmail, eventTitle, sender.Email, offerURL, expiresAt)
+		},
+	})
+
+	return &transfer, nil
+}
+
+// GetOffer shows a recipient the transfer behind their link.
+func (s *TransferService) GetOffer(token string) (*domain.TicketTransfer, error) {
+	var transfer domain.TicketTransfer
+	if err := s.DB.
+		Preload("Registration.Event").
+		Preload("Registration.TicketType").
+		Preload("Registration.Seat.Section").
+		First(&transfer, "token = ?", token).Error; err != nil {
+		return nil, errors.New("transfer not found")
+	}
+	return &transfer, nil
+}
+
+// AcceptTransfer completes a pending offer: the registration moves to the
+// recipient's account, or becomes a guest ticket with a claim link when the
+// recipient has no account with that email verified, and the sender's ticket
+// code is revoked and reissued.
+func (s *TransferService) AcceptTransfer(token string) (*domain.TicketTransfer, error) {
+	var transfer domain.TicketTransfer
+	var claim *domain.TicketClaim
+	var eventTitle, senderEmail string
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.lockPendingOffer(tx, token, &transfer); err != nil {
+			return err
+		}
+
+		var registration domain.Registration
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Event").
+			First(&registration, "id = ?", transfer.RegistrationID).Error; err != nil {
+			return errors.New("registration not found")
+		}
+		if registration.UserID == nil || *registration.UserID != transfer.SenderID {
+			return errors.New("the sender no longer owns this ticket")
+		}
+		if err := checkTransferable(&registration); err != nil {
+			return err
+		}
+		eventTitle = registration.Event.Title
+
+		var sender domain.User
+		if err := tx.First(&sender, "id = ?", transfer.SenderID).Error; err == nil {
+			senderEmail = sender.Email
+		}
+
+		// Only an account that has proven it owns the address gets the ticket outright
+		var recipient domain.User
+		err := tx.First(&recipient, "email = ? AND email_verified_at IS NOT NULL", transfer.RecipientEmail).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err == nil {
+			var ticketType domain.TicketType
+			if err := tx.First(&ticketType, "id = ?", registration.TicketTypeID).Error; err != nil {
+				return errors.New("ticket type not found")
+			}
+			if err := checkUserLimit(tx, recipient.ID, &ticketType, 1); err != nil {
+				return err
+			}
+		}
+
+		registration.AttendeeName = transfer.RecipientName
+		registration.AttendeeEmail = transfer.RecipientEmail
+		registration.UserID = nil
+		if err == nil {
+			registration.UserID = &recipient.ID
+			transfer.RecipientID = &recipient.ID
+		}
+		if err := tx.Omit(clause.Associations).Save(&registration).Error; err != nil {
+			return err
+		}
+
+		if registration.UserID == nil {
+			if claim, err = createTicketClaim(tx, registration.ID, transfer.RecipientEmail, transfer.RecipientName); err != nil {
+				return err
+			}
+		}
+
+		if err := s.TicketService.RevokeTicket(tx, registration.ID, "transferred"); err != nil {
+			return fmt.Errorf("failed to revoke old ticket: %w", err)
+		}
+
+		// Delete the old ticket so GenerateTicket creates a fresh one naming the recipient
+		if err := tx.Where("registration_id = ?", registration.ID).Delete(&domain.Ticket{}).Error; err != nil {
+			return fmt.Errorf("failed to clear old ticket: %w", err)
+		}
+		if _, err := s.TicketService.GenerateTicket(tx, registration.ID); err != nil {
+			return fmt.Errorf("failed to regenerate ticket for recipient: %w", err)
+		}
+
+		if err := s.respond(tx, &transfer, domain.TransferAccepted); err != nil {
+			return err
+		}
+
+		actor := transfer.SenderID
+		if transfer.RecipientID != nil {
+			actor = *transfer.RecipientID
+		}
+		return auditTransfer(tx, actor, &transfer, "ACCEPT_TICKET_TRANSFER")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	queueTicketEmail(s.Pool, s.EmailService, transfer.RecipientEmail, transfer.RegistrationID, "Transferred Ticket")
+	if claim != nil {
+		queueClaimEmail(s.Pool, s.EmailService, s.AppURL, claim, eventTitle, senderEmail)
+	}
+
+	return &transfer, nil
+}
+
+// DeclineTransfer closes a pending offer at the recipient's request; the
+// sender keeps the ticket.
+func (s *TransferService) DeclineTransfer(token string) (*domain.TicketTransfer, error) {
+	var transfer domain.TicketTransfer
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.lockPendingOffer(tx, token, &transfer); err != nil {
+			return err
+		}
+		if err := s.respond(tx, &transfer, domain.TransferDeclined); err != nil {
+			return err
+		}
+
+		actor := transfer.SenderID
+		var recipient domain.User
+		if err := tx.Select("id").First(&recipient, "email = ?", transfer.RecipientEmail).Error; err == nil {
+			actor = recipient.ID
+		}
+		return auditTransfer(tx, actor, &transfer, "DECLINE_TICKET_TRANSFER")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// RevokeTransfer lets the sender withdraw an offer the recipient has not
+// accepted yet.
+func (s *TransferService) RevokeTransfer(senderID uuid.UUID, transferID uuid.UUID) (*domain.TicketTransfer, error) {
+	var transfer domain.TicketTransfer
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&transfer, "id = ? AND sender_id = ?", transferID, senderID).Error; err != nil {
+			return errors.New("transfer not found")
+		}
+		if transfer.Status != domain.TransferPending {
+			return fmt.Errorf("transfer is already %s", transfer.Status)
+		}
+		if err := s.respond(tx, &transfer, domain.TransferRevoked); err != nil {
+			return err
+		}
+		return auditTransfer(tx, senderID, &transfer, "REVOKE_TICKET_TRANSFER")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// IncomingTransfer is a pending offer addressed to the signed-in user, with the
+// token they need to answer it.
+type IncomingTransfer struct {
+	domain.TicketTransfer
+	Token string `json:"token"`
+}
+
+type UserTransfers struct {
+	Sent     []domain.TicketTransfer `json:"sent"`
+	Incoming []IncomingTransfer      `json:"incoming"`
+}
+
+// ListTransfers returns the offers a user has sent and the pending ones
+// addressed to their email. Offers are only listed once the email is
+// verified; until then the emailed link is the way to answer them.
+func (s *TransferService) ListTransfers(userID uuid.UUID) (*UserTransfers, error) {
+	var user domain.User
+	if err := s.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	result := &UserTransfers{Sent: []domain.TicketTransfer{}, Incoming: []IncomingTransfer{}}
+	if err := s.DB.Where("sender_id = ?", userID).Order("created_at desc").Find(&result.Sent).Error; err != nil {
+		return nil, err
+	}
+
+	if user.EmailVerifiedAt == nil {
+		return result, nil
+	}
+
+	var incoming []domain.TicketTransfer
+	if err := s.DB.Preload("Registration.Event").Preload("Registration.TicketType").
+		Where("recipient_email = ? AND status = ? AND expires_at > ?", user.Email, domain.TransferPending, time.Now()).
+		Order("created_at desc").
+		Find(&incoming).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range incoming {
+		result.Incoming = append(result.Incoming, IncomingTransfer{TicketTransfer: t, Token: t.Token})
+	}
+
+	return result, nil
+}
+
+// ExpireOffers closes pending offers past their deadline. It runs from the
+// background sweeper.
+func (s *TransferService) ExpireOffers() {
+	var expired []domain.TicketTransfer
+	if err := s.DB.Where("status = ? AND expires_at < ?", domain.TransferPending, time.Now()).Find(&expired).Error; err != nil {
+		utils.Logger.Error("Failed to fetch expired transfer offers", zap.Error(err))
+		return
+	}
+
+	for i := range expired {
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&expired[i]).Where("status = ?", domain.TransferPending).Update("status", domain.TransferExpired)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			return auditTransfer(tx, expired[i].SenderID, &expired[i], "EXPIRE_TICKET_TRANSFER")
+		})
+		if err != nil {
+			utils.Logger.Error("Failed to expire transfer offer", zap.String("transfer_id", expired[i].ID.String()), zap.Error(err))
+		}
+	}
+}
+
+// lockPendingOffer loads the offer behind token for update and checks it can
+// still be answered.
+func (s *TransferService) lockPendingOffer(tx *gorm.DB, token string, transfer *domain.TicketTransfer) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(transfer, "token = ?", token).Error; err != nil {
+		return errors.New("transfer not found")
+	}
+	if transfer.Status != domain.TransferPending {
+		return fmt.Errorf("transfer is already %s", transfer.Status)
+	}
+	if time.Now().After(transfer.ExpiresAt) {
+		return errors.New("transfer offer has expired")
+	}
+	return nil
+}
+
+func (s *TransferService) respond(tx *gorm.DB, transfer *domain.TicketTransfer, status domain.TransferStatus) error {
+	now := time.Now()
+	transfer.Status = status
+	transfer.RespondedAt = &now
+	return tx.Model(transfer).Updates(map[string]interface{}{
+		"status":       status,
+		"responded_at": now,
+		"recipient_id": transfer.RecipientID,
+	}).Error
+}
+
+// checkTransferable rejects registrations whose ticket cannot change hands.
+func checkTransferable(registration *domain.Registration) error {
+	if registration.Status != domain.RegistrationConfirmed {
+		return errors.New("cannot transfer a registration that is not confirmed")
+	}
+	if registration.Event.Status != domain.StatusPublished {
+		return errors.New("cannot transfer registration for an event that is not published")
+	}
+	return nil
+}
+
+// revokePendingTransfers withdraws any open offer for a registration that is
+// leaving its owner some other way, such as a cancellation.
+func revokePendingTransfers(tx *gorm.DB, actorID uuid.UUID, registrationID uuid.UUID) error {
+	var pending []domain.TicketTransfer
+	if err := tx.Where("registration_id = ? AND status = ?", registrationID, domain.TransferPending).Find(&pending).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range pending {
+		pending[i].Status = domain.TransferRevoked
+		pending[i].RespondedAt = &now
+		if err := tx.Model(&pending[i]).Updates(map[string]interface{}{"status": domain.TransferRevoked, "responded_at": now}).Error; err != nil {
+			return err
+		}
+		if err := auditTransfer(tx, actorID, &pending[i], "REVOKE_TICKET_TRANSFER"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func auditTransfer(tx *gorm.DB, actorID uuid.UUID, transfer *domain.TicketTransfer, action string) error {
+	audit := domain.AuditLog{
+		ID:         uuid.New(),
+		UserID:     actorID,
+		Action:     action,
+		EntityType: "ticket_transfer",
+		EntityID:   transfer.ID,
+		NewValues: utils.ToJSON(map[string]interface{}{
+			"registration_id": transfer.RegistrationID,
+			"sender_id":       transfer.SenderID,
+			"recipient_email": transfer.RecipientEmail,
+			"recipient_id":    transfer.RecipientID,
+			"status":          transfer.Status,
+		}),
+		CreatedAt: time.Now(),
+	}
+	return tx.Create(&audit).Error
+}
+
+// createTicketClaim opens a claim link for a guest ticket, cancelling any
+// link still pending for the registration.
+func createTicketClaim(tx *gorm.DB, registrationID uuid.UUID, email string, name string) (*domain.TicketClaim, error) {
+	if err := tx.Model(&domain.TicketClaim{}).
+		Where("registration_id = ? AND status = ?", registrationID, domain.ClaimPending).
+		Update("status", domain.ClaimCancelled).Error; err != nil {
+		return nil, err
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+	claim := domain.TicketClaim{
+		ID:             uuid.New(),
+		RegistrationID: registrationID,
+		Email:          email,
+		Name:           name,
+		Token:          token,
+		Status:         domain.ClaimPending,
+	}
+	if err := tx.Create(&claim).Error; err != nil {
+		return nil, err
+	}
+	return &claim, nil
+}
+
+// newSecretToken returns a random token for links sent by email.
+func newSecretToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// queueClaimEmail sends a guest the claim link for their ticket.
+func queueClaimEmail(pool *worker.WorkerPool, es email.EmailService, appURL string, claim *domain.TicketClaim, eventTitle string, senderEmail string) {
+	claimURL := fmt.Sprintf("%s/api/v1/claims/%s", strings.TrimRight(appURL, "/"), claim.Token)
+	pool.Submit(worker.Task{
+		Type: worker.TaskUpdate,
+		Payload: map[string]interface{}{
+			"email":       claim.Email,
+			"event_title": eventTitle,
+		},
+		Callback: func(t worker.Task) error {
+			return es.SendTicketClaimEmail(claim.Email, eventTitle, senderEmail, claimURL)
+		},
+	})
+}
+
+// GetTicketClaim shows a guest their ticket through the claim link, without
+// needing an account.
+func (s *TransferService) GetTicketClaim(token string) (*domain.TicketClaim, error) {
+	var claim domain.TicketClaim
+	if err := s.DB.
+		Preload("Registration.Event").
+		Preload("Registration.TicketType").
+		Preload("Registration.Ticket").
+		Preload("Registration.Seat.Section").
+		First(&claim, "token = ?", token).Error; err != nil {
+		return nil, errors.New("claim link not found")
+	}
+	if claim.Status == domain.ClaimCancelled {
+		return nil, errors.New("this ticket has been transferred again and the link is no longer valid")
+	}
+	return &claim, nil
+}
+
+// ClaimTicket attaches a guest ticket to the account following its claim link.
+// The link works once.
+func (s *TransferService) ClaimTicket(userID uuid.UUID, token string) (*domain.Registration, error) {
+	var registration domain.Registration
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var claim domain.TicketClaim
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&claim, "token = ?", token).Error; err != nil {
+			return errors.New("claim link not found")
+		}
+		if claim.Status != domain.ClaimPending {
+			return errors.New("this claim link has already been used")
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&registration, "id = ?", claim.RegistrationID).Error; err != nil {
+			return errors.New("registration not found")
+		}
+		if registration.UserID != nil {
+			return errors.New("this ticket already belongs to an account")
+		}
+		if registration.Status == domain.RegistrationCancelled {
+			return errors.New("this ticket has been cancelled")
+		}
+
+		var ticketType domain.TicketType
+		if err := tx.First(&ticketType, "id = ?", registration.TicketTypeID).Error; err != nil {
+			return errors.New("ticket type not found")
+		}
+		if err := checkUserLimit(tx, userID, &ticketType, 1); err != nil {
+			return err
+		}
+
+		registration.UserID = &userID
+		if err := tx.Model(&registration).Update("user_id", userID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&claim).Updates(map[string]interface{}{
+			"status":     domain.ClaimClaimed,
+			"claimed_by": userID,
+			"claimed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		audit := domain.AuditLog{
+			ID:         uuid.New(),
+			UserID:     userID,
+			Action:     "CLAIM_TICKET",
+			EntityType: "registration",
+			EntityID:   registration.ID,
+			NewValues:  fmt.Sprintf(`{"claim_id": "%s", "email": %q}`, claim.ID, claim.Email),
+			CreatedAt:  now,
+		}
+		return tx.Create(&audit).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &registration, nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/worker"
+	"github.com/username/event-ticketing-system/pkg/email"
+	"github.com/username/event-ticketing-system/pkg/signing"
+)
+
+func newTestTransferService(t *testing.T) (*TransferService, sqlmock.Sqlmock, func()) {
+	gormDB, mock := newMockDB(t)
+
+	pool := worker.NewWorkerPool(1, 10)
+	pool.Start(context.Background())
+
+	signer, _ := signing.NewSigner(map[string]ed25519.PrivateKey{"test": signing.DeriveKey("test")}, "test")
+	ts := NewTicketService(gormDB, signer)
+	es := email.NewEmailService("smtp.test.com", "587", "user", "pass", "test@test.com")
+	svc := NewTransferService(gormDB, ts, es, pool, time.Hour, "http://localhost:8080")
+
+	return svc, mock, pool.Shutdown
+}
+
+func TestTransferService_AcceptTransfer_RejectsExpiredOffer(t *testing.T) {
+	svc, mock, cleanup := newTestTransferService(t)
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "ticket_transfers" WHERE token = \$1 .* FOR UPDATE`).
+		WithArgs("offer-token", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "registration_id", "sender_id", "recipient_email", "token", "status", "expires_at"}).
+			AddRow(uuid.New(), uuid.New(), uuid.New(), "friend@test.com", "offer-token", domain.TransferPending, time.Now().Add(-time.Minute)))
+	mock.ExpectRollback()
+
+	if _, err := svc.AcceptTransfer("offer-token"); err == nil {
+		t.Fatal("expected an expired offer to be rejected")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met: %s", err)
+	}
+}
+
+func TestTransferService_RevokeTransfer_OnlyPending(t *testing.T) {
+	svc, mock, cleanup := newTestTransferService(t)
+	defer cleanup()
+
+	senderID := uuid.New()
+	transferID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "ticket_transfers" WHERE id = \$1 AND sender_id = \$2 .* FOR UPDATE`).
+		WithArgs(transferID, senderID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "registration_id", "sender_id", "recipient_email", "status", "expires_at"}).
+			AddRow(transferID, uuid.New(), senderID, "friend@test.com", domain.TransferAccepted, time.Now().Add(time.Hour)))
+	mock.ExpectRollback()
+
+	if _, err := svc.RevokeTransfer(senderID, transferID); err == nil {
+		t.Fatal("expected an accepted transfer to be irrevocable")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met: %s", err)
+	}
+}
+
+func TestTransferService_ClaimTicket_SingleUse(t *testing.T) {
+	svc, mock, cleanup := newTestTransferService(t)
+	defer cleanup()
+
+	userID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "ticket_claims" WHERE token = \$1 .* FOR UPDATE`).
+		WithArgs("used-token", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "registration_id", "email", "token", "status"}).
+			AddRow(uuid.New(), uuid.New(), "guest@test.com", "used-token", "claimed"))
+	mock.ExpectRollback()
+
+	if _, err := svc.ClaimTicket(userID, "used-token"); err == nil {
+		t.Fatal("expected a used claim link to be rejected")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met: %s", err)
+	}
+}
+
+// errClaimStop ends a test once the transfer has reached its claim link.
+var errClaimStop = errors.New("stop at claim")
+
+func TestTransferService_AcceptTransfer_UnverifiedRecipientGetsClaimLink(t *testing.T) {
+	svc, mock, cleanup := newTestTransferService(t)
+	defer cleanup()
+
+	transferID, registrationID, senderID, eventID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "ticket_transfers" WHERE token = \$1 .* FOR UPDATE`).
+		WithArgs("offer-token", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "registration_id", "sender_id", "recipient_email", "token", "status", "expires_at"}).
+			AddRow(transferID, registrationID, senderID, "friend@test.com", "offer-token", domain.TransferPending, time.Now().Add(time.Hour)))
+	mock.ExpectQuery(`SELECT \* FROM "registrations" WHERE id = \$1 .* FOR UPDATE`).
+		WithArgs(registrationID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "event_id", "status"}).
+			AddRow(registrationID, senderID, eventID, domain.RegistrationConfirmed))
+	mock.ExpectQuery(`SELECT \* FROM "events"`).
+		WithArgs(eventID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status"}).AddRow(eventID, "Gig", domain.StatusPublished))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).
+		WithArgs(senderID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(senderID, "sender@test.com"))
+	// An account that registered the address without verifying it does not match
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE \(email = \$1 AND email_verified_at IS NOT NULL\)`).
+		WithArgs("friend@test.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`UPDATE "registrations"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "ticket_claims"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "ticket_claims"`).
+		WithArgs(registrationID, "friend@test.com", "", sqlmock.AnyArg(), domain.ClaimPending, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnError(errClaimStop)
+	mock.ExpectRollback()
+
+	if _, err := svc.AcceptTransfer("offer-token"); !errors.Is(err, errClaimStop) {
+		t.Fatalf("expected the ticket to go to a claim link, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met: %s", err)
+	}
+}
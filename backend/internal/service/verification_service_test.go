@@ -0,0 +1,55 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestParseVerificationToken(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now()
+
+	token, err := signVerificationToken("test-secret", userID, "ada@example.com", now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	gotID, address, err := parseVerificationToken("test-secret", token, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotID != userID || address != "ada@example.com" {
+		t.Errorf("unexpected claims: %s %s", gotID, address)
+	}
+
+	// An ordinary access token signed with the same secret isn't a verification link
+	accessToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   userID.String(),
+		"email": "ada@example.com",
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString([]byte("test-secret"))
+
+	tests := []struct {
+		name   string
+		secret string
+		token  string
+		now    time.Time
+	}{
+		{"expired", "test-secret", token, now.Add(2 * time.Hour)},
+		{"wrong secret", "other-secret", token, now},
+		{"wrong purpose", "test-secret", accessToken, now},
+		{"garbage", "test-secret", "not-a-token", now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := parseVerificationToken(tt.secret, tt.token, tt.now); !errors.Is(err, ErrInvalidVerificationLink) {
+				t.Errorf("expected ErrInvalidVerificationLink, got %v", err)
+			}
+		})
+	}
+}
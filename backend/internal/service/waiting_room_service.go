@@ -0,0 +1,273 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/pkg/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WaitingRoomService queues buyers for events with a waiting room and admits
+// them at a steady rate, so only admitted users reach checkout.
+// All queue state lives in Postgres and survives restarts.
+type WaitingRoomService struct {
+	DB                    *gorm.DB
+	DefaultAdmitPerMinute int
+	AdmissionTTL          time.Duration
+}
+
+func NewWaitingRoomService(db *gorm.DB, defaultAdmitPerMinute int, admissionTTL time.Duration) *WaitingRoomService {
+	return &WaitingRoomService{DB: db, DefaultAdmitPerMinute: defaultAdmitPerMinute, AdmissionTTL: admissionTTL}
+}
+
+type QueuePosition struct {
+	domain.QueueTicket
+	Position             int64 `json:"position,omitempty"`
+	EstimatedWaitSeconds int64 `json:"estimated_wait_seconds,omitempty"`
+}
+
+// Join puts the user in an event's queue. Joining again while still waiting or
+// admitted returns the existing place instead of a new one; a unique index on
+// active tickets keeps concurrent joins to one place as well.
+func (s *WaitingRoomService) Join(userID uuid.UUID, eventID uuid.UUID) (*QueuePosition, error) {
+	var event domain.Event
+	if err := s.DB.First(&event, "id = ?", eventID).Error; err != nil {
+		return nil, newCodedError(CodeEventNotFound, "event not found")
+	}
+	if !event.WaitingRoom {
+		return nil, errors.New("this event does not have a waiting room")
+	}
+	if event.Status != domain.StatusPublished {
+		return nil, newCodedError(CodeEventNotPublished, "event is not on sale")
+	}
+
+	var ticket domain.QueueTicket
+	err := activeQueueTicket(s.DB, eventID, userID, &ticket)
+	if err == nil {
+		return s.withPosition(&event, ticket)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	token, err := newQueueToken()
+	if err != nil {
+		return nil, err
+	}
+	ticket = domain.QueueTicket{
+		ID:      uuid.New(),
+		EventID: eventID,
+		UserID:  userID,
+		Token:   token,
+		Status:  domain.QueueWaiting,
+	}
+	result := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&ticket)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// A concurrent join by the same user got there first
+		ticket = domain.QueueTicket{}
+		if err := activeQueueTicket(s.DB, eventID, userID, &ticket); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.withPosition(&event, ticket)
+}
+
+func activeQueueTicket(db *gorm.DB, eventID uuid.UUID, userID uuid.UUID, ticket *domain.QueueTicket) error {
+	return db.Where("event_id = ? AND user_id = ? AND status IN ?", eventID, userID, []domain.QueueStatus{domain.QueueWaiting, domain.QueueAdmitted}).
+		First(ticket).Error
+}
+
+// GetPosition reports where a user's queue token stands.
+func (s *WaitingRoomService) GetPosition(userID uuid.UUID, token string) (*QueuePosition, error) {
+	var ticket domain.QueueTicket
+	if err := s.DB.First(&ticket, "token = ? AND user_id = ?", token, userID).Error; err != nil {
+		return nil, errors.New("queue token not found")
+	}
+
+	var event domain.Event
+	if err := s.DB.First(&event, "id = ?", ticket.EventID).Error; err != nil {
+		return nil, newCodedError(CodeEventNotFound, "event not found")
+	}
+
+	return s.withPosition(&event, ticket)
+}
+
+// AdvanceQueues expires unused admissions and admits the next users of every
+// waiting room, at most each event's admit rate per rolling minute. It runs
+// from the background sweeper.
+func (s *WaitingRoomService) AdvanceQueues() {
+	now := time.Now()
+	if err := s.DB.Model(&domain.QueueTicket{}).
+		Where("status = ? AND expires_at < ?", domain.QueueAdmitted, now).
+		Update("status", domain.QueueExpired).Error; err != nil {
+		utils.Logger.Error("Failed to expire queue admissions", zap.Error(err))
+	}
+
+	var events []domain.Event
+	if err := s.DB.Where("waiting_room = ? AND id IN (?)", true,
+		s.DB.Model(&domain.QueueTicket{}).Select("event_id").Where("status = ?", domain.QueueWaiting),
+	).Find(&events).Error; err != nil {
+		utils.Logger.Error("Failed to fetch waiting rooms", zap.Error(err))
+		return
+	}
+
+	for i := range events {
+		admitted, err := s.admit(&events[i], now)
+		if err != nil {
+			utils.Logger.Error("Failed to admit from waiting room", zap.String("event_id", events[i].ID.String()), zap.Error(err))
+			continue
+		}
+		if admitted > 0 {
+			utils.Logger.Info("Admitted users from waiting room", zap.String("event_id", events[i].ID.String()), zap.Int64("admitted", admitted))
+		}
+	}
+}
+
+// admit lets in the next users of one waiting room, up to what is left of its
+// rate for the rolling minute. Instances running the sweeper at the same time
+// take turns on a per-event advisory lock, so the budget is never spent twice;
+// checkouts do not take that lock and are not held up by it.
+func (s *WaitingRoomService) admit(event *domain.Event, now time.Time) (int64, error) {
+	var admitted int64
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", "waiting-room:"+event.ID.String()).Error; err != nil {
+			return err
+		}
+
+		var recent int64
+		if err := tx.Model(&domain.QueueTicket{}).
+			Where("event_id = ? AND admitted_at > ?", event.ID, now.Add(-time.Minute)).
+			Count(&recent).Error; err != nil {
+			return err
+		}
+
+		budget := int64(s.admitRate(event)) - recent
+		if budget <= 0 {
+			return nil
+		}
+
+		var next []uuid.UUID
+		if err := tx.Model(&domain.QueueTicket{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("event_id = ? AND status = ?", event.ID, domain.QueueWaiting).
+			Order("created_at").
+			Limit(int(budget)).
+			Pluck("id", &next).Error; err != nil {
+			return err
+		}
+		if len(next) == 0 {
+			return nil
+		}
+
+		result := tx.Model(&domain.QueueTicket{}).Where("id IN ?", next).Updates(map[string]interface{}{
+			"status":      domain.QueueAdmitted,
+			"admitted_at": now,
+			"expires_at":  now.Add(s.AdmissionTTL),
+		})
+		admitted = result.RowsAffected
+		return result.Error
+	})
+	return admitted, err
+}
+
+func (s *WaitingRoomService) admitRate(event *domain.Event) int {
+	if event.QueueAdmitPerMinute > 0 {
+		return event.QueueAdmitPerMinute
+	}
+	return s.DefaultAdmitPerMinute
+}
+
+func (s *WaitingRoomService) withPosition(event *domain.Event, ticket domain.QueueTicket) (*QueuePosition, error) {
+	p := &QueuePosition{QueueTicket: ticket}
+	if ticket.Status != domain.QueueWaiting {
+		return p, nil
+	}
+
+	var ahead int64
+	if err := s.DB.Model(&domain.QueueTicket{}).
+		Where("event_id = ? AND status = ? AND created_at < ?", ticket.EventID, domain.QueueWaiting, ticket.CreatedAt).
+		Count(&ahead).Error; err != nil {
+		return nil, err
+	}
+	p.Position = ahead + 1
+	p.EstimatedWaitSeconds = estimatedQueueWait(p.Position, s.admitRate(event))
+	return p, nil
+}
+
+// estimatedQueueWait is how long the user at position waits at rate
+// admissions per minute, rounded up to the next admission round.
+func estimatedQueueWait(position int64, rate int) int64 {
+	if rate <= 0 {
+		return 0
+	}
+	rounds := (position + int64(rate) - 1) / int64(rate)
+	return (rounds - 1) * 60
+}
+
+func newQueueToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// checkQueueAdmission is run before a checkout opens its transaction, so users
+// who have not been admitted never contend for inventory. It returns
+// the admitted ticket to spend, or nil when the event has no waiting room.
+func checkQueueAdmission(db *gorm.DB, eventID uuid.UUID, userID uuid.UUID, token string) (*domain.QueueTicket, error) {
+	var event domain.Event
+	if err := db.Select("id", "waiting_room").First(&event, "id = ?", eventID).Error; err != nil {
+		return nil, newCodedError(CodeEventNotFound, "event not found")
+	}
+	if !event.WaitingRoom {
+		return nil, nil
+	}
+	if token == "" {
+		return nil, newCodedError(CodeQueueTokenRequired, "this on-sale uses a waiting room; join the queue first")
+	}
+
+	var ticket domain.QueueTicket
+	if err := db.First(&ticket, "token = ? AND event_id = ? AND user_id = ?", token, eventID, userID).Error; err != nil {
+		return nil, newCodedError(CodeQueueTokenInvalid, "queue token is not valid for this event")
+	}
+
+	switch {
+	case ticket.Status == domain.QueueWaiting:
+		return nil, newCodedError(CodeQueueNotAdmitted, "you have not been admitted from the queue yet")
+	case ticket.Status == domain.QueueUsed:
+		return nil, newCodedError(CodeQueueTokenInvalid, "queue token has already been used")
+	case ticket.Status == domain.QueueExpired, ticket.ExpiresAt != nil && time.Now().After(*ticket.ExpiresAt):
+		return nil, newCodedError(CodeQueueTokenExpired, "your admission has expired; join the queue again")
+	}
+	return &ticket, nil
+}
+
+// spendQueueTicket marks an admission as used inside the checkout transaction,
+// so a failed checkout leaves it usable for a retry.
+func spendQueueTicket(tx *gorm.DB, ticket *domain.QueueTicket) error {
+	if ticket == nil {
+		return nil
+	}
+	result := tx.Model(&domain.QueueTicket{}).
+		Where("id = ? AND status = ?", ticket.ID, domain.QueueAdmitted).
+		Update("status", domain.QueueUsed)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return newCodedError(CodeQueueTokenInvalid, "queue token has already been used")
+	}
+	return nil
+}
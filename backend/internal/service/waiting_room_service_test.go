@@ -0,0 +1,117 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+)
+
+func TestEstimatedQueueWait(t *testing.T) {
+	tests := []struct {
+		position int64
+		rate     int
+		want     int64
+	}{
+		{1, 100, 0},
+		{100, 100, 0},
+		{101, 100, 60},
+		{250, 100, 120},
+		{5, 0, 0},
+	}
+
+	for _, tt := range tests {
+		if got := estimatedQueueWait(tt.position, tt.rate); got != tt.want {
+			t.Errorf("estimatedQueueWait(%d, %d) = %d, want %d", tt.position, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestWaitingRoomService_Join_ConcurrentJoinKeepsOnePlace(t *testing.T) {
+	db, mock := newMockDB(t)
+	svc := NewWaitingRoomService(db, 100, 10*time.Minute)
+
+	userID, eventID, existingID := uuid.New(), uuid.New(), uuid.New()
+	columns := []string{"id", "event_id", "user_id", "token", "status", "created_at"}
+	joinedAt := time.Now().Add(-time.Minute)
+
+	mock.ExpectQuery(`SELECT \* FROM "events"`).
+		WithArgs(eventID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "waiting_room", "queue_admit_per_minute"}).
+			AddRow(eventID, domain.StatusPublished, true, 0))
+	mock.ExpectQuery(`SELECT \* FROM "queue_tickets" WHERE event_id = \$1 AND user_id = \$2 AND status IN`).
+		WithArgs(eventID, userID, domain.QueueWaiting, domain.QueueAdmitted, 1).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	// Another request by the same user inserted first, so the insert is a no-op
+	mock.ExpectQuery(`INSERT INTO "queue_tickets" .* ON CONFLICT DO NOTHING`).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}))
+	mock.ExpectQuery(`SELECT \* FROM "queue_tickets" WHERE event_id = \$1 AND user_id = \$2 AND status IN`).
+		WithArgs(eventID, userID, domain.QueueWaiting, domain.QueueAdmitted, 1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(existingID, eventID, userID, "tok", domain.QueueWaiting, joinedAt))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "queue_tickets" WHERE event_id = \$1 AND status = \$2 AND created_at < \$3`).
+		WithArgs(eventID, domain.QueueWaiting, joinedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(150))
+
+	position, err := svc.Join(userID, eventID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if position.ID != existingID || position.Token != "tok" {
+		t.Errorf("expected the place taken by the concurrent join, got %+v", position.QueueTicket)
+	}
+	if position.Position != 151 || position.EstimatedWaitSeconds != 60 {
+		t.Errorf("expected position 151 about a minute out, got %d (%ds)", position.Position, position.EstimatedWaitSeconds)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestWaitingRoomService_Admit_SpendsRemainingBudgetUnderLock(t *testing.T) {
+	db, mock := newMockDB(t)
+	svc := NewWaitingRoomService(db, 100, 10*time.Minute)
+
+	event := &domain.Event{ID: uuid.New(), QueueAdmitPerMinute: 5}
+	now := time.Now()
+	first, second := uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	// The count is taken under the per-event lock, so two sweepers cannot both spend it
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock\(hashtextextended\(\$1, 0\)\)`).
+		WithArgs("waiting-room:" + event.ID.String()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "queue_tickets" WHERE event_id = \$1 AND admitted_at > \$2`).
+		WithArgs(event.ID, now.Add(-time.Minute)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(`SELECT "id" FROM "queue_tickets" WHERE event_id = \$1 AND status = \$2 ORDER BY created_at LIMIT \$3 FOR UPDATE SKIP LOCKED`).
+		WithArgs(event.ID, domain.QueueWaiting, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(first).AddRow(second))
+	mock.ExpectExec(`UPDATE "queue_tickets" SET "admitted_at"=\$1,"expires_at"=\$2,"status"=\$3,"updated_at"=\$4 WHERE id IN \(\$5,\$6\)`).
+		WithArgs(now, now.Add(10*time.Minute), domain.QueueAdmitted, sqlmock.AnyArg(), first, second).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	admitted, err := svc.admit(event, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if admitted != 2 {
+		t.Errorf("expected the 2 admissions left this minute, got %d", admitted)
+	}
+
+	// A spent budget admits nobody
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "queue_tickets"`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+	mock.ExpectCommit()
+
+	if admitted, err := svc.admit(event, now); err != nil || admitted != 0 {
+		t.Errorf("expected no admissions over the rate, got %d (%v)", admitted, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
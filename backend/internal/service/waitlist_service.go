@@ -0,0 +1,355 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/worker"
+	"github.com/username/event-ticketing-system/pkg/email"
+	"github.com/username/event-ticketing-system/pkg/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WaitlistService struct {
+	DB            *gorm.DB
+	TicketService *TicketService
+	EmailService  email.EmailService
+	Pool          *worker.WorkerPool
+	Inventory     *Inventory
+	OfferTTL      time.Duration
+	// Payments is set after construction since failed payments release their
+	// seats back through the waitlist.
+	Payments *PaymentService
+}
+
+func NewWaitlistService(db *gorm.DB, ts *TicketService, es email.EmailService, pool *worker.WorkerPool, inv *Inventory, offerTTL time.Duration) *WaitlistService {
+	return &WaitlistService{DB: db, TicketService: ts, EmailService: es, Pool: pool, Inventory: inv, OfferTTL: offerTTL}
+}
+
+type WaitlistPosition struct {
+	domain.WaitlistEntry
+	Position int64 `json:"position,omitempty"`
+}
+
+func (s *WaitlistService) Join(userID uuid.UUID, eventID uuid.UUID, ticketTypeID uuid.UUID) (*WaitlistPosition, error) {
+	if err := requireVerifiedEmail(s.DB, userID); err != nil {
+		return nil, err
+	}
+
+	var ticketType domain.TicketType
+	if err := s.DB.Scopes(WithTicketTypeRemaining).First(&ticketType, "id = ? AND event_id = ?", ticketTypeID, eventID).Error; err != nil {
+		return nil, errors.New("ticket type not found for this event")
+	}
+
+	if ticketType.RemainingTickets > 0 {
+		return nil, errors.New("tickets are still available for this ticket type")
+	}
+
+	var existing int64
+	if err := s.DB.Model(&domain.WaitlistEntry{}).
+		Where("user_id = ? AND ticket_type_id = ? AND status IN ?", userID, ticketTypeID, []domain.WaitlistStatus{domain.WaitlistWaiting, domain.WaitlistOffered}).
+		Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, errors.New("you are already on the waitlist for this ticket type")
+	}
+
+	entry := domain.WaitlistEntry{
+		ID:           uuid.New(),
+		UserID:       userID,
+		EventID:      eventID,
+		TicketTypeID: ticketTypeID,
+		Status:       domain.WaitlistWaiting,
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+
+		audit := domain.AuditLog{
+			ID:         uuid.New(),
+			UserID:     userID,
+			Action:     "JOIN_WAITLIST",
+			EntityType: "waitlist_entry",
+			EntityID:   entry.ID,
+			NewValues:  fmt.Sprintf(`{"event_id": "%s", "ticket_type_id": "%s"}`, eventID, ticketTypeID),
+			CreatedAt:  time.Now(),
+		}
+		return tx.Create(&audit).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.withPosition(entry)
+}
+
+func (s *WaitlistService) GetUserEntries(userID uuid.UUID) ([]WaitlistPosition, error) {
+	var entries []domain.WaitlistEntry
+	if err := s.DB.Preload("Event").Preload("TicketType").
+		Where("user_id = ? AND status IN ?", userID, []domain.WaitlistStatus{domain.WaitlistWaiting, domain.WaitlistOffered}).
+		Order("created_at").
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	positions := make([]WaitlistPosition, 0, len(entries))
+	for _, entry := range entries {
+		p, err := s.withPosition(entry)
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, *p)
+	}
+	return positions, nil
+}
+
+func (s *WaitlistService) Leave(userID uuid.UUID, entryID uuid.UUID) error {
+	var next *domain.WaitlistEntry
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var entry domain.WaitlistEntry
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&entry, "id = ? AND user_id = ?", entryID, userID).Error; err != nil {
+			return errors.New("waitlist entry not found")
+		}
+
+		if entry.Status != domain.WaitlistWaiting && entry.Status != domain.WaitlistOffered {
+			return errors.New("waitlist entry is no longer active")
+		}
+
+		wasOffered := entry.Status == domain.WaitlistOffered
+		entry.Status = domain.WaitlistLeft
+		if err := tx.Save(&entry).Error; err != nil {
+			return err
+		}
+
+		// A declined offer passes the held seat on to the next person in line
+		if wasOffered {
+			var err error
+			if next, err = s.ReleaseSeat(tx, entry.EventID, entry.TicketTypeID); err != nil {
+				return err
+			}
+		}
+
+		audit := domain.AuditLog{
+			ID:         uuid.New(),
+			UserID:     userID,
+			Action:     "LEAVE_WAITLIST",
+			EntityType: "waitlist_entry",
+			EntityID:   entry.ID,
+			CreatedAt:  time.Now(),
+		}
+		return tx.Create(&audit).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if next != nil {
+		s.NotifyOffer(next.ID)
+	}
+	return nil
+}
+
+// Claim turns an open offer into a confirmed registration with the given
+// answers to the event's registration form. The seat was taken out of
+// inventory when the offer was made, so no counters change here.
+func (s *WaitlistService) Claim(userID uuid.UUID, entryID uuid.UUID, answers map[uuid.UUID]json.RawMessage) (*domain.Registration, error) {
+	var registration *domain.Registration
+	var entry domain.WaitlistEntry
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&entry, "id = ? AND user_id = ?", entryID, userID).Error; err != nil {
+			return errors.New("waitlist entry not found")
+		}
+
+		if entry.Status != domain.WaitlistOffered {
+			return errors.New("there is no open offer for this waitlist entry")
+		}
+		if entry.OfferExpiresAt == nil || time.Now().After(*entry.OfferExpiresAt) {
+			return errors.New("this offer has expired")
+		}
+
+		var event domain.Event
+		if err := tx.First(&event, "id = ?", entry.EventID).Error; err != nil || event.Status != domain.StatusPublished {
+			return errors.New("cannot register for an event that is not published")
+		}
+
+		var ticketType domain.TicketType
+		if err := tx.First(&ticketType, "id = ?", entry.TicketTypeID).Error; err != nil {
+			return errors.New("ticket type not found")
+		}
+		if err := checkUserLimit(tx, userID, &ticketType, 1); err != nil {
+			return err
+		}
+
+		payment, err := s.Payments.Begin(tx, userID, ticketType.Price)
+		if err != nil {
+			return err
+		}
+
+		registration, err = issueRegistration(tx, s.TicketService, userID, entry.EventID, &ticketType, payment, answers, "CLAIM_WAITLIST_OFFER")
+		if err != nil {
+			return err
+		}
+
+		entry.Status = domain.WaitlistClaimed
+		return tx.Save(&entry).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if registration.Status != domain.RegistrationConfirmed {
+		if err := s.Payments.Open(registration.Payment); err != nil {
+			return nil, err
+		}
+		return registration, nil
+	}
+
+	var user domain.User
+	var event domain.Event
+	if err := s.DB.First(&user, "id = ?", userID).Error; err == nil {
+		s.DB.First(&event, "id = ?", entry.EventID)
+		queueTicketEmail(s.Pool, s.EmailService, user.Email, registration.ID, event.Title)
+	}
+
+	return registration, nil
+}
+
+// ReleaseSeat hands a freed seat of a ticket type to the next waiting user as a
+// time-limited offer. If nobody is waiting the seat goes back to the ticket
+// type's stock. It must run inside the caller's transaction; the returned
+// entry, if any, should be passed to NotifyOffer once that transaction commits.
+func (s *WaitlistService) ReleaseSeat(tx *gorm.DB, eventID uuid.UUID, ticketTypeID uuid.UUID) (*domain.WaitlistEntry, error) {
+	var next domain.WaitlistEntry
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("ticket_type_id = ? AND status = ?", ticketTypeID, domain.WaitlistWaiting).
+		Order("created_at").
+		First(&next).Error
+
+	if err == nil {
+		expiresAt := time.Now().Add(s.OfferTTL)
+		next.Status = domain.WaitlistOffered
+		next.OfferExpiresAt = &expiresAt
+		if err := tx.Save(&next).Error; err != nil {
+			return nil, err
+		}
+
+		audit := domain.AuditLog{
+			ID:         uuid.New(),
+			UserID:     next.UserID,
+			Action:     "WAITLIST_OFFER",
+			EntityType: "waitlist_entry",
+			EntityID:   next.ID,
+			NewValues:  fmt.Sprintf(`{"offer_expires_at": "%s"}`, expiresAt.Format(time.RFC3339)),
+			CreatedAt:  time.Now(),
+		}
+		if err := tx.Create(&audit).Error; err != nil {
+			return nil, err
+		}
+
+		return &next, nil
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	return nil, s.Inventory.Put(tx, ticketTypeID, 1)
+}
+
+// NotifyOffer emails the offered user through the worker pool.
+func (s *WaitlistService) NotifyOffer(entryID uuid.UUID) {
+	var entry domain.WaitlistEntry
+	if err := s.DB.Preload("User").Preload("Event").Preload("TicketType").First(&entry, "id = ?", entryID).Error; err != nil {
+		utils.Logger.Error("Failed to load waitlist offer for notification", zap.String("entry_id", entryID.String()), zap.Error(err))
+		return
+	}
+	if entry.OfferExpiresAt == nil {
+		return
+	}
+
+	s.Pool.Submit(worker.Task{
+		Type: worker.TaskUpdate,
+		Payload: map[string]interface{}{
+			"email":            entry.User.Email,
+			"event_title":      entry.Event.Title,
+			"ticket_type_name": entry.TicketType.Name,
+			"expires_at":       *entry.OfferExpiresAt,
+		},
+		Callback: func(t worker.Task) error {
+			return s.EmailService.SendWaitlistOfferEmail(
+				t.Payload["email"].(string),
+				t.Payload["event_title"].(string),
+				t.Payload["ticket_type_name"].(string),
+				t.Payload["expires_at"].(time.Time),
+			)
+		},
+	})
+}
+
+// ExpireOffers marks unclaimed offers past their deadline as expired and
+// cascades each held seat to the next person in the queue.
+func (s *WaitlistService) ExpireOffers() {
+	var expired []domain.WaitlistEntry
+	if err := s.DB.Where("status = ? AND offer_expires_at < ?", domain.WaitlistOffered, time.Now()).Find(&expired).Error; err != nil {
+		utils.Logger.Error("Failed to fetch expired waitlist offers", zap.Error(err))
+		return
+	}
+
+	for _, e := range expired {
+		var next *domain.WaitlistEntry
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			var entry domain.WaitlistEntry
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&entry, "id = ?", e.ID).Error; err != nil {
+				return err
+			}
+
+			// Claimed or withdrawn since we listed it
+			if entry.Status != domain.WaitlistOffered || entry.OfferExpiresAt == nil || time.Now().Before(*entry.OfferExpiresAt) {
+				return nil
+			}
+
+			entry.Status = domain.WaitlistExpired
+			if err := tx.Save(&entry).Error; err != nil {
+				return err
+			}
+
+			var err error
+			next, err = s.ReleaseSeat(tx, entry.EventID, entry.TicketTypeID)
+			return err
+		})
+		if err != nil {
+			utils.Logger.Error("Failed to expire waitlist offer", zap.String("entry_id", e.ID.String()), zap.Error(err))
+			continue
+		}
+
+		if next != nil {
+			s.NotifyOffer(next.ID)
+		}
+	}
+}
+
+func (s *WaitlistService) withPosition(entry domain.WaitlistEntry) (*WaitlistPosition, error) {
+	p := &WaitlistPosition{WaitlistEntry: entry}
+	if entry.Status != domain.WaitlistWaiting {
+		return p, nil
+	}
+
+	var ahead int64
+	if err := s.DB.Model(&domain.WaitlistEntry{}).
+		Where("ticket_type_id = ? AND status = ? AND created_at < ?", entry.TicketTypeID, domain.WaitlistWaiting, entry.CreatedAt).
+		Count(&ahead).Error; err != nil {
+		return nil, err
+	}
+	p.Position = ahead + 1
+	return p, nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/username/event-ticketing-system/internal/domain"
+	"github.com/username/event-ticketing-system/internal/worker"
+	"github.com/username/event-ticketing-system/pkg/email"
+	"github.com/username/event-ticketing-system/pkg/signing"
+	"gorm.io/gorm"
+)
+
+func newTestWaitlistService(t *testing.T) (*WaitlistService, sqlmock.Sqlmock, func()) {
+	gormDB, mock := newMockDB(t)
+
+	pool := worker.NewWorkerPool(1, 10)
+	pool.Start(context.Background())
+
+	signer, _ := signing.NewSigner(map[string]ed25519.PrivateKey{"test": signing.DeriveKey("test")}, "test")
+	ts := NewTicketService(gormDB, signer)
+	es := email.NewEmailService("smtp.test.com", "587", "user", "pass", "test@test.com")
+	svc := NewWaitlistService(gormDB, ts, es, pool, NewInventory(StrategyConditional, 1), time.Hour)
+
+	return svc, mock, pool.Shutdown
+}
+
+func TestWaitlistService_ExpireOffers_CascadesToNextInLine(t *testing.T) {
+	svc, mock, cleanup := newTestWaitlistService(t)
+	defer cleanup()
+
+	expiredID, nextID := uuid.New(), uuid.New()
+	nextUserID, eventID, ticketTypeID := uuid.New(), uuid.New(), uuid.New()
+	lapsed := time.Now().Add(-time.Minute)
+	columns := []string{"id", "user_id", "event_id", "ticket_type_id", "status", "offer_expires_at"}
+
+	mock.ExpectQuery(`SELECT \* FROM "waitlist_entries" WHERE status = \$1 AND offer_expires_at < \$2`).
+		WithArgs(domain.WaitlistOffered, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(expiredID, uuid.New(), eventID, ticketTypeID, domain.WaitlistOffered, lapsed))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "waitlist_entries" WHERE id = \$1 .* FOR UPDATE`).
+		WithArgs(expiredID, 1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(expiredID, uuid.New(), eventID, ticketTypeID, domain.WaitlistOffered, lapsed))
+	mock.ExpectExec(`UPDATE "waitlist_entries"`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// The held seat goes straight to the next person waiting, not back to stock
+	mock.ExpectQuery(`SELECT \* FROM "waitlist_entries" WHERE ticket_type_id = \$1 AND status = \$2 ORDER BY created_at.* FOR UPDATE SKIP LOCKED`).
+		WithArgs(ticketTypeID, domain.WaitlistWaiting, 1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(nextID, nextUserID, eventID, ticketTypeID, domain.WaitlistWaiting, nil))
+	mock.ExpectExec(`UPDATE "waitlist_entries"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(nextUserID, "WAITLIST_OFFER", "waitlist_entry", nextID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	// The new offer is emailed once the transaction commits
+	mock.ExpectQuery(`SELECT \* FROM "waitlist_entries" WHERE id = \$1`).
+		WithArgs(nextID, 1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(nextID, nextUserID, eventID, ticketTypeID, domain.WaitlistOffered, time.Now().Add(time.Hour)))
+	mock.ExpectQuery(`SELECT \* FROM "events"`).WithArgs(eventID).WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(eventID, "Gig"))
+	mock.ExpectQuery(`SELECT \* FROM "ticket_types"`).WithArgs(ticketTypeID).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(ticketTypeID, "GA"))
+	mock.ExpectQuery(`SELECT \* FROM "users"`).WithArgs(nextUserID).WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(nextUserID, "next@test.com"))
+
+	svc.ExpireOffers()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestWaitlistService_ReleaseSeat_ReturnsStockWhenNobodyWaits(t *testing.T) {
+	svc, mock, cleanup := newTestWaitlistService(t)
+	defer cleanup()
+
+	ticketTypeID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "waitlist_entries" .* FOR UPDATE SKIP LOCKED`).
+		WithArgs(ticketTypeID, domain.WaitlistWaiting, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`UPDATE ticket_type_shards SET remaining = remaining \+ \$1`).
+		WithArgs(1, ticketTypeID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var offer *domain.WaitlistEntry
+	err := svc.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		offer, err = svc.ReleaseSeat(tx, uuid.New(), ticketTypeID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if offer != nil {
+		t.Errorf("expected no offer with an empty waitlist, got %+v", offer)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestWaitlistService_Claim_RejectsExpiredOffer(t *testing.T) {
+	svc, mock, cleanup := newTestWaitlistService(t)
+	defer cleanup()
+
+	userID, entryID := uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "waitlist_entries" WHERE id = \$1 AND user_id = \$2 .* FOR UPDATE`).
+		WithArgs(entryID, userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status", "offer_expires_at"}).
+			AddRow(entryID, userID, domain.WaitlistOffered, time.Now().Add(-time.Second)))
+	mock.ExpectRollback()
+
+	if _, err := svc.Claim(userID, entryID, nil); err == nil || err.Error() != "this offer has expired" {
+		t.Errorf("expected an expired offer to be refused, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestWaitlistService_Claim_EnforcesMaxPerUser(t *testing.T) {
+	svc, mock, cleanup := newTestWaitlistService(t)
+	defer cleanup()
+
+	userID, entryID, eventID, ticketTypeID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "waitlist_entries" WHERE id = \$1 AND user_id = \$2 .* FOR UPDATE`).
+		WithArgs(entryID, userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "event_id", "ticket_type_id", "status", "offer_expires_at"}).
+			AddRow(entryID, userID, eventID, ticketTypeID, domain.WaitlistOffered, time.Now().Add(time.Hour)))
+	mock.ExpectQuery(`SELECT \* FROM "events"`).
+		WithArgs(eventID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(eventID, domain.StatusPublished))
+	mock.ExpectQuery(`SELECT \* FROM "ticket_types"`).
+		WithArgs(ticketTypeID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "max_per_user"}).AddRow(ticketTypeID, "General", 2))
+	// The claimer already holds as many tickets as the type allows, bought since joining the waitlist
+	mock.ExpectQuery(`SELECT "id" FROM "users" WHERE id = \$1 .* FOR UPDATE`).
+		WithArgs(userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(userID))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "registrations"`).
+		WithArgs(userID, ticketTypeID, domain.RegistrationCancelled).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(quantity\), 0\) FROM "seat_holds"`).
+		WithArgs(userID, ticketTypeID, domain.HoldActive).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(0))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "resale_listings" JOIN registrations`).
+		WithArgs(userID, domain.ResalePendingPayment, ticketTypeID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectRollback()
+
+	_, err := svc.Claim(userID, entryID, nil)
+	var coded *CodedError
+	if !errors.As(err, &coded) || coded.Code != CodeUserLimitReached {
+		t.Errorf("expected a claim over the per-user limit to be refused, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -76,7 +76,7 @@ func (w *NotificationWorker) processEventReminders(event domain.Event) {
 		w.Pool.Submit(Task{
 			Type: TaskReminder,
 			Payload: map[string]interface{}{
-				"email":           reg.User.Email,
+				"email":           reg.ContactEmail(),
 				"registration_id": reg.ID.String(),
 				"event_title":     event.Title,
 			},
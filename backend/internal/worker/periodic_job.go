@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/username/event-ticketing-system/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// PeriodicJob runs a maintenance function on a fixed interval, e.g. expiring
+// waitlist offers, until its context is cancelled.
+type PeriodicJob struct {
+	Name     string
+	Interval time.Duration
+	Run      func()
+}
+
+func NewPeriodicJob(name string, interval time.Duration, run func()) *PeriodicJob {
+	return &PeriodicJob{
+		Name:     name,
+		Interval: interval,
+		Run:      run,
+	}
+}
+
+func (j *PeriodicJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	utils.Logger.Info("Periodic job started", zap.String("job", j.Name), zap.Duration("interval", j.Interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			utils.Logger.Info("Periodic job stopping", zap.String("job", j.Name))
+			return
+		case <-ticker.C:
+			j.Run()
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html/template"
 	"net/smtp"
+	"time"
 
 	"github.com/username/event-ticketing-system/pkg/utils"
 	"go.uber.org/zap"
@@ -13,6 +14,18 @@ import (
 type EmailService interface {
 	SendTicketEmail(to, ticketCode, eventName string) error
 	SendPasswordResetEmail(to, resetURL string) error
+	SendWaitlistOfferEmail(to, eventName, ticketTypeName string, expiresAt time.Time) error
+	SendOrderConfirmationEmail(to, eventName, orderID string, tickets []OrderTicket) error
+	SendTicketClaimEmail(to, eventName, senderEmail, claimURL string) error
+	SendTransferOfferEmail(to, eventName, senderEmail, offerURL string, expiresAt time.Time) error
+	SendVerificationEmail(to, verifyURL string, expiresAt time.Time) error
+}
+
+// OrderTicket is one line of an order confirmation email.
+type OrderTicket struct {
+	TicketCode string
+	TicketType string
+	Attendee   string
 }
 
 type smtpEmailService struct {
@@ -104,3 +117,123 @@ func (s *smtpEmailService) SendPasswordResetEmail(to, resetURL string) error {
 
 	return s.sendHTML(to, "Password Reset Request", body.String())
 }
+
+func (s *smtpEmailService) SendWaitlistOfferEmail(to, eventName, ticketTypeName string, expiresAt time.Time) error {
+	tmpl, err := template.New("waitlist").Parse(waitlistOfferTemplate)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	data := struct {
+		EventName      string
+		TicketTypeName string
+		ExpiresAt      string
+	}{
+		EventName:      eventName,
+		TicketTypeName: ticketTypeName,
+		ExpiresAt:      expiresAt.Format("Jan 02, 2006 15:04 MST"),
+	}
+
+	if err := tmpl.Execute(&body, data); err != nil {
+		return err
+	}
+
+	return s.sendHTML(to, "A Seat Opened Up - "+eventName, body.String())
+}
+
+func (s *smtpEmailService) SendOrderConfirmationEmail(to, eventName, orderID string, tickets []OrderTicket) error {
+	tmpl, err := template.New("order").Parse(orderConfirmationTemplate)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	data := struct {
+		EventName string
+		OrderID   string
+		Tickets   []OrderTicket
+	}{
+		EventName: eventName,
+		OrderID:   orderID,
+		Tickets:   tickets,
+	}
+
+	if err := tmpl.Execute(&body, data); err != nil {
+		return err
+	}
+
+	return s.sendHTML(to, "Your Order Confirmation - "+eventName, body.String())
+}
+
+func (s *smtpEmailService) SendTicketClaimEmail(to, eventName, senderEmail, claimURL string) error {
+	tmpl, err := template.New("claim").Parse(ticketClaimTemplate)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	data := struct {
+		EventName   string
+		SenderEmail string
+		ClaimURL    string
+	}{
+		EventName:   eventName,
+		SenderEmail: senderEmail,
+		ClaimURL:    claimURL,
+	}
+
+	if err := tmpl.Execute(&body, data); err != nil {
+		return err
+	}
+
+	return s.sendHTML(to, "You've Been Sent a Ticket - "+eventName, body.String())
+}
+
+func (s *smtpEmailService) SendTransferOfferEmail(to, eventName, senderEmail, offerURL string, expiresAt time.Time) error {
+	tmpl, err := template.New("transfer").Parse(transferOfferTemplate)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	data := struct {
+		EventName   string
+		SenderEmail string
+		OfferURL    string
+		ExpiresAt   string
+	}{
+		EventName:   eventName,
+		SenderEmail: senderEmail,
+		OfferURL:    offerURL,
+		ExpiresAt:   expiresAt.Format("Jan 02, 2006 15:04 MST"),
+	}
+
+	if err := tmpl.Execute(&body, data); err != nil {
+		return err
+	}
+
+	return s.sendHTML(to, "A Ticket Is Waiting for You - "+eventName, body.String())
+}
+
+func (s *smtpEmailService) SendVerificationEmail(to, verifyURL string, expiresAt time.Time) error {
+	tmpl, err := template.New("verify").Parse(verificationTemplate)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	data := struct {
+		VerifyURL string
+		ExpiresAt string
+	}{
+		VerifyURL: verifyURL,
+		ExpiresAt: expiresAt.Format("Jan 02, 2006 15:04 MST"),
+	}
+
+	if err := tmpl.Execute(&body, data); err != nil {
+		return err
+	}
+
+	return s.sendHTML(to, "Verify Your Email Address", body.String())
+}
@@ -64,5 +64,168 @@ const (
     </div>
 </body>
 </html>
+`
+
+	waitlistOfferTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        .container { font-family: sans-serif; max-width: 600px; margin: 0 auto; padding: 20px; border: 1px solid #ddd; border-radius: 8px; }
+        .header { background-color: #FF9800; color: white; padding: 10px; text-align: center; border-radius: 8px 8px 0 0; }
+        .content { padding: 20px; line-height: 1.6; }
+        .footer { font-size: 0.8em; color: #666; text-align: center; margin-top: 20px; }
+        .deadline { background-color: #fff8e1; border: 1px dashed #FF9800; padding: 10px; font-weight: bold; text-align: center; margin: 20px 0; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>A Seat Is Waiting For You</h1>
+        </div>
+        <div class="content">
+            <p>Hello,</p>
+            <p>A <strong>{{.TicketTypeName}}</strong> ticket for <strong>{{.EventName}}</strong> has been released and you are next on the waitlist.</p>
+            <div class="deadline">Claim it before {{.ExpiresAt}}</div>
+            <p>Open your dashboard to claim the seat. If you don't claim it in time, it will be offered to the next person in line.</p>
+        </div>
+        <div class="footer">
+            <p>&copy; 2026 Event Ticketing System. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>
+`
+
+	orderConfirmationTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        .container { font-family: sans-serif; max-width: 600px; margin: 0 auto; padding: 20px; border: 1px solid #ddd; border-radius: 8px; }
+        .header { background-color: #4CAF50; color: white; padding: 10px; text-align: center; border-radius: 8px 8px 0 0; }
+        .content { padding: 20px; line-height: 1.6; }
+        .footer { font-size: 0.8em; color: #666; text-align: center; margin-top: 20px; }
+        table { width: 100%; border-collapse: collapse; margin: 20px 0; }
+        th, td { border-bottom: 1px solid #ddd; padding: 8px; text-align: left; }
+        .ticket-code { font-family: monospace; font-weight: bold; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Order Confirmed!</h1>
+        </div>
+        <div class="content">
+            <p>Hello,</p>
+            <p>Your order <strong>{{.OrderID}}</strong> for <strong>{{.EventName}}</strong> has been confirmed.</p>
+            <table>
+                <tr><th>Ticket Code</th><th>Type</th><th>Attendee</th></tr>
+                {{range .Tickets}}<tr><td class="ticket-code">{{.TicketCode}}</td><td>{{.TicketType}}</td><td>{{.Attendee}}</td></tr>
+                {{end}}
+            </table>
+            <p>Each attendee will need their own code for entry. You can download every PDF ticket from your dashboard.</p>
+        </div>
+        <div class="footer">
+            <p>&copy; 2026 Event Ticketing System. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>
+`
+
+	ticketClaimTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        .container { font-family: sans-serif; max-width: 600px; margin: 0 auto; padding: 20px; border: 1px solid #ddd; border-radius: 8px; }
+        .header { background-color: #4CAF50; color: white; padding: 10px; text-align: center; border-radius: 8px 8px 0 0; }
+        .content { padding: 20px; line-height: 1.6; }
+        .footer { font-size: 0.8em; color: #666; text-align: center; margin-top: 20px; }
+        .button { display: inline-block; padding: 10px 20px; background-color: #4CAF50; color: white; text-decoration: none; border-radius: 4px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>You've Been Sent a Ticket</h1>
+        </div>
+        <div class="content">
+            <p>Hello,</p>
+            <p><strong>{{.SenderEmail}}</strong> has sent you a ticket for <strong>{{.EventName}}</strong>. Your ticket is attached to this address and is valid at the door as it is.</p>
+            <p>To keep it in an account, sign in or sign up and open this link. It can only be used once.</p>
+            <p style="text-align: center;"><a href="{{.ClaimURL}}" class="button">Claim Ticket</a></p>
+        </div>
+        <div class="footer">
+            <p>&copy; 2026 Event Ticketing System. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>
+`
+
+	transferOfferTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        .container { font-family: sans-serif; max-width: 600px; margin: 0 auto; padding: 20px; border: 1px solid #ddd; border-radius: 8px; }
+        .header { background-color: #4CAF50; color: white; padding: 10px; text-align: center; border-radius: 8px 8px 0 0; }
+        .content { padding: 20px; line-height: 1.6; }
+        .footer { font-size: 0.8em; color: #666; text-align: center; margin-top: 20px; }
+        .button { display: inline-block; padding: 10px 20px; background-color: #4CAF50; color: white; text-decoration: none; border-radius: 4px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>A Ticket Is Waiting for You</h1>
+        </div>
+        <div class="content">
+            <p>Hello,</p>
+            <p><strong>{{.SenderEmail}}</strong> wants to transfer their ticket for <strong>{{.EventName}}</strong> to you.</p>
+            <p>Open the link below to accept or decline it before <strong>{{.ExpiresAt}}</strong>. The ticket only becomes yours once you accept.</p>
+            <p style="text-align: center;"><a href="{{.OfferURL}}" class="button">View Transfer</a></p>
+        </div>
+        <div class="footer">
+            <p>&copy; 2026 Event Ticketing System. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>
+`
+
+	verificationTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        .container { font-family: sans-serif; max-width: 600px; margin: 0 auto; padding: 20px; border: 1px solid #ddd; border-radius: 8px; }
+        .header { background-color: #2196F3; color: white; padding: 10px; text-align: center; border-radius: 8px 8px 0 0; }
+        .content { padding: 20px; line-height: 1.6; }
+        .footer { font-size: 0.8em; color: #666; text-align: center; margin-top: 20px; }
+        .button { background-color: #2196F3; color: white; padding: 10px 20px; text-decoration: none; border-radius: 5px; display: inline-block; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Verify Your Email</h1>
+        </div>
+        <div class="content">
+            <p>Hello,</p>
+            <p>Thanks for signing up. Confirm this is your email address to start registering for events:</p>
+            <p style="text-align: center;">
+                <a href="{{.VerifyURL}}" class="button">Verify Email</a>
+            </p>
+            <p>The link expires on <strong>{{.ExpiresAt}}</strong>. If you didn't create an account, you can safely ignore this email.</p>
+        </div>
+        <div class="footer">
+            <p>&copy; 2026 Event Ticketing System. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>
 `
 )
@@ -0,0 +1,189 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// stubIdP is a local OpenID provider that issues one code, bound to a PKCE
+// challenge, for a fixed user.
+type stubIdP struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	idp := &stubIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "stub-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "stub-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(t, idp.claims)})
+	})
+	idp.Server = httptest.NewServer(mux)
+
+	idp.claims = jwt.MapClaims{
+		"iss":            idp.URL,
+		"aud":            "client-id",
+		"sub":            "user-123",
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"name":           "Ada",
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+	return idp
+}
+
+func (idp *stubIdP) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "stub-key"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		t.Fatalf("failed to sign ID token: %v", err)
+	}
+	return signed
+}
+
+func TestProvider_CodeFlow(t *testing.T) {
+	idp := newStubIdP(t)
+	defer idp.Close()
+
+	p := NewProvider(Config{Issuer: idp.URL, ClientID: "client-id", ClientSecret: "secret", RedirectURL: "http://app/callback", Scopes: []string{"openid", "email"}}, nil)
+	ctx := context.Background()
+
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatalf("failed to create PKCE pair: %v", err)
+	}
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", challenge)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("code_challenge") != challenge || q.Get("code_challenge_method") != "S256" || q.Get("state") != "state-1" || q.Get("scope") != "openid email" {
+		t.Errorf("unexpected authorization URL %s", authURL)
+	}
+
+	idp.challenge = challenge
+	idp.claims["nonce"] = q.Get("nonce")
+
+	if _, err := p.Exchange(ctx, "stub-code", "wrong-verifier"); err == nil {
+		t.Error("expected the code to be refused without the right verifier")
+	}
+	raw, err := p.Exchange(ctx, "stub-code", verifier)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	claims, err := p.VerifyIDToken(ctx, raw, "nonce-1", time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *claims != (Claims{Subject: "user-123", Email: "ada@example.com", EmailVerified: true, Name: "Ada"}) {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestProvider_VerifyIDToken_Rejects(t *testing.T) {
+	idp := newStubIdP(t)
+	defer idp.Close()
+
+	p := NewProvider(Config{Issuer: idp.URL, ClientID: "client-id"}, nil)
+	ctx := context.Background()
+
+	with := func(changes jwt.MapClaims) jwt.MapClaims {
+		claims := jwt.MapClaims{"nonce": "nonce-1"}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		for k, v := range changes {
+			claims[k] = v
+		}
+		return claims
+	}
+
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, with(nil))
+	forged.Header["kid"] = "stub-key"
+	forgedToken, _ := forged.SignedString(otherKey)
+
+	hmacToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, with(nil)).SignedString([]byte("client-secret"))
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"wrong nonce", idp.sign(t, with(jwt.MapClaims{"nonce": "other"}))},
+		{"wrong audience", idp.sign(t, with(jwt.MapClaims{"aud": "someone-else"}))},
+		{"wrong issuer", idp.sign(t, with(jwt.MapClaims{"iss": "https://evil.example.com"}))},
+		{"expired", idp.sign(t, with(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}))},
+		{"other audience authorized", idp.sign(t, with(jwt.MapClaims{"aud": []string{"client-id", "other"}, "azp": "other"}))},
+		{"bad signature", forgedToken},
+		{"symmetric algorithm", hmacToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := p.VerifyIDToken(ctx, tt.token, "nonce-1", time.Now()); !errors.Is(err, ErrInvalidIDToken) {
+				t.Errorf("expected ErrInvalidIDToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestProvider_DiscoveryIssuerMismatch(t *testing.T) {
+	idp := newStubIdP(t)
+	defer idp.Close()
+
+	p := NewProvider(Config{Issuer: idp.URL + "/other", ClientID: "client-id"}, nil)
+	if _, err := p.AuthCodeURL(context.Background(), "s", "n", "c"); err == nil {
+		t.Error("expected discovery for a different issuer to fail")
+	}
+}
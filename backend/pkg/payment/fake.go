@@ -0,0 +1,155 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DeclinedAmount is the charge amount the fake provider always declines, so
+// failure paths can be exercised locally.
+const DeclinedAmount = 13.37
+
+// FakeProvider is a deterministic in-process provider for local development
+// and tests. Intent IDs encode the amount, so captures behave the same across
+// restarts and the same reference always yields the same intent.
+type FakeProvider struct {
+	secret []byte
+
+	mu       sync.Mutex
+	refunded map[string]int64
+	refunds  map[string]*Refund
+	canceled map[string]bool
+}
+
+func NewFakeProvider(webhookSecret string) *FakeProvider {
+	return &FakeProvider{
+		secret:   []byte(webhookSecret),
+		refunded: make(map[string]int64),
+		refunds:  make(map[string]*Refund),
+		canceled: make(map[string]bool),
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) CreateIntent(amount float64, currency, reference string) (*Intent, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("invalid payment amount %.2f", amount)
+	}
+
+	sum := sha256.Sum256([]byte(reference))
+	id := fmt.Sprintf("pi_fake_%s_%d", hex.EncodeToString(sum[:12]), toCents(amount))
+	return &Intent{
+		ID:           id,
+		ClientSecret: id + "_secret",
+		Amount:       amount,
+		Currency:     currency,
+		Status:       IntentRequiresCapture,
+	}, nil
+}
+
+func (p *FakeProvider) Capture(intentID string) (*Intent, error) {
+	cents, err := fakeIntentCents(intentID)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	canceled := p.canceled[intentID]
+	p.mu.Unlock()
+	if canceled {
+		return &Intent{ID: intentID, Amount: float64(cents) / 100, Status: IntentCanceled}, ErrIntentCanceled
+	}
+
+	intent := &Intent{ID: intentID, Amount: float64(cents) / 100, Status: IntentSucceeded}
+	if cents == toCents(DeclinedAmount) {
+		intent.Status = IntentFailed
+		intent.FailureReason = "card_declined"
+		return intent, ErrDeclined
+	}
+	return intent, nil
+}
+
+func (p *FakeProvider) Cancel(intentID string) error {
+	if _, err := fakeIntentCents(intentID); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.canceled[intentID] = true
+	return nil
+}
+
+func (p *FakeProvider) Refund(intentID string, amount float64, reference string) (*Refund, error) {
+	cents, err := fakeIntentCents(intentID)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if refund, ok := p.refunds[reference]; ok {
+		return refund, nil
+	}
+
+	requested := toCents(amount)
+	if requested <= 0 || p.refunded[intentID]+requested > cents {
+		return nil, ErrRefundTooLarge
+	}
+	p.refunded[intentID] += requested
+
+	sum := sha256.Sum256([]byte(intentID + ":" + reference))
+	refund := &Refund{
+		ID:       "re_fake_" + hex.EncodeToString(sum[:12]),
+		IntentID: intentID,
+		Amount:   amount,
+	}
+	p.refunds[reference] = refund
+	return refund, nil
+}
+
+func (p *FakeProvider) VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error) {
+	if !hmac.Equal([]byte(p.SignWebhook(payload)), []byte(signature)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	return &event, nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 signature the fake provider expects
+// on a webhook body. Use it to simulate provider callbacks locally.
+func (p *FakeProvider) SignWebhook(payload []byte) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func fakeIntentCents(intentID string) (int64, error) {
+	if !strings.HasPrefix(intentID, "pi_fake_") {
+		return 0, ErrIntentNotFound
+	}
+	cents, err := strconv.ParseInt(intentID[strings.LastIndex(intentID, "_")+1:], 10, 64)
+	if err != nil {
+		return 0, ErrIntentNotFound
+	}
+	return cents, nil
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
@@ -0,0 +1,91 @@
+package payment
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFakeProvider_CaptureIsDeterministic(t *testing.T) {
+	p := NewFakeProvider("secret")
+
+	first, err := p.CreateIntent(25, "USD", "payment-1")
+	if err != nil {
+		t.Fatalf("CreateIntent: %v", err)
+	}
+	second, _ := p.CreateIntent(25, "USD", "payment-1")
+	if first.ID != second.ID {
+		t.Errorf("expected the same intent for the same reference, got %s and %s", first.ID, second.ID)
+	}
+
+	captured, err := NewFakeProvider("secret").Capture(first.ID)
+	if err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	if captured.Status != IntentSucceeded || captured.Amount != 25 {
+		t.Errorf("unexpected capture result %+v", captured)
+	}
+}
+
+func TestFakeProvider_DeclinesMagicAmount(t *testing.T) {
+	p := NewFakeProvider("secret")
+
+	intent, _ := p.CreateIntent(DeclinedAmount, "USD", "payment-2")
+	captured, err := p.Capture(intent.ID)
+	if !errors.Is(err, ErrDeclined) {
+		t.Fatalf("expected ErrDeclined, got %v", err)
+	}
+	if captured.Status != IntentFailed {
+		t.Errorf("expected failed intent, got %s", captured.Status)
+	}
+}
+
+func TestFakeProvider_RefundCannotExceedCapture(t *testing.T) {
+	p := NewFakeProvider("secret")
+
+	intent, _ := p.CreateIntent(10, "USD", "payment-3")
+	first, err := p.Refund(intent.ID, 6, "refund-1")
+	if err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	if _, err := p.Refund(intent.ID, 5, "refund-2"); !errors.Is(err, ErrRefundTooLarge) {
+		t.Errorf("expected ErrRefundTooLarge, got %v", err)
+	}
+
+	// A retried refund returns the original instead of refunding again
+	retried, err := p.Refund(intent.ID, 6, "refund-1")
+	if err != nil || retried.ID != first.ID {
+		t.Errorf("expected the retry to return refund %s, got %+v (%v)", first.ID, retried, err)
+	}
+	if _, err := p.Refund(intent.ID, 4, "refund-3"); err != nil {
+		t.Errorf("expected the rest of the charge to stay refundable, got %v", err)
+	}
+}
+
+func TestFakeProvider_CanceledIntentCannotBeCaptured(t *testing.T) {
+	p := NewFakeProvider("secret")
+
+	intent, _ := p.CreateIntent(10, "USD", "payment-4")
+	if err := p.Cancel(intent.ID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if _, err := p.Capture(intent.ID); !errors.Is(err, ErrIntentCanceled) {
+		t.Errorf("expected ErrIntentCanceled, got %v", err)
+	}
+}
+
+func TestFakeProvider_VerifyWebhook(t *testing.T) {
+	p := NewFakeProvider("secret")
+	body := []byte(`{"type":"payment.succeeded","intent_id":"pi_fake_abc_1000"}`)
+
+	event, err := p.VerifyWebhook(body, p.SignWebhook(body))
+	if err != nil {
+		t.Fatalf("VerifyWebhook: %v", err)
+	}
+	if event.Type != EventPaymentSucceeded || event.IntentID != "pi_fake_abc_1000" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	if _, err := NewFakeProvider("other").VerifyWebhook(body, p.SignWebhook(body)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package payment
+
+import (
+	"errors"
+)
+
+// Webhook event types delivered by providers for asynchronous payment results.
+const (
+	EventPaymentSucceeded = "payment.succeeded"
+	EventPaymentFailed    = "payment.failed"
+)
+
+type IntentStatus string
+
+const (
+	IntentRequiresCapture IntentStatus = "requires_capture"
+	IntentSucceeded       IntentStatus = "succeeded"
+	IntentFailed          IntentStatus = "failed"
+	IntentCanceled        IntentStatus = "canceled"
+)
+
+var (
+	ErrDeclined         = errors.New("payment was declined")
+	ErrIntentNotFound   = errors.New("payment intent not found")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrRefundTooLarge   = errors.New("refund exceeds captured amount")
+	ErrIntentCanceled   = errors.New("payment intent was canceled")
+)
+
+type Intent struct {
+	ID            string       `json:"id"`
+	ClientSecret  string       `json:"client_secret"`
+	Amount        float64      `json:"amount"`
+	Currency      string       `json:"currency"`
+	Status        IntentStatus `json:"status"`
+	FailureReason string       `json:"failure_reason,omitempty"`
+}
+
+type Refund struct {
+	ID       string  `json:"id"`
+	IntentID string  `json:"intent_id"`
+	Amount   float64 `json:"amount"`
+}
+
+type WebhookEvent struct {
+	Type          string `json:"type"`
+	IntentID      string `json:"intent_id"`
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+// PaymentProvider is the boundary to a payment processor. Amounts are in the
+// major currency unit, matching event and ticket type prices.
+type PaymentProvider interface {
+	Name() string
+	// CreateIntent authorises a charge. reference is our own payment ID and is
+	// used by providers to deduplicate retries.
+	CreateIntent(amount float64, currency, reference string) (*Intent, error)
+	Capture(intentID string) (*Intent, error)
+	// Cancel voids an intent that has not been captured, so it can no longer
+	// be charged.
+	Cancel(intentID string) error
+	// Refund returns part or all of a captured charge. reference is our own
+	// refund ID; retrying with the same reference never refunds twice.
+	Refund(intentID string, amount float64, reference string) (*Refund, error)
+	// VerifyWebhook authenticates a webhook body and decodes its event.
+	VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error)
+}
@@ -16,6 +16,7 @@ type TicketData struct {
 	EventTime        string
 	Price            string
 	UserEmail        string
+	Seat             string // section/row/seat for reserved seating, empty for general admission
 	QRBytes          []byte
 }
 
@@ -58,6 +59,14 @@ func GenerateTicketPDF(data TicketData) (string, error) {
 	pdf.Cell(0, 10, data.TicketType)
 	pdf.Ln(8)
 
+	if data.Seat != "" {
+		pdf.SetFont("Arial", "B", 12)
+		pdf.Cell(40, 10, "Seat:")
+		pdf.SetFont("Arial", "", 12)
+		pdf.Cell(0, 10, data.Seat)
+		pdf.Ln(8)
+	}
+
 	pdf.SetFont("Arial", "B", 12)
 	pdf.Cell(40, 10, "Price:")
 	pdf.SetFont("Arial", "", 12)
@@ -0,0 +1,160 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ticket tokens have the form <key id>.<payload>.<signature> where payload is
+// a fixed-size binary record and both parts are base64url encoded. Keeping the
+// payload binary keeps the QR code small enough to scan reliably on phones.
+const (
+	payloadVersion = 1
+	payloadSize    = 1 + 16 + 16 + 16 + 8
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid ticket token")
+	ErrUnknownKey   = errors.New("ticket token signed with unknown key")
+)
+
+var encoding = base64.RawURLEncoding
+
+type TicketClaims struct {
+	TicketID     uuid.UUID `json:"ticket_id"`
+	EventID      uuid.UUID `json:"event_id"`
+	TicketTypeID uuid.UUID `json:"ticket_type_id"`
+	IssuedAt     time.Time `json:"issued_at"`
+	KeyID        string    `json:"key_id"`
+}
+
+// Signer signs with the active key and verifies against every configured key,
+// so tickets issued before a rotation stay valid while the old key is kept.
+type Signer struct {
+	keys      map[string]ed25519.PrivateKey
+	activeKID string
+}
+
+func NewSigner(keys map[string]ed25519.PrivateKey, activeKID string) (*Signer, error) {
+	if _, ok := keys[activeKID]; !ok {
+		return nil, fmt.Errorf("active signing key %q is not configured", activeKID)
+	}
+	return &Signer{keys: keys, activeKID: activeKID}, nil
+}
+
+// ParseKeys parses a comma separated list of "<key id>:<base64 seed>" pairs,
+// where each seed is a 32 byte Ed25519 private key seed.
+func ParseKeys(spec string) (map[string]ed25519.PrivateKey, error) {
+	keys := make(map[string]ed25519.PrivateKey)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kid, encoded, ok := strings.Cut(entry, ":")
+		if !ok || kid == "" || strings.Contains(kid, ".") {
+			return nil, fmt.Errorf("invalid signing key entry %q", entry)
+		}
+
+		seed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("signing key %q must be a base64 encoded %d byte seed", kid, ed25519.SeedSize)
+		}
+		keys[kid] = ed25519.NewKeyFromSeed(seed)
+	}
+	return keys, nil
+}
+
+// DeriveKey deterministically derives a key from a shared secret. It is only
+// meant for local development where no signing keys are configured.
+func DeriveKey(secret string) ed25519.PrivateKey {
+	seed := sha256.Sum256([]byte("ticket-signing:" + secret))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+func (s *Signer) KeyID() string {
+	return s.activeKID
+}
+
+func (s *Signer) SignTicket(claims TicketClaims) (string, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(payloadVersion)
+	buf.Write(claims.TicketID[:])
+	buf.Write(claims.EventID[:])
+	buf.Write(claims.TicketTypeID[:])
+	if err := binary.Write(&buf, binary.BigEndian, claims.IssuedAt.Unix()); err != nil {
+		return "", err
+	}
+
+	signingInput := s.activeKID + "." + encoding.EncodeToString(buf.Bytes())
+	sig := ed25519.Sign(s.keys[s.activeKID], []byte(signingInput))
+	return signingInput + "." + encoding.EncodeToString(sig), nil
+}
+
+// VerifyTicket checks the token signature and decodes its claims. It does not
+// touch the database, so it can run on scanners and at the edge.
+func (s *Signer) VerifyTicket(token string) (*TicketClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	key, ok := s.keys[parts[0]]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	sig, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !ed25519.Verify(key.Public().(ed25519.PublicKey), []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := encoding.DecodeString(parts[1])
+	if err != nil || len(payload) != payloadSize || payload[0] != payloadVersion {
+		return nil, ErrInvalidToken
+	}
+
+	claims := &TicketClaims{KeyID: parts[0]}
+	copy(claims.TicketID[:], payload[1:17])
+	copy(claims.EventID[:], payload[17:33])
+	copy(claims.TicketTypeID[:], payload[33:49])
+	claims.IssuedAt = time.Unix(int64(binary.BigEndian.Uint64(payload[49:])), 0)
+
+	return claims, nil
+}
+
+// Sign signs an arbitrary payload, such as an offline scanner manifest, with
+// the active key and returns the key id and base64url encoded signature.
+func (s *Signer) Sign(payload []byte) (string, string) {
+	sig := ed25519.Sign(s.keys[s.activeKID], payload)
+	return s.activeKID, encoding.EncodeToString(sig)
+}
+
+// PublicKeys returns every verification key, base64 encoded, keyed by key id.
+// Scanner devices use these to validate tokens and manifests offline.
+func (s *Signer) PublicKeys() map[string]string {
+	keys := make(map[string]string, len(s.keys))
+	for kid, key := range s.keys {
+		keys[kid] = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	}
+	return keys
+}
+
+// IsTicketToken reports whether a scanned value looks like a signed token
+// rather than a human-readable ticket code.
+func IsTicketToken(value string) bool {
+	return strings.Count(value, ".") == 2
+}
@@ -0,0 +1,96 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSignAndVerifyTicket(t *testing.T) {
+	signer, err := NewSigner(map[string]ed25519.PrivateKey{"k1": DeriveKey("secret")}, "k1")
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+
+	claims := TicketClaims{
+		TicketID:     uuid.New(),
+		EventID:      uuid.New(),
+		TicketTypeID: uuid.New(),
+		IssuedAt:     time.Unix(1767225600, 0),
+	}
+
+	token, err := signer.SignTicket(claims)
+	if err != nil {
+		t.Fatalf("SignTicket: %v", err)
+	}
+	if !IsTicketToken(token) {
+		t.Fatalf("expected %q to be recognised as a token", token)
+	}
+
+	got, err := signer.VerifyTicket(token)
+	if err != nil {
+		t.Fatalf("VerifyTicket: %v", err)
+	}
+	if got.TicketID != claims.TicketID || got.EventID != claims.EventID || got.TicketTypeID != claims.TicketTypeID {
+		t.Errorf("claims mismatch: got %+v, want %+v", got, claims)
+	}
+	if !got.IssuedAt.Equal(claims.IssuedAt) || got.KeyID != "k1" {
+		t.Errorf("unexpected issued_at/key_id: %+v", got)
+	}
+}
+
+func TestVerifyTicketRejectsTampering(t *testing.T) {
+	signer, _ := NewSigner(map[string]ed25519.PrivateKey{"k1": DeriveKey("secret")}, "k1")
+	token, _ := signer.SignTicket(TicketClaims{TicketID: uuid.New(), IssuedAt: time.Now()})
+
+	parts := strings.Split(token, ".")
+	forged, _ := signer.SignTicket(TicketClaims{TicketID: uuid.New(), IssuedAt: time.Now()})
+	parts[1] = strings.Split(forged, ".")[1]
+
+	if _, err := signer.VerifyTicket(strings.Join(parts, ".")); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for swapped payload, got %v", err)
+	}
+
+	other, _ := NewSigner(map[string]ed25519.PrivateKey{"k1": DeriveKey("other")}, "k1")
+	if _, err := other.VerifyTicket(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for foreign key, got %v", err)
+	}
+}
+
+func TestKeyRotationKeepsOldTicketsValid(t *testing.T) {
+	oldKey, newKey := DeriveKey("old"), DeriveKey("new")
+
+	before, _ := NewSigner(map[string]ed25519.PrivateKey{"2026a": oldKey}, "2026a")
+	token, _ := before.SignTicket(TicketClaims{TicketID: uuid.New(), IssuedAt: time.Now()})
+
+	after, _ := NewSigner(map[string]ed25519.PrivateKey{"2026a": oldKey, "2026b": newKey}, "2026b")
+	if _, err := after.VerifyTicket(token); err != nil {
+		t.Errorf("expected token signed with retired key to verify, got %v", err)
+	}
+
+	retired, _ := NewSigner(map[string]ed25519.PrivateKey{"2026b": newKey}, "2026b")
+	if _, err := retired.VerifyTicket(token); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey once the old key is removed, got %v", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	seed := base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize))
+
+	keys, err := ParseKeys("a:" + seed + ", b:" + seed)
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	if len(keys) != 2 {
+		t.Errorf("expected 2 keys, got %d", len(keys))
+	}
+
+	if _, err := ParseKeys("a:not-base64"); err == nil {
+		t.Error("expected error for malformed seed")
+	}
+}
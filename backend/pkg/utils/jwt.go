@@ -8,11 +8,15 @@ import (
 	"github.com/username/event-ticketing-system/internal/domain"
 )
 
-func GenerateJWT(userID uuid.UUID, role domain.UserRole, secret string, expiryHours int) (string, error) {
+// GenerateJWT signs an access token for a user's session that expires after ttl.
+// mfa records whether the user signs in with a second factor.
+func GenerateJWT(userID uuid.UUID, role domain.UserRole, sessionID uuid.UUID, mfa bool, secret string, ttl time.Duration) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userID.String(),
 		"role":    string(role),
-		"exp":     time.Now().Add(time.Hour * time.Duration(expiryHours)).Unix(),
+		"sid":     sessionID.String(),
+		"mfa":     mfa,
+		"exp":     time.Now().Add(ttl).Unix(),
 		"iat":     time.Now().Unix(),
 	}
 
@@ -10,6 +10,7 @@ type APIResponse struct {
 	Data    interface{} `json:"data,omitempty"`
 	Meta    interface{} `json:"meta,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"`
 }
 
 func SuccessResponse(c *gin.Context, statusCode int, message string, data interface{}) {
@@ -35,3 +36,13 @@ func ErrorResponse(c *gin.Context, statusCode int, err string) {
 		Error:   err,
 	})
 }
+
+// ErrorResponseWithCode adds a stable, machine-readable code alongside the
+// human-readable error so clients can branch on the failure reason.
+func ErrorResponseWithCode(c *gin.Context, statusCode int, code string, err string) {
+	c.JSON(statusCode, APIResponse{
+		Success: false,
+		Error:   err,
+		Code:    code,
+	})
+}
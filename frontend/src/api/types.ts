@@ -26,7 +26,6 @@ export interface RegisterRequest {
     password: string;
     /** Optional – backend accepts name field */
     name?: string;
-    role?: 'user' | 'admin';
 }
 
 export interface LoginResponse {
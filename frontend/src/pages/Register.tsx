@@ -1,16 +1,9 @@
 import { Link } from "react-router-dom";
 import { motion, AnimatePresence } from "framer-motion";
-import { Ticket, Mail, Lock, User, ArrowRight, Shield, UserCircle } from "lucide-react";
+import { Ticket, Mail, Lock, User, ArrowRight } from "lucide-react";
 import { useState } from "react";
 import RippleButton from "@/components/RippleButton";
 import AnimatedStatus from "@/components/AnimatedSuccess";
-import {
-  Select,
-  SelectContent,
-  SelectItem,
-  SelectTrigger,
-  SelectValue,
-} from "@/components/ui/select";
 
 import { useNavigate } from "react-router-dom";
 import { authService } from "@/api";
@@ -20,7 +13,6 @@ export default function Register() {
   const [errors, setErrors] = useState<Record<string, boolean>>({});
   const [success, setSuccess] = useState(false);
   const [loading, setLoading] = useState(false);
-  const [role, setRole] = useState<"user" | "admin">("user");
   const navigate = useNavigate();
 
   const handleSubmit = async (e: React.FormEvent) => {
@@ -48,8 +40,7 @@ export default function Register() {
       const response = await authService.register({
         email,
         password,
-        name: firstName,
-        role
+        name: firstName
       });
 
       if (response.success) {
@@ -118,31 +109,6 @@ export default function Register() {
               <input name="password" type="password" placeholder="••••••••" className={`input-glass pl-10 ${errors.password ? "input-error animate-shake" : ""}`} />
             </div>
           </div>
-          <div>
-            <label className="text-sm font-medium text-muted-foreground mb-1.5 block">Role</label>
-            <div className={`relative ${errors.role ? "animate-shake" : ""}`}>
-              <UserCircle size={16} className="absolute left-3 top-1/2 -translate-y-1/2 text-muted-foreground z-10" />
-              <Select value={role} onValueChange={(val: "user" | "admin") => setRole(val)}>
-                <SelectTrigger className={`input-glass pl-10 border-none h-auto py-3 ring-0 focus:ring-0 focus:ring-offset-0 ${errors.role ? "input-error" : ""}`}>
-                  <SelectValue placeholder="Select a role" />
-                </SelectTrigger>
-                <SelectContent className="glass-strong border-border/60">
-                  <SelectItem value="user" className="focus:bg-primary/20 focus:text-primary">
-                    <div className="flex items-center gap-2">
-                      <User size={14} />
-                      <span>User</span>
-                    </div>
-                  </SelectItem>
-                  <SelectItem value="admin" className="focus:bg-secondary/20 focus:text-secondary">
-                    <div className="flex items-center gap-2">
-                      <Shield size={14} />
-                      <span>Admin</span>
-                    </div>
-                  </SelectItem>
-                </SelectContent>
-              </Select>
-            </div>
-          </div>
 
           <RippleButton
             type="submit"